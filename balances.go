@@ -0,0 +1,143 @@
+package web3scanner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+// balanceChange is one balance adjustment caused by a scanned transfer.
+type balanceChange struct {
+	address     common.Address
+	token       common.Address
+	delta       *big.Int
+	blockNumber *big.Int
+	timestamp   uint64
+}
+
+// balanceChanges returns the adjustments for deposits and sweeps in block
+// order: a deposit credits the user address, a sweep of any type debits its
+// sender and credits its recipient. Only transferred values are tracked;
+// gas fees are not. Hot wallet debits for withdrawals come from
+// withdrawalChanges.
+func balanceChanges(deposits []database.Deposits, sweeps []database.Sweeps) []balanceChange {
+	changes := make([]balanceChange, 0, len(deposits)+2*len(sweeps))
+	for _, d := range deposits {
+		changes = append(changes, balanceChange{d.ToAddress, d.TokenAddress, d.Amount, d.BlockNumber, d.Timestamp})
+	}
+	for _, s := range sweeps {
+		changes = append(changes,
+			balanceChange{s.FromAddress, s.TokenAddress, new(big.Int).Neg(s.Amount), s.BlockNumber, s.Timestamp},
+			balanceChange{s.ToAddress, s.TokenAddress, s.Amount, s.BlockNumber, s.Timestamp})
+	}
+	sortBalanceChanges(changes)
+	return changes
+}
+
+// withdrawalChanges returns the debits of the hot wallets for mined
+// withdrawals that succeeded, confirmed or not, at the block timestamp.
+// Collections and cold wallet transfers are skipped: they move funds between
+// tracked addresses and are already accounted for as sweeps.
+func withdrawalChanges(withdrawals []*database.Withdrawals, timestamp uint64) []balanceChange {
+	var changes []balanceChange
+	for _, w := range withdrawals {
+		succeeded := w.Status == database.WithdrawalStatusMined || w.Status == database.WithdrawalStatusConfirmed
+		if !succeeded || w.Type != database.WithdrawalTypeWithdrawal {
+			continue
+		}
+		changes = append(changes, balanceChange{w.FromAddress, w.TokenAddress, new(big.Int).Neg(w.Amount), w.BlockNumber, timestamp})
+	}
+	return changes
+}
+
+// sortBalanceChanges orders changes by block, keeping the order of changes
+// within a block.
+func sortBalanceChanges(changes []balanceChange) {
+	slices.SortStableFunc(changes, func(a, b balanceChange) int {
+		return a.blockNumber.Cmp(b.blockNumber)
+	})
+}
+
+// snapshotKey identifies the balance history row of an address and token
+// at one block.
+type snapshotKey struct {
+	address common.Address
+	token   common.Address
+	block   string
+}
+
+// applyBalanceChanges applies changes to the running balances inside tx and
+// snapshots the resulting balances into the balance history. Several
+// changes to the same address and token in one block produce a single
+// snapshot holding the balance after the last of them.
+//
+// A debit larger than the recorded balance means the funds arrived before
+// the scanned range, so it is logged and skipped rather than failing the
+// whole range.
+func applyBalanceChanges(tx *database.DB, changes []balanceChange) error {
+	snapshots := make([]database.BalanceHistory, 0, len(changes))
+	snapshotIndex := make(map[snapshotKey]int, len(changes))
+	for _, change := range changes {
+		err := tx.Balances.UpdateBalance(change.address, change.token, change.delta)
+		if errors.Is(err, database.ErrInsufficientBalance) {
+			log.Warn("skipping balance debit beyond recorded balance", "address", change.address, "token", change.token, "err", err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("update balance of %s: %w", change.address, err)
+		}
+		balance, err := tx.Balances.QueryBalance(change.address, change.token)
+		if err != nil {
+			return fmt.Errorf("query balance of %s: %w", change.address, err)
+		}
+		snapshot := database.BalanceHistory{
+			Address:      change.address,
+			TokenAddress: change.token,
+			Balance:      balance.Balance,
+			BlockNumber:  change.blockNumber,
+			Timestamp:    change.timestamp,
+		}
+		key := snapshotKey{change.address, change.token, change.blockNumber.String()}
+		if i, ok := snapshotIndex[key]; ok {
+			snapshots[i] = snapshot
+			continue
+		}
+		snapshotIndex[key] = len(snapshots)
+		snapshots = append(snapshots, snapshot)
+	}
+	_, err := tx.BalanceHistory.StoreBalanceSnapshots(snapshots)
+	return err
+}
+
+// revertBalanceChanges undoes the adjustments of orphaned deposits, sweeps
+// and withdrawals during a reorg rollback. History snapshots of the orphaned blocks
+// are deleted by the caller.
+func revertBalanceChanges(tx *database.DB, changes []balanceChange) error {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		err := tx.Balances.UpdateBalance(change.address, change.token, new(big.Int).Neg(change.delta))
+		if errors.Is(err, database.ErrInsufficientBalance) {
+			log.Warn("skipping balance revert beyond recorded balance", "address", change.address, "token", change.token, "err", err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("revert balance of %s: %w", change.address, err)
+		}
+	}
+	return nil
+}
+
+// derefAll copies the rows returned by a query into a value slice.
+func derefAll[T any](rows []*T) []T {
+	values := make([]T, len(rows))
+	for i, row := range rows {
+		values[i] = *row
+	}
+	return values
+}
@@ -0,0 +1,48 @@
+package web3scanner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestApplyBalanceChangesOneSnapshotPerBlock(t *testing.T) {
+	user := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	hot := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	token := common.Address{}
+	block := big.NewInt(100)
+
+	deposits := []database.Deposits{
+		{ToAddress: user, TokenAddress: token, Amount: big.NewInt(5), BlockNumber: block, Timestamp: 1},
+		{ToAddress: user, TokenAddress: token, Amount: big.NewInt(7), BlockNumber: block, Timestamp: 1},
+	}
+	sweeps := []database.Sweeps{
+		{FromAddress: user, ToAddress: hot, TokenAddress: token, Amount: big.NewInt(10), BlockNumber: block, Timestamp: 1},
+	}
+
+	balances := newFakeBalances()
+	history := &fakeBalanceHistory{}
+	tx := &database.DB{Balances: balances, BalanceHistory: history}
+	if err := applyBalanceChanges(tx, balanceChanges(deposits, sweeps)); err != nil {
+		t.Fatalf("applyBalanceChanges: %v", err)
+	}
+
+	if got := balances.get(user, token); got.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("user balance = %s, want 2", got)
+	}
+	if got := balances.get(hot, token); got.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("hot wallet balance = %s, want 10", got)
+	}
+	want := map[common.Address]int64{user: 2, hot: 10}
+	if len(history.snapshots) != len(want) {
+		t.Fatalf("got %d snapshots, want %d", len(history.snapshots), len(want))
+	}
+	for _, snapshot := range history.snapshots {
+		if snapshot.Balance.Cmp(big.NewInt(want[snapshot.Address])) != 0 {
+			t.Errorf("snapshot of %s = %s, want %d", snapshot.Address, snapshot.Balance, want[snapshot.Address])
+		}
+	}
+}
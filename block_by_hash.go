@@ -0,0 +1,46 @@
+package web3scanner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+// ProcessedBlock holds what the scanner finds in a single block.
+type ProcessedBlock struct {
+	Block    *types.Block
+	Deposits []database.Deposits
+	// Sweeps are linked to their deposit like during a scan.
+	Sweeps []database.Sweeps
+}
+
+// ProcessBlockByHash fetches the block with the given hash and classifies
+// its transfers exactly as a scan round would. It is meant for
+// investigating specific blocks, such as orphaned ones whose number now
+// belongs to another block.
+//
+// Nothing is stored: only the scan loop stores blocks, which keeps the
+// stored chain linked and prevents the deposits of a canonical block from
+// being recorded twice once the loop reaches it. Registered transaction
+// hooks run as during a scan. If the node doesn't know the block, the
+// returned error wraps ethereum.NotFound.
+func (ws *Web3Scanner) ProcessBlockByHash(ctx context.Context, hash common.Hash) (*ProcessedBlock, error) {
+	block, err := ws.client.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("fetch block %s: %w", hash, err)
+	}
+	matches, err := ws.processBlock(ctx, block, nil)
+	if err != nil {
+		return nil, fmt.Errorf("process block %s: %w", hash, err)
+	}
+	for i := range matches.sweeps {
+		if err := ws.linkSweep(&matches.sweeps[i], matches.deposits); err != nil {
+			return nil, fmt.Errorf("link sweep %s: %w", matches.sweeps[i].TxHash, err)
+		}
+	}
+	return &ProcessedBlock{Block: block, Deposits: matches.deposits, Sweeps: matches.sweeps}, nil
+}
@@ -0,0 +1,40 @@
+package web3scanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestProcessBlockByHash(t *testing.T) {
+	user, payer := newTestAccount(t), newTestAccount(t)
+	client := newFakeClient()
+	deposit := payer.transfer(t, user.address, 5)
+	orphaned := client.addBlock(deposit)
+	// Block 1 is reorged out and its number reused by an empty block.
+	client.reorg(1)
+	client.addBlock()
+	db := &database.DB{Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}}}
+	ws := newTestScanner(db, client)
+
+	processed, err := ws.ProcessBlockByHash(context.Background(), orphaned.Hash())
+	if err != nil {
+		t.Fatalf("ProcessBlockByHash: %v", err)
+	}
+	if processed.Block.Hash() != orphaned.Hash() {
+		t.Errorf("processed block %s, want the orphaned block %s", processed.Block.Hash(), orphaned.Hash())
+	}
+	if len(processed.Deposits) != 1 || processed.Deposits[0].TxHash != deposit.Hash() || processed.Deposits[0].BlockHash != orphaned.Hash() {
+		t.Errorf("deposits = %+v, want %s in block %s", processed.Deposits, deposit.Hash(), orphaned.Hash())
+	}
+
+	_, err = ws.ProcessBlockByHash(context.Background(), common.HexToHash("0xdead"))
+	if !errors.Is(err, ethereum.NotFound) {
+		t.Errorf("ProcessBlockByHash of an unknown block: %v, want %v", err, ethereum.NotFound)
+	}
+}
@@ -0,0 +1,66 @@
+package web3scanner
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestBlocksStepForSwitchesModeWhenCaughtUp(t *testing.T) {
+	ws := newTestScanner(&database.DB{}, newFakeClient())
+	ws.blocksStep = 2
+	ws.catchUpBlocksStep = 10
+	ws.catchUpThreshold = 12
+
+	// Walk the cursor from block 0 to a head at 30 the way scanBlocks does.
+	head := big.NewInt(30)
+	var steps []uint64
+	var modes []bool
+	for next := big.NewInt(0); next.Cmp(head) <= 0; {
+		step := ws.blocksStepFor(next, head)
+		steps = append(steps, step)
+		modes = append(modes, ws.catchingUp)
+		next.Add(next, new(big.Int).SetUint64(step))
+	}
+	// 31 and 21 blocks left are above the threshold, 11 and fewer are not.
+	if want := []uint64{10, 10, 2, 2, 2, 2, 2, 2}; !slices.Equal(steps, want) {
+		t.Errorf("steps = %v, want %v", steps, want)
+	}
+	if want := []bool{true, true, false, false, false, false, false, false}; !slices.Equal(modes, want) {
+		t.Errorf("catch-up modes = %v, want %v", modes, want)
+	}
+
+	// Falling behind again switches back.
+	if step := ws.blocksStepFor(big.NewInt(31), big.NewInt(100)); step != 10 || !ws.catchingUp {
+		t.Errorf("step 70 blocks behind = %d (catching up %t), want 10 (true)", step, ws.catchingUp)
+	}
+
+	ws.catchUpBlocksStep = 0
+	if step := ws.blocksStepFor(big.NewInt(0), big.NewInt(1_000)); step != 2 || ws.catchingUp {
+		t.Errorf("step without catch-up = %d (catching up %t), want 2 (false)", step, ws.catchingUp)
+	}
+}
+
+func TestScanBlocksUsesCatchUpStep(t *testing.T) {
+	client := newFakeClient()
+	for range 20 {
+		client.addBlock()
+	}
+	// The batch fetch returns one block short, so the error reports how
+	// large a range the round asked for without needing a database.
+	db := &database.DB{Blocks: &fakeBlocks{}}
+	ws := newTestScanner(db, shortBatchClient{client})
+	ws.blocksStep = 2
+	ws.catchUpBlocksStep = 8
+	ws.catchUpThreshold = 5
+	ws.rpcBatchSize = 8
+
+	_, err := ws.scanBlocks(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "blocks 0-7 returned 7 blocks, want 8") {
+		t.Fatalf("scanBlocks error = %v, want a catch-up range of 8 blocks", err)
+	}
+}
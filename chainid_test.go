@@ -0,0 +1,80 @@
+package web3scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiaopengjun5162/web3scanner/config"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+// newMockNode serves a JSON-RPC endpoint that only answers eth_chainId.
+func newMockNode(t *testing.T, chainID uint64) string {
+	t.Helper()
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method != "eth_chainId" {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"method not found"}}`, req.ID)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x%x"}`, req.ID, chainID)
+	}))
+	t.Cleanup(node.Close)
+	return node.URL
+}
+
+func TestCheckChainID(t *testing.T) {
+	for _, tt := range []struct {
+		expected uint64
+		actual   *big.Int
+		wantErr  bool
+	}{
+		{1, big.NewInt(1), false},
+		{1, big.NewInt(5), true},
+		{0, big.NewInt(5), false},
+		{1, new(big.Int).Lsh(big.NewInt(1), 64), true},
+	} {
+		if err := checkChainID(tt.expected, tt.actual); (err != nil) != tt.wantErr {
+			t.Errorf("checkChainID(%d, %s) = %v, want error %t", tt.expected, tt.actual, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewWeb3ScannerChecksNodeChainID(t *testing.T) {
+	_, dbConfig := dbtest.NewDB(t)
+	newScanner := func(configured, served uint64) (*Web3Scanner, error) {
+		cfg := &config.Config{
+			ChainID:      configured,
+			RpcUrl:       newMockNode(t, served),
+			BlocksStep:   10,
+			PollInterval: time.Second,
+			MasterDB:     dbConfig,
+		}
+		return NewWeb3Scanner(context.Background(), cfg, func(error) {})
+	}
+
+	if _, err := newScanner(1, 5); err == nil || !strings.Contains(err.Error(), "chain id") {
+		t.Fatalf("NewWeb3Scanner against a chain 5 node configured for chain 1: error = %v, want chain id mismatch", err)
+	}
+	ws, err := newScanner(5, 5)
+	if err != nil {
+		t.Fatalf("NewWeb3Scanner against a matching node: %v", err)
+	}
+	ws.client.Close()
+	_ = ws.db.Close()
+}
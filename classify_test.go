@@ -0,0 +1,103 @@
+package web3scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestProcessBlockIgnoresSelfTransfers(t *testing.T) {
+	user, hot := newTestAccount(t), newTestAccount(t)
+	token := common.HexToAddress("0x5000000000000000000000000000000000000005")
+
+	client := newFakeClient()
+	native := user.transfer(t, user.address, 5)
+	hotNative := hot.transfer(t, hot.address, 6)
+	erc20 := user.transfer(t, token, 0)
+	block := client.addBlockWithReceipts(
+		[]*types.Transaction{native, hotNative, erc20},
+		[]*types.Receipt{
+			{TxHash: native.Hash(), Status: types.ReceiptStatusSuccessful},
+			{TxHash: hotNative.Hash(), Status: types.ReceiptStatusSuccessful},
+			transferReceipt(erc20, token, user.address, user.address, 7),
+		})
+	db := &database.DB{
+		Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser), hot.row(database.AddressTypeHot)}},
+		Tokens:    &fakeTokens{rows: []database.Tokens{{TokenAddress: token}}},
+	}
+
+	m, err := newTestScanner(db, client).processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	if len(m.deposits) != 0 || len(m.sweeps) != 0 {
+		t.Errorf("self-transfers recorded %d deposits and %d sweeps, want none", len(m.deposits), len(m.sweeps))
+	}
+	// They are still sent transactions, so queued withdrawals can match them.
+	if len(m.succeeded) != 3 {
+		t.Errorf("%d sent transactions collected, want 3", len(m.succeeded))
+	}
+}
+
+func TestClassifyTransferBetweenTrackedAddresses(t *testing.T) {
+	user, other, hot, cold := newTestAccount(t), newTestAccount(t), newTestAccount(t), newTestAccount(t)
+	addresses := &fakeAddresses{rows: []database.Addresses{
+		user.row(database.AddressTypeUser),
+		other.row(database.AddressTypeUser),
+		hot.row(database.AddressTypeHot),
+		cold.row(database.AddressTypeCold),
+	}}
+	const none = 0xff
+
+	for _, tt := range []struct {
+		name         string
+		from         *testAccount
+		to           *testAccount
+		detectSweeps bool
+		// sweepType is the type of the recorded sweep, or none.
+		sweepType uint8
+		deposits  int
+	}{
+		{"user to hot", user, hot, true, database.SweepTypeSweep, 0},
+		{"user to hot without sweep detection", user, hot, false, none, 0},
+		{"hot to cold", hot, cold, true, database.SweepTypeColdCollection, 0},
+		{"hot to cold without sweep detection", hot, cold, false, database.SweepTypeColdCollection, 0},
+		{"user to user", user, other, true, database.SweepTypeInternal, 0},
+		{"user to user without sweep detection", user, other, false, none, 1},
+		{"hot to user", hot, user, true, none, 1},
+		{"cold to hot", cold, hot, true, none, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient()
+			tx := tt.from.transfer(t, tt.to.address, 5)
+			block := client.addBlock(tx)
+			ws := newTestScanner(&database.DB{Addresses: addresses}, client)
+			ws.detectSweeps = tt.detectSweeps
+
+			m, err := ws.processBlock(context.Background(), block, nil)
+			if err != nil {
+				t.Fatalf("processBlock: %v", err)
+			}
+			if len(m.deposits) != tt.deposits {
+				t.Errorf("%d deposits recorded, want %d", len(m.deposits), tt.deposits)
+			}
+			if tt.sweepType == none {
+				if len(m.sweeps) != 0 {
+					t.Errorf("%d sweeps recorded, want none", len(m.sweeps))
+				}
+				return
+			}
+			if len(m.sweeps) != 1 {
+				t.Fatalf("%d sweeps recorded, want 1", len(m.sweeps))
+			}
+			if s := m.sweeps[0]; s.Type != tt.sweepType || s.FromAddress != tt.from.address || s.ToAddress != tt.to.address || s.Amount.Int64() != 5 {
+				t.Errorf("sweep = type %d of %s from %s to %s, want type %d of 5 from %s to %s",
+					s.Type, s.Amount, s.FromAddress, s.ToAddress, tt.sweepType, tt.from.address, tt.to.address)
+			}
+		})
+	}
+}
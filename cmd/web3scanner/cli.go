@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+
+	"github.com/qiaopengjun5162/web3scanner"
+	"github.com/qiaopengjun5162/web3scanner/common/cliapp"
+	"github.com/qiaopengjun5162/web3scanner/common/opio"
+	"github.com/qiaopengjun5162/web3scanner/config"
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/export"
+	"github.com/qiaopengjun5162/web3scanner/flags"
+	"github.com/qiaopengjun5162/web3scanner/rpc"
+)
+
+func runWeb3Scanner(ctx *cli.Context, shutdown context.CancelCauseFunc) (cliapp.Lifecycle, error) {
+	log.Info("run web3scanner")
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return nil, err
+	}
+	return web3scanner.NewWeb3Scanner(ctx.Context, &cfg, shutdown)
+}
+
+func runMigrations(ctx *cli.Context) error {
+	ctx.Context = opio.CancelOnInterrupt(ctx.Context)
+	log.Info("running migrations...")
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB, cfg.ChainID)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer func(db *database.DB) {
+		if err := db.Close(); err != nil {
+			log.Error("fail to close database", "err", err)
+		}
+	}(db)
+	return db.ExecuteSQLMigration(cfg.Migrations)
+}
+
+// runValidateAddresses streams every stored address and reports rows that
+// aren't in canonical form, rewriting them in place when --fix is set.
+func runValidateAddresses(ctx *cli.Context) error {
+	ctx.Context = opio.CancelOnInterrupt(ctx.Context)
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB, cfg.ChainID)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer func(db *database.DB) {
+		if err := db.Close(); err != nil {
+			log.Error("fail to close database", "err", err)
+		}
+	}(db)
+
+	fix := ctx.Bool(flags.FixFlag.Name)
+	var issues, fixed int
+	checked, err := db.Addresses.ValidateStoredAddresses(fix, func(issue database.AddressIssue) {
+		issues++
+		switch {
+		case issue.Fixed:
+			fixed++
+			log.Info("fixed non-canonical address", "guid", issue.GUID, "stored", issue.Stored, "canonical", issue.Canonical)
+		case issue.FixErr != nil:
+			log.Error("failed to fix address", "guid", issue.GUID, "stored", issue.Stored, "canonical", issue.Canonical, "err", issue.FixErr)
+		case issue.Canonical == "":
+			log.Warn("invalid stored address", "guid", issue.GUID, "stored", issue.Stored)
+		default:
+			log.Warn("non-canonical stored address", "guid", issue.GUID, "stored", issue.Stored, "canonical", issue.Canonical)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("address validation finished", "checked", checked, "issues", issues, "fixed", fixed)
+	return nil
+}
+
+// runImportAddresses bulk-loads the addresses of a CSV file. By default the
+// import is strict and aborts on the first invalid entry; with
+// --skip-invalid invalid entries are reported and the rest is imported.
+func runImportAddresses(ctx *cli.Context) error {
+	ctx.Context = opio.CancelOnInterrupt(ctx.Context)
+	file, err := os.Open(ctx.String(flags.ImportInputFlag.Name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	addresses, err := readAddressesCSV(file, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB, cfg.ChainID)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer func(db *database.DB) {
+		if err := db.Close(); err != nil {
+			log.Error("fail to close database", "err", err)
+		}
+	}(db)
+
+	summary, err := db.Addresses.ImportAddresses(ctx.Context, addresses, ctx.Bool(flags.SkipInvalidFlag.Name))
+	if err != nil {
+		return err
+	}
+	for _, skipped := range summary.Skipped {
+		// Index 0 is the first line after the header.
+		log.Warn("skipped invalid address", "line", skipped.Index+2, "address", skipped.Address, "reason", skipped.Reason)
+	}
+	log.Info("address import finished", "read", len(addresses), "imported", summary.Imported, "skipped", len(summary.Skipped))
+	return nil
+}
+
+// addressesCSVHeader is the header line of an address import file.
+var addressesCSVHeader = []string{"address", "address_type", "public_key"}
+
+// readAddressesCSV parses an address import file, stamping every row with
+// timestamp. Malformed lines fail the whole read; public keys are checked
+// by the import itself.
+func readAddressesCSV(r io.Reader, timestamp int64) ([]database.Addresses, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(addressesCSVHeader)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if !slices.Equal(header, addressesCSVHeader) {
+		return nil, fmt.Errorf("header is %q, want %q", strings.Join(header, ","), strings.Join(addressesCSVHeader, ","))
+	}
+
+	var addresses []database.Addresses
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return addresses, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !common.IsHexAddress(record[0]) {
+			return nil, fmt.Errorf("line %d: invalid address %q", line, record[0])
+		}
+		addressType, err := strconv.ParseUint(record[1], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid address type %q", line, record[1])
+		}
+		addresses = append(addresses, database.Addresses{
+			Address:     common.HexToAddress(record[0]),
+			AddressType: uint8(addressType),
+			PublicKey:   record[2],
+			Timestamp:   timestamp,
+		})
+	}
+}
+
+// runRewind moves the scan cursor back to --to, removing the stored blocks
+// above it with their deposits, sweeps and balance changes. It asks for
+// confirmation unless --yes is set.
+func runRewind(ctx *cli.Context) error {
+	ctx.Context = opio.CancelOnInterrupt(ctx.Context)
+	to := new(big.Int).SetUint64(ctx.Uint64(flags.RewindToFlag.Name))
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB, cfg.ChainID)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer func(db *database.DB) {
+		if err := db.Close(); err != nil {
+			log.Error("fail to close database", "err", err)
+		}
+	}(db)
+
+	if !ctx.Bool(flags.YesFlag.Name) {
+		prompt := fmt.Sprintf("Remove every stored block above %s with its deposits, sweeps and balance changes?", to)
+		if !confirm(ctx.App.Reader, ctx.App.ErrWriter, prompt) {
+			log.Info("rewind aborted")
+			return nil
+		}
+	}
+	removed, err := web3scanner.Rewind(db, to)
+	if err != nil {
+		return err
+	}
+	if removed.Blocks == 0 {
+		log.Info("no stored blocks above the rewind target", "to", to)
+	}
+	return nil
+}
+
+// confirm writes prompt to w and reports whether the answer read from r is
+// yes.
+func confirm(r io.Reader, w io.Writer, prompt string) bool {
+	fmt.Fprintf(w, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// runExportDeposits streams the deposits of a block range to a file or
+// stdout in the accounting CSV layout. It reads from the slave database when
+// one is configured. Confirmations are filled in when an RPC URL is
+// configured.
+func runExportDeposits(ctx *cli.Context) error {
+	ctx.Context = opio.CancelOnInterrupt(ctx.Context)
+	if format := ctx.String(flags.ExportFormatFlag.Name); format != "csv" {
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+	from := new(big.Int).SetUint64(ctx.Uint64(flags.ExportFromFlag.Name))
+	to := new(big.Int).SetUint64(ctx.Uint64(flags.ExportToFlag.Name))
+	if from.Cmp(to) > 0 {
+		return fmt.Errorf("--from %s is after --to %s", from, to)
+	}
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	dbConfig := cfg.MasterDB
+	if cfg.SlaveDB.Host != "" {
+		dbConfig = cfg.SlaveDB
+	}
+	db, err := database.NewObserverDB(ctx.Context, dbConfig, cfg.ChainID)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer func(db *database.ObserverDB) {
+		if err := db.Close(); err != nil {
+			log.Error("fail to close database", "err", err)
+		}
+	}(db)
+
+	out := io.Writer(os.Stdout)
+	if path := ctx.String(flags.ExportOutputFlag.Name); path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	writer := export.NewDepositsCSVWriter(out)
+	writer.Metadata = export.TokenMetadataFromDB(db.Tokens)
+	if cfg.RpcUrl != "" {
+		client, err := rpc.DialEthClient(ctx.Context, cfg.RpcUrl)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		head, err := client.BlockNumber(ctx.Context)
+		if err != nil {
+			return fmt.Errorf("query head block number: %w", err)
+		}
+		writer.Head = new(big.Int).SetUint64(head)
+	}
+
+	if err := writer.WriteHeader(); err != nil {
+		return err
+	}
+	var exported int
+	err = db.Deposits.IterateDepositsByBlockRange(from, to, func(deposit *database.Deposits) error {
+		exported++
+		return writer.Write(deposit)
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	log.Info("exported deposits", "from", from, "to", to, "count", exported)
+	return nil
+}
+
+// runStatus prints the scanner's diagnostics: the scan cursor, the chain
+// head and the lag, database and node health and the tracked address
+// count. It is the first thing to run when something looks wrong.
+func runStatus(ctx *cli.Context) error {
+	ctx.Context = opio.CancelOnInterrupt(ctx.Context)
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	scanner, err := web3scanner.NewWeb3Scanner(ctx.Context, &cfg, func(error) {})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := scanner.Stop(context.Background()); err != nil {
+			log.Error("fail to stop web3scanner", "err", err)
+		}
+	}()
+	report, err := scanner.Diagnostics()
+	if err != nil {
+		return err
+	}
+	return writeDiagnostics(ctx.App.Writer, report)
+}
+
+// writeDiagnostics formats report for the status command, one field per
+// line.
+func writeDiagnostics(w io.Writer, report *web3scanner.DiagnosticReport) error {
+	orUnknown := func(v *big.Int) string {
+		if v == nil {
+			return "unknown"
+		}
+		return v.String()
+	}
+	status := func(err error) string {
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		return "ok"
+	}
+	hash := "unknown"
+	if report.CursorHash != nil {
+		hash = report.CursorHash.Hex()
+	}
+	tracked := "unknown"
+	if report.DBAvailable {
+		tracked = strconv.FormatInt(report.TrackedAddresses, 10)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "cursor:\t%s\n", orUnknown(report.Cursor))
+	fmt.Fprintf(tw, "cursor hash:\t%s\n", hash)
+	fmt.Fprintf(tw, "head:\t%s\n", orUnknown(report.Head))
+	fmt.Fprintf(tw, "lag:\t%s\n", orUnknown(report.Lag))
+	fmt.Fprintf(tw, "database:\t%s\n", status(report.DBError))
+	fmt.Fprintf(tw, "rpc:\t%s\n", status(report.RPCError))
+	fmt.Fprintf(tw, "tracked addresses:\t%s\n", tracked)
+	return tw.Flush()
+}
+
+func versionWithCommit(gitCommit, gitDate string) string {
+	if len(gitCommit) >= 8 {
+		return fmt.Sprintf("%s-%s", gitCommit[:8], gitDate)
+	}
+	return "unknown"
+}
+
+func NewCli(GitCommit string, GitDate string) *cli.App {
+	return &cli.App{
+		Version:              versionWithCommit(GitCommit, GitDate),
+		Usage:                "A scanner that tracks deposits to managed Ethereum addresses",
+		EnableBashCompletion: true,
+		Commands: []*cli.Command{
+			{
+				Name:   "index",
+				Flags:  flags.Flags,
+				Usage:  "Run the web3scanner",
+				Action: cliapp.LifecycleCmd(runWeb3Scanner),
+			},
+			{
+				Name:   "migrate",
+				Flags:  flags.Flags,
+				Usage:  "Run database migrations",
+				Action: runMigrations,
+			},
+			{
+				Name:   "validate-addresses",
+				Flags:  slices.Concat(flags.Flags, []cli.Flag{flags.FixFlag}),
+				Usage:  "Report stored addresses that are not in canonical form, optionally fixing them with --fix",
+				Action: runValidateAddresses,
+			},
+			{
+				Name:   "import-addresses",
+				Flags:  slices.Concat(flags.Flags, []cli.Flag{flags.ImportInputFlag, flags.SkipInvalidFlag}),
+				Usage:  "Bulk-import addresses from a CSV file, optionally skipping invalid entries with --skip-invalid",
+				Action: runImportAddresses,
+			},
+			{
+				Name:   "rewind",
+				Flags:  slices.Concat(flags.Flags, []cli.Flag{flags.RewindToFlag, flags.YesFlag}),
+				Usage:  "Move the scan cursor back to a stored block, removing the blocks above it with their deposits and sweeps",
+				Action: runRewind,
+			},
+			{
+				Name:   "export-deposits",
+				Flags:  slices.Concat(flags.Flags, []cli.Flag{flags.ExportFromFlag, flags.ExportToFlag, flags.ExportFormatFlag, flags.ExportOutputFlag}),
+				Usage:  "Export the deposits of a block range for accounting",
+				Action: runExportDeposits,
+			},
+			{
+				Name:   "status",
+				Flags:  flags.Flags,
+				Usage:  "Print the scan cursor, the chain head, database and node health and the tracked address count",
+				Action: runStatus,
+			},
+			{
+				Name:  "version",
+				Usage: "Print version",
+				Action: func(ctx *cli.Context) error {
+					cli.ShowVersion(ctx)
+					return nil
+				},
+			},
+		},
+	}
+}
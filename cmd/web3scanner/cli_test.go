@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner"
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestReadAddressesCSV(t *testing.T) {
+	input := "address,address_type,public_key\n" +
+		"0x2c7536E3605D9C16a7a3D7b1898e529396a65c23,1,04abcd\n" +
+		"0x1000000000000000000000000000000000000001,0,\n"
+	addresses, err := readAddressesCSV(strings.NewReader(input), 1700000000)
+	if err != nil {
+		t.Fatalf("readAddressesCSV: %v", err)
+	}
+	// Public keys are left to the import to validate.
+	want := []database.Addresses{
+		{Address: common.HexToAddress("0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"), AddressType: database.AddressTypeHot, PublicKey: "04abcd", Timestamp: 1700000000},
+		{Address: common.HexToAddress("0x1000000000000000000000000000000000000001"), AddressType: database.AddressTypeUser, Timestamp: 1700000000},
+	}
+	if len(addresses) != len(want) {
+		t.Fatalf("read %d addresses, want %d", len(addresses), len(want))
+	}
+	for i := range want {
+		if addresses[i] != want[i] {
+			t.Errorf("address %d = %+v, want %+v", i, addresses[i], want[i])
+		}
+	}
+
+	for name, input := range map[string]string{
+		"wrong header":  "address,public_key\n",
+		"bad address":   "address,address_type,public_key\nnot-an-address,0,04\n",
+		"bad type":      "address,address_type,public_key\n0x1000000000000000000000000000000000000001,user,04\n",
+		"missing field": "address,address_type,public_key\n0x1000000000000000000000000000000000000001,0\n",
+	} {
+		if _, err := readAddressesCSV(strings.NewReader(input), 1700000000); err == nil {
+			t.Errorf("%s: readAddressesCSV accepted %q", name, input)
+		}
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	for answer, want := range map[string]bool{
+		"y\n":    true,
+		"YES\n":  true,
+		"yes":    true,
+		"n\n":    false,
+		"\n":     false,
+		"":       false,
+		"sure\n": false,
+	} {
+		var prompt strings.Builder
+		if got := confirm(strings.NewReader(answer), &prompt, "Rewind?"); got != want {
+			t.Errorf("confirm(%q) = %t, want %t", answer, got, want)
+		}
+		if prompt.String() != "Rewind? [y/N] " {
+			t.Errorf("prompt = %q, want %q", prompt.String(), "Rewind? [y/N] ")
+		}
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	hash := common.HexToHash("0x01")
+	var out strings.Builder
+	err := writeDiagnostics(&out, &web3scanner.DiagnosticReport{
+		Cursor:           big.NewInt(3),
+		CursorHash:       &hash,
+		DBAvailable:      true,
+		RPCError:         errors.New("connection refused"),
+		TrackedAddresses: 2,
+	})
+	if err != nil {
+		t.Fatalf("writeDiagnostics: %v", err)
+	}
+	want := "cursor:             3\n" +
+		"cursor hash:        " + hash.Hex() + "\n" +
+		"head:               unknown\n" +
+		"lag:                unknown\n" +
+		"database:           ok\n" +
+		"rpc:                error: connection refused\n" +
+		"tracked addresses:  2\n"
+	if out.String() != want {
+		t.Errorf("writeDiagnostics wrote\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,27 @@
+// Package main is the entrypoint of the web3scanner binary.
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3scanner/common/opio"
+)
+
+var (
+	// GitCommit and GitDate are set at build time through -ldflags, see Makefile.
+	GitCommit = ""
+	GitDate   = ""
+)
+
+func main() {
+	log.SetDefault(log.NewLogger(log.NewTerminalHandlerWithLevel(os.Stderr, log.LevelInfo, true)))
+	app := NewCli(GitCommit, GitDate)
+	ctx := opio.WithInterruptBlocker(context.Background())
+	if err := app.RunContext(ctx, os.Args); err != nil {
+		log.Error("Application failed", "err", err)
+		os.Exit(1)
+	}
+}
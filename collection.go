@@ -0,0 +1,204 @@
+package web3scanner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"gorm.io/gorm"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+// errNoHotWallet is returned by collect when there is no hot wallet to
+// collect into.
+var errNoHotWallet = errors.New("no hot wallet configured")
+
+// errNoColdWallet is returned by topUpCold when there is no cold wallet to
+// move excess funds to.
+var errNoColdWallet = errors.New("no cold wallet configured")
+
+// collectionKey identifies the balance of one token at one user address.
+type collectionKey struct {
+	address common.Address
+	token   common.Address
+}
+
+// maybeCollect starts a collection run in the background once
+// collectionInterval has passed since the last one started, so queueing
+// collections never holds up scanning. A run still in progress is not
+// overlapped. Dry runs never collect.
+func (ws *Web3Scanner) maybeCollect() {
+	if ws.dryRun || ws.collectionInterval <= 0 || time.Since(ws.lastCollection) < ws.collectionInterval {
+		return
+	}
+	if !ws.collecting.CompareAndSwap(false, true) {
+		return
+	}
+	ws.lastCollection = time.Now()
+	ws.backgroundRuns.Add(1)
+	go func() {
+		defer ws.backgroundRuns.Done()
+		defer ws.collecting.Store(false)
+		ws.runCollection()
+	}()
+}
+
+// runCollection runs collect and then topUpCold. Failures are logged; the
+// next attempt waits a full interval.
+func (ws *Web3Scanner) runCollection() {
+	if queued, err := collect(ws.db, ws.collectionStrategy); err != nil {
+		log.Error("queue collections fail", "err", err)
+	} else if queued > 0 {
+		log.Info("queued collections", "count", queued)
+	}
+	if queued, err := topUpCold(ws.db); err != nil {
+		log.Error("queue cold wallet transfers fail", "err", err)
+	} else if queued > 0 {
+		log.Info("queued cold wallet transfers", "count", queued)
+	}
+}
+
+// availableBalance returns the part of balance that is not locked.
+func availableBalance(balance *database.Balances) *big.Int {
+	available := new(big.Int).Set(balance.Balance)
+	if balance.LockBalance != nil {
+		available.Sub(available, balance.LockBalance)
+	}
+	return available
+}
+
+// collect queues a collection into a hot wallet, chosen by strategy for
+// each collection, for every user address whose available balance of a
+// token, i.e. Balance minus LockBalance, has reached that token's
+// CollectAmount. The candidates come from a single query joining balances
+// and tokens. Tokens with no CollectAmount are never collected; native coin
+// is collected only if the tokens table has a row for the zero address. The
+// full available balance is collected, so for native coin the signer has to
+// deduct the gas fee.
+//
+// Each collection is stored as soon as its hot wallet is chosen, so the
+// lowest-balance strategy counts it as in flight for the next one. An
+// address and token with a collection that is not confirmed yet is skipped,
+// so repeated runs don't queue the same funds twice. It returns the number
+// of collections queued.
+func collect(db *database.DB, strategy database.CollectionStrategy) (int, error) {
+	balances, err := db.Balances.QueryCollectableBalances()
+	if err != nil {
+		return 0, fmt.Errorf("query collectable balances: %w", err)
+	}
+	pending, err := db.Withdrawals.QueryPendingWithdrawals(database.WithdrawalTypeCollection)
+	if err != nil {
+		return 0, fmt.Errorf("query pending collections: %w", err)
+	}
+	inFlight := make(map[collectionKey]struct{}, len(pending))
+	for _, p := range pending {
+		inFlight[collectionKey{p.FromAddress, p.TokenAddress}] = struct{}{}
+	}
+
+	var queued int
+	for _, balance := range balances {
+		if _, ok := inFlight[collectionKey{balance.Address, balance.TokenAddress}]; ok {
+			continue
+		}
+		hotWallet, err := db.Addresses.SelectCollectionWallet(strategy, balance.TokenAddress)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return queued, errNoHotWallet
+		}
+		if err != nil {
+			return queued, fmt.Errorf("select hot wallet: %w", err)
+		}
+		collection := database.Withdrawals{
+			FromAddress:  balance.Address,
+			ToAddress:    hotWallet.Address,
+			Amount:       balance.Available,
+			TokenAddress: balance.TokenAddress,
+			Status:       database.WithdrawalStatusUnsigned,
+			Type:         database.WithdrawalTypeCollection,
+		}
+		if err := db.Withdrawals.StoreWithdrawals([]database.Withdrawals{collection}); err != nil {
+			return queued, fmt.Errorf("store collection: %w", err)
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// topUpCold queues a transfer to the cold wallet for every hot wallet whose
+// available balance of a token exceeds that token's HotCeiling, moving
+// everything above HotRetain. Tokens with no HotCeiling are left alone, as
+// are hot wallets with a cold wallet transfer of the same token that is not
+// confirmed yet. The hot wallet balance is debited by the scanner once a
+// withdrawal or cold wallet transfer from it is mined, so a confirmed
+// transfer is not queued again. It returns the number of transfers queued.
+func topUpCold(db *database.DB) (int, error) {
+	coldWallet, err := db.Addresses.QueryColdWalletInfo()
+	if err != nil {
+		return 0, fmt.Errorf("query cold wallet: %w", err)
+	}
+	if coldWallet == nil {
+		return 0, errNoColdWallet
+	}
+	hotWallets, err := db.Addresses.QueryHotWalletsInfo()
+	if err != nil {
+		return 0, fmt.Errorf("query hot wallets: %w", err)
+	}
+	tokens, err := db.Tokens.ListTokens()
+	if err != nil {
+		return 0, fmt.Errorf("list tokens: %w", err)
+	}
+	pending, err := db.Withdrawals.QueryPendingWithdrawals(database.WithdrawalTypeColdTopUp)
+	if err != nil {
+		return 0, fmt.Errorf("query pending cold wallet transfers: %w", err)
+	}
+	inFlight := make(map[collectionKey]struct{}, len(pending))
+	for _, p := range pending {
+		inFlight[collectionKey{p.FromAddress, p.TokenAddress}] = struct{}{}
+	}
+
+	var transfers []database.Withdrawals
+	for _, token := range tokens {
+		if token.HotCeiling == nil || token.HotCeiling.Sign() <= 0 {
+			continue
+		}
+		retain := token.HotRetain
+		if retain == nil {
+			retain = new(big.Int)
+		}
+		if retain.Cmp(token.HotCeiling) > 0 {
+			log.Warn("hot wallet retain amount above ceiling, skipping cold wallet transfer", "token", token.TokenAddress, "ceiling", token.HotCeiling, "retain", retain)
+			continue
+		}
+		for _, hotWallet := range hotWallets {
+			if _, ok := inFlight[collectionKey{hotWallet.Address, token.TokenAddress}]; ok {
+				continue
+			}
+			balance, err := db.Balances.QueryBalance(hotWallet.Address, token.TokenAddress)
+			if err != nil {
+				return 0, fmt.Errorf("query balance of %s: %w", hotWallet.Address, err)
+			}
+			if balance == nil {
+				continue
+			}
+			available := availableBalance(balance)
+			if available.Cmp(token.HotCeiling) <= 0 {
+				continue
+			}
+			transfers = append(transfers, database.Withdrawals{
+				FromAddress:  hotWallet.Address,
+				ToAddress:    coldWallet.Address,
+				Amount:       available.Sub(available, retain),
+				TokenAddress: token.TokenAddress,
+				Status:       database.WithdrawalStatusUnsigned,
+				Type:         database.WithdrawalTypeColdTopUp,
+			})
+		}
+	}
+	if err := db.Withdrawals.StoreWithdrawals(transfers); err != nil {
+		return 0, fmt.Errorf("store cold wallet transfers: %w", err)
+	}
+	return len(transfers), nil
+}
@@ -0,0 +1,173 @@
+package web3scanner
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestCollectUsesStrategy(t *testing.T) {
+	user := newTestAccount(t)
+	hot := newTestAccount(t)
+	addresses := &fakeAddresses{rows: []database.Addresses{
+		user.row(database.AddressTypeUser),
+		hot.row(database.AddressTypeHot),
+	}}
+	tokens := &fakeTokens{rows: []database.Tokens{{CollectAmount: big.NewInt(50)}}}
+	balances := newFakeBalances()
+	balances.addresses, balances.tokens = addresses, tokens
+	balances.set(user.address, common.Address{}, 100)
+	withdrawals := &fakeWithdrawals{}
+	db := &database.DB{
+		Addresses:   addresses,
+		Balances:    balances,
+		Tokens:      tokens,
+		Withdrawals: withdrawals,
+	}
+
+	queued, err := collect(db, database.CollectionStrategyRoundRobin)
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if queued != 1 || len(withdrawals.rows) != 1 {
+		t.Fatalf("queued %d collections, stored %d, want 1", queued, len(withdrawals.rows))
+	}
+	if got := withdrawals.rows[0].ToAddress; got != hot.address {
+		t.Errorf("collection goes to %s, want hot wallet %s", got, hot.address)
+	}
+	if len(addresses.strategies) != 1 || addresses.strategies[0] != database.CollectionStrategyRoundRobin {
+		t.Errorf("SelectCollectionWallet strategies = %v, want [%s]", addresses.strategies, database.CollectionStrategyRoundRobin)
+	}
+
+	// The collection is pending, so the next run must not queue it again.
+	if queued, err := collect(db, database.CollectionStrategyRoundRobin); err != nil || queued != 0 {
+		t.Errorf("second collect = %d, %v, want 0, nil", queued, err)
+	}
+}
+
+func TestTopUpColdAfterTransferMined(t *testing.T) {
+	hot := newTestAccount(t)
+	cold := newTestAccount(t)
+	token := common.Address{}
+	balances := newFakeBalances()
+	balances.set(hot.address, token, 100)
+	withdrawals := &fakeWithdrawals{}
+	db := &database.DB{
+		Addresses: &fakeAddresses{rows: []database.Addresses{
+			hot.row(database.AddressTypeHot),
+			cold.row(database.AddressTypeCold),
+		}},
+		Balances:       balances,
+		BalanceHistory: &fakeBalanceHistory{},
+		Tokens:         &fakeTokens{rows: []database.Tokens{{HotCeiling: big.NewInt(80), HotRetain: big.NewInt(50)}}},
+		Withdrawals:    withdrawals,
+	}
+
+	if queued, err := topUpCold(db); err != nil || queued != 1 {
+		t.Fatalf("topUpCold = %d, %v, want 1, nil", queued, err)
+	}
+	transfer := &withdrawals.rows[0]
+	if transfer.Amount.Cmp(big.NewInt(50)) != 0 {
+		t.Fatalf("cold wallet transfer amount = %s, want 50", transfer.Amount)
+	}
+
+	// The scanner sees the transfer mined and records it as a cold
+	// collection, which debits the hot wallet; the withdrawal itself
+	// doesn't debit it a second time.
+	transfer.Status = database.WithdrawalStatusConfirmed
+	transfer.BlockNumber = big.NewInt(7)
+	sweep := database.Sweeps{FromAddress: hot.address, ToAddress: cold.address, Type: database.SweepTypeColdCollection, TokenAddress: token, Amount: transfer.Amount, BlockNumber: transfer.BlockNumber}
+	changes := append(balanceChanges(nil, []database.Sweeps{sweep}), withdrawalChanges([]*database.Withdrawals{transfer}, 1)...)
+	if err := applyBalanceChanges(db, changes); err != nil {
+		t.Fatalf("applyBalanceChanges: %v", err)
+	}
+	if got := balances.get(hot.address, token); got.Cmp(big.NewInt(50)) != 0 {
+		t.Fatalf("hot wallet balance = %s, want 50", got)
+	}
+	if got := balances.get(cold.address, token); got.Cmp(big.NewInt(50)) != 0 {
+		t.Fatalf("cold wallet balance = %s, want 50", got)
+	}
+	if queued, err := topUpCold(db); err != nil || queued != 0 {
+		t.Errorf("topUpCold after transfer mined = %d, %v, want 0, nil", queued, err)
+	}
+}
+
+func TestWithdrawalChangesSkipsTransfersBetweenTrackedAddressesAndFailures(t *testing.T) {
+	hot := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	user := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	block := big.NewInt(3)
+	withdrawals := []*database.Withdrawals{
+		{FromAddress: hot, Amount: big.NewInt(1), Status: database.WithdrawalStatusConfirmed, Type: database.WithdrawalTypeWithdrawal, BlockNumber: block},
+		{FromAddress: hot, Amount: big.NewInt(2), Status: database.WithdrawalStatusConfirmed, Type: database.WithdrawalTypeColdTopUp, BlockNumber: block},
+		{FromAddress: hot, Amount: big.NewInt(4), Status: database.WithdrawalStatusFailed, Type: database.WithdrawalTypeWithdrawal, BlockNumber: block},
+		{FromAddress: user, Amount: big.NewInt(8), Status: database.WithdrawalStatusConfirmed, Type: database.WithdrawalTypeCollection, BlockNumber: block},
+		// Mined but not yet confirmed: the funds already left.
+		{FromAddress: hot, Amount: big.NewInt(16), Status: database.WithdrawalStatusMined, Type: database.WithdrawalTypeWithdrawal, BlockNumber: block},
+	}
+	total := new(big.Int)
+	for _, change := range withdrawalChanges(withdrawals, 1) {
+		if change.address != hot {
+			t.Errorf("unexpected change of %s", change.address)
+		}
+		total.Add(total, change.delta)
+	}
+	if total.Cmp(big.NewInt(-17)) != 0 {
+		t.Errorf("hot wallet delta = %s, want -17", total)
+	}
+}
+
+// blockingBalances holds QueryCollectableBalances until release is closed.
+type blockingBalances struct {
+	*fakeBalances
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (b *blockingBalances) QueryCollectableBalances() ([]database.CollectableBalance, error) {
+	b.calls.Add(1)
+	<-b.release
+	return b.fakeBalances.QueryCollectableBalances()
+}
+
+func TestMaybeCollectRunsInBackground(t *testing.T) {
+	addresses := &fakeAddresses{}
+	tokens := &fakeTokens{}
+	balances := &blockingBalances{fakeBalances: newFakeBalances(), release: make(chan struct{})}
+	balances.addresses, balances.tokens = addresses, tokens
+	ws := newTestScanner(&database.DB{
+		Addresses:   addresses,
+		Balances:    balances,
+		Tokens:      tokens,
+		Withdrawals: &fakeWithdrawals{},
+	}, newFakeClient())
+	ws.collectionInterval = time.Nanosecond
+
+	// The first run blocks in the database, but maybeCollect returns
+	// right away, and runs don't overlap.
+	ws.maybeCollect()
+	for balances.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(time.Millisecond)
+	ws.maybeCollect()
+	if calls := balances.calls.Load(); calls != 1 {
+		t.Errorf("%d collection runs started while one was in progress, want 1", calls)
+	}
+
+	close(balances.release)
+	ws.backgroundRuns.Wait()
+	if ws.collecting.Load() {
+		t.Error("collection still marked running after it finished")
+	}
+	time.Sleep(time.Millisecond)
+	ws.maybeCollect()
+	ws.backgroundRuns.Wait()
+	if calls := balances.calls.Load(); calls != 2 {
+		t.Errorf("%d collection runs after the first finished, want 2", calls)
+	}
+}
@@ -47,6 +47,39 @@ func Do2[T, U any](ctx context.Context, maxAttempts int, strategy Strategy, op f
 	return res.a, res.b, err
 }
 
+type triple[T, U, V any] struct {
+	a T
+	b U
+	c V
+}
+
+// Do3 retries an operation that returns three values and an error, using the given strategy.
+// It continues until the operation succeeds or the maximum number of attempts is reached.
+//
+// Parameters:
+//   - ctx: A context.Context for cancellation and timeout control.
+//   - maxAttempts: The maximum number of times to attempt the operation.
+//   - strategy: The retry strategy to use between attempts.
+//   - op: The operation function to be retried. It should return three values of types T, U and V, and an error.
+//
+// Returns:
+//   - T: The first return value of the operation if successful.
+//   - U: The second return value of the operation if successful.
+//   - V: The third return value of the operation if successful.
+//   - error: An error if the operation failed permanently, or nil if successful.
+func Do3[T, U, V any](ctx context.Context, maxAttempts int, strategy Strategy, op func() (T, U, V, error)) (T, U, V, error) {
+	f := func() (triple[T, U, V], error) {
+		a, b, c, err := op()
+		return triple[T, U, V]{a, b, c}, err
+	}
+	res, err := Do(ctx, maxAttempts, strategy, f)
+	return res.a, res.b, res.c, err
+}
+
+// OnRetry is called by DoWithHooks after a failed attempt that will be retried, before
+// sleeping. attempt is 1-based and err is the error returned by that attempt.
+type OnRetry func(attempt int, err error)
+
 // Do retry an operation that returns a value and an error, using the given strategy.
 // It continues until the operation succeeds or the maximum number of attempts is reached.
 //
@@ -60,6 +93,14 @@ func Do2[T, U any](ctx context.Context, maxAttempts int, strategy Strategy, op f
 //   - T: The return value of the operation if successful.
 //   - error: An error if the operation failed permanently, or nil if successful.
 func Do[T any](ctx context.Context, maxAttempts int, strategy Strategy, op func() (T, error)) (T, error) {
+	return DoWithHooks(ctx, maxAttempts, strategy, nil, op)
+}
+
+// DoWithHooks is like Do but calls onRetry after every failed attempt that is followed by
+// another one, so callers can log or count failures without wrapping op. The final failure
+// is not reported to onRetry; it is returned as an *ErrFailedPermanently instead.
+// A nil onRetry behaves exactly like Do.
+func DoWithHooks[T any](ctx context.Context, maxAttempts int, strategy Strategy, onRetry OnRetry, op func() (T, error)) (T, error) {
 	var empty, ret T
 	var err error
 	if maxAttempts < 1 {
@@ -75,6 +116,9 @@ func Do[T any](ctx context.Context, maxAttempts int, strategy Strategy, op func(
 			return ret, nil
 		}
 		if i != maxAttempts-1 {
+			if onRetry != nil {
+				onRetry(i+1, err)
+			}
 			time.Sleep(strategy.Duration(i))
 		}
 	}
@@ -83,3 +127,53 @@ func Do[T any](ctx context.Context, maxAttempts int, strategy Strategy, op func(
 		LastErr:  err,
 	}
 }
+
+// DoUntil retries an operation that returns a value and an error, using the given strategy,
+// until it succeeds or the total time spent exceeds deadline. Unlike Do it is bounded by
+// wall-clock time rather than by the number of attempts.
+//
+// The wait before the next attempt is cut short so it never overshoots deadline, and it is
+// interrupted as soon as ctx is cancelled.
+//
+// Parameters:
+//   - ctx: A context.Context for cancellation and timeout control.
+//   - deadline: The maximum total time to keep retrying the operation.
+//   - strategy: The retry strategy to use between attempts.
+//   - op: The operation function to be retried. It should return a value of type T and an error.
+//
+// Returns:
+//   - T: The return value of the operation if successful.
+//   - error: ctx.Err() if the context was cancelled, an *ErrFailedPermanently wrapping the
+//     last error if the deadline passed, or nil if successful.
+func DoUntil[T any](ctx context.Context, deadline time.Duration, strategy Strategy, op func() (T, error)) (T, error) {
+	var empty T
+	if deadline <= 0 {
+		return empty, fmt.Errorf("need a positive deadline to run op, but have %v", deadline)
+	}
+
+	start := time.Now()
+	for i := 0; ; i++ {
+		if ctx.Err() != nil {
+			return empty, ctx.Err()
+		}
+		ret, err := op()
+		if err == nil {
+			return ret, nil
+		}
+
+		remaining := deadline - time.Since(start)
+		if remaining <= 0 {
+			return empty, &ErrFailedPermanently{
+				attempts: i + 1,
+				LastErr:  err,
+			}
+		}
+		timer := time.NewTimer(min(strategy.Duration(i), remaining))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return empty, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDo3(t *testing.T) {
+	attempts := 0
+	a, b, c, err := Do3(context.Background(), 3, Fixed(0), func() (int, string, []byte, error) {
+		attempts++
+		if attempts < 3 {
+			return attempts, "partial", nil, errors.New("not yet")
+		}
+		return 42, "answer", []byte{1, 2}, nil
+	})
+	if err != nil {
+		t.Fatalf("Do3: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("op ran %d times, want 3", attempts)
+	}
+	if a != 42 || b != "answer" || len(c) != 2 || c[0] != 1 || c[1] != 2 {
+		t.Errorf("Do3 = %d, %q, %v, want 42, \"answer\", [1 2]", a, b, c)
+	}
+}
+
+func TestDo3FailsPermanently(t *testing.T) {
+	failure := errors.New("down")
+	a, b, c, err := Do3(context.Background(), 2, Fixed(0), func() (int, string, []byte, error) {
+		return 1, "partial", []byte{1}, failure
+	})
+	var permanent *ErrFailedPermanently
+	if !errors.As(err, &permanent) || !errors.Is(err, failure) {
+		t.Fatalf("Do3 error = %v, want permanent failure wrapping %v", err, failure)
+	}
+	// Values from failed attempts are not returned.
+	if a != 0 || b != "" || c != nil {
+		t.Errorf("Do3 = %d, %q, %v after failing, want zero values", a, b, c)
+	}
+}
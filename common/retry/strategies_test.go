@@ -0,0 +1,16 @@
+package retry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFixedStrategyIsConstant(t *testing.T) {
+	for _, strategy := range []Strategy{&FixedStrategy{Dur: 250 * time.Millisecond}, Fixed(250 * time.Millisecond)} {
+		for _, attempt := range []int{-1, 0, 1, 2, 10, 100} {
+			if got := strategy.Duration(attempt); got != 250*time.Millisecond {
+				t.Errorf("%T.Duration(%d) = %v, want %v", strategy, attempt, got, 250*time.Millisecond)
+			}
+		}
+	}
+}
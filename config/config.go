@@ -2,46 +2,413 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/qiaopengjun5162/web3scanner/flags"
 	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Migrations string
-	MasterDB   DBConfig
-	SlaveDB    DBConfig
+	Migrations      string        `yaml:"migrations"`
+	MasterDB        DBConfig      `yaml:"master_db"`
+	SlaveDB         DBConfig      `yaml:"slave_db"`
+	RpcUrl          string        `yaml:"rpc_url"`
+	ChainID         uint64        `yaml:"chain_id"`
+	StartingHeight  uint64        `yaml:"starting_height"`
+	BlocksStep      uint64        `yaml:"blocks_step"`
+	PollInterval    time.Duration `yaml:"poll_interval"`
+	FailOnHookError bool          `yaml:"fail_on_hook_error"`
+	VerifyBlocks    bool          `yaml:"verify_blocks"`
+	DetectSweeps    bool          `yaml:"detect_sweeps"`
+
+	// DepositConfirmations is the number of blocks on top of a deposit's
+	// block after which it is marked confirmed.
+	DepositConfirmations uint64 `yaml:"deposit_confirmations"`
+	// WithdrawalConfirmations is the number of blocks on top of a mined
+	// withdrawal's block after which it is marked confirmed. Our own
+	// outbound transfers may warrant fewer than untrusted deposits.
+	WithdrawalConfirmations uint64 `yaml:"withdrawal_confirmations"`
+
+	// CatchUpBlocksStep is the number of blocks processed and committed per
+	// round while more than CatchUpThreshold blocks are left to the chain
+	// head, so a backfill runs in large batches and the scanner falls back to
+	// BlocksStep near the head. Zero always uses BlocksStep.
+	CatchUpBlocksStep uint64 `yaml:"catch_up_blocks_step"`
+	CatchUpThreshold  uint64 `yaml:"catch_up_threshold"`
+
+	// RpcBatchSize is the maximum number of blocks fetched per batch request
+	// while catching up. Zero disables batching.
+	RpcBatchSize uint64 `yaml:"rpc_batch_size"`
+
+	// TxSubBatchSize bounds the memory spent on one block: when set, blocks
+	// are fetched with transaction hashes only, and their transactions and
+	// receipts in batches of at most this many, each processed before the
+	// next is fetched. RpcBatchSize batching, which fetches whole blocks, is
+	// then not used. Zero fetches whole blocks.
+	TxSubBatchSize uint64 `yaml:"tx_sub_batch_size"`
+
+	// DepositPartitionSize is the number of blocks per partition of the
+	// deposits table. When set, the scanner creates the partitions ahead of
+	// the chain head, up to DepositPartitionsAhead partitions past the one
+	// holding it. Zero leaves every deposit in the default partition.
+	DepositPartitionSize   uint64 `yaml:"deposit_partition_size"`
+	DepositPartitionsAhead uint64 `yaml:"deposit_partitions_ahead"`
+
+	// MetricsListenAddr is the address of the /metrics HTTP server. Empty
+	// disables it.
+	MetricsListenAddr string `yaml:"metrics_listen_addr"`
+
+	// DepositAlertThreshold is the number of deposits to one address within
+	// DepositAlertWindow above which an alert fires. Zero disables alerts.
+	DepositAlertThreshold    int           `yaml:"deposit_alert_threshold"`
+	DepositAlertWindow       time.Duration `yaml:"deposit_alert_window"`
+	DepositAlertMaxAddresses int           `yaml:"deposit_alert_max_addresses"`
+
+	// AddressCacheMaxSize is the largest tracked-address count that is kept
+	// fully in memory for existence checks. Zero disables the cache.
+	AddressCacheMaxSize int `yaml:"address_cache_max_size"`
+
+	// CollectionInterval is how often user address balances are checked for
+	// collection into the hot wallet, and hot wallet balances for transfer
+	// to the cold wallet. Zero disables both.
+	CollectionInterval time.Duration `yaml:"collection_interval"`
+
+	// CollectionStrategy selects the hot wallet that receives collections
+	// when there are several. Empty means priority.
+	CollectionStrategy string `yaml:"collection_strategy"`
+
+	// HeartbeatInterval is how often the scanner logs its cursor, the chain
+	// head, the lag and its uptime, even when idle. Zero disables it.
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+
+	// EnsLookup looks up the primary ENS name of every tracked address over
+	// RPC and stores it with the address, best effort. Off by default given
+	// the extra node calls. EnsRefreshInterval is how often stored names are
+	// looked up again; zero only looks up new addresses.
+	EnsLookup          bool          `yaml:"ens_lookup"`
+	EnsRefreshInterval time.Duration `yaml:"ens_refresh_interval"`
+
+	// LogMatches logs every matched deposit and sweep at info level, to
+	// trace detection without querying the database. Off by default.
+	LogMatches bool `yaml:"log_matches"`
+
+	// StrictDecode halts the scanner on decode anomalies, such as malformed
+	// Transfer logs or a receipt for another transaction, instead of
+	// skipping the data with a warning. Off by default.
+	StrictDecode bool `yaml:"strict_decode"`
+
+	// SkipZeroValueTransfers ignores zero-value native and ERC20 transfers,
+	// which carry no funds, instead of recording them as deposits.
+	SkipZeroValueTransfers bool `yaml:"skip_zero_value_transfers"`
+
+	// DryRun processes blocks as usual but rolls back every write, logging
+	// what each range would have recorded instead. Nothing is stored, not
+	// even the scan cursor, and no collections are queued.
+	DryRun bool `yaml:"dry_run"`
+
+	// RPCStats counts node calls, errors and latency per client method,
+	// exposed as metrics and through Web3Scanner.RPCStats. Off by default to
+	// avoid the overhead.
+	RPCStats bool `yaml:"rpc_stats"`
 }
 
 type DBConfig struct {
-	Host     string
-	Port     int
-	Name     string
-	User     string
-	Password string
+	Host            string `yaml:"host"`
+	Port            int    `yaml:"port"`
+	Name            string `yaml:"name"`
+	User            string `yaml:"user"`
+	Password        string `yaml:"password"`
+	ApplicationName string `yaml:"application_name"`
+
+	// KeepAliveInterval is how often the pool is pinged and the maximum time
+	// a connection may sit idle before being retired. Zero disables it.
+	KeepAliveInterval time.Duration `yaml:"keepalive_interval"`
+
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime tune the connection
+	// pool. Zero values fall back to the database package defaults.
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+
+	// SSLMode is the Postgres sslmode; empty means disable. SSLRootCert,
+	// SSLCert and SSLKey are optional paths to the CA certificate and the
+	// client certificate and key.
+	SSLMode     string `yaml:"sslmode"`
+	SSLRootCert string `yaml:"sslrootcert"`
+	SSLCert     string `yaml:"sslcert"`
+	SSLKey      string `yaml:"sslkey"`
 }
 
+// collectionStrategies are the accepted CollectionStrategy values; they
+// match the database.CollectionStrategy constants.
+var collectionStrategies = []string{"priority", "round-robin", "lowest-balance"}
+
+// sslModes are the sslmode values accepted by Postgres.
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// LoadConfig builds the config from the CLI flags and, if --config is set,
+// the YAML file it points at. File values take precedence over flag
+// defaults, and flags that are set explicitly take precedence over the
+// file.
 func LoadConfig(cliCtx *cli.Context) (Config, error) {
-	var cfg Config
-	cfg = NewConfig(cliCtx)
+	cfg := NewConfig(cliCtx)
+	if path := cliCtx.String(flags.ConfigFlag.Name); path != "" {
+		flagCfg := cfg
+		if err := readConfigFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+		overrideSetFlags(cliCtx, &cfg, flagCfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
+	}
 	return cfg, nil
 }
 
+// Validate checks the config for missing or out-of-range values that would
+// otherwise only surface as a confusing connection error later on.
+func (c *Config) Validate() error {
+	if c.MasterDB.Host == "" {
+		return errors.New("master database host is required")
+	}
+	if c.MasterDB.Name == "" {
+		return errors.New("master database name is required")
+	}
+	if err := validatePort("master", c.MasterDB.Port); err != nil {
+		return err
+	}
+	if err := validateSSLMode("master", c.MasterDB.SSLMode); err != nil {
+		return err
+	}
+	if c.SlaveDB.Host != "" {
+		if c.SlaveDB.Name == "" {
+			return errors.New("slave database name is required when its host is set")
+		}
+		if err := validatePort("slave", c.SlaveDB.Port); err != nil {
+			return err
+		}
+		if err := validateSSLMode("slave", c.SlaveDB.SSLMode); err != nil {
+			return err
+		}
+	}
+	if c.CollectionStrategy != "" && !slices.Contains(collectionStrategies, c.CollectionStrategy) {
+		return fmt.Errorf("collection strategy %q is not one of %s", c.CollectionStrategy, strings.Join(collectionStrategies, ", "))
+	}
+	if c.DepositAlertThreshold > 0 && (c.DepositAlertWindow <= 0 || c.DepositAlertMaxAddresses <= 0) {
+		return errors.New("deposit alert window and max addresses must be greater than zero when the alert threshold is set")
+	}
+	if c.Migrations != "" {
+		info, err := os.Stat(c.Migrations)
+		if err != nil {
+			return fmt.Errorf("migrations path: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("migrations path %s is not a directory", c.Migrations)
+		}
+	}
+	return nil
+}
+
+// validatePort checks a database port. Zero means the driver default.
+func validatePort(db string, port int) error {
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("%s database port %d is out of range", db, port)
+	}
+	return nil
+}
+
+// validateSSLMode checks a database sslmode. Empty means disable.
+func validateSSLMode(db, mode string) error {
+	if mode == "" || slices.Contains(sslModes, mode) {
+		return nil
+	}
+	return fmt.Errorf("%s database sslmode %q is not one of %s", db, mode, strings.Join(sslModes, ", "))
+}
+
+// LoadConfigFromFile reads a config from a YAML file, without any CLI flag
+// defaults applied, and validates it like LoadConfig. Boolean settings whose
+// flag defaults to true keep that default when the file omits them, since
+// their zero value would silently change behavior.
+func LoadConfigFromFile(path string) (Config, error) {
+	cfg := Config{DetectSweeps: true, SkipZeroValueTransfers: true}
+	if err := readConfigFile(path, &cfg); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// readConfigFile unmarshals the YAML file at path over cfg, leaving the
+// fields the file doesn't mention untouched. Unknown keys are rejected so
+// that typos don't silently fall back to defaults.
+func readConfigFile(path string, cfg *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// overrideSetFlags copies from flagCfg every value whose flag was set
+// explicitly on the command line or through its environment variable.
+func overrideSetFlags(ctx *cli.Context, cfg *Config, flagCfg Config) {
+	override := func(flag cli.Flag, apply func()) {
+		if ctx.IsSet(flag.Names()[0]) {
+			apply()
+		}
+	}
+	override(flags.MigrationsFlag, func() { cfg.Migrations = flagCfg.Migrations })
+
+	override(flags.MasterDbHostFlag, func() { cfg.MasterDB.Host = flagCfg.MasterDB.Host })
+	override(flags.MasterDbPortFlag, func() { cfg.MasterDB.Port = flagCfg.MasterDB.Port })
+	override(flags.MasterDbNameFlag, func() { cfg.MasterDB.Name = flagCfg.MasterDB.Name })
+	override(flags.MasterDbUserFlag, func() { cfg.MasterDB.User = flagCfg.MasterDB.User })
+	override(flags.MasterDbPasswordFlag, func() { cfg.MasterDB.Password = flagCfg.MasterDB.Password })
+	override(flags.MasterDbMaxOpenConnsFlag, func() { cfg.MasterDB.MaxOpenConns = flagCfg.MasterDB.MaxOpenConns })
+	override(flags.MasterDbMaxIdleConnsFlag, func() { cfg.MasterDB.MaxIdleConns = flagCfg.MasterDB.MaxIdleConns })
+	override(flags.MasterDbConnMaxLifetimeFlag, func() { cfg.MasterDB.ConnMaxLifetime = flagCfg.MasterDB.ConnMaxLifetime })
+	override(flags.MasterDbSSLModeFlag, func() { cfg.MasterDB.SSLMode = flagCfg.MasterDB.SSLMode })
+	override(flags.MasterDbSSLRootCertFlag, func() { cfg.MasterDB.SSLRootCert = flagCfg.MasterDB.SSLRootCert })
+	override(flags.MasterDbSSLCertFlag, func() { cfg.MasterDB.SSLCert = flagCfg.MasterDB.SSLCert })
+	override(flags.MasterDbSSLKeyFlag, func() { cfg.MasterDB.SSLKey = flagCfg.MasterDB.SSLKey })
+
+	override(flags.SlaveDbHostFlag, func() { cfg.SlaveDB.Host = flagCfg.SlaveDB.Host })
+	override(flags.SlaveDbPortFlag, func() { cfg.SlaveDB.Port = flagCfg.SlaveDB.Port })
+	override(flags.SlaveDbNameFlag, func() { cfg.SlaveDB.Name = flagCfg.SlaveDB.Name })
+	override(flags.SlaveDbUserFlag, func() { cfg.SlaveDB.User = flagCfg.SlaveDB.User })
+	override(flags.SlaveDbPasswordFlag, func() { cfg.SlaveDB.Password = flagCfg.SlaveDB.Password })
+	override(flags.SlaveDbMaxOpenConnsFlag, func() { cfg.SlaveDB.MaxOpenConns = flagCfg.SlaveDB.MaxOpenConns })
+	override(flags.SlaveDbMaxIdleConnsFlag, func() { cfg.SlaveDB.MaxIdleConns = flagCfg.SlaveDB.MaxIdleConns })
+	override(flags.SlaveDbConnMaxLifetimeFlag, func() { cfg.SlaveDB.ConnMaxLifetime = flagCfg.SlaveDB.ConnMaxLifetime })
+	override(flags.SlaveDbSSLModeFlag, func() { cfg.SlaveDB.SSLMode = flagCfg.SlaveDB.SSLMode })
+	override(flags.SlaveDbSSLRootCertFlag, func() { cfg.SlaveDB.SSLRootCert = flagCfg.SlaveDB.SSLRootCert })
+	override(flags.SlaveDbSSLCertFlag, func() { cfg.SlaveDB.SSLCert = flagCfg.SlaveDB.SSLCert })
+	override(flags.SlaveDbSSLKeyFlag, func() { cfg.SlaveDB.SSLKey = flagCfg.SlaveDB.SSLKey })
+
+	override(flags.DbApplicationNameFlag, func() {
+		cfg.MasterDB.ApplicationName = flagCfg.MasterDB.ApplicationName
+		cfg.SlaveDB.ApplicationName = flagCfg.SlaveDB.ApplicationName
+	})
+	override(flags.DbKeepAliveIntervalFlag, func() {
+		cfg.MasterDB.KeepAliveInterval = flagCfg.MasterDB.KeepAliveInterval
+		cfg.SlaveDB.KeepAliveInterval = flagCfg.SlaveDB.KeepAliveInterval
+	})
+
+	override(flags.RpcUrlFlag, func() { cfg.RpcUrl = flagCfg.RpcUrl })
+	override(flags.ChainIdFlag, func() { cfg.ChainID = flagCfg.ChainID })
+	override(flags.StartingHeightFlag, func() { cfg.StartingHeight = flagCfg.StartingHeight })
+	override(flags.BlocksStepFlag, func() { cfg.BlocksStep = flagCfg.BlocksStep })
+	override(flags.PollIntervalFlag, func() { cfg.PollInterval = flagCfg.PollInterval })
+	override(flags.FailOnHookErrorFlag, func() { cfg.FailOnHookError = flagCfg.FailOnHookError })
+	override(flags.VerifyBlocksFlag, func() { cfg.VerifyBlocks = flagCfg.VerifyBlocks })
+	override(flags.DetectSweepsFlag, func() { cfg.DetectSweeps = flagCfg.DetectSweeps })
+	override(flags.DepositConfirmationsFlag, func() { cfg.DepositConfirmations = flagCfg.DepositConfirmations })
+	override(flags.WithdrawalConfirmationsFlag, func() { cfg.WithdrawalConfirmations = flagCfg.WithdrawalConfirmations })
+	override(flags.CatchUpBlocksStepFlag, func() { cfg.CatchUpBlocksStep = flagCfg.CatchUpBlocksStep })
+	override(flags.CatchUpThresholdFlag, func() { cfg.CatchUpThreshold = flagCfg.CatchUpThreshold })
+	override(flags.RpcBatchSizeFlag, func() { cfg.RpcBatchSize = flagCfg.RpcBatchSize })
+	override(flags.TxSubBatchSizeFlag, func() { cfg.TxSubBatchSize = flagCfg.TxSubBatchSize })
+	override(flags.DepositPartitionSizeFlag, func() { cfg.DepositPartitionSize = flagCfg.DepositPartitionSize })
+	override(flags.DepositPartitionsAheadFlag, func() { cfg.DepositPartitionsAhead = flagCfg.DepositPartitionsAhead })
+	override(flags.MetricsListenAddrFlag, func() { cfg.MetricsListenAddr = flagCfg.MetricsListenAddr })
+	override(flags.DepositAlertThresholdFlag, func() { cfg.DepositAlertThreshold = flagCfg.DepositAlertThreshold })
+	override(flags.DepositAlertWindowFlag, func() { cfg.DepositAlertWindow = flagCfg.DepositAlertWindow })
+	override(flags.DepositAlertMaxAddressesFlag, func() { cfg.DepositAlertMaxAddresses = flagCfg.DepositAlertMaxAddresses })
+	override(flags.AddressCacheMaxSizeFlag, func() { cfg.AddressCacheMaxSize = flagCfg.AddressCacheMaxSize })
+	override(flags.CollectionIntervalFlag, func() { cfg.CollectionInterval = flagCfg.CollectionInterval })
+	override(flags.CollectionStrategyFlag, func() { cfg.CollectionStrategy = flagCfg.CollectionStrategy })
+	override(flags.HeartbeatIntervalFlag, func() { cfg.HeartbeatInterval = flagCfg.HeartbeatInterval })
+	override(flags.EnsLookupFlag, func() { cfg.EnsLookup = flagCfg.EnsLookup })
+	override(flags.EnsRefreshIntervalFlag, func() { cfg.EnsRefreshInterval = flagCfg.EnsRefreshInterval })
+	override(flags.LogMatchesFlag, func() { cfg.LogMatches = flagCfg.LogMatches })
+	override(flags.StrictDecodeFlag, func() { cfg.StrictDecode = flagCfg.StrictDecode })
+	override(flags.SkipZeroValueTransfersFlag, func() { cfg.SkipZeroValueTransfers = flagCfg.SkipZeroValueTransfers })
+	override(flags.DryRunFlag, func() { cfg.DryRun = flagCfg.DryRun })
+	override(flags.RPCStatsFlag, func() { cfg.RPCStats = flagCfg.RPCStats })
+}
+
 func NewConfig(ctx *cli.Context) Config {
 	return Config{
 		Migrations: ctx.String(flags.MigrationsFlag.Name),
 		MasterDB: DBConfig{
-			Host:     ctx.String(flags.MasterDbHostFlag.Name),
-			Port:     ctx.Int(flags.MasterDbPortFlag.Name),
-			Name:     ctx.String(flags.MasterDbNameFlag.Name),
-			User:     ctx.String(flags.MasterDbUserFlag.Name),
-			Password: ctx.String(flags.MasterDbPasswordFlag.Name),
+			Host:              ctx.String(flags.MasterDbHostFlag.Name),
+			Port:              ctx.Int(flags.MasterDbPortFlag.Name),
+			Name:              ctx.String(flags.MasterDbNameFlag.Name),
+			User:              ctx.String(flags.MasterDbUserFlag.Name),
+			Password:          ctx.String(flags.MasterDbPasswordFlag.Name),
+			ApplicationName:   ctx.String(flags.DbApplicationNameFlag.Name),
+			KeepAliveInterval: ctx.Duration(flags.DbKeepAliveIntervalFlag.Name),
+			MaxOpenConns:      ctx.Int(flags.MasterDbMaxOpenConnsFlag.Name),
+			MaxIdleConns:      ctx.Int(flags.MasterDbMaxIdleConnsFlag.Name),
+			ConnMaxLifetime:   ctx.Duration(flags.MasterDbConnMaxLifetimeFlag.Name),
+			SSLMode:           ctx.String(flags.MasterDbSSLModeFlag.Name),
+			SSLRootCert:       ctx.String(flags.MasterDbSSLRootCertFlag.Name),
+			SSLCert:           ctx.String(flags.MasterDbSSLCertFlag.Name),
+			SSLKey:            ctx.String(flags.MasterDbSSLKeyFlag.Name),
 		},
 		SlaveDB: DBConfig{
-			Host:     ctx.String(flags.SlaveDbHostFlag.Name),
-			Port:     ctx.Int(flags.SlaveDbPortFlag.Name),
-			Name:     ctx.String(flags.SlaveDbNameFlag.Name),
-			User:     ctx.String(flags.SlaveDbUserFlag.Name),
-			Password: ctx.String(flags.SlaveDbPasswordFlag.Name),
+			Host:              ctx.String(flags.SlaveDbHostFlag.Name),
+			Port:              ctx.Int(flags.SlaveDbPortFlag.Name),
+			Name:              ctx.String(flags.SlaveDbNameFlag.Name),
+			User:              ctx.String(flags.SlaveDbUserFlag.Name),
+			Password:          ctx.String(flags.SlaveDbPasswordFlag.Name),
+			ApplicationName:   ctx.String(flags.DbApplicationNameFlag.Name),
+			KeepAliveInterval: ctx.Duration(flags.DbKeepAliveIntervalFlag.Name),
+			MaxOpenConns:      ctx.Int(flags.SlaveDbMaxOpenConnsFlag.Name),
+			MaxIdleConns:      ctx.Int(flags.SlaveDbMaxIdleConnsFlag.Name),
+			ConnMaxLifetime:   ctx.Duration(flags.SlaveDbConnMaxLifetimeFlag.Name),
+			SSLMode:           ctx.String(flags.SlaveDbSSLModeFlag.Name),
+			SSLRootCert:       ctx.String(flags.SlaveDbSSLRootCertFlag.Name),
+			SSLCert:           ctx.String(flags.SlaveDbSSLCertFlag.Name),
+			SSLKey:            ctx.String(flags.SlaveDbSSLKeyFlag.Name),
 		},
+		RpcUrl:                  ctx.String(flags.RpcUrlFlag.Name),
+		ChainID:                 ctx.Uint64(flags.ChainIdFlag.Name),
+		StartingHeight:          ctx.Uint64(flags.StartingHeightFlag.Name),
+		BlocksStep:              ctx.Uint64(flags.BlocksStepFlag.Name),
+		PollInterval:            ctx.Duration(flags.PollIntervalFlag.Name),
+		FailOnHookError:         ctx.Bool(flags.FailOnHookErrorFlag.Name),
+		VerifyBlocks:            ctx.Bool(flags.VerifyBlocksFlag.Name),
+		DetectSweeps:            ctx.Bool(flags.DetectSweepsFlag.Name),
+		DepositConfirmations:    ctx.Uint64(flags.DepositConfirmationsFlag.Name),
+		WithdrawalConfirmations: ctx.Uint64(flags.WithdrawalConfirmationsFlag.Name),
+		CatchUpBlocksStep:       ctx.Uint64(flags.CatchUpBlocksStepFlag.Name),
+		CatchUpThreshold:        ctx.Uint64(flags.CatchUpThresholdFlag.Name),
+		RpcBatchSize:            ctx.Uint64(flags.RpcBatchSizeFlag.Name),
+		TxSubBatchSize:          ctx.Uint64(flags.TxSubBatchSizeFlag.Name),
+		DepositPartitionSize:    ctx.Uint64(flags.DepositPartitionSizeFlag.Name),
+		DepositPartitionsAhead:  ctx.Uint64(flags.DepositPartitionsAheadFlag.Name),
+		MetricsListenAddr:       ctx.String(flags.MetricsListenAddrFlag.Name),
+
+		DepositAlertThreshold:    ctx.Int(flags.DepositAlertThresholdFlag.Name),
+		DepositAlertWindow:       ctx.Duration(flags.DepositAlertWindowFlag.Name),
+		DepositAlertMaxAddresses: ctx.Int(flags.DepositAlertMaxAddressesFlag.Name),
+		AddressCacheMaxSize:      ctx.Int(flags.AddressCacheMaxSizeFlag.Name),
+		CollectionInterval:       ctx.Duration(flags.CollectionIntervalFlag.Name),
+		CollectionStrategy:       ctx.String(flags.CollectionStrategyFlag.Name),
+		HeartbeatInterval:        ctx.Duration(flags.HeartbeatIntervalFlag.Name),
+		EnsLookup:                ctx.Bool(flags.EnsLookupFlag.Name),
+		EnsRefreshInterval:       ctx.Duration(flags.EnsRefreshIntervalFlag.Name),
+		LogMatches:               ctx.Bool(flags.LogMatchesFlag.Name),
+		StrictDecode:             ctx.Bool(flags.StrictDecodeFlag.Name),
+		SkipZeroValueTransfers:   ctx.Bool(flags.SkipZeroValueTransfersFlag.Name),
+		DryRun:                   ctx.Bool(flags.DryRunFlag.Name),
+		RPCStats:                 ctx.Bool(flags.RPCStatsFlag.Name),
 	}
 }
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/qiaopengjun5162/web3scanner/flags"
+)
+
+func validConfig() Config {
+	return Config{
+		MasterDB: DBConfig{Host: "localhost", Port: 5432, Name: "web3scanner"},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	notDir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notDir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"default port", func(c *Config) { c.MasterDB.Port = 0 }, ""},
+		{"missing master host", func(c *Config) { c.MasterDB.Host = "" }, "master database host"},
+		{"missing master name", func(c *Config) { c.MasterDB.Name = "" }, "master database name"},
+		{"negative master port", func(c *Config) { c.MasterDB.Port = -1 }, "master database port"},
+		{"master port too large", func(c *Config) { c.MasterDB.Port = 65536 }, "master database port"},
+		{"master sslmode", func(c *Config) { c.MasterDB.SSLMode = "verify-full" }, ""},
+		{"unknown master sslmode", func(c *Config) { c.MasterDB.SSLMode = "on" }, "master database sslmode"},
+		{"slave", func(c *Config) { c.SlaveDB = DBConfig{Host: "replica", Name: "web3scanner"} }, ""},
+		{"slave without name", func(c *Config) { c.SlaveDB.Host = "replica" }, "slave database name"},
+		{"slave port", func(c *Config) { c.SlaveDB = DBConfig{Host: "replica", Name: "web3scanner", Port: 70000} }, "slave database port"},
+		{"slave sslmode", func(c *Config) { c.SlaveDB = DBConfig{Host: "replica", Name: "web3scanner", SSLMode: "on"} }, "slave database sslmode"},
+		{"unset slave is not checked", func(c *Config) { c.SlaveDB.Port = -1 }, ""},
+		{"collection strategy", func(c *Config) { c.CollectionStrategy = "lowest-balance" }, ""},
+		{"unknown collection strategy", func(c *Config) { c.CollectionStrategy = "random" }, "collection strategy"},
+		{"deposit alert", func(c *Config) {
+			c.DepositAlertThreshold, c.DepositAlertWindow, c.DepositAlertMaxAddresses = 5, time.Minute, 100
+		}, ""},
+		{"deposit alert without window", func(c *Config) {
+			c.DepositAlertThreshold, c.DepositAlertMaxAddresses = 5, 100
+		}, "deposit alert window"},
+		{"deposit alert without max addresses", func(c *Config) {
+			c.DepositAlertThreshold, c.DepositAlertWindow = 5, time.Minute
+		}, "deposit alert window and max addresses"},
+		{"unset deposit alert is not checked", func(c *Config) { c.DepositAlertWindow = -1 }, ""},
+		{"migrations dir", func(c *Config) { c.Migrations = t.TempDir() }, ""},
+		{"missing migrations dir", func(c *Config) { c.Migrations = filepath.Join(t.TempDir(), "missing") }, "migrations path"},
+		{"migrations file", func(c *Config) { c.Migrations = notDir }, "not a directory"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// loadConfig runs LoadConfig with the scanner's flags parsed from args.
+func loadConfig(t *testing.T, args ...string) (Config, error) {
+	t.Helper()
+	var (
+		cfg Config
+		err error
+	)
+	app := &cli.App{
+		Flags: flags.Flags,
+		Action: func(ctx *cli.Context) error {
+			cfg, err = LoadConfig(ctx)
+			return nil
+		},
+	}
+	if runErr := app.Run(append([]string{"web3scanner"}, args...)); runErr != nil {
+		t.Fatalf("run app: %v", runErr)
+	}
+	return cfg, err
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	migrations := t.TempDir()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := "migrations: " + migrations + "\n" +
+		"master_db:\n  host: file-host\n  name: file-db\n" +
+		"blocks_step: 7\n" +
+		"poll_interval: 1m\n"
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(t, "--config", path, "--master-db-host", "flag-host", "--blocks-step", "10")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	for _, check := range []struct {
+		name      string
+		got, want any
+	}{
+		// Explicit flags win over the file, even when equal to the default.
+		{"master host", cfg.MasterDB.Host, "flag-host"},
+		{"blocks step", cfg.BlocksStep, uint64(10)},
+		// The file wins over flag defaults.
+		{"master name", cfg.MasterDB.Name, "file-db"},
+		{"poll interval", cfg.PollInterval, time.Minute},
+		{"migrations", cfg.Migrations, migrations},
+		// Flag defaults fill what the file leaves out.
+		{"deposit confirmations", cfg.DepositConfirmations, uint64(12)},
+		{"withdrawal confirmations", cfg.WithdrawalConfirmations, uint64(12)},
+		{"catch-up blocks step", cfg.CatchUpBlocksStep, uint64(0)},
+		{"catch-up threshold", cfg.CatchUpThreshold, uint64(100)},
+		{"sslmode", cfg.MasterDB.SSLMode, "disable"},
+	} {
+		if check.got != check.want {
+			t.Errorf("%s = %v, want %v", check.name, check.got, check.want)
+		}
+	}
+}
+
+func TestLoadConfigValidates(t *testing.T) {
+	_, err := loadConfig(t, "--migrations-dir", t.TempDir(), "--master-db-name", "web3scanner")
+	if err == nil || !strings.Contains(err.Error(), "master database host") {
+		t.Errorf("LoadConfig without a master host = %v, want host error", err)
+	}
+}
+
+func TestReadConfigFileRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("blocks_stepp: 7\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	if err := readConfigFile(path, &cfg); err == nil {
+		t.Error("readConfigFile accepted an unknown key")
+	}
+}
+
+func TestLoadConfigFromFileValidates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cfg, err := LoadConfigFromFile(write("valid.yaml", "master_db:\n  host: localhost\n  name: web3scanner\nblocks_step: 7\n"))
+	if err != nil {
+		t.Fatalf("LoadConfigFromFile: %v", err)
+	}
+	if cfg.MasterDB.Host != "localhost" || cfg.BlocksStep != 7 {
+		t.Errorf("LoadConfigFromFile = %+v, want the file's values", cfg)
+	}
+	if !cfg.SkipZeroValueTransfers || !cfg.DetectSweeps {
+		t.Errorf("SkipZeroValueTransfers, DetectSweeps = %t, %t for a file that omits them, want the flag defaults true", cfg.SkipZeroValueTransfers, cfg.DetectSweeps)
+	}
+	cfg, err = LoadConfigFromFile(write("keep-zero-value.yaml", "master_db:\n  host: localhost\n  name: web3scanner\nskip_zero_value_transfers: false\n"))
+	if err != nil {
+		t.Fatalf("LoadConfigFromFile: %v", err)
+	}
+	if cfg.SkipZeroValueTransfers {
+		t.Error("SkipZeroValueTransfers = true, want the file's false")
+	}
+
+	for name, content := range map[string]string{
+		"missing host":     "master_db:\n  name: web3scanner\n",
+		"port":             "master_db:\n  host: localhost\n  name: web3scanner\n  port: 70000\n",
+		"unknown strategy": "master_db:\n  host: localhost\n  name: web3scanner\ncollection_strategy: random\n",
+	} {
+		if _, err := LoadConfigFromFile(write(strings.ReplaceAll(name, " ", "-")+".yaml", content)); err == nil || !strings.Contains(err.Error(), "invalid config") {
+			t.Errorf("%s: LoadConfigFromFile error = %v, want a validation error", name, err)
+		}
+	}
+}
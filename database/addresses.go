@@ -1,10 +1,15 @@
 package database
 
 import (
+	"context"
 	"errors"
-	"strings"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/google/uuid"
 
@@ -33,8 +38,65 @@ type Addresses struct {
 	// Timestamp 存储了地址创建的时间戳，为 uint64 类型。
 	// 它用于记录地址的创建时间。
 	Timestamp int64
+
+	// UpdatedAt 存储了地址最后一次修改的时间戳（秒），由 GORM 自动维护。
+	UpdatedAt int64 `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	// Priority 是热钱包的路由优先级，数值越大越优先被选为归集地址。
+	// 对用户地址和冷钱包地址没有意义。
+	Priority int `json:"priority"`
+
+	// FirstSeenBlock 记录该地址第一次在链上出现活动的区块号，从未出现过时为 NULL。
+	FirstSeenBlock *big.Int `json:"firstSeenBlock" gorm:"serializer:u256"`
+
+	// EnsName 是该地址的 ENS 主名称，用于报表展示，没有或未查询时为空。
+	// EnsResolvedAt 是最近一次查询 ENS 名称的时间戳（秒），从未查询过时为 NULL。
+	EnsName       string `json:"ensName"`
+	EnsResolvedAt *int64 `json:"ensResolvedAt"`
+
+	// DeletedAt 记录地址被软删除的时间，未删除时为 NULL。
+	// 软删除的地址保留用于审计，但默认查询不会返回它们。
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty"`
 }
 
+// BeforeCreate assigns a GUID from the configured IDGenerator when the
+// caller left it unset.
+func (a *Addresses) BeforeCreate(_ *gorm.DB) error {
+	if a.GUID == uuid.Nil {
+		a.GUID = NewGUID()
+	}
+	return nil
+}
+
+// Address types stored in Addresses.AddressType.
+const (
+	AddressTypeUser uint8 = 0
+	AddressTypeHot  uint8 = 1
+	AddressTypeCold uint8 = 2
+)
+
+// CollectionStrategy selects which hot wallet receives a collection sweep
+// when several hot wallets are configured.
+type CollectionStrategy string
+
+// inQueryChunkSize bounds the number of values bound into a single
+// IN (...) clause, keeping well under Postgres' 65535 parameter limit.
+const inQueryChunkSize = 10_000
+
+const (
+	// CollectionStrategyPriority picks the hot wallet with the highest
+	// Priority, breaking ties by the oldest Timestamp.
+	CollectionStrategyPriority CollectionStrategy = "priority"
+	// CollectionStrategyRoundRobin cycles through all hot wallets in
+	// Timestamp order on successive calls.
+	CollectionStrategyRoundRobin CollectionStrategy = "round-robin"
+	// CollectionStrategyLowestBalance picks the hot wallet with the lowest
+	// recorded balance of the collected token plus the collections of it
+	// queued but not yet mined, a wallet without a balance row counting as
+	// zero, breaking ties by the oldest Timestamp.
+	CollectionStrategyLowestBalance CollectionStrategy = "lowest-balance"
+)
+
 // AddressesView defines the interface for querying address-related information.
 // It includes methods for checking the existence of addresses, querying address details,
 // and obtaining wallet information.
@@ -43,19 +105,65 @@ type AddressesView interface {
 	// the type of the address if it exists. If the address does not exist,
 	// returns false and 0.
 	AddressExist(address *common.Address) (bool, uint8)
+	// BatchAddressExist checks many addresses with a single IN (...) query
+	// (chunked for very large inputs) and returns only the tracked ones,
+	// mapped to their address type.
+	BatchAddressExist(addresses []common.Address) (map[common.Address]uint8, error)
 	// QueryAddressesByToAddress returns the Addresses entry with the given address
 	// if it exists. If the address does not exist, returns nil and gorm.ErrRecordNotFound.
 	QueryAddressesByToAddress(*common.Address) (*Addresses, error)
+	// QueryAddressByGUID returns the Addresses entry with the given GUID if it
+	// exists. If it does not exist, returns nil and gorm.ErrRecordNotFound.
+	QueryAddressByGUID(guid uuid.UUID) (*Addresses, error)
 	// QueryHotWalletInfo returns the Addresses entry with the hot wallet address
 	// if it exists. If the address does not exist, returns nil and gorm.ErrRecordNotFound.
+	// When several hot wallets exist it returns the one with the lowest
+	// Timestamp, i.e. the first entry of QueryHotWalletsInfo.
 	QueryHotWalletInfo() (*Addresses, error)
+	// QueryHotWalletsInfo returns all hot wallet entries ordered by Timestamp
+	// ascending (GUID breaks ties). It returns an empty slice if there are none.
+	QueryHotWalletsInfo() ([]*Addresses, error)
 	// QueryColdWalletInfo returns the Addresses entry with the cold wallet address
 	// if it exists. If the address does not exist, returns nil and gorm.ErrRecordNotFound.
 	QueryColdWalletInfo() (*Addresses, error)
-	// GetAllAddresses returns all Addresses entries in the database.
+	// GetAllAddresses returns all Addresses entries in the database, excluding
+	// soft-deleted ones.
 	// It returns a slice of Addresses and a nil error if successful.
 	// If there is an error, it returns a nil slice and the error.
 	GetAllAddresses() ([]*Addresses, error)
+	// GetAllAddressesIncludingDeleted is like GetAllAddresses but also returns
+	// soft-deleted entries, for audits.
+	GetAllAddressesIncludingDeleted() ([]*Addresses, error)
+	// GetAddressesPaginated returns one page of Addresses entries ordered by
+	// Timestamp descending (GUID breaks ties, so pages are stable), together
+	// with the total number of addresses.
+	GetAddressesPaginated(offset, limit int) ([]*Addresses, int64, error)
+	// GetAddressesByType returns one page of Addresses entries of the given
+	// address type, ordered like GetAddressesPaginated. It returns an error
+	// for an unknown address type.
+	GetAddressesByType(addrType uint8, offset, limit int) ([]*Addresses, error)
+	// CountAddresses returns the number of Addresses entries, excluding
+	// soft-deleted ones.
+	CountAddresses() (int64, error)
+	// CountAddressesByType is like CountAddresses but only counts entries of
+	// the given address type. It returns an error for an unknown address type.
+	CountAddressesByType(addrType uint8) (int64, error)
+	// QueryAddressesUpdatedSince returns all Addresses entries whose UpdatedAt
+	// is at or after the given unix timestamp, ordered by UpdatedAt ascending.
+	// Rows updated exactly at ts are included, so callers syncing
+	// incrementally may see boundary rows twice.
+	QueryAddressesUpdatedSince(ts int64) ([]*Addresses, error)
+	// SelectCollectionWallet returns the hot wallet that should receive the
+	// next collection sweep of token according to the given strategy. If no
+	// hot wallet exists, returns nil and gorm.ErrRecordNotFound.
+	SelectCollectionWallet(strategy CollectionStrategy, token common.Address) (*Addresses, error)
+	// QueryAddressesByGUIDs returns the Addresses entries for the given GUIDs.
+	// GUIDs that do not exist are simply absent from the result. Large sets
+	// are queried in chunks.
+	QueryAddressesByGUIDs(guids []uuid.UUID) ([]*Addresses, error)
+	// QueryUnseenAddresses returns all Addresses entries that have never had
+	// on-chain activity, i.e. whose FirstSeenBlock is NULL.
+	QueryUnseenAddresses() ([]*Addresses, error)
 }
 
 // AddressesDB 定义了一个接口，用于管理地址数据的存储和检索。
@@ -70,15 +178,72 @@ type AddressesDB interface {
 	// 返回值:
 	//   - error: 如果存储过程中发生错误，返回一个描述错误的 error 对象；否则返回 nil。
 	StoreAddresses([]Addresses) error
+
+	// UpsertAddresses 方法与 StoreAddresses 类似，但地址已存在时不会报错，
+	// 而是更新其地址类型和公钥，因此可以安全地重复导入同一份地址列表。
+	// 已被软删除的地址会被恢复（清空 deleted_at）。
+	UpsertAddresses([]Addresses) error
+
+	// CopyAddresses 方法通过 Postgres COPY 批量导入地址数据，适用于超大规模导入。
+	// 返回值为成功导入的行数。
+	CopyAddresses(ctx context.Context, addressList []Addresses) (int64, error)
+
+	// ImportAddresses 方法与 CopyAddresses 相同，但 skipInvalid 为 true 时会跳过校验失败的地址
+	// 并导入其余地址，跳过的地址记录在返回的 AddressImportSummary 中。
+	// skipInvalid 为 false 时为严格模式，任一地址无效则不导入任何地址。
+	ImportAddresses(ctx context.Context, addressList []Addresses, skipInvalid bool) (AddressImportSummary, error)
+
+	// ValidateStoredAddresses 方法逐行扫描已存储的地址，检查其是否为 AddressNormalizer 给出的规范形式，
+	// 每发现一个问题调用一次 onIssue。fix 为 true 时会原地修复可修复的行。
+	// 返回值为检查过的行数。
+	ValidateStoredAddresses(fix bool, onIssue func(AddressIssue)) (int64, error)
+
+	// DeleteAddress 方法软删除指定 GUID 的地址：设置 deleted_at，保留行用于审计，
+	// 之后的 AddressExist、GetAllAddresses 等查询不再匹配它。
+	// 地址唯一约束仍然生效，已删除的地址不能通过 StoreAddresses 重新存储，
+	// 但 UpsertAddresses 会恢复它。
+	// 地址不存在或已被删除时返回 gorm.ErrRecordNotFound。
+	DeleteAddress(guid uuid.UUID) error
+
+	// QueryAddressesForEnsLookup 方法返回最多 limit 个需要查询 ENS 名称的地址：
+	// 从未查询过的地址，以及最近一次查询早于 resolvedBefore 的地址，从未查询过的排在前面。
+	QueryAddressesForEnsLookup(resolvedBefore int64, limit int) ([]*Addresses, error)
+
+	// UpdateEnsName 方法记录地址的 ENS 名称和查询时间。它不修改 UpdatedAt，
+	// 因为定期刷新名称并不是对地址本身的修改。
+	UpdateEnsName(address common.Address, name string, resolvedAt int64) error
+}
+
+// AddressIssue describes a stored address row whose raw value is not in the
+// canonical form produced by the AddressNormalizer.
+type AddressIssue struct {
+	GUID   string
+	Stored string
+	// Canonical is the canonical form of Stored, or empty if Stored is not a
+	// valid address at all and cannot be fixed.
+	Canonical string
+	// Fixed reports whether the row was rewritten to Canonical.
+	Fixed bool
+	// FixErr is set when fixing was requested but the update failed, e.g.
+	// because the canonical form collides with another row.
+	FixErr error
 }
 
 type addressesDB struct {
 	gorm *gorm.DB
+	// reader serves the AddressesView queries; it is gorm unless a read
+	// replica is configured.
+	reader     *gorm.DB
+	normalizer AddressNormalizer
+
+	// roundRobin counts SelectCollectionWallet calls for the round-robin
+	// strategy.
+	roundRobin atomic.Uint64
 }
 
 func (db *addressesDB) AddressExist(address *common.Address) (bool, uint8) {
 	var addressEntry Addresses
-	err := db.gorm.Table("addresses").Where("address", strings.ToLower(address.String())).First(&addressEntry).Error
+	err := db.reader.Table("addresses").Where("address", db.normalizer.Normalize(*address)).First(&addressEntry).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return false, 0
@@ -88,9 +253,35 @@ func (db *addressesDB) AddressExist(address *common.Address) (bool, uint8) {
 	return true, addressEntry.AddressType
 }
 
+func (db *addressesDB) BatchAddressExist(addresses []common.Address) (map[common.Address]uint8, error) {
+	keys := make([]string, 0, len(addresses))
+	seen := make(map[common.Address]struct{}, len(addresses))
+	for _, address := range addresses {
+		if _, ok := seen[address]; ok {
+			continue
+		}
+		seen[address] = struct{}{}
+		keys = append(keys, db.normalizer.Normalize(address))
+	}
+
+	tracked := make(map[common.Address]uint8)
+	for start := 0; start < len(keys); start += inQueryChunkSize {
+		end := min(start+inQueryChunkSize, len(keys))
+		var rows []Addresses
+		err := db.reader.Table("addresses").Select("address", "address_type").Where("address IN ?", keys[start:end]).Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			tracked[row.Address] = row.AddressType
+		}
+	}
+	return tracked, nil
+}
+
 func (db *addressesDB) QueryAddressesByToAddress(address *common.Address) (*Addresses, error) {
 	var addressEntry Addresses
-	err := db.gorm.Table("addresses").Where("address", strings.ToLower(address.String())).Take(&addressEntry).Error
+	err := db.reader.Table("addresses").Where("address", db.normalizer.Normalize(*address)).Take(&addressEntry).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, gorm.ErrRecordNotFound
@@ -100,6 +291,15 @@ func (db *addressesDB) QueryAddressesByToAddress(address *common.Address) (*Addr
 	return &addressEntry, nil
 }
 
+func (db *addressesDB) QueryAddressByGUID(guid uuid.UUID) (*Addresses, error) {
+	var addressEntry Addresses
+	err := db.reader.Table("addresses").Where("guid", guid).Take(&addressEntry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &addressEntry, nil
+}
+
 // NewAddressesDB returns a new instance of the AddressesDB interface, which is
 // backed by the given Gorm DB.
 //
@@ -109,18 +309,65 @@ func (db *addressesDB) QueryAddressesByToAddress(address *common.Address) (*Addr
 // The returned AddressesDB instance is safe for concurrent use by multiple
 // goroutines.
 func NewAddressesDB(db *gorm.DB) AddressesDB {
-	return &addressesDB{gorm: db}
+	return NewAddressesDBWithNormalizer(db, EVMAddressNormalizer{})
+}
+
+// NewAddressesDBWithNormalizer is like NewAddressesDB but uses the given
+// AddressNormalizer to build address lookup keys, for chains whose canonical
+// address form isn't lowercase hex.
+func NewAddressesDBWithNormalizer(db *gorm.DB, normalizer AddressNormalizer) AddressesDB {
+	return &addressesDB{gorm: db, reader: db, normalizer: normalizer}
+}
+
+// NewAddressesDBWithReader is like NewAddressesDBWithNormalizer but serves
+// the AddressesView queries from reader, typically a read replica, while
+// writes and ValidateStoredAddresses stay on db.
+func NewAddressesDBWithReader(db, reader *gorm.DB, normalizer AddressNormalizer) AddressesDB {
+	return &addressesDB{gorm: db, reader: reader, normalizer: normalizer}
 }
 
-// StoreAddresses store address
+// StoreAddresses store address. Entries are validated first and nothing is
+// stored if any is invalid, see validateAddresses.
 func (db *addressesDB) StoreAddresses(addressList []Addresses) error {
+	if err := validateAddresses(addressList); err != nil {
+		return err
+	}
 	result := db.gorm.Table("addresses").CreateInBatches(&addressList, len(addressList))
 	return result.Error
 }
 
+// UpsertAddresses stores addresses, updating address_type and public_key of
+// rows whose address already exists. The existing row keeps its GUID,
+// Timestamp and Priority.
+func (db *addressesDB) UpsertAddresses(addressList []Addresses) error {
+	if err := validateAddresses(addressList); err != nil {
+		return err
+	}
+	// Postgres rejects an upsert that touches the same row twice, so keep
+	// only the last entry for each address.
+	last := make(map[common.Address]int, len(addressList))
+	for i, a := range addressList {
+		last[a.Address] = i
+	}
+	unique := make([]Addresses, 0, len(last))
+	for i, a := range addressList {
+		if last[a.Address] == i {
+			unique = append(unique, a)
+		}
+	}
+
+	result := db.gorm.Table("addresses").Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "address"}},
+		// deleted_at is NULL for the inserted row, so this also restores
+		// soft-deleted addresses.
+		DoUpdates: clause.AssignmentColumns([]string{"address_type", "public_key", "updated_at", "deleted_at"}),
+	}).CreateInBatches(&unique, len(unique))
+	return result.Error
+}
+
 func (db *addressesDB) QueryHotWalletInfo() (*Addresses, error) {
 	var addressEntry Addresses
-	err := db.gorm.Table("addresses").Where("address_type", 1).Take(&addressEntry).Error
+	err := db.reader.Table("addresses").Where("address_type", AddressTypeHot).Order("timestamp asc, guid asc").Take(&addressEntry).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -130,9 +377,18 @@ func (db *addressesDB) QueryHotWalletInfo() (*Addresses, error) {
 	return &addressEntry, nil
 }
 
+func (db *addressesDB) QueryHotWalletsInfo() ([]*Addresses, error) {
+	var hotWallets []*Addresses
+	err := db.reader.Table("addresses").Where("address_type", AddressTypeHot).Order("timestamp asc, guid asc").Find(&hotWallets).Error
+	if err != nil {
+		return nil, err
+	}
+	return hotWallets, nil
+}
+
 func (db *addressesDB) QueryColdWalletInfo() (*Addresses, error) {
 	var addressEntry Addresses
-	err := db.gorm.Table("addresses").Where("address_type", 2).Take(&addressEntry).Error
+	err := db.reader.Table("addresses").Where("address_type", AddressTypeCold).Take(&addressEntry).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -144,7 +400,7 @@ func (db *addressesDB) QueryColdWalletInfo() (*Addresses, error) {
 
 func (db *addressesDB) GetAllAddresses() ([]*Addresses, error) {
 	var addresses []*Addresses
-	err := db.gorm.Table("addresses").Find(&addresses).Error
+	err := db.reader.Table("addresses").Find(&addresses).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -153,3 +409,238 @@ func (db *addressesDB) GetAllAddresses() ([]*Addresses, error) {
 	}
 	return addresses, nil
 }
+
+func (db *addressesDB) GetAllAddressesIncludingDeleted() ([]*Addresses, error) {
+	var addresses []*Addresses
+	err := db.reader.Table("addresses").Unscoped().Find(&addresses).Error
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+func (db *addressesDB) DeleteAddress(guid uuid.UUID) error {
+	result := db.gorm.Table("addresses").Where("guid", guid).Delete(&Addresses{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (db *addressesDB) QueryAddressesUpdatedSince(ts int64) ([]*Addresses, error) {
+	var addresses []*Addresses
+	err := db.reader.Table("addresses").Where("updated_at >= ?", ts).Order("updated_at asc").Find(&addresses).Error
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+func (db *addressesDB) SelectCollectionWallet(strategy CollectionStrategy, token common.Address) (*Addresses, error) {
+	var hotWallets []*Addresses
+	query := db.reader.Table("addresses").Where("address_type", AddressTypeHot)
+	switch strategy {
+	case CollectionStrategyPriority:
+		query = query.Order("priority desc, timestamp asc")
+	case CollectionStrategyRoundRobin:
+		query = query.Order("timestamp asc, guid asc")
+	case CollectionStrategyLowestBalance:
+		// Collections still on their way add to the balance once mined.
+		// Mined ones are already credited through their sweep. The
+		// primary is read so collections queued just before count too.
+		key := db.normalizer.Normalize(token)
+		query = db.gorm.Table("addresses").Where("address_type", AddressTypeHot).
+			Select("addresses.*").
+			Joins("LEFT JOIN balances ON balances.address = addresses.address AND balances.token_address = ?", key).
+			Joins("LEFT JOIN (SELECT to_address, SUM(amount) AS amount FROM withdrawals WHERE type = ? AND token_address = ? AND status IN ? GROUP BY to_address) AS in_flight ON in_flight.to_address = addresses.address",
+				WithdrawalTypeCollection, key, statusList(WithdrawalStatusUnsigned, WithdrawalStatusSigned, WithdrawalStatusSent)).
+			Order("COALESCE(balances.balance, 0) + COALESCE(in_flight.amount, 0) asc, addresses.timestamp asc, addresses.guid asc")
+	default:
+		return nil, fmt.Errorf("unknown collection strategy: %q", strategy)
+	}
+	if err := query.Find(&hotWallets).Error; err != nil {
+		return nil, err
+	}
+	if len(hotWallets) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if strategy == CollectionStrategyRoundRobin {
+		next := db.roundRobin.Add(1) - 1
+		return hotWallets[next%uint64(len(hotWallets))], nil
+	}
+	return hotWallets[0], nil
+}
+
+func (db *addressesDB) QueryAddressesByGUIDs(guids []uuid.UUID) ([]*Addresses, error) {
+	addresses := make([]*Addresses, 0, len(guids))
+	for start := 0; start < len(guids); start += inQueryChunkSize {
+		end := min(start+inQueryChunkSize, len(guids))
+		var chunk []*Addresses
+		err := db.reader.Table("addresses").Where("guid IN ?", guids[start:end]).Find(&chunk).Error
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, chunk...)
+	}
+	return addresses, nil
+}
+
+func (db *addressesDB) ValidateStoredAddresses(fix bool, onIssue func(AddressIssue)) (int64, error) {
+	issues, checked, err := db.scanAddressIssues()
+	if err != nil {
+		return checked, err
+	}
+
+	// Fix only once the cursor is closed: with MaxOpenConns=1 an update
+	// issued while the rows are still open waits for a connection forever.
+	for _, issue := range issues {
+		if fix && issue.Canonical != "" {
+			issue.FixErr = db.gorm.Table("addresses").Where("guid", issue.GUID).Updates(map[string]any{
+				"address":    issue.Canonical,
+				"updated_at": time.Now().Unix(),
+			}).Error
+			issue.Fixed = issue.FixErr == nil
+		}
+		onIssue(issue)
+	}
+	return checked, nil
+}
+
+// scanAddressIssues returns the stored addresses that aren't in canonical
+// form and the number of rows checked.
+func (db *addressesDB) scanAddressIssues() ([]AddressIssue, int64, error) {
+	// Read the raw column rather than the model so the bytes serializer
+	// doesn't normalize away exactly what we are looking for.
+	rows, err := db.gorm.Table("addresses").Select("guid", "address").Order("guid").Rows()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var issues []AddressIssue
+	var checked int64
+	for rows.Next() {
+		var guid, stored string
+		if err := rows.Scan(&guid, &stored); err != nil {
+			return nil, checked, err
+		}
+		checked++
+
+		canonical := ""
+		if address, err := db.normalizer.Parse(stored); err == nil {
+			canonical = db.normalizer.Normalize(address)
+		}
+		if stored != canonical {
+			issues = append(issues, AddressIssue{GUID: guid, Stored: stored, Canonical: canonical})
+		}
+	}
+	return issues, checked, rows.Err()
+}
+
+func (db *addressesDB) QueryUnseenAddresses() ([]*Addresses, error) {
+	var addresses []*Addresses
+	err := db.reader.Table("addresses").Where("first_seen_block IS NULL").Order("timestamp asc").Find(&addresses).Error
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// QueryAddressesForEnsLookup reads from the primary database, so addresses
+// updated by UpdateEnsName aren't returned again because of replica lag.
+func (db *addressesDB) QueryAddressesForEnsLookup(resolvedBefore int64, limit int) ([]*Addresses, error) {
+	var addresses []*Addresses
+	err := db.gorm.Table("addresses").
+		Where("ens_resolved_at IS NULL OR ens_resolved_at < ?", resolvedBefore).
+		Order("ens_resolved_at asc NULLS FIRST, guid asc").
+		Limit(limit).
+		Find(&addresses).Error
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+func (db *addressesDB) UpdateEnsName(address common.Address, name string, resolvedAt int64) error {
+	return db.gorm.Table("addresses").
+		Where("address", db.normalizer.Normalize(address)).
+		UpdateColumns(map[string]any{"ens_name": name, "ens_resolved_at": resolvedAt}).Error
+}
+
+func (db *addressesDB) GetAddressesPaginated(offset, limit int) ([]*Addresses, int64, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, 0, fmt.Errorf("invalid pagination: offset %d, limit %d", offset, limit)
+	}
+
+	var total int64
+	if err := db.reader.Table("addresses").Model(&Addresses{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var addresses []*Addresses
+	err := db.reader.Table("addresses").
+		Order("timestamp desc, guid asc").
+		Offset(offset).
+		Limit(limit).
+		Find(&addresses).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return addresses, total, nil
+}
+
+func (db *addressesDB) GetAddressesByType(addrType uint8, offset, limit int) ([]*Addresses, error) {
+	if err := validateAddressType(addrType); err != nil {
+		return nil, err
+	}
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("invalid pagination: offset %d, limit %d", offset, limit)
+	}
+
+	var addresses []*Addresses
+	err := db.reader.Table("addresses").
+		Where("address_type = ?", addrType).
+		Order("timestamp desc, guid asc").
+		Offset(offset).
+		Limit(limit).
+		Find(&addresses).Error
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+func (db *addressesDB) CountAddresses() (int64, error) {
+	var count int64
+	if err := db.reader.Table("addresses").Model(&Addresses{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (db *addressesDB) CountAddressesByType(addrType uint8) (int64, error) {
+	if err := validateAddressType(addrType); err != nil {
+		return 0, err
+	}
+	var count int64
+	err := db.reader.Table("addresses").Model(&Addresses{}).Where("address_type = ?", addrType).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// validateAddressType rejects values that are not one of the AddressType
+// constants.
+func validateAddressType(addrType uint8) error {
+	switch addrType {
+	case AddressTypeUser, AddressTypeHot, AddressTypeCold:
+		return nil
+	default:
+		return fmt.Errorf("unknown address type %d", addrType)
+	}
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// cachedAddressesDB wraps an AddressesDB and, while the number of tracked
+// addresses is at most maxSize, keeps the whole address set in memory so
+// AddressExist never hits the database during scanning. Above the threshold
+// it falls back to per-query lookups.
+//
+// The cache is reloaded after every write made through it. Writes made
+// through DB.Transaction are picked up when the transaction commits;
+// transactions that don't write addresses leave the cache alone.
+type cachedAddressesDB struct {
+	AddressesDB
+	gorm       *gorm.DB
+	maxSize    int
+	normalizer AddressNormalizer
+
+	mu    sync.RWMutex
+	full  bool
+	types map[common.Address]uint8
+}
+
+func newCachedAddressesDB(inner AddressesDB, db *gorm.DB, maxSize int, normalizer AddressNormalizer) (*cachedAddressesDB, error) {
+	cache := &cachedAddressesDB{AddressesDB: inner, gorm: db, maxSize: maxSize, normalizer: normalizer}
+	if err := cache.reload(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// reload refreshes the in-memory set, or disables full caching if the
+// address count exceeds maxSize.
+func (c *cachedAddressesDB) reload() error {
+	var count int64
+	if err := c.gorm.Table("addresses").Model(&Addresses{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > int64(c.maxSize) {
+		c.mu.Lock()
+		if c.full {
+			log.Info("address count exceeds cache threshold, falling back to per-query lookups", "count", count, "max", c.maxSize)
+		}
+		c.full, c.types = false, nil
+		c.mu.Unlock()
+		return nil
+	}
+
+	// Scan the raw column and parse it with the normalizer, which defines
+	// the stored form of the chain's addresses.
+	var rows []struct {
+		Address     string
+		AddressType uint8
+	}
+	if err := c.gorm.Table("addresses").Select("address", "address_type").Where("deleted_at IS NULL").Find(&rows).Error; err != nil {
+		return err
+	}
+	types := make(map[common.Address]uint8, len(rows))
+	for _, row := range rows {
+		address, err := c.normalizer.Parse(row.Address)
+		if err != nil {
+			return fmt.Errorf("parse stored address %q: %w", row.Address, err)
+		}
+		types[address] = row.AddressType
+	}
+
+	c.mu.Lock()
+	c.full, c.types = true, types
+	c.mu.Unlock()
+	return nil
+}
+
+// refresh reloads the cache after a write, logging rather than failing the
+// already-successful write. On failure the cache drops to per-query mode so
+// stale results are never served.
+func (c *cachedAddressesDB) refresh() {
+	if err := c.reload(); err != nil {
+		log.Error("failed to refresh address cache", "err", err)
+		c.mu.Lock()
+		c.full, c.types = false, nil
+		c.mu.Unlock()
+	}
+}
+
+func (c *cachedAddressesDB) AddressExist(address *common.Address) (bool, uint8) {
+	c.mu.RLock()
+	if c.full {
+		addressType, ok := c.types[*address]
+		c.mu.RUnlock()
+		return ok, addressType
+	}
+	c.mu.RUnlock()
+	return c.AddressesDB.AddressExist(address)
+}
+
+func (c *cachedAddressesDB) BatchAddressExist(addresses []common.Address) (map[common.Address]uint8, error) {
+	c.mu.RLock()
+	if c.full {
+		tracked := make(map[common.Address]uint8)
+		for _, address := range addresses {
+			if addressType, ok := c.types[address]; ok {
+				tracked[address] = addressType
+			}
+		}
+		c.mu.RUnlock()
+		return tracked, nil
+	}
+	c.mu.RUnlock()
+	return c.AddressesDB.BatchAddressExist(addresses)
+}
+
+func (c *cachedAddressesDB) StoreAddresses(addressList []Addresses) error {
+	if err := c.AddressesDB.StoreAddresses(addressList); err != nil {
+		return err
+	}
+	c.refresh()
+	return nil
+}
+
+func (c *cachedAddressesDB) UpsertAddresses(addressList []Addresses) error {
+	if err := c.AddressesDB.UpsertAddresses(addressList); err != nil {
+		return err
+	}
+	c.refresh()
+	return nil
+}
+
+func (c *cachedAddressesDB) CopyAddresses(ctx context.Context, addressList []Addresses) (int64, error) {
+	copied, err := c.AddressesDB.CopyAddresses(ctx, addressList)
+	if err != nil {
+		return copied, err
+	}
+	c.refresh()
+	return copied, nil
+}
+
+func (c *cachedAddressesDB) ImportAddresses(ctx context.Context, addressList []Addresses, skipInvalid bool) (AddressImportSummary, error) {
+	summary, err := c.AddressesDB.ImportAddresses(ctx, addressList, skipInvalid)
+	if err != nil {
+		return summary, err
+	}
+	c.refresh()
+	return summary, nil
+}
+
+func (c *cachedAddressesDB) DeleteAddress(guid uuid.UUID) error {
+	if err := c.AddressesDB.DeleteAddress(guid); err != nil {
+		return err
+	}
+	c.refresh()
+	return nil
+}
+
+func (c *cachedAddressesDB) ValidateStoredAddresses(fix bool, onIssue func(AddressIssue)) (int64, error) {
+	checked, err := c.AddressesDB.ValidateStoredAddresses(fix, onIssue)
+	if fix {
+		c.refresh()
+	}
+	return checked, err
+}
+
+// writeTrackingAddressesDB records whether any write went through it, so
+// DB.Transaction only reloads the address cache after transactions that
+// changed addresses.
+type writeTrackingAddressesDB struct {
+	AddressesDB
+	wrote bool
+}
+
+func (w *writeTrackingAddressesDB) StoreAddresses(addressList []Addresses) error {
+	w.wrote = true
+	return w.AddressesDB.StoreAddresses(addressList)
+}
+
+func (w *writeTrackingAddressesDB) UpsertAddresses(addressList []Addresses) error {
+	w.wrote = true
+	return w.AddressesDB.UpsertAddresses(addressList)
+}
+
+func (w *writeTrackingAddressesDB) CopyAddresses(ctx context.Context, addressList []Addresses) (int64, error) {
+	w.wrote = true
+	return w.AddressesDB.CopyAddresses(ctx, addressList)
+}
+
+func (w *writeTrackingAddressesDB) ImportAddresses(ctx context.Context, addressList []Addresses, skipInvalid bool) (AddressImportSummary, error) {
+	w.wrote = true
+	return w.AddressesDB.ImportAddresses(ctx, addressList, skipInvalid)
+}
+
+func (w *writeTrackingAddressesDB) DeleteAddress(guid uuid.UUID) error {
+	w.wrote = true
+	return w.AddressesDB.DeleteAddress(guid)
+}
+
+func (w *writeTrackingAddressesDB) ValidateStoredAddresses(fix bool, onIssue func(AddressIssue)) (int64, error) {
+	w.wrote = w.wrote || fix
+	return w.AddressesDB.ValidateStoredAddresses(fix, onIssue)
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// expectCacheReload expects the queries of a full address cache reload
+// returning addresses as hot wallets.
+func expectCacheReload(mock sqlmock.Sqlmock, addresses ...common.Address) {
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "addresses"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(len(addresses)))
+	rows := sqlmock.NewRows([]string{"address", "address_type"})
+	for _, address := range addresses {
+		rows.AddRow(EVMAddressNormalizer{}.Normalize(address), AddressTypeHot)
+	}
+	mock.ExpectQuery(`SELECT "address","address_type" FROM "addresses"`).WillReturnRows(rows)
+}
+
+func TestTransactionKeepsAddressCache(t *testing.T) {
+	db, mock := newMockDB(t)
+	hot := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	stored := newTestAddress(t, AddressTypeHot)
+	other := stored.Address
+	expectCacheReload(mock, hot)
+	if err := db.EnableAddressCache(10); err != nil {
+		t.Fatalf("EnableAddressCache: %v", err)
+	}
+
+	// A scan round: a transaction that doesn't touch addresses, followed
+	// by existence checks. None of it may query the addresses table.
+	for range 3 {
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+		if err := db.Transaction(func(*DB) error { return nil }); err != nil {
+			t.Fatalf("Transaction: %v", err)
+		}
+		tracked, err := db.Addresses.BatchAddressExist([]common.Address{hot, other})
+		if err != nil {
+			t.Fatalf("BatchAddressExist: %v", err)
+		}
+		if len(tracked) != 1 || tracked[hot] != AddressTypeHot {
+			t.Fatalf("BatchAddressExist = %v, want only %s as hot wallet", tracked, hot)
+		}
+		if ok, _ := db.Addresses.AddressExist(&other); ok {
+			t.Fatalf("AddressExist(%s) = true, want false", other)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction that stores an address reloads the cache on commit.
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "addresses"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectCacheReload(mock, hot, other)
+	err := db.Transaction(func(tx *DB) error {
+		return tx.Addresses.StoreAddresses([]Addresses{stored})
+	})
+	if err != nil {
+		t.Fatalf("Transaction: %v", err)
+	}
+	if ok, addressType := db.Addresses.AddressExist(&other); !ok || addressType != AddressTypeHot {
+		t.Errorf("AddressExist(%s) = %t, %d after store, want true, %d", other, ok, addressType, AddressTypeHot)
+	}
+}
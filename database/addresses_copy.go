@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// addressesCopyColumns lists the columns written by CopyAddresses, in the
+// order produced by addressCopyRow.
+var addressesCopyColumns = []string{"guid", "address", "address_type", "public_key", "timestamp", "updated_at", "priority", "first_seen_block"}
+
+// CopyAddresses bulk-loads addresses with Postgres COPY FROM STDIN via the pgx
+// driver, bypassing GORM. It is intended for very large imports where batched
+// INSERTs are too slow.
+//
+// Addresses are encoded with the AddressNormalizer, which for EVM chains is
+// exactly what the bytes serializer writes (lowercase 0x hex), so rows are
+// indistinguishable from those written by StoreAddresses.
+// COPY fails on the first duplicate address; deduplicate beforehand or run a
+// cleanup pass afterwards. It must not be called inside Transaction.
+//
+// It returns the number of rows copied.
+func (db *addressesDB) CopyAddresses(ctx context.Context, addressList []Addresses) (int64, error) {
+	if err := validateAddresses(addressList); err != nil {
+		return 0, err
+	}
+	return db.copyAddresses(ctx, addressList)
+}
+
+// AddressImportSummary is the outcome of ImportAddresses.
+type AddressImportSummary struct {
+	// Imported is the number of rows copied.
+	Imported int64
+	// Skipped lists the entries left out because they failed validation, in
+	// input order. Their Index is the position in the imported list.
+	Skipped []*AddressValidationError
+}
+
+// ImportAddresses bulk-loads addresses like CopyAddresses. With skipInvalid
+// unset it is strict and imports nothing if any entry is invalid. With
+// skipInvalid set, entries that fail validation are skipped and reported in
+// the summary while the valid ones are imported, which makes importing large,
+// slightly dirty datasets practical.
+func (db *addressesDB) ImportAddresses(ctx context.Context, addressList []Addresses, skipInvalid bool) (AddressImportSummary, error) {
+	if !skipInvalid {
+		copied, err := db.CopyAddresses(ctx, addressList)
+		return AddressImportSummary{Imported: copied}, err
+	}
+	valid, skipped := partitionAddresses(addressList)
+	summary := AddressImportSummary{Skipped: skipped}
+	if len(valid) == 0 {
+		return summary, nil
+	}
+	copied, err := db.copyAddresses(ctx, valid)
+	summary.Imported = copied
+	return summary, err
+}
+
+// copyAddresses runs the COPY of CopyAddresses without validating the
+// entries.
+func (db *addressesDB) copyAddresses(ctx context.Context, addressList []Addresses) (int64, error) {
+	sqlDB, err := db.gorm.DB()
+	if err != nil {
+		return 0, fmt.Errorf("copy addresses needs a pooled connection: %w", err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	now := start.Unix()
+	var copied int64
+	err = conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection type: %T", driverConn)
+		}
+		rows := pgx.CopyFromSlice(len(addressList), func(i int) ([]any, error) {
+			return addressCopyRow(&addressList[i], db.normalizer, now), nil
+		})
+		copied, err = stdConn.Conn().CopyFrom(ctx, pgx.Identifier{"addresses"}, addressesCopyColumns, rows)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy addresses: %w", err)
+	}
+
+	elapsed := time.Since(start)
+	log.Info("copied addresses", "rows", copied, "duration", elapsed, "rows_per_sec", int64(float64(copied)/elapsed.Seconds()))
+	return copied, nil
+}
+
+// addressCopyRow encodes an address row for COPY. A missing GUID and
+// UpdatedAt are filled in the same way the BeforeCreate hook and GORM's
+// autoUpdateTime do on insert.
+func addressCopyRow(a *Addresses, normalizer AddressNormalizer, now int64) []any {
+	if a.GUID == uuid.Nil {
+		a.GUID = NewGUID()
+	}
+	var firstSeen any
+	if a.FirstSeenBlock != nil {
+		firstSeen = pgtype.Numeric{Int: a.FirstSeenBlock, Valid: true}
+	}
+	updatedAt := a.UpdatedAt
+	if updatedAt == 0 {
+		updatedAt = now
+	}
+	return []any{
+		a.GUID.String(),
+		normalizer.Normalize(a.Address),
+		int16(a.AddressType),
+		a.PublicKey,
+		a.Timestamp,
+		updatedAt,
+		a.Priority,
+		firstSeen,
+	}
+}
@@ -0,0 +1,111 @@
+package database_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+// newAddresses returns n user address rows with fresh keys.
+func newAddresses(t testing.TB, n int) []database.Addresses {
+	t.Helper()
+	addresses := make([]database.Addresses, n)
+	for i := range addresses {
+		addresses[i] = newAddress(t, database.AddressTypeUser)
+	}
+	return addresses
+}
+
+func TestCopyAddresses(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	addresses := newAddresses(t, 20_000)
+	copied, err := db.Addresses.CopyAddresses(context.Background(), addresses)
+	if err != nil {
+		t.Fatalf("CopyAddresses: %v", err)
+	}
+	if copied != int64(len(addresses)) {
+		t.Fatalf("CopyAddresses copied %d rows, want %d", copied, len(addresses))
+	}
+	count, err := db.Addresses.CountAddresses()
+	if err != nil {
+		t.Fatalf("CountAddresses: %v", err)
+	}
+	if count != int64(len(addresses)) {
+		t.Errorf("%d addresses stored, want %d", count, len(addresses))
+	}
+
+	// Copied rows are found by the normal lookups.
+	for _, a := range []database.Addresses{addresses[0], addresses[len(addresses)-1]} {
+		stored, err := db.Addresses.QueryAddressByGUID(a.GUID)
+		if err != nil {
+			t.Fatalf("QueryAddressByGUID: %v", err)
+		}
+		if stored.Address != a.Address || stored.PublicKey != a.PublicKey {
+			t.Errorf("stored %s, %s, want %s, %s", stored.Address, stored.PublicKey, a.Address, a.PublicKey)
+		}
+		if ok, _ := db.Addresses.AddressExist(&a.Address); !ok {
+			t.Errorf("AddressExist(%s) = false after copy", a.Address)
+		}
+	}
+
+	// COPY fails on a duplicate address.
+	if _, err := db.Addresses.CopyAddresses(context.Background(), addresses[:1]); err == nil {
+		t.Error("CopyAddresses accepted a duplicate address")
+	}
+}
+
+func BenchmarkCopyAddresses(b *testing.B) {
+	db, cfg := dbtest.NewDB(b)
+	addresses := newAddresses(b, 100_000)
+	b.ResetTimer()
+	for range b.N {
+		b.StopTimer()
+		batch := make([]database.Addresses, len(addresses))
+		copy(batch, addresses)
+		dbtest.Exec(b, cfg, "TRUNCATE addresses")
+		b.StartTimer()
+		if _, err := db.Addresses.CopyAddresses(context.Background(), batch); err != nil {
+			b.Fatalf("CopyAddresses: %v", err)
+		}
+	}
+	b.ReportMetric(float64(len(addresses)*b.N)/b.Elapsed().Seconds(), "rows/s")
+}
+
+func TestImportAddressesSkipInvalid(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	addresses := newAddresses(t, 5)
+	addresses[1].PublicKey = addresses[0].PublicKey // another address's key
+	addresses[3].PublicKey = ""
+
+	// Strict mode imports nothing.
+	if _, err := db.Addresses.ImportAddresses(context.Background(), addresses, false); err == nil {
+		t.Fatal("strict ImportAddresses accepted invalid public keys")
+	}
+	if count, err := db.Addresses.CountAddresses(); err != nil || count != 0 {
+		t.Fatalf("%d addresses stored after a rejected strict import (err %v), want 0", count, err)
+	}
+
+	summary, err := db.Addresses.ImportAddresses(context.Background(), addresses, true)
+	if err != nil {
+		t.Fatalf("ImportAddresses: %v", err)
+	}
+	if summary.Imported != 3 {
+		t.Errorf("imported %d addresses, want 3", summary.Imported)
+	}
+	var skipped []int
+	for _, s := range summary.Skipped {
+		skipped = append(skipped, s.Index)
+	}
+	if !slices.Equal(skipped, []int{1, 3}) {
+		t.Errorf("skipped entries %v, want [1 3]", skipped)
+	}
+	for i, a := range addresses {
+		ok, _ := db.Addresses.AddressExist(&a.Address)
+		if want := i != 1 && i != 3; ok != want {
+			t.Errorf("entry %d stored = %t, want %t", i, ok, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAddressCopyRow(t *testing.T) {
+	a := newTestAddress(t, AddressTypeHot)
+	a.Timestamp = 42
+	a.Priority = 3
+	row := addressCopyRow(&a, EVMAddressNormalizer{}, 1_000)
+
+	if a.GUID == uuid.Nil {
+		t.Fatal("addressCopyRow left the GUID unset")
+	}
+	// The address must be encoded exactly as the bytes serializer stores it.
+	want := []any{a.GUID.String(), EVMAddressNormalizer{}.Normalize(a.Address), int16(AddressTypeHot), a.PublicKey, int64(42), int64(1_000), 3, nil}
+	if len(row) != len(addressesCopyColumns) {
+		t.Fatalf("row has %d values for %d columns", len(row), len(addressesCopyColumns))
+	}
+	for i := range want {
+		if row[i] != want[i] {
+			t.Errorf("column %s = %#v, want %#v", addressesCopyColumns[i], row[i], want[i])
+		}
+	}
+
+	// Existing values are kept.
+	guid := a.GUID
+	a.UpdatedAt = 7
+	a.FirstSeenBlock = big.NewInt(9)
+	row = addressCopyRow(&a, EVMAddressNormalizer{}, 1_000)
+	if row[0] != guid.String() || row[5] != int64(7) || row[7] == nil {
+		t.Errorf("addressCopyRow overwrote set values: %v", row)
+	}
+}
@@ -0,0 +1,359 @@
+package database_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"maps"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+// newAddress returns an address row of the given type with a freshly
+// generated public key.
+func newAddress(t testing.TB, addressType uint8) database.Addresses {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return database.Addresses{
+		Address:     crypto.PubkeyToAddress(key.PublicKey),
+		AddressType: addressType,
+		PublicKey:   hex.EncodeToString(crypto.FromECDSAPub(&key.PublicKey)),
+		Timestamp:   time.Now().Unix(),
+	}
+}
+
+func TestUpsertAddresses(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	live := newAddress(t, database.AddressTypeUser)
+	deleted := newAddress(t, database.AddressTypeUser)
+	if err := db.Addresses.StoreAddresses([]database.Addresses{live, deleted}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+	stored, err := db.Addresses.QueryAddressesByToAddress(&deleted.Address)
+	if err != nil {
+		t.Fatalf("QueryAddressesByToAddress: %v", err)
+	}
+	if err := db.Addresses.DeleteAddress(stored.GUID); err != nil {
+		t.Fatalf("DeleteAddress: %v", err)
+	}
+
+	live.AddressType = database.AddressTypeHot
+	deleted.AddressType = database.AddressTypeHot
+	if err := db.Addresses.UpsertAddresses([]database.Addresses{live, deleted}); err != nil {
+		t.Fatalf("UpsertAddresses: %v", err)
+	}
+	for name, a := range map[string]database.Addresses{"live": live, "soft-deleted": deleted} {
+		ok, addressType := db.Addresses.AddressExist(&a.Address)
+		if !ok || addressType != database.AddressTypeHot {
+			t.Errorf("%s address after upsert: exists %t, type %d, want true, %d", name, ok, addressType, database.AddressTypeHot)
+		}
+	}
+}
+
+func TestSelectCollectionWalletLowestBalance(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	rich, poor, empty := newAddress(t, database.AddressTypeHot), newAddress(t, database.AddressTypeHot), newAddress(t, database.AddressTypeHot)
+	token := newAddress(t, database.AddressTypeUser).Address
+	if err := db.Addresses.StoreAddresses([]database.Addresses{rich, poor}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+	for address, balance := range map[*database.Addresses]int64{&rich: 100, &poor: 5} {
+		if err := db.Balances.UpdateBalance(address.Address, token, big.NewInt(balance)); err != nil {
+			t.Fatalf("UpdateBalance: %v", err)
+		}
+	}
+	// A wallet that holds another token but none of this one.
+	if err := db.Balances.UpdateBalance(rich.Address, empty.Address, big.NewInt(1)); err != nil {
+		t.Fatalf("UpdateBalance: %v", err)
+	}
+
+	wallet, err := db.Addresses.SelectCollectionWallet(database.CollectionStrategyLowestBalance, token)
+	if err != nil {
+		t.Fatalf("SelectCollectionWallet: %v", err)
+	}
+	if wallet.Address != poor.Address {
+		t.Errorf("selected %s, want the lowest balance wallet %s", wallet.Address, poor.Address)
+	}
+
+	// A wallet without a balance row for the token counts as empty.
+	if err := db.Addresses.StoreAddresses([]database.Addresses{empty}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+	wallet, err = db.Addresses.SelectCollectionWallet(database.CollectionStrategyLowestBalance, token)
+	if err != nil {
+		t.Fatalf("SelectCollectionWallet: %v", err)
+	}
+	if wallet.Address != empty.Address {
+		t.Errorf("selected %s, want the wallet without a balance %s", wallet.Address, empty.Address)
+	}
+}
+
+func TestSelectCollectionWalletLowestBalanceCountsInFlight(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	rich, poor := newAddress(t, database.AddressTypeHot), newAddress(t, database.AddressTypeHot)
+	user := newAddress(t, database.AddressTypeUser)
+	token := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	if err := db.Addresses.StoreAddresses([]database.Addresses{rich, poor, user}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+	for address, balance := range map[common.Address]int64{rich.Address: 100, poor.Address: 5} {
+		if err := db.Balances.UpdateBalance(address, token, big.NewInt(balance)); err != nil {
+			t.Fatalf("UpdateBalance: %v", err)
+		}
+	}
+	collection := func(to common.Address, amount int64, status uint8) database.Withdrawals {
+		return database.Withdrawals{FromAddress: user.Address, ToAddress: to, TokenAddress: token, Amount: big.NewInt(amount), Status: status, Type: database.WithdrawalTypeCollection}
+	}
+	err := db.Withdrawals.StoreWithdrawals([]database.Withdrawals{
+		// Mined collections are already credited through their sweep, and
+		// confirmed or failed ones are done.
+		collection(poor.Address, 1_000, database.WithdrawalStatusMined),
+		collection(poor.Address, 1_000, database.WithdrawalStatusConfirmed),
+		collection(poor.Address, 1_000, database.WithdrawalStatusFailed),
+		// A withdrawal to the wallet is not a collection.
+		{FromAddress: user.Address, ToAddress: poor.Address, TokenAddress: token, Amount: big.NewInt(1_000), Status: database.WithdrawalStatusSent},
+	})
+	if err != nil {
+		t.Fatalf("StoreWithdrawals: %v", err)
+	}
+	wallet, err := db.Addresses.SelectCollectionWallet(database.CollectionStrategyLowestBalance, token)
+	if err != nil {
+		t.Fatalf("SelectCollectionWallet: %v", err)
+	}
+	if wallet.Address != poor.Address {
+		t.Errorf("selected %s, want %s, whose pending transfers are settled or not collections", wallet.Address, poor.Address)
+	}
+
+	// 5 + 60 + 40 in flight now outweighs 100.
+	err = db.Withdrawals.StoreWithdrawals([]database.Withdrawals{
+		collection(poor.Address, 60, database.WithdrawalStatusUnsigned),
+		collection(poor.Address, 40, database.WithdrawalStatusSent),
+	})
+	if err != nil {
+		t.Fatalf("StoreWithdrawals: %v", err)
+	}
+	wallet, err = db.Addresses.SelectCollectionWallet(database.CollectionStrategyLowestBalance, token)
+	if err != nil {
+		t.Fatalf("SelectCollectionWallet: %v", err)
+	}
+	if wallet.Address != rich.Address {
+		t.Errorf("selected %s, want %s once collections to %s are in flight", wallet.Address, rich.Address, poor.Address)
+	}
+}
+
+func TestQueryAddressesUpdatedSince(t *testing.T) {
+	db, cfg := dbtest.NewDB(t)
+	stale, updated := newAddress(t, database.AddressTypeUser), newAddress(t, database.AddressTypeUser)
+	if err := db.Addresses.StoreAddresses([]database.Addresses{stale, updated}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+	// Backdate both rows so only the update below is recent.
+	dbtest.Exec(t, cfg, "UPDATE addresses SET updated_at = 1")
+	since := time.Now().Unix()
+
+	updated.AddressType = database.AddressTypeHot
+	if err := db.Addresses.UpsertAddresses([]database.Addresses{updated}); err != nil {
+		t.Fatalf("UpsertAddresses: %v", err)
+	}
+	changed, err := db.Addresses.QueryAddressesUpdatedSince(since)
+	if err != nil {
+		t.Fatalf("QueryAddressesUpdatedSince: %v", err)
+	}
+	if len(changed) != 1 || changed[0].Address != updated.Address || changed[0].AddressType != database.AddressTypeHot {
+		t.Fatalf("QueryAddressesUpdatedSince(%d) = %v, want only the updated address %s", since, changed, updated.Address)
+	}
+
+	all, err := db.Addresses.QueryAddressesUpdatedSince(0)
+	if err != nil {
+		t.Fatalf("QueryAddressesUpdatedSince: %v", err)
+	}
+	if len(all) != 2 || all[0].Address != stale.Address {
+		t.Errorf("QueryAddressesUpdatedSince(0) returned %d addresses, want 2 with the stale one first", len(all))
+	}
+}
+
+func TestQueryAddressesByGUIDs(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	first, second := newAddress(t, database.AddressTypeUser), newAddress(t, database.AddressTypeHot)
+	first.GUID, second.GUID = uuid.New(), uuid.New()
+	if err := db.Addresses.StoreAddresses([]database.Addresses{first, second}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+
+	found, err := db.Addresses.QueryAddressesByGUIDs([]uuid.UUID{uuid.New(), second.GUID, uuid.New(), first.GUID})
+	if err != nil {
+		t.Fatalf("QueryAddressesByGUIDs: %v", err)
+	}
+	got := make(map[uuid.UUID]common.Address)
+	for _, a := range found {
+		got[a.GUID] = a.Address
+	}
+	want := map[uuid.UUID]common.Address{first.GUID: first.Address, second.GUID: second.Address}
+	if !maps.Equal(got, want) {
+		t.Errorf("QueryAddressesByGUIDs = %v, want %v", got, want)
+	}
+
+	if found, err := db.Addresses.QueryAddressesByGUIDs(nil); err != nil || len(found) != 0 {
+		t.Errorf("QueryAddressesByGUIDs(nil) = %v, %v, want no addresses", found, err)
+	}
+}
+
+func TestValidateStoredAddresses(t *testing.T) {
+	db, cfg := dbtest.NewDB(t)
+	good, bad := newAddress(t, database.AddressTypeUser), newAddress(t, database.AddressTypeUser)
+	bad.GUID = uuid.New()
+	if err := db.Addresses.StoreAddresses([]database.Addresses{good, bad}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+	// Store bad the way the old bug did: checksummed rather than lowercase.
+	dbtest.Exec(t, cfg, "UPDATE addresses SET address = $1 WHERE address = $2", bad.Address.Hex(), strings.ToLower(bad.Address.Hex()))
+	if ok, _ := db.Addresses.AddressExist(&bad.Address); ok {
+		t.Fatal("mis-stored address found before the fix; the test does not reproduce the bug")
+	}
+
+	for _, fix := range []bool{false, true} {
+		var issues []database.AddressIssue
+		checked, err := db.Addresses.ValidateStoredAddresses(fix, func(issue database.AddressIssue) {
+			issues = append(issues, issue)
+		})
+		if err != nil {
+			t.Fatalf("ValidateStoredAddresses(%t): %v", fix, err)
+		}
+		if checked != 2 {
+			t.Errorf("ValidateStoredAddresses(%t) checked %d rows, want 2", fix, checked)
+		}
+		want := database.AddressIssue{GUID: bad.GUID.String(), Stored: bad.Address.Hex(), Canonical: strings.ToLower(bad.Address.Hex()), Fixed: fix}
+		if len(issues) != 1 || issues[0] != want {
+			t.Fatalf("ValidateStoredAddresses(%t) reported %+v, want [%+v]", fix, issues, want)
+		}
+	}
+
+	if ok, _ := db.Addresses.AddressExist(&bad.Address); !ok {
+		t.Error("fixed address not found")
+	}
+	issues := 0
+	if _, err := db.Addresses.ValidateStoredAddresses(false, func(database.AddressIssue) { issues++ }); err != nil || issues != 0 {
+		t.Errorf("ValidateStoredAddresses after fix: %d issues, %v", issues, err)
+	}
+}
+
+func TestQueryUnseenAddresses(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	seen := newAddress(t, database.AddressTypeUser)
+	seen.FirstSeenBlock = big.NewInt(12)
+	first, second := newAddress(t, database.AddressTypeUser), newAddress(t, database.AddressTypeHot)
+	first.Timestamp, second.Timestamp = 1, 2
+	if err := db.Addresses.StoreAddresses([]database.Addresses{second, seen, first}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+
+	unseen, err := db.Addresses.QueryUnseenAddresses()
+	if err != nil {
+		t.Fatalf("QueryUnseenAddresses: %v", err)
+	}
+	var got []common.Address
+	for _, a := range unseen {
+		got = append(got, a.Address)
+	}
+	if want := []common.Address{first.Address, second.Address}; !slices.Equal(got, want) {
+		t.Errorf("QueryUnseenAddresses = %v, want %v", got, want)
+	}
+}
+
+func TestEnsLookup(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	never, stale, fresh := newAddress(t, database.AddressTypeUser), newAddress(t, database.AddressTypeUser), newAddress(t, database.AddressTypeHot)
+	if err := db.Addresses.StoreAddresses([]database.Addresses{fresh, stale, never}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+	if err := db.Addresses.UpdateEnsName(stale.Address, "stale.eth", 100); err != nil {
+		t.Fatalf("UpdateEnsName: %v", err)
+	}
+	if err := db.Addresses.UpdateEnsName(fresh.Address, "fresh.eth", 300); err != nil {
+		t.Fatalf("UpdateEnsName: %v", err)
+	}
+
+	due, err := db.Addresses.QueryAddressesForEnsLookup(200, 10)
+	if err != nil {
+		t.Fatalf("QueryAddressesForEnsLookup: %v", err)
+	}
+	var got []common.Address
+	for _, a := range due {
+		got = append(got, a.Address)
+	}
+	// Addresses never looked up come first, then the stalest.
+	if want := []common.Address{never.Address, stale.Address}; !slices.Equal(got, want) {
+		t.Errorf("QueryAddressesForEnsLookup = %v, want %v", got, want)
+	}
+	if due[1].EnsName != "stale.eth" || due[1].EnsResolvedAt == nil || *due[1].EnsResolvedAt != 100 {
+		t.Errorf("stale address has name %q looked up at %v, want stale.eth at 100", due[1].EnsName, due[1].EnsResolvedAt)
+	}
+	if due, err := db.Addresses.QueryAddressesForEnsLookup(0, 10); err != nil || len(due) != 1 {
+		t.Errorf("QueryAddressesForEnsLookup(0) = %d addresses, %v, want only the one never looked up", len(due), err)
+	}
+}
+
+func TestChecksummedAddressLookup(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	key, err := crypto.HexToECDSA("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	if err != nil {
+		t.Fatalf("parse key: %v", err)
+	}
+	// 0x2c7536E3605D9C16a7a3D7b1898e529396a65c23, with mixed case letters.
+	stored := database.Addresses{
+		Address:     crypto.PubkeyToAddress(key.PublicKey),
+		AddressType: database.AddressTypeHot,
+		PublicKey:   hex.EncodeToString(crypto.FromECDSAPub(&key.PublicKey)),
+		Timestamp:   time.Now().Unix(),
+	}
+	if err := db.Addresses.StoreAddresses([]database.Addresses{stored}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+	for _, form := range []string{stored.Address.Hex(), strings.ToLower(stored.Address.Hex())} {
+		address := common.HexToAddress(form)
+		if ok, addressType := db.Addresses.AddressExist(&address); !ok || addressType != database.AddressTypeHot {
+			t.Errorf("AddressExist(%s) = %t, %d, want true, %d", form, ok, addressType, database.AddressTypeHot)
+		}
+		tracked, err := db.Addresses.BatchAddressExist([]common.Address{address})
+		if err != nil {
+			t.Fatalf("BatchAddressExist: %v", err)
+		}
+		if tracked[stored.Address] != database.AddressTypeHot {
+			t.Errorf("BatchAddressExist(%s) = %v, want %s as hot wallet", form, tracked, stored.Address)
+		}
+	}
+}
+
+func TestQueryAddressByGUID(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	stored := newAddress(t, database.AddressTypeHot)
+	stored.GUID = uuid.New()
+	if err := db.Addresses.StoreAddresses([]database.Addresses{stored}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+
+	found, err := db.Addresses.QueryAddressByGUID(stored.GUID)
+	if err != nil {
+		t.Fatalf("QueryAddressByGUID: %v", err)
+	}
+	if found.GUID != stored.GUID || found.Address != stored.Address || found.AddressType != stored.AddressType ||
+		found.PublicKey != stored.PublicKey || found.Timestamp != stored.Timestamp {
+		t.Errorf("QueryAddressByGUID = %+v, want %+v", found, stored)
+	}
+	if found, err := db.Addresses.QueryAddressByGUID(uuid.New()); found != nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("QueryAddressByGUID(unknown) = %v, %v, want nil, %v", found, err, gorm.ErrRecordNotFound)
+	}
+}
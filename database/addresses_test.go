@@ -0,0 +1,262 @@
+package database
+
+import (
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// newTestAddress returns an address row of the given type with a freshly
+// generated public key.
+func newTestAddress(t *testing.T, addressType uint8) Addresses {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return Addresses{
+		Address:     crypto.PubkeyToAddress(key.PublicKey),
+		AddressType: addressType,
+		PublicKey:   hex.EncodeToString(crypto.FromECDSAPub(&key.PublicKey)),
+	}
+}
+
+func TestUpsertAddressesRestoresDeleted(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`ON CONFLICT \("address"\) DO UPDATE SET .*"deleted_at"="excluded"."deleted_at"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := db.Addresses.UpsertAddresses([]Addresses{newTestAddress(t, AddressTypeUser)}); err != nil {
+		t.Fatalf("UpsertAddresses: %v", err)
+	}
+}
+
+func TestSelectCollectionWalletLowestBalance(t *testing.T) {
+	db, mock := newMockDB(t)
+	low, high := newTestAddress(t, AddressTypeHot), newTestAddress(t, AddressTypeHot)
+	token := newTestAddress(t, AddressTypeUser).Address
+	key := EVMAddressNormalizer{}.Normalize(token)
+	// Unsigned, signed and sent collections count as in flight; mined ones
+	// are already part of the balance.
+	mock.ExpectQuery(`SELECT addresses\.\* FROM "addresses" LEFT JOIN balances ON balances.address = addresses.address AND balances.token_address = \$1 `+
+		`LEFT JOIN \(SELECT to_address, SUM\(amount\) AS amount FROM withdrawals WHERE type = \$2 AND token_address = \$3 AND status IN \(\$4,\$5,\$6\) GROUP BY to_address\) AS in_flight ON in_flight.to_address = addresses.address `+
+		`WHERE "address_type" = \$7 AND "addresses"."deleted_at" IS NULL ORDER BY COALESCE\(balances.balance, 0\) \+ COALESCE\(in_flight.amount, 0\) asc`).
+		WithArgs(key, WithdrawalTypeCollection, key, WithdrawalStatusUnsigned, WithdrawalStatusSigned, WithdrawalStatusSent, AddressTypeHot).
+		WillReturnRows(sqlmock.NewRows([]string{"address", "address_type"}).
+			AddRow(EVMAddressNormalizer{}.Normalize(low.Address), AddressTypeHot).
+			AddRow(EVMAddressNormalizer{}.Normalize(high.Address), AddressTypeHot))
+
+	wallet, err := db.Addresses.SelectCollectionWallet(CollectionStrategyLowestBalance, token)
+	if err != nil {
+		t.Fatalf("SelectCollectionWallet: %v", err)
+	}
+	if wallet.Address != low.Address {
+		t.Errorf("selected %s, want %s", wallet.Address, low.Address)
+	}
+}
+
+func TestSelectCollectionWalletUnknownStrategy(t *testing.T) {
+	db, _ := newMockDB(t)
+	if _, err := db.Addresses.SelectCollectionWallet("largest-first", newTestAddress(t, AddressTypeUser).Address); err == nil {
+		t.Fatal("SelectCollectionWallet accepted an unknown strategy")
+	}
+}
+
+func TestQueryAddressesByGUIDsChunks(t *testing.T) {
+	db, mock := newMockDB(t)
+	guids := make([]uuid.UUID, inQueryChunkSize+1)
+	for i := range guids {
+		guids[i] = uuid.New()
+	}
+	found := newTestAddress(t, AddressTypeUser)
+	found.GUID = guids[len(guids)-1]
+	mock.ExpectQuery(`SELECT \* FROM "addresses" WHERE guid IN`).
+		WillReturnRows(sqlmock.NewRows([]string{"guid"}))
+	mock.ExpectQuery(`SELECT \* FROM "addresses" WHERE guid IN \(\$1\)`).
+		WithArgs(found.GUID).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "address"}).AddRow(found.GUID, EVMAddressNormalizer{}.Normalize(found.Address)))
+
+	addresses, err := db.Addresses.QueryAddressesByGUIDs(guids)
+	if err != nil {
+		t.Fatalf("QueryAddressesByGUIDs: %v", err)
+	}
+	if len(addresses) != 1 || addresses[0].GUID != found.GUID || addresses[0].Address != found.Address {
+		t.Errorf("QueryAddressesByGUIDs = %v, want only %s", addresses, found.GUID)
+	}
+}
+
+func TestValidateStoredAddressesFix(t *testing.T) {
+	db, mock := newMockDB(t)
+	// With a single connection, fixing a row while the cursor is still open
+	// would wait for a free connection forever.
+	sqlDB, err := db.gorm.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	good, bad := newTestAddress(t, AddressTypeUser), newTestAddress(t, AddressTypeUser)
+	canonical := EVMAddressNormalizer{}.Normalize(bad.Address)
+	mock.ExpectQuery(`SELECT guid,address FROM "addresses" ORDER BY guid`).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "address"}).
+			AddRow("1", EVMAddressNormalizer{}.Normalize(good.Address)).
+			AddRow("2", bad.Address.Hex()).
+			AddRow("3", "not an address"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "addresses" SET "address"=\$1,"updated_at"=\$2 WHERE "guid" = \$3`).
+		WithArgs(canonical, sqlmock.AnyArg(), "2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var issues []AddressIssue
+	checked, err := db.Addresses.ValidateStoredAddresses(true, func(issue AddressIssue) {
+		issues = append(issues, issue)
+	})
+	if err != nil {
+		t.Fatalf("ValidateStoredAddresses: %v", err)
+	}
+	if checked != 3 {
+		t.Errorf("checked %d rows, want 3", checked)
+	}
+	want := []AddressIssue{
+		{GUID: "2", Stored: bad.Address.Hex(), Canonical: canonical, Fixed: true},
+		{GUID: "3", Stored: "not an address"},
+	}
+	if !slices.Equal(issues, want) {
+		t.Errorf("issues = %+v, want %+v", issues, want)
+	}
+}
+
+func TestQueryUnseenAddresses(t *testing.T) {
+	db, mock := newMockDB(t)
+	unseen := newTestAddress(t, AddressTypeUser)
+	mock.ExpectQuery(`SELECT \* FROM "addresses" WHERE first_seen_block IS NULL AND "addresses"."deleted_at" IS NULL ORDER BY timestamp asc`).
+		WillReturnRows(sqlmock.NewRows([]string{"address", "first_seen_block"}).
+			AddRow(EVMAddressNormalizer{}.Normalize(unseen.Address), nil))
+
+	addresses, err := db.Addresses.QueryUnseenAddresses()
+	if err != nil {
+		t.Fatalf("QueryUnseenAddresses: %v", err)
+	}
+	if len(addresses) != 1 || addresses[0].Address != unseen.Address || addresses[0].FirstSeenBlock != nil {
+		t.Errorf("QueryUnseenAddresses = %v, want only %s", addresses, unseen.Address)
+	}
+}
+
+func TestEnsLookupQueries(t *testing.T) {
+	db, mock := newMockDB(t)
+	address := newTestAddress(t, AddressTypeUser)
+	lower := EVMAddressNormalizer{}.Normalize(address.Address)
+	mock.ExpectQuery(`SELECT \* FROM "addresses" WHERE \(ens_resolved_at IS NULL OR ens_resolved_at < \$1\) AND "addresses"."deleted_at" IS NULL ORDER BY ens_resolved_at asc NULLS FIRST, guid asc LIMIT \$2`).
+		WithArgs(int64(1_000), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"address", "ens_resolved_at"}).AddRow(lower, nil))
+	addresses, err := db.Addresses.QueryAddressesForEnsLookup(1_000, 100)
+	if err != nil {
+		t.Fatalf("QueryAddressesForEnsLookup: %v", err)
+	}
+	if len(addresses) != 1 || addresses[0].Address != address.Address || addresses[0].EnsResolvedAt != nil {
+		t.Errorf("QueryAddressesForEnsLookup = %v, want only %s", addresses, address.Address)
+	}
+
+	// UpdatedAt is left alone.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "addresses" SET "ens_name"=\$1,"ens_resolved_at"=\$2 WHERE "address" = \$3`).
+		WithArgs("alice.eth", int64(2_000), lower).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := db.Addresses.UpdateEnsName(address.Address, "alice.eth", 2_000); err != nil {
+		t.Fatalf("UpdateEnsName: %v", err)
+	}
+}
+
+// checksummedAddress returns an address row for a fixed key whose address
+// has upper and lowercase letters in its checksummed form.
+func checksummedAddress(t *testing.T, addressType uint8) Addresses {
+	t.Helper()
+	key, err := crypto.HexToECDSA("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	if err != nil {
+		t.Fatalf("parse key: %v", err)
+	}
+	a := Addresses{
+		Address:     crypto.PubkeyToAddress(key.PublicKey),
+		AddressType: addressType,
+		PublicKey:   hex.EncodeToString(crypto.FromECDSAPub(&key.PublicKey)),
+	}
+	if got := a.Address.Hex(); got != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Fatalf("fixed key derives %s", got)
+	}
+	return a
+}
+
+func TestChecksummedAddressLookup(t *testing.T) {
+	db, mock := newMockDB(t)
+	stored := checksummedAddress(t, AddressTypeHot)
+	checksummed, lower := stored.Address.Hex(), strings.ToLower(stored.Address.Hex())
+
+	// Written lowercase, the way the bytes serializer encodes it.
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "addresses"`).
+		WithArgs(sqlmock.AnyArg(), lower, AddressTypeHot, stored.PublicKey, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := db.Addresses.StoreAddresses([]Addresses{stored}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+
+	// Looked up lowercase, whatever form the caller parsed.
+	for _, form := range []string{checksummed, lower} {
+		mock.ExpectQuery(`SELECT \* FROM "addresses" WHERE "address" = \$1`).
+			WithArgs(lower, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"address", "address_type"}).AddRow(lower, AddressTypeHot))
+		address := common.HexToAddress(form)
+		if ok, addressType := db.Addresses.AddressExist(&address); !ok || addressType != AddressTypeHot {
+			t.Errorf("AddressExist(%s) = %t, %d, want true, %d", form, ok, addressType, AddressTypeHot)
+		}
+	}
+}
+
+func TestQueryAddressByGUID(t *testing.T) {
+	db, mock := newMockDB(t)
+	stored := newTestAddress(t, AddressTypeUser)
+	stored.GUID = uuid.New()
+	stored.Timestamp = 1700000000
+	lower := EVMAddressNormalizer{}.Normalize(stored.Address)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "addresses"`).
+		WithArgs(stored.GUID.String(), lower, AddressTypeUser, stored.PublicKey, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := db.Addresses.StoreAddresses([]Addresses{stored}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "addresses" WHERE "guid" = \$1`).
+		WithArgs(stored.GUID.String(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "address", "address_type", "public_key", "timestamp"}).
+			AddRow(stored.GUID.String(), lower, AddressTypeUser, stored.PublicKey, stored.Timestamp))
+	found, err := db.Addresses.QueryAddressByGUID(stored.GUID)
+	if err != nil {
+		t.Fatalf("QueryAddressByGUID: %v", err)
+	}
+	if found.GUID != stored.GUID || found.Address != stored.Address || found.AddressType != stored.AddressType ||
+		found.PublicKey != stored.PublicKey || found.Timestamp != stored.Timestamp {
+		t.Errorf("QueryAddressByGUID = %+v, want %+v", found, stored)
+	}
+
+	missing := uuid.New()
+	mock.ExpectQuery(`SELECT \* FROM "addresses" WHERE "guid" = \$1`).
+		WithArgs(missing.String(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"guid"}))
+	if found, err := db.Addresses.QueryAddressByGUID(missing); found != nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("QueryAddressByGUID(unknown) = %v, %v, want nil, %v", found, err, gorm.ErrRecordNotFound)
+	}
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Accepted public key lengths in bytes: compressed, raw and uncompressed
+// secp256k1 keys.
+const (
+	compressedPublicKeyLen   = 33
+	rawPublicKeyLen          = 64
+	uncompressedPublicKeyLen = 65
+)
+
+// AddressValidationError identifies an address entry rejected before being
+// stored.
+type AddressValidationError struct {
+	// Index is the position of the entry in the stored list.
+	Index   int
+	Address common.Address
+	Reason  string
+}
+
+func (e *AddressValidationError) Error() string {
+	return fmt.Sprintf("invalid address entry %d (%s): %s", e.Index, e.Address.Hex(), e.Reason)
+}
+
+// DeriveAddress returns the Ethereum address of a secp256k1 public key given
+// as hex, with or without 0x prefix. The key may be compressed (33 bytes),
+// uncompressed (65 bytes) or raw, i.e. uncompressed without the 0x04 prefix
+// (64 bytes).
+func DeriveAddress(pubKeyHex string) (common.Address, error) {
+	key, err := hex.DecodeString(strings.TrimPrefix(pubKeyHex, "0x"))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("public key is not valid hex: %w", err)
+	}
+
+	var pubKey *ecdsa.PublicKey
+	switch len(key) {
+	case compressedPublicKeyLen:
+		pubKey, err = crypto.DecompressPubkey(key)
+	case rawPublicKeyLen:
+		pubKey, err = crypto.UnmarshalPubkey(append([]byte{0x04}, key...))
+	case uncompressedPublicKeyLen:
+		pubKey, err = crypto.UnmarshalPubkey(key)
+	default:
+		return common.Address{}, fmt.Errorf("public key has %d bytes, want %d, %d or %d", len(key), compressedPublicKeyLen, rawPublicKeyLen, uncompressedPublicKeyLen)
+	}
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid public key: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
+// validateAddresses rejects entries with the zero address, a missing or
+// undecodable public key, or a public key that does not derive the entry's
+// address.
+func validateAddresses(addressList []Addresses) error {
+	for i := range addressList {
+		if err := validateAddress(i, &addressList[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partitionAddresses splits addressList into the entries that pass
+// validateAddresses and the errors of those that don't, both in list order.
+func partitionAddresses(addressList []Addresses) ([]Addresses, []*AddressValidationError) {
+	valid := make([]Addresses, 0, len(addressList))
+	var invalid []*AddressValidationError
+	for i := range addressList {
+		if err := validateAddress(i, &addressList[i]); err != nil {
+			invalid = append(invalid, err)
+			continue
+		}
+		valid = append(valid, addressList[i])
+	}
+	return valid, invalid
+}
+
+// validateAddress checks the entry at index i, see validateAddresses.
+func validateAddress(i int, a *Addresses) *AddressValidationError {
+	if a.Address == (common.Address{}) {
+		return &AddressValidationError{Index: i, Address: a.Address, Reason: "zero address"}
+	}
+	if a.PublicKey == "" {
+		return &AddressValidationError{Index: i, Address: a.Address, Reason: "missing public key"}
+	}
+	derived, err := DeriveAddress(a.PublicKey)
+	if err != nil {
+		return &AddressValidationError{Index: i, Address: a.Address, Reason: err.Error()}
+	}
+	if derived != a.Address {
+		return &AddressValidationError{Index: i, Address: a.Address, Reason: fmt.Sprintf("public key derives %s", derived.Hex())}
+	}
+	return nil
+}
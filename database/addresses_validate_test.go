@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestValidateAddresses(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	uncompressed := crypto.FromECDSAPub(&key.PublicKey)
+	other := newTestAddress(t, AddressTypeUser)
+
+	tests := []struct {
+		name      string
+		address   common.Address
+		publicKey string
+		wantErr   bool
+	}{
+		{"uncompressed", address, hex.EncodeToString(uncompressed), false},
+		{"uncompressed with 0x prefix", address, "0x" + hex.EncodeToString(uncompressed), false},
+		{"compressed", address, hex.EncodeToString(crypto.CompressPubkey(&key.PublicKey)), false},
+		{"raw", address, hex.EncodeToString(uncompressed[1:]), false},
+		{"zero address", common.Address{}, hex.EncodeToString(uncompressed), true},
+		{"empty public key", address, "", true},
+		{"not hex", address, "not a public key", true},
+		{"wrong length", address, hex.EncodeToString(uncompressed[:20]), true},
+		{"not on the curve", address, "04" + hex.EncodeToString(make([]byte, 64)), true},
+		{"public key of another address", address, other.PublicKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid := newTestAddress(t, AddressTypeUser)
+			entry := Addresses{Address: tt.address, AddressType: AddressTypeUser, PublicKey: tt.publicKey}
+			err := validateAddresses([]Addresses{valid, entry})
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("validateAddresses: %v", err)
+				}
+				return
+			}
+			var validationErr *AddressValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("validateAddresses returned %v, want an AddressValidationError", err)
+			}
+			if validationErr.Index != 1 || validationErr.Address != tt.address {
+				t.Errorf("error identifies entry %d (%s), want 1 (%s)", validationErr.Index, validationErr.Address.Hex(), tt.address.Hex())
+			}
+		})
+	}
+}
+
+func TestStoreAddressesRejectsInvalidEntry(t *testing.T) {
+	// The mock expects no statements, so any query fails the test.
+	db, _ := newMockDB(t)
+	entry := newTestAddress(t, AddressTypeUser)
+	entry.PublicKey = ""
+	if err := db.Addresses.StoreAddresses([]Addresses{entry}); err == nil {
+		t.Fatal("StoreAddresses accepted an address without a public key")
+	}
+}
+
+func TestPartitionAddresses(t *testing.T) {
+	addresses := make([]Addresses, 6)
+	for i := range addresses {
+		addresses[i] = newTestAddress(t, AddressTypeUser)
+	}
+	addresses[1].PublicKey = addresses[0].PublicKey
+	addresses[3].PublicKey = ""
+	addresses[4].PublicKey = "not a public key"
+
+	valid, invalid := partitionAddresses(addresses)
+	if len(valid) != 3 || valid[0].Address != addresses[0].Address || valid[1].Address != addresses[2].Address || valid[2].Address != addresses[5].Address {
+		t.Errorf("valid entries = %v, want entries 0, 2 and 5", valid)
+	}
+	var indexes []int
+	for _, err := range invalid {
+		if err.Address != addresses[err.Index].Address {
+			t.Errorf("error for entry %d names %s, want %s", err.Index, err.Address, addresses[err.Index].Address)
+		}
+		indexes = append(indexes, err.Index)
+	}
+	if !slices.Equal(indexes, []int{1, 3, 4}) {
+		t.Errorf("invalid entries = %v, want 1, 3 and 4", indexes)
+	}
+}
+
+func TestImportAddressesStrictRejectsBatch(t *testing.T) {
+	// The mock expects no statements, so any query fails the test.
+	db, _ := newMockDB(t)
+	valid, invalid := newTestAddress(t, AddressTypeUser), newTestAddress(t, AddressTypeUser)
+	invalid.PublicKey = valid.PublicKey
+	summary, err := db.Addresses.ImportAddresses(context.Background(), []Addresses{valid, invalid}, false)
+	var validationErr *AddressValidationError
+	if !errors.As(err, &validationErr) || validationErr.Index != 1 {
+		t.Fatalf("strict ImportAddresses error = %v, want entry 1 rejected", err)
+	}
+	if summary.Imported != 0 || len(summary.Skipped) != 0 {
+		t.Errorf("strict ImportAddresses summary = %+v, want nothing imported or skipped", summary)
+	}
+}
+
+func TestImportAddressesSkipsOnlyInvalid(t *testing.T) {
+	db, _ := newMockDB(t)
+	invalid := newTestAddress(t, AddressTypeUser)
+	invalid.PublicKey = ""
+	// With nothing valid there is nothing to copy and no statement is run.
+	summary, err := db.Addresses.ImportAddresses(context.Background(), []Addresses{invalid}, true)
+	if err != nil {
+		t.Fatalf("ImportAddresses: %v", err)
+	}
+	if summary.Imported != 0 || len(summary.Skipped) != 1 || summary.Skipped[0].Reason != "missing public key" {
+		t.Errorf("ImportAddresses summary = %+v, want the entry skipped for its missing public key", summary)
+	}
+}
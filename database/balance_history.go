@@ -0,0 +1,139 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BalanceHistory 结构体是某个地址某种代币在某个区块高度上的余额快照。
+// 只在余额发生变化时写入，因此相邻两条快照之间余额保持不变。
+type BalanceHistory struct {
+	// GUID 是快照的唯一标识符，并且是主键。
+	GUID uuid.UUID `gorm:"primaryKey" json:"guid"`
+
+	// Address 是受管地址，TokenAddress 是代币合约地址，原生币为零地址。
+	Address      common.Address `json:"address" gorm:"serializer:bytes"`
+	TokenAddress common.Address `json:"tokenAddress" gorm:"serializer:bytes"`
+
+	// Balance 是该区块之后的余额（最小单位）。
+	Balance *big.Int `json:"balance" gorm:"serializer:u256"`
+
+	// BlockNumber 是余额变化所在的区块高度。
+	BlockNumber *big.Int `json:"blockNumber" gorm:"serializer:u256"`
+
+	// Timestamp 是所在区块的时间戳（秒）。
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// BeforeCreate assigns a GUID from the configured IDGenerator when the
+// caller left it unset.
+func (b *BalanceHistory) BeforeCreate(_ *gorm.DB) error {
+	if b.GUID == uuid.Nil {
+		b.GUID = NewGUID()
+	}
+	return nil
+}
+
+// BalanceHistoryView defines read access to balance snapshots.
+type BalanceHistoryView interface {
+	// QueryBalanceHistory returns the snapshots of address and token with
+	// from <= block number <= to, ordered by block number. The balance at a
+	// block without a snapshot is that of the closest earlier snapshot.
+	QueryBalanceHistory(address, token common.Address, from, to *big.Int) ([]*BalanceHistory, error)
+}
+
+// BalanceHistoryDB 在 BalanceHistoryView 的基础上增加了写入余额快照的能力。
+type BalanceHistoryDB interface {
+	BalanceHistoryView
+
+	// StoreBalanceSnapshots 方法写入余额快照，跳过与该地址、代币最近一条快照余额相同的记录。
+	// 返回实际写入的条数。快照应按区块高度升序传入。
+	StoreBalanceSnapshots([]BalanceHistory) (int, error)
+	// DeleteBalanceHistoryFrom 方法删除区块高度大于等于 number 的快照，用于链重组回滚。
+	DeleteBalanceHistoryFrom(number *big.Int) error
+}
+
+type balanceHistoryDB struct {
+	gorm       *gorm.DB
+	normalizer AddressNormalizer
+}
+
+// NewBalanceHistoryDB returns a BalanceHistoryDB backed by the given Gorm DB
+// that builds address lookup keys with normalizer.
+func NewBalanceHistoryDB(db *gorm.DB, normalizer AddressNormalizer) BalanceHistoryDB {
+	return &balanceHistoryDB{gorm: db, normalizer: normalizer}
+}
+
+type balanceKey struct {
+	address common.Address
+	token   common.Address
+}
+
+func (db *balanceHistoryDB) StoreBalanceSnapshots(snapshots []BalanceHistory) (int, error) {
+	last := make(map[balanceKey]*big.Int)
+	changed := make([]BalanceHistory, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		key := balanceKey{address: snapshot.Address, token: snapshot.TokenAddress}
+		previous, ok := last[key]
+		if !ok {
+			latest, err := db.latestSnapshotBefore(key, snapshot.BlockNumber)
+			if err != nil {
+				return 0, err
+			}
+			if latest != nil {
+				previous = latest.Balance
+			}
+		}
+		last[key] = snapshot.Balance
+		if previous != nil && previous.Cmp(snapshot.Balance) == 0 {
+			continue
+		}
+		changed = append(changed, snapshot)
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	if err := db.gorm.Table("balance_history").CreateInBatches(&changed, len(changed)).Error; err != nil {
+		return 0, err
+	}
+	return len(changed), nil
+}
+
+// latestSnapshotBefore returns the newest snapshot of key below block, or
+// nil if there is none.
+func (db *balanceHistoryDB) latestSnapshotBefore(key balanceKey, block *big.Int) (*BalanceHistory, error) {
+	var snapshot BalanceHistory
+	err := db.gorm.Table("balance_history").
+		Where("address = ? AND token_address = ? AND block_number < ?",
+			db.normalizer.Normalize(key.address), db.normalizer.Normalize(key.token), block.String()).
+		Order("block_number desc").
+		Take(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (db *balanceHistoryDB) QueryBalanceHistory(address, token common.Address, from, to *big.Int) ([]*BalanceHistory, error) {
+	var history []*BalanceHistory
+	err := db.gorm.Table("balance_history").
+		Where("address = ? AND token_address = ?", db.normalizer.Normalize(address), db.normalizer.Normalize(token)).
+		Where("block_number >= ? AND block_number <= ?", from.String(), to.String()).
+		Order("block_number asc").
+		Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (db *balanceHistoryDB) DeleteBalanceHistoryFrom(number *big.Int) error {
+	return db.gorm.Table("balance_history").Where("block_number >= ?", number.String()).Delete(&BalanceHistory{}).Error
+}
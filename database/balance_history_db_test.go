@@ -0,0 +1,80 @@
+package database_test
+
+import (
+	"maps"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+func TestBalanceHistory(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	address := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	other := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	token := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	snapshot := func(address common.Address, block, balance int64) database.BalanceHistory {
+		return database.BalanceHistory{Address: address, TokenAddress: token, Balance: big.NewInt(balance), BlockNumber: big.NewInt(block), Timestamp: uint64(block)}
+	}
+	history := func(from, to int64) map[int64]int64 {
+		t.Helper()
+		rows, err := db.BalanceHistory.QueryBalanceHistory(address, token, big.NewInt(from), big.NewInt(to))
+		if err != nil {
+			t.Fatalf("QueryBalanceHistory: %v", err)
+		}
+		balances := make(map[int64]int64, len(rows))
+		var previous int64 = -1
+		for _, row := range rows {
+			if row.BlockNumber.Int64() <= previous {
+				t.Errorf("history not ordered by block: %d after %d", row.BlockNumber, previous)
+			}
+			previous = row.BlockNumber.Int64()
+			balances[previous] = row.Balance.Int64()
+		}
+		return balances
+	}
+
+	// Only changes are recorded: the unchanged balance at block 20 is not.
+	stored, err := db.BalanceHistory.StoreBalanceSnapshots([]database.BalanceHistory{
+		snapshot(address, 10, 5),
+		snapshot(other, 10, 1),
+		snapshot(address, 20, 5),
+		snapshot(address, 30, 8),
+		snapshot(address, 40, 3),
+	})
+	if err != nil {
+		t.Fatalf("StoreBalanceSnapshots: %v", err)
+	}
+	if stored != 4 {
+		t.Errorf("StoreBalanceSnapshots stored %d snapshots, want 4", stored)
+	}
+	// Unchanged against the stored history too.
+	if stored, err := db.BalanceHistory.StoreBalanceSnapshots([]database.BalanceHistory{snapshot(address, 50, 3)}); err != nil || stored != 0 {
+		t.Errorf("StoreBalanceSnapshots of an unchanged balance = %d, %v, want 0", stored, err)
+	}
+
+	for _, tt := range []struct {
+		from, to int64
+		want     map[int64]int64
+	}{
+		{0, 100, map[int64]int64{10: 5, 30: 8, 40: 3}},
+		{15, 35, map[int64]int64{30: 8}},
+		{30, 40, map[int64]int64{30: 8, 40: 3}},
+		{41, 100, map[int64]int64{}},
+	} {
+		if got := history(tt.from, tt.to); !maps.Equal(got, tt.want) {
+			t.Errorf("history %d-%d = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	// A reorg drops the snapshots of orphaned blocks.
+	if err := db.BalanceHistory.DeleteBalanceHistoryFrom(big.NewInt(30)); err != nil {
+		t.Fatalf("DeleteBalanceHistoryFrom: %v", err)
+	}
+	if got, want := history(0, 100), map[int64]int64{10: 5}; !maps.Equal(got, want) {
+		t.Errorf("history after reorg = %v, want %v", got, want)
+	}
+}
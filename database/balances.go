@@ -0,0 +1,201 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientBalance is returned by UpdateBalance when applying the
+// delta would make a balance negative.
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// Balances 结构体表示某个地址持有某种代币的当前余额，每个地址和代币组合只有一行。
+type Balances struct {
+	// GUID 是余额记录的唯一标识符，并且是主键。
+	GUID uuid.UUID `gorm:"primaryKey" json:"guid"`
+
+	// Address 是受管地址，TokenAddress 是代币合约地址，原生币为零地址。
+	Address      common.Address `json:"address" gorm:"serializer:bytes"`
+	TokenAddress common.Address `json:"tokenAddress" gorm:"serializer:bytes"`
+
+	// Balance 是当前余额（最小单位）。
+	Balance *big.Int `json:"balance" gorm:"serializer:u256"`
+
+	// LockBalance 是已被锁定、不可再用于归集或提现的余额（最小单位）。
+	LockBalance *big.Int `json:"lockBalance" gorm:"serializer:u256"`
+
+	// Timestamp 是余额最后一次变化的时间戳（秒）。
+	Timestamp int64 `json:"timestamp"`
+}
+
+// BeforeCreate assigns a GUID from the configured IDGenerator when the
+// caller left it unset.
+func (b *Balances) BeforeCreate(_ *gorm.DB) error {
+	if b.GUID == uuid.Nil {
+		b.GUID = NewGUID()
+	}
+	return nil
+}
+
+// BalancesView defines read access to current balances.
+type BalancesView interface {
+	// QueryBalance returns the balance of address for token, or nil and a
+	// nil error if none has been recorded.
+	QueryBalance(address, token common.Address) (*Balances, error)
+	// SumBalancesByToken sums the balances of every managed address per
+	// token, ordered by token address.
+	SumBalancesByToken() ([]TokenBalance, error)
+	// QueryCollectableBalances returns the available balance, i.e. Balance
+	// minus LockBalance, of every user address and token whose available
+	// balance has reached the token's CollectAmount, ordered by address and
+	// token. Tokens without a CollectAmount are left out.
+	QueryCollectableBalances() ([]CollectableBalance, error)
+}
+
+// CollectableBalance 是某个用户地址上达到归集门槛的某种代币的可用余额。
+type CollectableBalance struct {
+	// Address 是用户地址，TokenAddress 是代币合约地址，原生币为零地址。
+	Address      common.Address `json:"address" gorm:"serializer:bytes"`
+	TokenAddress common.Address `json:"tokenAddress" gorm:"serializer:bytes"`
+
+	// Available 是余额减去锁定余额后的可用余额（最小单位）。
+	Available *big.Int `json:"available" gorm:"serializer:u256"`
+}
+
+// TokenBalance 是所有受管地址持有某种代币的余额汇总。
+type TokenBalance struct {
+	// TokenAddress 是代币合约地址，原生币为零地址。
+	TokenAddress common.Address `json:"tokenAddress" gorm:"serializer:bytes"`
+
+	// Total 是余额之和（最小单位）。
+	Total *big.Int `json:"total" gorm:"serializer:u256"`
+
+	// Holders 是余额不为零的地址数。
+	Holders int64 `json:"holders"`
+}
+
+// BalancesDB 在 BalancesView 的基础上增加了写入和调整余额的能力。
+type BalancesDB interface {
+	BalancesView
+
+	// UpdateOrCreate 方法按 地址 + 代币 写入余额，已存在的记录会被覆盖。
+	UpdateOrCreate([]Balances) error
+
+	// UpdateBalance 方法在事务中把 delta（可为负数）累加到地址的代币余额上，
+	// 没有记录时以零余额创建。结果为负数时返回 ErrInsufficientBalance 且不做任何修改。
+	UpdateBalance(address, token common.Address, delta *big.Int) error
+}
+
+type balancesDB struct {
+	gorm       *gorm.DB
+	normalizer AddressNormalizer
+}
+
+// NewBalancesDB returns a BalancesDB backed by the given Gorm DB that builds
+// address lookup keys with normalizer.
+func NewBalancesDB(db *gorm.DB, normalizer AddressNormalizer) BalancesDB {
+	return &balancesDB{gorm: db, normalizer: normalizer}
+}
+
+func (db *balancesDB) QueryBalance(address, token common.Address) (*Balances, error) {
+	var balance Balances
+	err := db.gorm.Table("balances").
+		Where("address = ? AND token_address = ?", db.normalizer.Normalize(address), db.normalizer.Normalize(token)).
+		Take(&balance).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// SumBalancesByToken sums in SQL, on the NUMERIC balance column, so totals
+// can't overflow. Tokens whose balances are all zero are left out.
+func (db *balancesDB) QueryCollectableBalances() ([]CollectableBalance, error) {
+	var balances []CollectableBalance
+	err := db.gorm.Table("balances").
+		Select("balances.address, balances.token_address, balances.balance - balances.lock_balance AS available").
+		Joins("JOIN tokens ON tokens.token_address = balances.token_address").
+		Joins("JOIN addresses ON addresses.address = balances.address AND addresses.deleted_at IS NULL").
+		Where("addresses.address_type = ? AND tokens.collect_amount > 0", AddressTypeUser).
+		Where("balances.balance - balances.lock_balance >= tokens.collect_amount").
+		Order("balances.address asc, balances.token_address asc").
+		Find(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+func (db *balancesDB) SumBalancesByToken() ([]TokenBalance, error) {
+	var totals []TokenBalance
+	err := db.gorm.Table("balances").
+		Select("token_address, SUM(balance) AS total, COUNT(*) AS holders").
+		Where("balance > 0").
+		Group("token_address").
+		Order("token_address asc").
+		Find(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+func (db *balancesDB) UpdateOrCreate(balanceList []Balances) error {
+	if len(balanceList) == 0 {
+		return nil
+	}
+	for i := range balanceList {
+		if balanceList[i].LockBalance == nil {
+			balanceList[i].LockBalance = new(big.Int)
+		}
+	}
+	result := db.gorm.Table("balances").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "address"}, {Name: "token_address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"balance", "lock_balance", "timestamp"}),
+	}).CreateInBatches(&balanceList, len(balanceList))
+	return result.Error
+}
+
+func (db *balancesDB) UpdateBalance(address, token common.Address, delta *big.Int) error {
+	return db.gorm.Transaction(func(tx *gorm.DB) error {
+		var balance Balances
+		err := tx.Table("balances").
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("address = ? AND token_address = ?", db.normalizer.Normalize(address), db.normalizer.Normalize(token)).
+			Take(&balance).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if delta.Sign() < 0 {
+				return fmt.Errorf("%w: %s has no %s balance to subtract %s from", ErrInsufficientBalance, address, token, delta)
+			}
+			return tx.Table("balances").Create(&Balances{
+				Address:      address,
+				TokenAddress: token,
+				Balance:      new(big.Int).Set(delta),
+				LockBalance:  new(big.Int),
+				Timestamp:    time.Now().Unix(),
+			}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		updated := new(big.Int).Add(balance.Balance, delta)
+		if updated.Sign() < 0 {
+			return fmt.Errorf("%w: %s %s balance %s cannot absorb %s", ErrInsufficientBalance, address, token, balance.Balance, delta)
+		}
+		return tx.Table("balances").Where("guid = ?", balance.GUID).Updates(map[string]any{
+			"balance":   updated.String(),
+			"timestamp": time.Now().Unix(),
+		}).Error
+	})
+}
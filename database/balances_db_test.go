@@ -0,0 +1,96 @@
+package database_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+func TestSumBalancesByToken(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	usdc := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	dai := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	native := common.Address{}
+	alice := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	bob := common.HexToAddress("0x4000000000000000000000000000000000000004")
+	// 10^30 per balance, so the native total doesn't fit in an int64.
+	large := new(big.Int).Exp(big.NewInt(10), big.NewInt(30), nil)
+	err := db.Balances.UpdateOrCreate([]database.Balances{
+		{Address: alice, TokenAddress: native, Balance: large},
+		{Address: bob, TokenAddress: native, Balance: large},
+		{Address: alice, TokenAddress: usdc, Balance: big.NewInt(5_000_000)},
+		{Address: bob, TokenAddress: usdc, Balance: big.NewInt(0)},
+		{Address: bob, TokenAddress: dai, Balance: big.NewInt(0)},
+	})
+	if err != nil {
+		t.Fatalf("UpdateOrCreate: %v", err)
+	}
+
+	totals, err := db.Balances.SumBalancesByToken()
+	if err != nil {
+		t.Fatalf("SumBalancesByToken: %v", err)
+	}
+	want := []database.TokenBalance{
+		{TokenAddress: native, Total: new(big.Int).Mul(large, big.NewInt(2)), Holders: 2},
+		{TokenAddress: usdc, Total: big.NewInt(5_000_000), Holders: 1},
+	}
+	if len(totals) != len(want) {
+		t.Fatalf("got %d totals, want %d: %+v", len(totals), len(want), totals)
+	}
+	for i, got := range totals {
+		if got.TokenAddress != want[i].TokenAddress || got.Total.Cmp(want[i].Total) != 0 || got.Holders != want[i].Holders {
+			t.Errorf("total %d = %s %s (%d holders), want %s %s (%d holders)", i,
+				got.TokenAddress, got.Total, got.Holders, want[i].TokenAddress, want[i].Total, want[i].Holders)
+		}
+	}
+}
+
+func TestQueryCollectableBalances(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	usdc := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	uncollected := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	rich, poor, locked, deleted := newAddress(t, database.AddressTypeUser), newAddress(t, database.AddressTypeUser), newAddress(t, database.AddressTypeUser), newAddress(t, database.AddressTypeUser)
+	hot := newAddress(t, database.AddressTypeHot)
+	if err := db.Addresses.StoreAddresses([]database.Addresses{rich, poor, locked, deleted, hot}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+	stored, err := db.Addresses.QueryAddressesByToAddress(&deleted.Address)
+	if err != nil {
+		t.Fatalf("QueryAddressesByToAddress: %v", err)
+	}
+	if err := db.Addresses.DeleteAddress(stored.GUID); err != nil {
+		t.Fatalf("DeleteAddress: %v", err)
+	}
+	err = db.Tokens.StoreTokens([]database.Tokens{
+		{TokenAddress: usdc, Symbol: "USDC", Decimals: 6, CollectAmount: big.NewInt(50)},
+		{TokenAddress: uncollected, Symbol: "NONE", Decimals: 18, CollectAmount: big.NewInt(0)},
+	})
+	if err != nil {
+		t.Fatalf("StoreTokens: %v", err)
+	}
+	err = db.Balances.UpdateOrCreate([]database.Balances{
+		{Address: rich.Address, TokenAddress: usdc, Balance: big.NewInt(80), LockBalance: big.NewInt(0)},
+		{Address: rich.Address, TokenAddress: uncollected, Balance: big.NewInt(80), LockBalance: big.NewInt(0)},
+		{Address: poor.Address, TokenAddress: usdc, Balance: big.NewInt(49), LockBalance: big.NewInt(0)},
+		// 60 held, but only 10 available.
+		{Address: locked.Address, TokenAddress: usdc, Balance: big.NewInt(60), LockBalance: big.NewInt(50)},
+		{Address: deleted.Address, TokenAddress: usdc, Balance: big.NewInt(80), LockBalance: big.NewInt(0)},
+		// Hot wallets are not collected from.
+		{Address: hot.Address, TokenAddress: usdc, Balance: big.NewInt(500), LockBalance: big.NewInt(0)},
+	})
+	if err != nil {
+		t.Fatalf("UpdateOrCreate: %v", err)
+	}
+
+	balances, err := db.Balances.QueryCollectableBalances()
+	if err != nil {
+		t.Fatalf("QueryCollectableBalances: %v", err)
+	}
+	if len(balances) != 1 || balances[0].Address != rich.Address || balances[0].TokenAddress != usdc || balances[0].Available.Int64() != 80 {
+		t.Errorf("collectable balances = %+v, want 80 USDC of %s", balances, rich.Address)
+	}
+}
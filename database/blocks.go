@@ -0,0 +1,115 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/qiaopengjun5162/web3scanner/database/utils"
+)
+
+// Blocks 结构体表示一个已扫描区块的区块头信息，用于断点续扫和重组检测。
+type Blocks struct {
+	// GUID 是区块记录的唯一标识符，并且是主键。
+	GUID uuid.UUID `gorm:"primaryKey" json:"guid"`
+
+	// BlockHash 是区块哈希，ParentHash 是父区块哈希，均以十六进制字符串存储。
+	BlockHash  common.Hash `json:"blockHash" gorm:"serializer:bytes"`
+	ParentHash common.Hash `json:"parentHash" gorm:"serializer:bytes"`
+
+	// Number 是区块高度，以 UINT256 存储。
+	Number *big.Int `json:"number" gorm:"serializer:u256"`
+
+	// Timestamp 是区块时间戳（秒）。
+	Timestamp uint64 `json:"timestamp"`
+
+	// RLPHeader 是 RLP 编码后的完整区块头。
+	RLPHeader *utils.RLPHeader `json:"rlpHeader" gorm:"serializer:rlp"`
+
+	// BaseFee 是 EIP-1559 区块基础费用，1559 之前的区块或不支持的链上为 NULL。
+	BaseFee *big.Int `json:"baseFee" gorm:"serializer:u256"`
+}
+
+// BeforeCreate assigns a GUID from the configured IDGenerator when the
+// caller left it unset.
+func (b *Blocks) BeforeCreate(_ *gorm.DB) error {
+	if b.GUID == uuid.Nil {
+		b.GUID = NewGUID()
+	}
+	return nil
+}
+
+// BlockFromHeader builds a Blocks row from a block header.
+func BlockFromHeader(header *types.Header) Blocks {
+	return Blocks{
+		BlockHash:  header.Hash(),
+		ParentHash: header.ParentHash,
+		Number:     header.Number,
+		Timestamp:  header.Time,
+		RLPHeader:  (*utils.RLPHeader)(header),
+		BaseFee:    header.BaseFee,
+	}
+}
+
+// BlocksView defines read access to scanned block headers.
+type BlocksView interface {
+	// LatestBlock returns the highest stored block, or nil and a nil error if
+	// no block has been stored yet.
+	LatestBlock() (*Blocks, error)
+	// QueryBlockByNumber returns the stored block with the given number. If
+	// it does not exist, returns nil and gorm.ErrRecordNotFound.
+	QueryBlockByNumber(number *big.Int) (*Blocks, error)
+}
+
+// BlocksDB 在 BlocksView 的基础上增加了存储区块头的能力。
+type BlocksDB interface {
+	BlocksView
+
+	// StoreBlocks 方法用于批量存储区块头。
+	StoreBlocks([]Blocks) error
+	// DeleteBlocksFrom 方法删除高度大于等于 number 的所有区块，用于链重组回滚。
+	DeleteBlocksFrom(number *big.Int) error
+}
+
+type blocksDB struct {
+	gorm *gorm.DB
+}
+
+// NewBlocksDB returns a BlocksDB backed by the given Gorm DB.
+func NewBlocksDB(db *gorm.DB) BlocksDB {
+	return &blocksDB{gorm: db}
+}
+
+func (db *blocksDB) StoreBlocks(blockList []Blocks) error {
+	result := db.gorm.Table("blocks").CreateInBatches(&blockList, len(blockList))
+	return result.Error
+}
+
+func (db *blocksDB) LatestBlock() (*Blocks, error) {
+	var block Blocks
+	err := db.gorm.Table("blocks").Order("number desc").Take(&block).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (db *blocksDB) QueryBlockByNumber(number *big.Int) (*Blocks, error) {
+	var block Blocks
+	err := db.gorm.Table("blocks").Where("number = ?", number.String()).Take(&block).Error
+	if err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (db *blocksDB) DeleteBlocksFrom(number *big.Int) error {
+	return db.gorm.Table("blocks").Where("number >= ?", number.String()).Delete(&Blocks{}).Error
+}
@@ -0,0 +1,33 @@
+package database_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+func TestStoreBlocksBaseFee(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	legacy := &types.Header{Number: big.NewInt(12_964_999), Time: 1628166812, Difficulty: new(big.Int)}
+	london := &types.Header{Number: big.NewInt(12_965_000), Time: 1628166822, Difficulty: new(big.Int), ParentHash: legacy.Hash(), BaseFee: big.NewInt(7_000_000_000)}
+	if err := db.Blocks.StoreBlocks([]database.Blocks{database.BlockFromHeader(legacy), database.BlockFromHeader(london)}); err != nil {
+		t.Fatalf("StoreBlocks: %v", err)
+	}
+
+	for _, header := range []*types.Header{legacy, london} {
+		block, err := db.Blocks.QueryBlockByNumber(header.Number)
+		if err != nil {
+			t.Fatalf("QueryBlockByNumber(%s): %v", header.Number, err)
+		}
+		if (block.BaseFee == nil) != (header.BaseFee == nil) || (block.BaseFee != nil && block.BaseFee.Cmp(header.BaseFee) != 0) {
+			t.Errorf("block %s base fee = %v, want %v", header.Number, block.BaseFee, header.BaseFee)
+		}
+		if block.BlockHash != header.Hash() {
+			t.Errorf("block %s hash = %s, want %s", header.Number, block.BlockHash, header.Hash())
+		}
+	}
+}
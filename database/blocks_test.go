@@ -0,0 +1,65 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// testHeaders returns an EIP-1559 header with a base fee of 7 gwei and a
+// legacy header without one.
+func testHeaders() (london, legacy *types.Header) {
+	london = &types.Header{Number: big.NewInt(12_965_000), Time: 1628166822, Difficulty: new(big.Int), BaseFee: big.NewInt(7_000_000_000)}
+	legacy = &types.Header{Number: big.NewInt(12_964_999), Time: 1628166812, Difficulty: new(big.Int)}
+	return london, legacy
+}
+
+func TestBlockFromHeaderBaseFee(t *testing.T) {
+	london, legacy := testHeaders()
+	if got := BlockFromHeader(london).BaseFee; got == nil || got.Cmp(london.BaseFee) != 0 {
+		t.Errorf("EIP-1559 block base fee = %v, want %v", got, london.BaseFee)
+	}
+	if got := BlockFromHeader(legacy).BaseFee; got != nil {
+		t.Errorf("legacy block base fee = %v, want nil", got)
+	}
+}
+
+func TestStoreBlocksBaseFee(t *testing.T) {
+	db, mock := newMockDB(t)
+	london, legacy := testHeaders()
+
+	// Numbers are written in the Numeric text form; the legacy block stores a
+	// NULL base fee.
+	arg := sqlmock.AnyArg()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "blocks" \(.*"base_fee"\)`).
+		WithArgs(arg, arg, arg, "12965000e0", london.Time, arg, "7000000000e0",
+			arg, arg, arg, "12964999e0", legacy.Time, arg, nil).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	if err := db.Blocks.StoreBlocks([]Blocks{BlockFromHeader(london), BlockFromHeader(legacy)}); err != nil {
+		t.Fatalf("StoreBlocks: %v", err)
+	}
+
+	for _, tt := range []struct {
+		header  *types.Header
+		baseFee any
+	}{
+		{london, "7000000000"},
+		{legacy, nil},
+	} {
+		mock.ExpectQuery(`SELECT \* FROM "blocks" WHERE number = \$1`).
+			WithArgs(tt.header.Number.String(), arg).
+			WillReturnRows(sqlmock.NewRows([]string{"number", "timestamp", "base_fee"}).
+				AddRow(tt.header.Number.String(), tt.header.Time, tt.baseFee))
+		block, err := db.Blocks.QueryBlockByNumber(tt.header.Number)
+		if err != nil {
+			t.Fatalf("QueryBlockByNumber: %v", err)
+		}
+		if (block.BaseFee == nil) != (tt.header.BaseFee == nil) || (block.BaseFee != nil && block.BaseFee.Cmp(tt.header.BaseFee) != 0) {
+			t.Errorf("block %s base fee = %v, want %v", tt.header.Number, block.BaseFee, tt.header.BaseFee)
+		}
+	}
+}
@@ -2,12 +2,22 @@
 package database
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pkg/errors"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -20,20 +30,284 @@ import (
 )
 
 type DB struct {
-	gorm      *gorm.DB
-	Addresses AddressesDB
+	gorm *gorm.DB
+	// reader serves address view queries. It is a connection to the slave
+	// database, or the same handle as gorm when no slave is configured.
+	reader         *gorm.DB
+	Addresses      AddressesDB
+	Blocks         BlocksDB
+	Deposits       DepositsDB
+	Sweeps         SweepsDB
+	Reorgs         ReorgsDB
+	Balances       BalancesDB
+	Tokens         TokensDB
+	BalanceHistory BalanceHistoryDB
+	Withdrawals    WithdrawalsDB
+	Nonces         NonceDB
+
+	// normalizer builds the address lookup keys of every table.
+	normalizer AddressNormalizer
+
+	// nonceSeeder seeds Nonces, see SetNonceSeeder.
+	nonceSeeder NonceSeeder
+
+	// stopKeepAlive stops the keepalive goroutines, if any were started.
+	stopKeepAlive context.CancelFunc
+}
+
+// NewDB connects to a single database used for both reads and writes.
+func NewDB(ctx context.Context, dbConfig config.DBConfig, chainID uint64) (*DB, error) {
+	return NewDBWithReplica(ctx, dbConfig, config.DBConfig{}, chainID)
+}
+
+// NewDBWithReplica connects to the master database and, if replica.Host is
+// set, to a read replica that serves the address view queries. Writes,
+// transactions and the scanner's block cursor always use the master, as
+// they must see their own writes. With an empty replica host everything
+// runs on the master.
+//
+// Addresses are normalized with the AddressNormalizer registered for
+// chainID, EVMAddressNormalizer unless another was registered.
+func NewDBWithReplica(ctx context.Context, master, replica config.DBConfig, chainID uint64) (*DB, error) {
+	gorm, stopKeepAlive, err := openPool(ctx, buildDSN(master), master)
+	if err != nil {
+		return nil, err
+	}
+	reader := gorm
+	if replica.Host != "" {
+		var stopReaderKeepAlive context.CancelFunc
+		reader, stopReaderKeepAlive, err = openPool(ctx, buildDSN(replica), replica)
+		if err != nil {
+			stopKeepAlive()
+			if sqlDB, dbErr := gorm.DB(); dbErr == nil {
+				_ = sqlDB.Close()
+			}
+			return nil, fmt.Errorf("connect to slave database: %w", err)
+		}
+		stopMasterKeepAlive := stopKeepAlive
+		stopKeepAlive = func() {
+			stopMasterKeepAlive()
+			stopReaderKeepAlive()
+		}
+	}
+
+	db := newDB(gorm, reader, AddressNormalizerForChain(chainID), nil)
+	db.stopKeepAlive = stopKeepAlive
+	return db, nil
+}
+
+// newDB builds the tables on top of the given connections, all of them
+// building address lookup keys with normalizer.
+func newDB(gorm, reader *gorm.DB, normalizer AddressNormalizer, nonceSeeder NonceSeeder) *DB {
+	return &DB{
+		gorm:           gorm,
+		reader:         reader,
+		Addresses:      NewAddressesDBWithReader(gorm, reader, normalizer),
+		Blocks:         NewBlocksDB(gorm),
+		Deposits:       NewDepositsDB(gorm, normalizer),
+		Sweeps:         NewSweepsDB(gorm),
+		Reorgs:         NewReorgsDB(gorm),
+		Balances:       NewBalancesDB(gorm, normalizer),
+		Tokens:         NewTokensDB(gorm, normalizer),
+		BalanceHistory: NewBalanceHistoryDB(gorm, normalizer),
+		Withdrawals:    NewWithdrawalsDB(gorm),
+		Nonces:         NewNonceDB(gorm, nonceSeeder, normalizer),
+		normalizer:     normalizer,
+		nonceSeeder:    nonceSeeder,
+	}
+}
+
+// openPool opens a connection pool for the DSN and applies the pool and
+// keepalive settings from dbConfig.
+func openPool(ctx context.Context, dsn string, dbConfig config.DBConfig) (*gorm.DB, context.CancelFunc, error) {
+	gorm, err := openGorm(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := configurePool(gorm, dbConfig); err != nil {
+		return nil, nil, err
+	}
+	stopKeepAlive, err := startKeepAlive(ctx, gorm, dbConfig.KeepAliveInterval)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gorm, stopKeepAlive, nil
+}
+
+// ObserverDB is a read-only database handle for analytics and BI tooling.
+//
+// It only exposes the view interfaces, so write methods are not reachable
+// at compile time. The underlying connection additionally sets
+// default_transaction_read_only, so Postgres rejects writes even if the
+// configured role would allow them.
+type ObserverDB struct {
+	gorm           *gorm.DB
+	Addresses      AddressesView
+	Blocks         BlocksView
+	Deposits       DepositsView
+	Sweeps         SweepsView
+	Reorgs         ReorgsView
+	Balances       BalancesView
+	Tokens         TokensView
+	BalanceHistory BalanceHistoryView
+	Withdrawals    WithdrawalsView
+
+	stopKeepAlive context.CancelFunc
+}
+
+// NewObserverDB opens a read-only connection using the given config.
+//
+// The config should point at a read-only Postgres role for least privilege.
+// Addresses are normalized as in NewDBWithReplica.
+func NewObserverDB(ctx context.Context, dbConfig config.DBConfig, chainID uint64) (*ObserverDB, error) {
+	dsn := buildDSN(dbConfig) + " default_transaction_read_only=on"
+	gorm, stopKeepAlive, err := openPool(ctx, dsn, dbConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := newDB(gorm, gorm, AddressNormalizerForChain(chainID), nil)
+	db := &ObserverDB{
+		gorm:           gorm,
+		Addresses:      tables.Addresses,
+		Blocks:         tables.Blocks,
+		Deposits:       tables.Deposits,
+		Sweeps:         tables.Sweeps,
+		Reorgs:         tables.Reorgs,
+		Balances:       tables.Balances,
+		Tokens:         tables.Tokens,
+		BalanceHistory: tables.BalanceHistory,
+		Withdrawals:    tables.Withdrawals,
+		stopKeepAlive:  stopKeepAlive,
+	}
+	return db, nil
+}
+
+// Close closes the observer's database connection.
+func (db *ObserverDB) Close() error {
+	db.stopKeepAlive()
+	sql, err := db.gorm.DB()
+	if err != nil {
+		return err
+	}
+	return sql.Close()
+}
+
+// Connection pool defaults, used when the corresponding DBConfig field is
+// zero. database/sql would otherwise allow unlimited open connections, keep
+// only two idle ones and never recycle a connection.
+const (
+	defaultMaxOpenConns    = 20
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 30 * time.Minute
+)
+
+// configurePool applies the pool limits from dbConfig, falling back to the
+// defaults for unset fields.
+func configurePool(gorm *gorm.DB, dbConfig config.DBConfig) error {
+	sqlDB, err := gorm.DB()
+	if err != nil {
+		return err
+	}
+	maxOpen, maxIdle, maxLifetime := dbConfig.MaxOpenConns, dbConfig.MaxIdleConns, dbConfig.ConnMaxLifetime
+	if maxOpen <= 0 {
+		maxOpen = defaultMaxOpenConns
+	}
+	if maxIdle <= 0 {
+		maxIdle = min(defaultMaxIdleConns, maxOpen)
+	}
+	if maxLifetime <= 0 {
+		maxLifetime = defaultConnMaxLifetime
+	}
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(maxLifetime)
+	return nil
 }
 
-func NewDB(ctx context.Context, dbConfig config.DBConfig) (*DB, error) {
-	dsn := fmt.Sprintf("host=%s dbname=%s sslmode=disable", dbConfig.Host, dbConfig.Name)
+// startKeepAlive keeps the connection pool healthy across long idle periods,
+// during which firewalls or proxies may silently drop pooled connections.
+//
+// Connections idle for longer than interval are retired instead of reused,
+// and the pool is pinged every interval so at least one connection stays
+// warm. The returned function stops the pinger; it also stops when ctx is
+// done. A non-positive interval disables keepalive.
+func startKeepAlive(ctx context.Context, gorm *gorm.DB, interval time.Duration) (context.CancelFunc, error) {
+	if interval <= 0 {
+		return func() {}, nil
+	}
+	sqlDB, err := gorm.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetConnMaxIdleTime(interval)
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pingCtx, pingCancel := context.WithTimeout(ctx, interval/2)
+				if err := sqlDB.PingContext(pingCtx); err != nil && ctx.Err() == nil {
+					log.Warn("database keepalive ping failed", "err", err)
+				}
+				pingCancel()
+			}
+		}
+	}()
+	return cancel, nil
+}
+
+// buildDSN builds a Postgres keyword/value DSN from the given config. String
+// values are quoted, so they may contain spaces, quotes and backslashes.
+func buildDSN(dbConfig config.DBConfig) string {
+	sslMode := dbConfig.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	dsn := fmt.Sprintf("host=%s dbname=%s sslmode=%s", quoteDSNValue(dbConfig.Host), quoteDSNValue(dbConfig.Name), sslMode)
 	if dbConfig.Port != 0 {
 		dsn += fmt.Sprintf(" port=%d", dbConfig.Port)
 	}
-	if dbConfig.User != "" {
-		dsn += fmt.Sprintf(" user=%s", dbConfig.User)
+	for _, param := range []struct{ key, value string }{
+		{"user", dbConfig.User},
+		{"password", dbConfig.Password},
+		{"application_name", dbConfig.ApplicationName},
+		{"sslrootcert", dbConfig.SSLRootCert},
+		{"sslcert", dbConfig.SSLCert},
+		{"sslkey", dbConfig.SSLKey},
+	} {
+		if param.value != "" {
+			dsn += fmt.Sprintf(" %s=%s", param.key, quoteDSNValue(param.value))
+		}
 	}
-	if dbConfig.Password != "" {
-		dsn += fmt.Sprintf(" password=%s", dbConfig.Password)
+	return dsn
+}
+
+// quoteDSNValue single-quotes a DSN value. Backslashes are escaped before
+// quotes, so the escape added for a quote isn't itself escaped.
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "'", `\'`)
+	return "'" + value + "'"
+}
+
+// openGorm opens a GORM connection for the DSN, retrying with exponential
+// backoff while the database is unreachable.
+//
+// Server notices (e.g. RAISE NOTICE from migrations) are forwarded to the
+// log.
+func openGorm(dsn string) (*gorm.DB, error) {
+	pgxConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+	pgxConfig.OnNotice = func(_ *pgconn.PgConn, notice *pgconn.Notice) {
+		log.Info("database notice", "severity", notice.Severity, "message", notice.Message)
 	}
 
 	gormConfig := gorm.Config{
@@ -42,39 +316,95 @@ func NewDB(ctx context.Context, dbConfig config.DBConfig) (*DB, error) {
 	}
 
 	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
-	gorm, err := retry.Do[*gorm.DB](context.Background(), 10, retryStrategy, func() (*gorm.DB, error) {
-		gorm, err := gorm.Open(postgres.Open(dsn), &gormConfig)
+	onRetry := func(attempt int, err error) {
+		log.Warn("database connection attempt failed, retrying", "attempt", attempt, "err", err)
+	}
+	return retry.DoWithHooks[*gorm.DB](context.Background(), 10, retryStrategy, onRetry, func() (*gorm.DB, error) {
+		sqlDB := stdlib.OpenDB(*pgxConfig)
+		gorm, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gormConfig)
 		if err != nil {
+			_ = sqlDB.Close()
 			return nil, fmt.Errorf("failed to connect to database: %w", err)
 		}
 		return gorm, nil
 	})
+}
 
+// EnableAddressCache switches Addresses to a cached implementation that
+// keeps the full address set in memory while it holds at most maxSize
+// entries, so AddressExist doesn't query the database. Larger sets keep
+// using per-query lookups. A non-positive maxSize leaves caching disabled.
+func (db *DB) EnableAddressCache(maxSize int) error {
+	if maxSize <= 0 {
+		return nil
+	}
+	cache, err := newCachedAddressesDB(db.Addresses, db.gorm, maxSize, db.normalizer)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	db.Addresses = cache
+	return nil
+}
 
-	db := &DB{
-		gorm:      gorm,
-		Addresses: NewAddressesDB(gorm),
-	}
-	return db, nil
+// SetNonceSeeder sets the source of on-chain nonces used to seed Nonces the
+// first time an address is used. Until it is set, NextNonce only works for
+// addresses that already have a stored nonce.
+func (db *DB) SetNonceSeeder(seed NonceSeeder) {
+	db.nonceSeeder = seed
+	db.Nonces = NewNonceDB(db.gorm, seed, db.normalizer)
 }
 
+// Transaction runs fn with a DB whose tables all use the same database
+// transaction, committing if fn returns nil. The address cache, if enabled,
+// is reloaded after a commit only when fn wrote addresses.
 func (db *DB) Transaction(fn func(db *DB) error) error {
-	return db.gorm.Transaction(func(tx *gorm.DB) error {
-		txDB := &DB{
-			gorm:      tx,
-			Addresses: NewAddressesDB(tx),
-		}
+	var addresses *writeTrackingAddressesDB
+	err := db.gorm.Transaction(func(tx *gorm.DB) error {
+		txDB := newDB(tx, tx, db.normalizer, db.nonceSeeder)
+		addresses = &writeTrackingAddressesDB{AddressesDB: txDB.Addresses}
+		txDB.Addresses = addresses
 		return fn(txDB)
 	})
+	if cache, ok := db.Addresses.(*cachedAddressesDB); ok && err == nil && addresses.wrote {
+		cache.refresh()
+	}
+	return err
+}
+
+// Ping checks that the database, and the slave database if one is
+// configured, is reachable.
+func (db *DB) Ping(ctx context.Context) error {
+	sql, err := db.gorm.DB()
+	if err != nil {
+		return err
+	}
+	if err := sql.PingContext(ctx); err != nil {
+		return err
+	}
+	if db.reader == nil || db.reader == db.gorm {
+		return nil
+	}
+	readerSQL, err := db.reader.DB()
+	if err != nil {
+		return err
+	}
+	return readerSQL.PingContext(ctx)
 }
 
 // Close closes the database connection.
 //
 // It returns an error if closing the connection fails.
 func (db *DB) Close() error {
+	if db.stopKeepAlive != nil {
+		db.stopKeepAlive()
+	}
+	if db.reader != nil && db.reader != db.gorm {
+		if readerSQL, err := db.reader.DB(); err == nil {
+			if err := readerSQL.Close(); err != nil {
+				log.Warn("failed to close slave database", "err", err)
+			}
+		}
+	}
 	sql, err := db.gorm.DB()
 	if err != nil {
 		return err
@@ -82,16 +412,110 @@ func (db *DB) Close() error {
 	return sql.Close()
 }
 
-// ExecuteSQLMigration applies all SQL migrations found in the given folder.
+// schemaMigrationsTable records which migration files have been applied.
+// It is created by ExecuteSQLMigration itself rather than by a migration.
+const schemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    filename   VARCHAR PRIMARY KEY,
+    checksum   VARCHAR NOT NULL,
+    applied_at INTEGER NOT NULL,
+    seq        BIGSERIAL
+)`
+
+// schemaMigration is a row of the schema_migrations table. The seq column
+// is assigned by Postgres and records the application order.
+type schemaMigration struct {
+	Filename  string
+	Checksum  string
+	AppliedAt int64
+}
+
+// ExecuteSQLMigration applies the SQL migrations found in the given folder
+// that have not been applied yet.
 //
-// It iterates over all files in the folder and executes their content as SQL.
-// If any error occurs, it is returned.
+// It collects every .sql file in the folder and its subfolders, sorts them
+// by their numeric prefix (see sortMigrations) and executes them in that
+// order. Other files are skipped. Each file runs in a transaction together
+// with its schema_migrations row, keyed by its path relative to the folder,
+// so re-running is safe. A file that was applied but has since been
+// modified is reported as an error instead of being re-run.
 func (db *DB) ExecuteSQLMigration(migrationsFolder string) error {
+	paths, err := collectMigrations(migrationsFolder)
+	if err != nil {
+		return err
+	}
+	if err := db.gorm.Exec(schemaMigrationsTable).Error; err != nil {
+		return errors.Wrap(err, "Error creating schema_migrations table")
+	}
+	var applied []schemaMigration
+	if err := db.gorm.Table("schema_migrations").Find(&applied).Error; err != nil {
+		return errors.Wrap(err, "Error reading schema_migrations")
+	}
+	checksums := make(map[string]string, len(applied))
+	for _, migration := range applied {
+		checksums[migration.Filename] = migration.Checksum
+	}
+
+	for _, path := range paths {
+		// Read the file content
+		fileContent, readErr := os.ReadFile(path)
+		if readErr != nil {
+			return errors.Wrap(readErr, fmt.Sprintf("Error reading SQL file: %s", path))
+		}
+		relativePath, err := filepath.Rel(migrationsFolder, path)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("Failed to process migration file: %s", path))
+		}
+		filename := filepath.ToSlash(relativePath)
+		sum := sha256.Sum256(fileContent)
+		checksum := hex.EncodeToString(sum[:])
+
+		if appliedChecksum, ok := checksums[filename]; ok {
+			if appliedChecksum != checksum {
+				return fmt.Errorf("migration %s was modified after it was applied", filename)
+			}
+			continue
+		}
+
+		execErr := db.gorm.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(string(fileContent)).Error; err != nil {
+				return err
+			}
+			return tx.Table("schema_migrations").Create(&schemaMigration{
+				Filename:  filename,
+				Checksum:  checksum,
+				AppliedAt: time.Now().Unix(),
+			}).Error
+		})
+		if execErr != nil {
+			return errors.Wrap(execErr, fmt.Sprintf("Error executing SQL script: %s", path))
+		}
+		log.Info("applied migration", "file", filename)
+	}
+	return nil
+}
+
+// AppliedMigrations returns the file names, relative to the migrations
+// folder, of the migrations recorded as applied, in the order they were
+// applied.
+func (db *DB) AppliedMigrations() ([]string, error) {
+	var filenames []string
+	err := db.gorm.Table("schema_migrations").Order("seq asc").Pluck("filename", &filenames).Error
+	if err != nil {
+		return nil, err
+	}
+	return filenames, nil
+}
+
+// collectMigrations returns the paths of all .sql files under
+// migrationsFolder in execution order.
+func collectMigrations(migrationsFolder string) ([]string, error) {
+	var paths []string
 	err := filepath.Walk(migrationsFolder, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return errors.Wrap(err, fmt.Sprintf("Failed to process migration file: %s", path))
 		}
-		if info.IsDir() {
+		if info.IsDir() || filepath.Ext(path) != ".sql" {
 			return nil
 		}
 
@@ -100,17 +524,45 @@ func (db *DB) ExecuteSQLMigration(migrationsFolder string) error {
 		if err != nil || strings.Contains(relativePath, "..") {
 			return errors.New("invalid migration file path")
 		}
-		// Read the file content
-		fileContent, readErr := os.ReadFile(path)
-		if readErr != nil {
-			return errors.Wrap(readErr, fmt.Sprintf("Error reading SQL file: %s", path))
-		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortMigrations(paths)
+	return paths, nil
+}
 
-		execErr := db.gorm.Exec(string(fileContent)).Error
-		if execErr != nil {
-			return errors.Wrap(execErr, fmt.Sprintf("Error executing SQL script: %s", path))
+// sortMigrations orders migration paths by the numeric prefix of their file
+// name, so 2_x.sql runs before 10_x.sql regardless of directory. Files with
+// equal prefixes are ordered by name, and files without a numeric prefix
+// run last.
+func sortMigrations(paths []string) {
+	slices.SortStableFunc(paths, func(a, b string) int {
+		nameA, nameB := filepath.Base(a), filepath.Base(b)
+		numA, okA := migrationNumber(nameA)
+		numB, okB := migrationNumber(nameB)
+		switch {
+		case okA && !okB:
+			return -1
+		case !okA && okB:
+			return 1
+		case okA && okB && numA.Cmp(numB) != 0:
+			return numA.Cmp(numB)
 		}
-		return nil
+		return cmp.Or(strings.Compare(nameA, nameB), strings.Compare(a, b))
 	})
-	return err
+}
+
+// migrationNumber parses the leading digits of a migration file name.
+func migrationNumber(name string) (*big.Int, bool) {
+	end := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' })
+	if end == -1 {
+		end = len(name)
+	}
+	if end == 0 {
+		return nil, false
+	}
+	return new(big.Int).SetString(name[:end], 10)
 }
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/qiaopengjun5162/web3scanner/config"
+)
+
+func TestBuildDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.DBConfig
+	}{
+		{"plain", config.DBConfig{Host: "db", Port: 5433, Name: "scanner", User: "scanner", Password: "secret", ApplicationName: "web3scanner"}},
+		{"quote", config.DBConfig{Host: "db", Name: "scanner", Password: "it's", ApplicationName: "o'brien"}},
+		{"backslash", config.DBConfig{Host: "db", Name: "scanner", Password: `a\b`, ApplicationName: `trailing\`}},
+		{"backslash before quote", config.DBConfig{Host: "db", Name: "scanner", Password: `a\'b`, ApplicationName: `\'`}},
+		{"spaces", config.DBConfig{Host: "db", Name: "scanner", Password: "two words", ApplicationName: "web3scanner eu-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn := buildDSN(tt.cfg)
+			parsed, err := pgx.ParseConfig(dsn)
+			if err != nil {
+				t.Fatalf("parse %q: %v", dsn, err)
+			}
+			if parsed.Host != tt.cfg.Host || parsed.Database != tt.cfg.Name || parsed.Password != tt.cfg.Password {
+				t.Errorf("parsed host %q, dbname %q, password %q from %q, want %q, %q, %q",
+					parsed.Host, parsed.Database, parsed.Password, dsn, tt.cfg.Host, tt.cfg.Name, tt.cfg.Password)
+			}
+			if tt.cfg.Port != 0 && parsed.Port != uint16(tt.cfg.Port) {
+				t.Errorf("parsed port %d, want %d", parsed.Port, tt.cfg.Port)
+			}
+			if tt.cfg.User != "" && parsed.User != tt.cfg.User {
+				t.Errorf("parsed user %q, want %q", parsed.User, tt.cfg.User)
+			}
+			if got := parsed.RuntimeParams["application_name"]; got != tt.cfg.ApplicationName {
+				t.Errorf("parsed application_name %q from %q, want %q", got, dsn, tt.cfg.ApplicationName)
+			}
+		})
+	}
+}
+
+// writeMigrations creates the named files, which may be in subdirectories,
+// under a new directory and returns it.
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCollectMigrationsOrder(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"10_c.sql":        "",
+		"2_b.sql":         "",
+		"1_a.sql":         "",
+		"archive/3_a.sql": "",
+		"002_a.sql":       "",
+		"seed.sql":        "",
+		"README.md":       "",
+		"11_d.sql.bak":    "",
+	})
+	paths, err := collectMigrations(dir)
+	if err != nil {
+		t.Fatalf("collectMigrations: %v", err)
+	}
+	got := make([]string, len(paths))
+	for i, path := range paths {
+		rel, _ := filepath.Rel(dir, path)
+		got[i] = filepath.ToSlash(rel)
+	}
+	// Numeric order across directories, name order for equal numbers, files
+	// without a number last and non-SQL files skipped.
+	want := []string{"1_a.sql", "002_a.sql", "2_b.sql", "archive/3_a.sql", "10_c.sql", "seed.sql"}
+	if !slices.Equal(got, want) {
+		t.Errorf("collectMigrations = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+// Package dbtest provides scratch Postgres databases for tests that need a
+// real database.
+//
+// The tests are skipped unless WEB3SCANNER_TEST_DB_HOST points at a Postgres
+// server; WEB3SCANNER_TEST_DB_PORT, WEB3SCANNER_TEST_DB_USER,
+// WEB3SCANNER_TEST_DB_PASSWORD and WEB3SCANNER_TEST_DB_NAME default to 5432,
+// postgres, no password and postgres. The user needs the CREATEDB privilege.
+package dbtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/qiaopengjun5162/web3scanner/config"
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+// HostEnv is the environment variable that enables database tests.
+const HostEnv = "WEB3SCANNER_TEST_DB_HOST"
+
+// Config returns the connection settings of the test server, skipping the
+// test when HostEnv is not set.
+func Config(t testing.TB) config.DBConfig {
+	t.Helper()
+	host := os.Getenv(HostEnv)
+	if host == "" {
+		t.Skipf("%s not set, skipping database test", HostEnv)
+	}
+	port := 5432
+	if value := os.Getenv("WEB3SCANNER_TEST_DB_PORT"); value != "" {
+		var err error
+		if port, err = strconv.Atoi(value); err != nil {
+			t.Fatalf("parse WEB3SCANNER_TEST_DB_PORT: %v", err)
+		}
+	}
+	return config.DBConfig{
+		Host:     host,
+		Port:     port,
+		Name:     getenv("WEB3SCANNER_TEST_DB_NAME", "postgres"),
+		User:     getenv("WEB3SCANNER_TEST_DB_USER", "postgres"),
+		Password: os.Getenv("WEB3SCANNER_TEST_DB_PASSWORD"),
+	}
+}
+
+// NewDB creates an empty database on the test server, applies all
+// migrations and returns a DB connected to it together with its connection
+// settings. The database is dropped when the test ends, so tests and test
+// packages never share state.
+func NewDB(t testing.TB) (*database.DB, config.DBConfig) {
+	t.Helper()
+	db, cfg := NewEmptyDB(t)
+	if err := db.ExecuteSQLMigration(MigrationsDir()); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	return db, cfg
+}
+
+// NewEmptyDB is like NewDB but applies no migrations, for tests of the
+// migrations themselves.
+func NewEmptyDB(t testing.TB) (*database.DB, config.DBConfig) {
+	t.Helper()
+	cfg := Config(t)
+	ctx := context.Background()
+
+	admin, err := pgx.Connect(ctx, connString(cfg))
+	if err != nil {
+		t.Fatalf("connect to test server: %v", err)
+	}
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatalf("generate database name: %v", err)
+	}
+	name := "web3scanner_test_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec(ctx, "CREATE DATABASE "+name); err != nil {
+		_ = admin.Close(ctx)
+		t.Fatalf("create test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(ctx, "DROP DATABASE IF EXISTS "+name+" WITH (FORCE)"); err != nil {
+			t.Errorf("drop test database %s: %v", name, err)
+		}
+		_ = admin.Close(ctx)
+	})
+
+	cfg.Name = name
+	db, err := database.NewDB(ctx, cfg, 0)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db, cfg
+}
+
+// MigrationsDir returns the path of the repository's migrations folder.
+func MigrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "migrations")
+}
+
+func connString(cfg config.DBConfig) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     "/" + cfg.Name,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Exec runs sql on the database described by cfg, for tests that need to
+// put rows in a state the DB API can't produce.
+func Exec(t testing.TB, cfg config.DBConfig, sql string, args ...any) {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connString(cfg))
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	defer conn.Close(ctx)
+	if _, err := conn.Exec(ctx, sql, args...); err != nil {
+		t.Fatalf("exec %q: %v", sql, err)
+	}
+}
@@ -0,0 +1,311 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Deposits 结构体表示一笔转入受管用户地址的充值记录。
+type Deposits struct {
+	// GUID 是充值记录的唯一标识符，并且是主键。
+	GUID uuid.UUID `gorm:"primaryKey" json:"guid"`
+
+	// BlockHash 和 BlockNumber 标识充值交易所在的区块。
+	BlockHash   common.Hash `json:"blockHash" gorm:"serializer:bytes"`
+	BlockNumber *big.Int    `json:"blockNumber" gorm:"serializer:u256"`
+
+	// TxHash 是充值交易的哈希。
+	TxHash common.Hash `json:"txHash" gorm:"serializer:bytes"`
+
+	// TxIndex 是交易在区块中的序号。LogIndex 是 ERC20 Transfer 日志在区块中的序号，
+	// 原生币充值没有日志，为空。两者用于同一区块、同一交易内多笔转账的排序和去重。
+	TxIndex  uint  `json:"txIndex"`
+	LogIndex *uint `json:"logIndex"`
+
+	// FromAddress 是转出方地址，ToAddress 是接收充值的受管地址。
+	FromAddress common.Address `json:"fromAddress" gorm:"serializer:bytes"`
+	ToAddress   common.Address `json:"toAddress" gorm:"serializer:bytes"`
+
+	// TokenAddress 是代币合约地址，原生币充值时为零地址。
+	TokenAddress common.Address `json:"tokenAddress" gorm:"serializer:bytes"`
+
+	// Amount 是充值金额（最小单位）。
+	Amount *big.Int `json:"amount" gorm:"serializer:u256"`
+
+	// Timestamp 是所在区块的时间戳（秒）。
+	Timestamp uint64 `json:"timestamp"`
+
+	// Status 是充值的确认状态：DepositStatusPending 表示仍可能被链重组回滚，
+	// DepositStatusConfirmed 表示已达到确认深度，可以入账。
+	Status uint8 `json:"status"`
+}
+
+// Deposit statuses. Only confirmed deposits should be credited to users.
+const (
+	DepositStatusPending   uint8 = 0
+	DepositStatusConfirmed uint8 = 1
+)
+
+// transferOrder orders deposits and sweeps as they happened on chain: by
+// block, transaction and log. A native transfer has no log and comes before
+// the logs of its transaction.
+const transferOrder = "block_number asc, tx_index asc, log_index asc NULLS FIRST"
+
+// NativeTokenDecimals is the number of decimals of the chain's native
+// currency, which has no row in the tokens table.
+const NativeTokenDecimals uint8 = 18
+
+// AdjustedDeposit 是附带代币精度的充值记录，用于按精度换算金额。
+type AdjustedDeposit struct {
+	Deposits
+
+	// Decimals 是代币精度，来自 tokens 表，原生币为 NativeTokenDecimals。
+	// 代币不在 tokens 表中时为 nil。
+	Decimals *uint8 `json:"decimals"`
+}
+
+// AdjustedAmount returns Amount divided by 10^Decimals, e.g. 1.5 for a raw
+// USDC amount of 1500000. It returns nil when the token's decimals are
+// unknown.
+func (d *AdjustedDeposit) AdjustedAmount() *big.Rat {
+	if d.Decimals == nil || d.Amount == nil {
+		return nil
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(*d.Decimals)), nil)
+	return new(big.Rat).SetFrac(d.Amount, scale)
+}
+
+// BeforeCreate assigns a GUID from the configured IDGenerator when the
+// caller left it unset.
+func (d *Deposits) BeforeCreate(_ *gorm.DB) error {
+	if d.GUID == uuid.Nil {
+		d.GUID = NewGUID()
+	}
+	return nil
+}
+
+// DepositsView defines read access to recorded deposits.
+type DepositsView interface {
+	// QueryDepositsByBlockRange returns deposits with from <= block number <= to,
+	// ordered by block number, transaction index and log index.
+	QueryDepositsByBlockRange(from, to *big.Int) ([]*Deposits, error)
+	// QueryDepositsByConfirmations is like QueryDepositsByBlockRange but only
+	// returns deposits with at least confirmations blocks on top of them,
+	// i.e. latest - block number >= confirmations, where latest is the
+	// latest stored block. It returns nothing while no block is stored.
+	QueryDepositsByConfirmations(from, to *big.Int, confirmations uint64) ([]*Deposits, error)
+	// QueryLatestDepositByToAddress returns the most recent deposit to the
+	// given address, or nil if there is none.
+	QueryLatestDepositByToAddress(address common.Address) (*Deposits, error)
+	// IterateDepositsByBlockRange streams deposits with from <= block number
+	// <= to, in the order of QueryDepositsByBlockRange, calling fn for each
+	// one without loading the whole range into memory. Iteration stops at
+	// the first error fn returns.
+	IterateDepositsByBlockRange(from, to *big.Int, fn func(*Deposits) error) error
+	// AggregateDepositsByToken sums the deposits with from <= block number
+	// <= to per token, ordered by total descending.
+	AggregateDepositsByToken(from, to *big.Int) ([]TokenAggregate, error)
+	// QueryAdjustedDepositsByBlockRange is like QueryDepositsByBlockRange but
+	// also returns each deposit's token decimals, see AdjustedDeposit.
+	QueryAdjustedDepositsByBlockRange(from, to *big.Int) ([]*AdjustedDeposit, error)
+}
+
+// TokenAggregate 是一个代币在一段区块范围内的充值汇总。
+type TokenAggregate struct {
+	// TokenAddress 是代币合约地址，原生币为零地址。
+	TokenAddress common.Address `json:"tokenAddress" gorm:"serializer:bytes"`
+
+	// Total 是充值金额之和（最小单位）。
+	Total *big.Int `json:"total" gorm:"serializer:u256"`
+
+	// DepositCount 是充值笔数。
+	DepositCount int64 `json:"depositCount"`
+}
+
+// DepositsDB 在 DepositsView 的基础上增加了存储充值记录的能力。
+type DepositsDB interface {
+	DepositsView
+
+	// StoreDeposits 方法用于批量存储充值记录。
+	StoreDeposits([]Deposits) error
+	// MarkConfirmed 方法把区块高度小于等于 blockNumber 的待确认充值标记为已确认。
+	MarkConfirmed(blockNumber *big.Int) error
+	// DeleteDepositsFrom 方法删除区块高度大于等于 number 的充值记录，用于链重组回滚。
+	DeleteDepositsFrom(number *big.Int) error
+	// CreateDepositPartition 方法创建存放区块高度在 [fromBlock, toBlock) 内充值记录的分区，
+	// 并把默认分区中已有的这些记录移入新分区。分区已存在时不做任何事。
+	CreateDepositPartition(fromBlock, toBlock *big.Int) error
+}
+
+type depositsDB struct {
+	gorm       *gorm.DB
+	normalizer AddressNormalizer
+}
+
+// NewDepositsDB returns a DepositsDB backed by the given Gorm DB that builds
+// address lookup keys with normalizer.
+func NewDepositsDB(db *gorm.DB, normalizer AddressNormalizer) DepositsDB {
+	return &depositsDB{gorm: db, normalizer: normalizer}
+}
+
+func (db *depositsDB) StoreDeposits(depositList []Deposits) error {
+	if len(depositList) == 0 {
+		return nil
+	}
+	result := db.gorm.Table("deposits").CreateInBatches(&depositList, len(depositList))
+	return result.Error
+}
+
+func (db *depositsDB) QueryDepositsByBlockRange(from, to *big.Int) ([]*Deposits, error) {
+	var deposits []*Deposits
+	err := db.gorm.Table("deposits").
+		Where("block_number >= ? AND block_number <= ?", from.String(), to.String()).
+		Order(transferOrder).
+		Find(&deposits).Error
+	if err != nil {
+		return nil, err
+	}
+	return deposits, nil
+}
+
+func (db *depositsDB) QueryDepositsByConfirmations(from, to *big.Int, confirmations uint64) ([]*Deposits, error) {
+	var deposits []*Deposits
+	err := db.gorm.Table("deposits").
+		Where("block_number >= ? AND block_number <= ?", from.String(), to.String()).
+		Where("block_number <= (SELECT MAX(number) FROM blocks) - ?", new(big.Int).SetUint64(confirmations).String()).
+		Order(transferOrder).
+		Find(&deposits).Error
+	if err != nil {
+		return nil, err
+	}
+	return deposits, nil
+}
+
+func (db *depositsDB) QueryAdjustedDepositsByBlockRange(from, to *big.Int) ([]*AdjustedDeposit, error) {
+	var deposits []*AdjustedDeposit
+	err := db.gorm.Table("deposits").
+		Select("deposits.*, tokens.decimals AS decimals").
+		Joins("LEFT JOIN tokens ON tokens.token_address = deposits.token_address").
+		Where("deposits.block_number >= ? AND deposits.block_number <= ?", from.String(), to.String()).
+		Order("deposits.block_number asc, deposits.tx_index asc, deposits.log_index asc NULLS FIRST").
+		Find(&deposits).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deposits {
+		if d.TokenAddress == (common.Address{}) {
+			decimals := NativeTokenDecimals
+			d.Decimals = &decimals
+		}
+	}
+	return deposits, nil
+}
+
+func (db *depositsDB) QueryLatestDepositByToAddress(address common.Address) (*Deposits, error) {
+	var deposit Deposits
+	err := db.gorm.Table("deposits").
+		Where("to_address = ?", db.normalizer.Normalize(address)).
+		Order("block_number desc, tx_index desc, log_index desc NULLS LAST").
+		Take(&deposit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &deposit, nil
+}
+
+func (db *depositsDB) DeleteDepositsFrom(number *big.Int) error {
+	return db.gorm.Table("deposits").Where("block_number >= ?", number.String()).Delete(&Deposits{}).Error
+}
+
+func (db *depositsDB) IterateDepositsByBlockRange(from, to *big.Int, fn func(*Deposits) error) error {
+	query := db.gorm.Table("deposits").
+		Where("block_number >= ? AND block_number <= ?", from.String(), to.String()).
+		Order(transferOrder)
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deposit Deposits
+		if err := query.ScanRows(rows, &deposit); err != nil {
+			return err
+		}
+		if err := fn(&deposit); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// AggregateDepositsByToken sums the amounts in SQL, on the NUMERIC amount
+// column, so totals can't overflow. Tokens with equal totals are ordered by
+// address, so the result is deterministic.
+func (db *depositsDB) AggregateDepositsByToken(from, to *big.Int) ([]TokenAggregate, error) {
+	var aggregates []TokenAggregate
+	err := db.gorm.Table("deposits").
+		Select("token_address, SUM(amount) AS total, COUNT(*) AS deposit_count").
+		Where("block_number >= ? AND block_number <= ?", from.String(), to.String()).
+		Group("token_address").
+		Order("total desc, token_address asc").
+		Find(&aggregates).Error
+	if err != nil {
+		return nil, err
+	}
+	return aggregates, nil
+}
+
+// depositPartitionName returns the name of the deposits partition for the
+// block range [fromBlock, toBlock).
+func depositPartitionName(fromBlock, toBlock *big.Int) string {
+	return fmt.Sprintf("deposits_%s_%s", fromBlock, toBlock)
+}
+
+// CreateDepositPartition creates the partition and attaches it in one
+// transaction. Postgres rejects attaching a range while the default
+// partition holds rows in it, so those are moved over first; ranges ahead
+// of the scan head have none. A range overlapping another partition fails.
+func (db *depositsDB) CreateDepositPartition(fromBlock, toBlock *big.Int) error {
+	if fromBlock.Sign() < 0 || fromBlock.Cmp(toBlock) >= 0 {
+		return fmt.Errorf("invalid deposit partition range %s-%s", fromBlock, toBlock)
+	}
+	name := depositPartitionName(fromBlock, toBlock)
+	return db.gorm.Transaction(func(tx *gorm.DB) error {
+		var exists bool
+		if err := tx.Raw("SELECT to_regclass(?) IS NOT NULL", name).Scan(&exists).Error; err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		// The bounds are decimal integers, so they are safe to inline where
+		// DDL takes no parameters.
+		statements := []string{
+			fmt.Sprintf("CREATE TABLE %s (LIKE deposits INCLUDING DEFAULTS INCLUDING CONSTRAINTS)", name),
+			fmt.Sprintf("INSERT INTO %s SELECT * FROM deposits_default WHERE block_number >= %s AND block_number < %s", name, fromBlock, toBlock),
+			fmt.Sprintf("DELETE FROM deposits_default WHERE block_number >= %s AND block_number < %s", fromBlock, toBlock),
+			fmt.Sprintf("ALTER TABLE deposits ATTACH PARTITION %s FOR VALUES FROM (%s) TO (%s)", name, fromBlock, toBlock),
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *depositsDB) MarkConfirmed(blockNumber *big.Int) error {
+	return db.gorm.Table("deposits").
+		Where("status = ? AND block_number <= ?", DepositStatusPending, blockNumber.String()).
+		Update("status", DepositStatusConfirmed).Error
+}
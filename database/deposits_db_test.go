@@ -0,0 +1,257 @@
+package database_test
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+func TestAggregateDepositsByToken(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	user := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	usdc := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	dai := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	native := common.Address{}
+	// 10^30 per deposit, so the DAI total doesn't fit in an int64.
+	large := new(big.Int).Exp(big.NewInt(10), big.NewInt(30), nil)
+	deposit := func(block int64, token common.Address, amount *big.Int) database.Deposits {
+		return database.Deposits{
+			BlockHash:    common.BigToHash(big.NewInt(block)),
+			BlockNumber:  big.NewInt(block),
+			TxHash:       common.BigToHash(big.NewInt(1_000 + block)),
+			FromAddress:  common.HexToAddress("0x4000000000000000000000000000000000000004"),
+			ToAddress:    user,
+			TokenAddress: token,
+			Amount:       amount,
+			Timestamp:    uint64(block),
+		}
+	}
+	err := db.Deposits.StoreDeposits([]database.Deposits{
+		deposit(10, usdc, big.NewInt(5_000_000)),
+		deposit(11, usdc, big.NewInt(7_000_000)),
+		deposit(12, dai, large),
+		deposit(13, dai, large),
+		deposit(14, native, big.NewInt(3)),
+		// Outside the queried range.
+		deposit(9, native, large),
+		deposit(21, usdc, big.NewInt(1)),
+	})
+	if err != nil {
+		t.Fatalf("StoreDeposits: %v", err)
+	}
+
+	aggregates, err := db.Deposits.AggregateDepositsByToken(big.NewInt(10), big.NewInt(20))
+	if err != nil {
+		t.Fatalf("AggregateDepositsByToken: %v", err)
+	}
+	want := []database.TokenAggregate{
+		{TokenAddress: dai, Total: new(big.Int).Mul(large, big.NewInt(2)), DepositCount: 2},
+		{TokenAddress: usdc, Total: big.NewInt(12_000_000), DepositCount: 2},
+		{TokenAddress: native, Total: big.NewInt(3), DepositCount: 1},
+	}
+	if len(aggregates) != len(want) {
+		t.Fatalf("got %d aggregates, want %d: %+v", len(aggregates), len(want), aggregates)
+	}
+	for i, got := range aggregates {
+		if got.TokenAddress != want[i].TokenAddress || got.Total.Cmp(want[i].Total) != 0 || got.DepositCount != want[i].DepositCount {
+			t.Errorf("aggregate %d = %s %s (%d deposits), want %s %s (%d deposits)", i,
+				got.TokenAddress, got.Total, got.DepositCount, want[i].TokenAddress, want[i].Total, want[i].DepositCount)
+		}
+	}
+
+	if aggregates, err := db.Deposits.AggregateDepositsByToken(big.NewInt(30), big.NewInt(40)); err != nil || len(aggregates) != 0 {
+		t.Errorf("AggregateDepositsByToken of an empty range = %v, %v, want none", aggregates, err)
+	}
+}
+
+func TestQueryAdjustedDepositsByBlockRange(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	usdc := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	unknown := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	if err := db.Tokens.StoreTokens([]database.Tokens{{TokenAddress: usdc, Symbol: "USDC", Decimals: 6}}); err != nil {
+		t.Fatalf("StoreTokens: %v", err)
+	}
+	deposit := func(block int64, token common.Address, amount *big.Int) database.Deposits {
+		return database.Deposits{
+			BlockHash:    common.BigToHash(big.NewInt(block)),
+			BlockNumber:  big.NewInt(block),
+			TxHash:       common.BigToHash(big.NewInt(1_000 + block)),
+			FromAddress:  common.HexToAddress("0x4000000000000000000000000000000000000004"),
+			ToAddress:    common.HexToAddress("0x1000000000000000000000000000000000000001"),
+			TokenAddress: token,
+			Amount:       amount,
+			Timestamp:    uint64(block),
+		}
+	}
+	oneAndAHalfEther, _ := new(big.Int).SetString("1500000000000000000", 10)
+	err := db.Deposits.StoreDeposits([]database.Deposits{
+		deposit(10, usdc, big.NewInt(1_500_000)),
+		deposit(11, common.Address{}, oneAndAHalfEther),
+		deposit(12, unknown, big.NewInt(7)),
+	})
+	if err != nil {
+		t.Fatalf("StoreDeposits: %v", err)
+	}
+
+	deposits, err := db.Deposits.QueryAdjustedDepositsByBlockRange(big.NewInt(10), big.NewInt(12))
+	if err != nil {
+		t.Fatalf("QueryAdjustedDepositsByBlockRange: %v", err)
+	}
+	if len(deposits) != 3 {
+		t.Fatalf("got %d deposits, want 3", len(deposits))
+	}
+	want := []*big.Rat{big.NewRat(3, 2), big.NewRat(3, 2), nil}
+	for i, d := range deposits {
+		got := d.AdjustedAmount()
+		if (got == nil) != (want[i] == nil) || (got != nil && got.Cmp(want[i]) != 0) {
+			t.Errorf("deposit %d (%s) adjusted amount = %v, want %v", i, d.TokenAddress, got, want[i])
+		}
+	}
+}
+
+func TestQueryDepositsByConfirmations(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	deposit := func(block int64) database.Deposits {
+		return database.Deposits{
+			BlockHash:    common.BigToHash(big.NewInt(block + 1)),
+			BlockNumber:  big.NewInt(block),
+			TxHash:       common.BigToHash(big.NewInt(1_000 + block)),
+			FromAddress:  common.HexToAddress("0x4000000000000000000000000000000000000004"),
+			ToAddress:    common.HexToAddress("0x1000000000000000000000000000000000000001"),
+			TokenAddress: common.Address{},
+			Amount:       big.NewInt(1),
+			Timestamp:    uint64(block),
+		}
+	}
+	// With the latest stored block at 10 and 2 confirmations required, block
+	// 7 is one past the depth, block 8 exactly at it and block 9 one short.
+	if err := db.Deposits.StoreDeposits([]database.Deposits{deposit(7), deposit(8), deposit(9)}); err != nil {
+		t.Fatalf("StoreDeposits: %v", err)
+	}
+
+	deposits, err := db.Deposits.QueryDepositsByConfirmations(big.NewInt(0), big.NewInt(10), 2)
+	if err != nil {
+		t.Fatalf("QueryDepositsByConfirmations: %v", err)
+	}
+	if len(deposits) != 0 {
+		t.Errorf("with no stored block got %d deposits, want none", len(deposits))
+	}
+
+	latest := database.BlockFromHeader(&types.Header{Number: big.NewInt(10), Difficulty: new(big.Int)})
+	if err := db.Blocks.StoreBlocks([]database.Blocks{latest}); err != nil {
+		t.Fatalf("StoreBlocks: %v", err)
+	}
+	deposits, err = db.Deposits.QueryDepositsByConfirmations(big.NewInt(0), big.NewInt(10), 2)
+	if err != nil {
+		t.Fatalf("QueryDepositsByConfirmations: %v", err)
+	}
+	var blocks []int64
+	for _, d := range deposits {
+		blocks = append(blocks, d.BlockNumber.Int64())
+	}
+	if len(blocks) != 2 || blocks[0] != 7 || blocks[1] != 8 {
+		t.Errorf("deposits with 2 confirmations are in blocks %v, want [7 8]", blocks)
+	}
+}
+
+func TestQueryDepositsByBlockRangeOrdersByTransferPosition(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	deposit := func(tx, txIndex uint, logIndex *uint) database.Deposits {
+		return database.Deposits{
+			BlockHash:    common.BigToHash(big.NewInt(10)),
+			BlockNumber:  big.NewInt(10),
+			TxHash:       common.BigToHash(big.NewInt(int64(tx))),
+			TxIndex:      txIndex,
+			LogIndex:     logIndex,
+			FromAddress:  common.HexToAddress("0x4000000000000000000000000000000000000004"),
+			ToAddress:    common.HexToAddress("0x1000000000000000000000000000000000000001"),
+			TokenAddress: common.HexToAddress("0x2000000000000000000000000000000000000002"),
+			Amount:       big.NewInt(1),
+			Timestamp:    10,
+		}
+	}
+	index := func(i uint) *uint { return &i }
+	// Two identical transfers in transaction 2 are told apart by their log
+	// index, and the native deposit of transaction 1 sorts before both.
+	err := db.Deposits.StoreDeposits([]database.Deposits{
+		deposit(2, 1, index(4)),
+		deposit(2, 1, index(3)),
+		deposit(1, 0, nil),
+	})
+	if err != nil {
+		t.Fatalf("StoreDeposits: %v", err)
+	}
+	if err := db.Deposits.StoreDeposits([]database.Deposits{deposit(2, 1, index(3))}); err == nil {
+		t.Error("storing a transfer twice succeeded, want a unique violation")
+	}
+
+	deposits, err := db.Deposits.QueryDepositsByBlockRange(big.NewInt(10), big.NewInt(10))
+	if err != nil {
+		t.Fatalf("QueryDepositsByBlockRange: %v", err)
+	}
+	var got []string
+	for _, d := range deposits {
+		position := fmt.Sprintf("%d", d.TxIndex)
+		if d.LogIndex != nil {
+			position += fmt.Sprintf(":%d", *d.LogIndex)
+		}
+		got = append(got, position)
+	}
+	if want := []string{"0", "1:3", "1:4"}; !slices.Equal(got, want) {
+		t.Errorf("deposits are at %v, want %v", got, want)
+	}
+}
+
+func TestCreateDepositPartitionRoutesDeposits(t *testing.T) {
+	db, cfg := dbtest.NewDB(t)
+	deposit := func(block int64) database.Deposits {
+		return database.Deposits{
+			BlockHash:    common.BigToHash(big.NewInt(block)),
+			BlockNumber:  big.NewInt(block),
+			TxHash:       common.BigToHash(big.NewInt(1_000 + block)),
+			FromAddress:  common.HexToAddress("0x4000000000000000000000000000000000000004"),
+			ToAddress:    common.HexToAddress("0x1000000000000000000000000000000000000001"),
+			TokenAddress: common.Address{},
+			Amount:       big.NewInt(block),
+			Timestamp:    uint64(block),
+		}
+	}
+	// Block 50 is stored before its partition exists, so it starts out in
+	// the default partition and is moved when the partition is created.
+	if err := db.Deposits.StoreDeposits([]database.Deposits{deposit(50), deposit(250)}); err != nil {
+		t.Fatalf("StoreDeposits: %v", err)
+	}
+	for _, r := range [][2]int64{{0, 100}, {100, 200}, {100, 200}} {
+		if err := db.Deposits.CreateDepositPartition(big.NewInt(r[0]), big.NewInt(r[1])); err != nil {
+			t.Fatalf("CreateDepositPartition(%d, %d): %v", r[0], r[1], err)
+		}
+	}
+	if err := db.Deposits.CreateDepositPartition(big.NewInt(150), big.NewInt(300)); err == nil {
+		t.Error("CreateDepositPartition of a range overlapping a partition succeeded")
+	}
+	if err := db.Deposits.StoreDeposits([]database.Deposits{deposit(150)}); err != nil {
+		t.Fatalf("StoreDeposits into a partition: %v", err)
+	}
+
+	// Only the deposits outside every partition are left after emptying the
+	// default partition.
+	dbtest.Exec(t, cfg, "DELETE FROM deposits_default")
+	deposits, err := db.Deposits.QueryDepositsByBlockRange(big.NewInt(0), big.NewInt(1_000))
+	if err != nil {
+		t.Fatalf("QueryDepositsByBlockRange: %v", err)
+	}
+	var got []int64
+	for _, d := range deposits {
+		got = append(got, d.BlockNumber.Int64())
+	}
+	if want := []int64{50, 150}; !slices.Equal(got, want) {
+		t.Errorf("deposits in partitions are in blocks %v, want %v", got, want)
+	}
+}
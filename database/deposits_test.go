@@ -0,0 +1,90 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAdjustedAmount(t *testing.T) {
+	decimals := func(d uint8) *uint8 { return &d }
+	oneAndAHalfEther, _ := new(big.Int).SetString("1500000000000000000", 10)
+	tests := []struct {
+		name     string
+		amount   *big.Int
+		decimals *uint8
+		want     string
+	}{
+		{"6 decimals", big.NewInt(1_500_000), decimals(6), "1.500000"},
+		{"6 decimals below one unit", big.NewInt(1), decimals(6), "0.000001"},
+		{"18 decimals", oneAndAHalfEther, decimals(18), "1.500000000000000000"},
+		{"18 decimals below one unit", big.NewInt(1), decimals(18), "0.000000000000000001"},
+		{"0 decimals", big.NewInt(42), decimals(0), "42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := AdjustedDeposit{Deposits: Deposits{Amount: tt.amount}, Decimals: tt.decimals}
+			got := d.AdjustedAmount()
+			if got == nil {
+				t.Fatal("AdjustedAmount = nil")
+			}
+			if s := got.FloatString(int(*tt.decimals)); s != tt.want {
+				t.Errorf("AdjustedAmount = %s, want %s", s, tt.want)
+			}
+		})
+	}
+
+	unknown := AdjustedDeposit{Deposits: Deposits{Amount: big.NewInt(1)}}
+	if got := unknown.AdjustedAmount(); got != nil {
+		t.Errorf("AdjustedAmount with unknown decimals = %s, want nil", got)
+	}
+}
+
+func TestQueryDepositsByConfirmationsQuery(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(`SELECT \* FROM "deposits" WHERE \(block_number >= \$1 AND block_number <= \$2\) AND block_number <= \(SELECT MAX\(number\) FROM blocks\) - \$3 ORDER BY block_number asc`).
+		WithArgs("5", "10", "12").
+		WillReturnRows(sqlmock.NewRows([]string{"block_number"}).AddRow("6"))
+
+	deposits, err := db.Deposits.QueryDepositsByConfirmations(big.NewInt(5), big.NewInt(10), 12)
+	if err != nil {
+		t.Fatalf("QueryDepositsByConfirmations: %v", err)
+	}
+	if len(deposits) != 1 || deposits[0].BlockNumber.Int64() != 6 {
+		t.Errorf("QueryDepositsByConfirmations = %v, want the deposit in block 6", deposits)
+	}
+}
+
+func TestCreateDepositPartitionStatements(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT to_regclass\(\$1\) IS NOT NULL`).
+		WithArgs("deposits_100_200").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(false))
+	mock.ExpectExec(`CREATE TABLE deposits_100_200 \(LIKE deposits`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO deposits_100_200 SELECT \* FROM deposits_default WHERE block_number >= 100 AND block_number < 200`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM deposits_default WHERE block_number >= 100 AND block_number < 200`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE deposits ATTACH PARTITION deposits_100_200 FOR VALUES FROM \(100\) TO \(200\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	if err := db.Deposits.CreateDepositPartition(big.NewInt(100), big.NewInt(200)); err != nil {
+		t.Fatalf("CreateDepositPartition: %v", err)
+	}
+
+	// An existing partition is left alone.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT to_regclass\(\$1\) IS NOT NULL`).
+		WithArgs("deposits_100_200").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(true))
+	mock.ExpectCommit()
+	if err := db.Deposits.CreateDepositPartition(big.NewInt(100), big.NewInt(200)); err != nil {
+		t.Fatalf("CreateDepositPartition of an existing partition: %v", err)
+	}
+
+	if err := db.Deposits.CreateDepositPartition(big.NewInt(200), big.NewInt(200)); err == nil {
+		t.Error("CreateDepositPartition of an empty range succeeded")
+	}
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"github.com/google/uuid"
+)
+
+// IDGenerator produces GUIDs for new rows.
+//
+// The default generates random UUIDv4 values. Deployments that want better
+// B-tree locality on the primary key can plug in a time-ordered generator
+// (e.g. UUIDv7 or a ULID encoded as a UUID) with SetIDGenerator.
+type IDGenerator interface {
+	NewID() uuid.UUID
+}
+
+// IDGeneratorFunc is an adapter to allow the use of ordinary functions as an
+// IDGenerator.
+type IDGeneratorFunc func() uuid.UUID
+
+// NewID calls f().
+func (f IDGeneratorFunc) NewID() uuid.UUID {
+	return f()
+}
+
+var idGenerator IDGenerator = IDGeneratorFunc(uuid.New)
+
+// SetIDGenerator replaces the generator used for new GUIDs. Passing nil
+// restores the UUIDv4 default. It is not safe to call concurrently with
+// inserts and should be called once at startup.
+func SetIDGenerator(gen IDGenerator) {
+	if gen == nil {
+		gen = IDGeneratorFunc(uuid.New)
+	}
+	idGenerator = gen
+}
+
+// NewGUID returns a new GUID from the configured IDGenerator.
+func NewGUID() uuid.UUID {
+	return idGenerator.NewID()
+}
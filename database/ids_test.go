@@ -0,0 +1,50 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// sequentialIDs is a deterministic IDGenerator returning 1, 2, 3, ...
+type sequentialIDs struct {
+	next byte
+}
+
+func (s *sequentialIDs) NewID() uuid.UUID {
+	s.next++
+	return uuid.UUID{15: s.next}
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	SetIDGenerator(&sequentialIDs{})
+	t.Cleanup(func() { SetIDGenerator(nil) })
+
+	// The BeforeCreate hook uses the generator.
+	db, mock := newMockDB(t)
+	stored := newTestAddress(t, AddressTypeUser)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "addresses"`).
+		WithArgs(uuid.UUID{15: 1}, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := db.Addresses.StoreAddresses([]Addresses{stored}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+
+	// So does the COPY import path, and a GUID set by the caller is kept.
+	copied := newTestAddress(t, AddressTypeUser)
+	if row := addressCopyRow(&copied, EVMAddressNormalizer{}, 0); row[0] != (uuid.UUID{15: 2}).String() {
+		t.Errorf("copied row GUID = %v, want %s", row[0], uuid.UUID{15: 2})
+	}
+	if row := addressCopyRow(&copied, EVMAddressNormalizer{}, 0); row[0] != (uuid.UUID{15: 2}).String() {
+		t.Errorf("copied row GUID = %v after a second encoding, want %s", row[0], uuid.UUID{15: 2})
+	}
+
+	// nil restores random UUIDv4s.
+	SetIDGenerator(nil)
+	if guid := NewGUID(); guid.Version() != 4 {
+		t.Errorf("NewGUID after SetIDGenerator(nil) = %s, want a v4 UUID", guid)
+	}
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// firewallConnector opens connections that a simulated firewall silently
+// drops once they have been idle for longer than timeout. Like pgx, a
+// connection that failed on the network reports itself invalid, so the pool
+// discards it.
+type firewallConnector struct {
+	timeout time.Duration
+}
+
+func (c *firewallConnector) Connect(context.Context) (driver.Conn, error) {
+	return &firewallConn{timeout: c.timeout, lastUsed: time.Now()}, nil
+}
+
+func (c *firewallConnector) Driver() driver.Driver {
+	return nil
+}
+
+type firewallConn struct {
+	timeout time.Duration
+
+	mu       sync.Mutex
+	lastUsed time.Time
+	broken   bool
+}
+
+// use fails if the firewall dropped the connection, and otherwise marks it
+// active.
+func (c *firewallConn) use() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.broken || time.Since(c.lastUsed) > c.timeout {
+		c.broken = true
+		return errors.New("connection reset by peer")
+	}
+	c.lastUsed = time.Now()
+	return nil
+}
+
+func (c *firewallConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	if err := c.use(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *firewallConn) Ping(context.Context) error {
+	return c.use()
+}
+
+func (c *firewallConn) IsValid() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.broken
+}
+
+func (c *firewallConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements not supported")
+}
+
+func (c *firewallConn) Close() error {
+	return nil
+}
+
+func (c *firewallConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+// newFirewallGorm returns a Gorm DB whose connections are dropped after
+// being idle for timeout.
+func newFirewallGorm(t *testing.T, timeout time.Duration) *gorm.DB {
+	t.Helper()
+	sqlDB := sql.OpenDB(&firewallConnector{timeout: timeout})
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+	return db
+}
+
+func TestKeepAliveSurvivesIdleDrop(t *testing.T) {
+	const firewallTimeout = 200 * time.Millisecond
+	idle := func(t *testing.T, keepAlive time.Duration) error {
+		db := newFirewallGorm(t, firewallTimeout)
+		stop, err := startKeepAlive(context.Background(), db, keepAlive)
+		if err != nil {
+			t.Fatalf("startKeepAlive: %v", err)
+		}
+		defer stop()
+		if err := db.Exec("SELECT 1").Error; err != nil {
+			t.Fatalf("first query: %v", err)
+		}
+		time.Sleep(2 * firewallTimeout)
+		return db.Exec("SELECT 1").Error
+	}
+
+	// Without keepalive the pooled connection was dropped while idle and
+	// the first query after the idle period fails.
+	if err := idle(t, 0); err == nil {
+		t.Fatal("query after idle period succeeded without keepalive; the test does not reproduce the drop")
+	}
+	if err := idle(t, firewallTimeout/10); err != nil {
+		t.Errorf("query after idle period with keepalive: %v", err)
+	}
+}
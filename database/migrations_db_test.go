@@ -0,0 +1,134 @@
+package database_test
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+// copyMigrations copies the migrations sorting before until into a new
+// directory and returns it.
+func copyMigrations(t *testing.T, until string) string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dbtest.MigrationsDir(), "*.sql"))
+	if err != nil {
+		t.Fatalf("list migrations: %v", err)
+	}
+	dir := t.TempDir()
+	for _, path := range paths {
+		if filepath.Base(path) >= until {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read migration: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(path)), content, 0o644); err != nil {
+			t.Fatalf("copy migration: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestMigrationNormalizesLegacyAddressTypes(t *testing.T) {
+	db, cfg := dbtest.NewEmptyDB(t)
+	if err := db.ExecuteSQLMigration(copyMigrations(t, "20261017006.sql")); err != nil {
+		t.Fatalf("apply migrations before the address type check: %v", err)
+	}
+
+	legacy := map[string]uint8{"out-of-range": 7, "max-smallint": 255, "hot": database.AddressTypeHot}
+	rows := make(map[string]database.Addresses, len(legacy))
+	for name, addressType := range legacy {
+		a := newAddress(t, database.AddressTypeUser)
+		a.GUID = uuid.New()
+		dbtest.Exec(t, cfg, "INSERT INTO addresses (guid, address, address_type, public_key, timestamp) VALUES ($1, $2, $3, $4, $5)",
+			a.GUID.String(), strings.ToLower(a.Address.Hex()), int16(addressType), a.PublicKey, a.Timestamp)
+		rows[name] = a
+	}
+
+	if err := db.ExecuteSQLMigration(dbtest.MigrationsDir()); err != nil {
+		t.Fatalf("apply remaining migrations: %v", err)
+	}
+	want := map[string]uint8{"out-of-range": database.AddressTypeUser, "max-smallint": database.AddressTypeUser, "hot": database.AddressTypeHot}
+	for name, a := range rows {
+		stored, err := db.Addresses.QueryAddressByGUID(a.GUID)
+		if err != nil {
+			t.Fatalf("QueryAddressByGUID: %v", err)
+		}
+		if stored.AddressType != want[name] {
+			t.Errorf("%s address type after migration = %d, want %d", name, stored.AddressType, want[name])
+		}
+	}
+}
+
+func TestExecuteSQLMigrationNumericOrder(t *testing.T) {
+	db, _ := dbtest.NewEmptyDB(t)
+	// Each migration depends on the previous one, and lexical order would
+	// run 10 before 2 and 9.
+	dir := t.TempDir()
+	files := map[string]string{
+		"10_insert.sql": "INSERT INTO ordering (id, label) VALUES (1, 'ok');",
+		"2_create.sql":  "CREATE TABLE ordering (id INTEGER PRIMARY KEY);",
+		"9_alter.sql":   "ALTER TABLE ordering ADD COLUMN label VARCHAR;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.ExecuteSQLMigration(dir); err != nil {
+		t.Fatalf("ExecuteSQLMigration: %v", err)
+	}
+	applied, err := db.AppliedMigrations()
+	if err != nil {
+		t.Fatalf("AppliedMigrations: %v", err)
+	}
+	if want := []string{"2_create.sql", "9_alter.sql", "10_insert.sql"}; !slices.Equal(applied, want) {
+		t.Errorf("applied migrations = %v, want %v", applied, want)
+	}
+}
+
+func TestMigrationPartitionsExistingDeposits(t *testing.T) {
+	db, _ := dbtest.NewEmptyDB(t)
+	if err := db.ExecuteSQLMigration(copyMigrations(t, "20261017021.sql")); err != nil {
+		t.Fatalf("apply migrations before deposits partitioning: %v", err)
+	}
+	deposit := database.Deposits{
+		GUID:        uuid.New(),
+		BlockHash:   common.BigToHash(big.NewInt(7)),
+		BlockNumber: big.NewInt(7),
+		TxHash:      common.BigToHash(big.NewInt(7)),
+		FromAddress: common.HexToAddress("0x4000000000000000000000000000000000000004"),
+		ToAddress:   common.HexToAddress("0x1000000000000000000000000000000000000001"),
+		Amount:      big.NewInt(7),
+		Timestamp:   7,
+	}
+	if err := db.Deposits.StoreDeposits([]database.Deposits{deposit}); err != nil {
+		t.Fatalf("StoreDeposits: %v", err)
+	}
+
+	if err := db.ExecuteSQLMigration(dbtest.MigrationsDir()); err != nil {
+		t.Fatalf("apply remaining migrations: %v", err)
+	}
+	deposits, err := db.Deposits.QueryDepositsByBlockRange(big.NewInt(7), big.NewInt(7))
+	if err != nil {
+		t.Fatalf("QueryDepositsByBlockRange: %v", err)
+	}
+	if len(deposits) != 1 || deposits[0].GUID != deposit.GUID {
+		t.Fatalf("deposits after partitioning = %v, want the deposit stored before", deposits)
+	}
+	// The partition takes over the migrated deposit from the default one.
+	if err := db.Deposits.CreateDepositPartition(big.NewInt(0), big.NewInt(10)); err != nil {
+		t.Fatalf("CreateDepositPartition: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Nonces 结构体记录每个热钱包下一笔待签名交易使用的 nonce。
+type Nonces struct {
+	// GUID 是记录的唯一标识符，并且是主键。
+	GUID uuid.UUID `gorm:"primaryKey" json:"guid"`
+
+	// Address 是热钱包地址，每个地址只有一条记录。
+	Address common.Address `json:"address" gorm:"serializer:bytes"`
+
+	// Nonce 是该地址下一笔交易应使用的 nonce。
+	Nonce uint64 `json:"nonce"`
+
+	// Timestamp 是最后一次分配 nonce 的时间戳（秒）。
+	Timestamp int64 `json:"timestamp"`
+}
+
+// BeforeCreate assigns a GUID from the configured IDGenerator when the
+// caller left it unset.
+func (n *Nonces) BeforeCreate(_ *gorm.DB) error {
+	if n.GUID == uuid.Nil {
+		n.GUID = NewGUID()
+	}
+	return nil
+}
+
+// NonceSeeder returns the on-chain nonce of an address. It seeds the stored
+// nonce the first time NextNonce is called for that address.
+type NonceSeeder func(address common.Address) (uint64, error)
+
+// NonceDB hands out monotonically increasing nonces per hot wallet, so
+// withdrawals signed concurrently never share a nonce.
+type NonceDB interface {
+	// NextNonce 方法返回该地址下一笔交易应使用的 nonce，并在同一事务中把存储的值加一。
+	// 地址第一次使用时，用 NonceSeeder 给出的链上 nonce 初始化。
+	NextNonce(address common.Address) (uint64, error)
+}
+
+type nonceDB struct {
+	gorm       *gorm.DB
+	seed       NonceSeeder
+	normalizer AddressNormalizer
+}
+
+// NewNonceDB returns a NonceDB backed by the given Gorm DB that builds
+// address lookup keys with normalizer. seed may be nil, in which case
+// NextNonce fails for addresses without a stored nonce.
+func NewNonceDB(db *gorm.DB, seed NonceSeeder, normalizer AddressNormalizer) NonceDB {
+	return &nonceDB{gorm: db, seed: seed, normalizer: normalizer}
+}
+
+func (db *nonceDB) NextNonce(address common.Address) (uint64, error) {
+	var next uint64
+	err := db.gorm.Transaction(func(tx *gorm.DB) error {
+		nonce, err := db.lockNonce(tx, address)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := db.seedNonce(tx, address); err != nil {
+				return err
+			}
+			nonce, err = db.lockNonce(tx, address)
+		}
+		if err != nil {
+			return err
+		}
+
+		next = nonce.Nonce
+		return tx.Table("nonces").Where("guid = ?", nonce.GUID).Updates(map[string]any{
+			"nonce":     nonce.Nonce + 1,
+			"timestamp": time.Now().Unix(),
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// lockNonce reads the stored nonce of address, locking the row until the
+// transaction ends.
+func (db *nonceDB) lockNonce(tx *gorm.DB, address common.Address) (*Nonces, error) {
+	var nonce Nonces
+	err := tx.Table("nonces").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("address = ?", db.normalizer.Normalize(address)).
+		Take(&nonce).Error
+	if err != nil {
+		return nil, err
+	}
+	return &nonce, nil
+}
+
+// seedNonce stores the on-chain nonce of address. A concurrent caller may
+// seed the same address first, in which case its row is kept.
+func (db *nonceDB) seedNonce(tx *gorm.DB, address common.Address) error {
+	if db.seed == nil {
+		return fmt.Errorf("no nonce stored for %s and no nonce seeder configured", address)
+	}
+	onChain, err := db.seed(address)
+	if err != nil {
+		return fmt.Errorf("failed to seed nonce for %s: %w", address, err)
+	}
+	seed := Nonces{Address: address, Nonce: onChain, Timestamp: time.Now().Unix()}
+	return tx.Table("nonces").Clauses(clause.OnConflict{DoNothing: true}).Create(&seed).Error
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// AddressNormalizer converts between the 20-byte address used internally and
+// the canonical string form used as the lookup key in the address column.
+//
+// EVM chains use lowercase 0x hex, which is also what the bytes serializer
+// writes. A chain with a different text format (e.g. Tron's base58 over the
+// same 20 bytes) plugs in its own normalizer; it must then be paired with a
+// column serializer that writes the same form.
+type AddressNormalizer interface {
+	// Normalize returns the canonical stored form of address.
+	Normalize(address common.Address) string
+	// Parse decodes an address string in any form accepted by the chain.
+	Parse(s string) (common.Address, error)
+}
+
+// EVMAddressNormalizer is the default AddressNormalizer for EVM chains.
+//
+// Normalize encodes with hexutil.Encode, the same function the bytes
+// serializer uses when writing the address column. Lookup keys and stored
+// values therefore share one encoding and cannot drift apart, whatever the
+// case of the input (e.g. a checksummed address).
+type EVMAddressNormalizer struct{}
+
+func (EVMAddressNormalizer) Normalize(address common.Address) string {
+	return hexutil.Encode(address.Bytes())
+}
+
+func (EVMAddressNormalizer) Parse(s string) (common.Address, error) {
+	if !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("invalid EVM address: %q", s)
+	}
+	return common.HexToAddress(s), nil
+}
+
+var (
+	normalizersMu sync.RWMutex
+	normalizers   = map[uint64]AddressNormalizer{}
+)
+
+// RegisterAddressNormalizer sets the normalizer used for the given chain ID.
+func RegisterAddressNormalizer(chainID uint64, normalizer AddressNormalizer) {
+	normalizersMu.Lock()
+	defer normalizersMu.Unlock()
+	normalizers[chainID] = normalizer
+}
+
+// AddressNormalizerForChain returns the normalizer registered for the chain
+// ID, or EVMAddressNormalizer if none was registered.
+func AddressNormalizerForChain(chainID uint64) AddressNormalizer {
+	normalizersMu.RLock()
+	defer normalizersMu.RUnlock()
+	if normalizer, ok := normalizers[chainID]; ok {
+		return normalizer
+	}
+	return EVMAddressNormalizer{}
+}
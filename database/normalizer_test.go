@@ -0,0 +1,85 @@
+package database
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// upperNormalizer stores addresses as uppercase hex behind a "0X" prefix,
+// standing in for a chain with its own address text format.
+type upperNormalizer struct{}
+
+func (upperNormalizer) Normalize(address common.Address) string {
+	return "0X" + strings.ToUpper(hex.EncodeToString(address.Bytes()))
+}
+
+func (upperNormalizer) Parse(s string) (common.Address, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0X"))
+	if err != nil || len(b) != common.AddressLength {
+		return common.Address{}, fmt.Errorf("invalid address: %q", s)
+	}
+	return common.BytesToAddress(b), nil
+}
+
+func TestAddressNormalizerForChain(t *testing.T) {
+	const chainID = 424242
+	if _, ok := AddressNormalizerForChain(chainID).(EVMAddressNormalizer); !ok {
+		t.Fatalf("unregistered chain uses %T, want EVMAddressNormalizer", AddressNormalizerForChain(chainID))
+	}
+	RegisterAddressNormalizer(chainID, upperNormalizer{})
+	if _, ok := AddressNormalizerForChain(chainID).(upperNormalizer); !ok {
+		t.Fatalf("registered chain uses %T, want upperNormalizer", AddressNormalizerForChain(chainID))
+	}
+}
+
+func TestTablesUseNormalizer(t *testing.T) {
+	gorm, mock := newMockGorm(t)
+	normalizer := upperNormalizer{}
+	db := newDB(gorm, gorm, normalizer, nil)
+	address := common.HexToAddress("0xabcdef0000000000000000000000000000000001")
+	token := common.HexToAddress("0xabcdef0000000000000000000000000000000002")
+	key, tokenKey := normalizer.Normalize(address), normalizer.Normalize(token)
+	empty := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"guid"}) }
+
+	mock.ExpectQuery(`FROM "addresses" WHERE "address" = \$1`).WithArgs(key, sqlmock.AnyArg()).WillReturnRows(empty())
+	if ok, _ := db.Addresses.AddressExist(&address); ok {
+		t.Errorf("AddressExist = true on empty result")
+	}
+
+	mock.ExpectQuery(`FROM "deposits" WHERE to_address = \$1`).WithArgs(key, sqlmock.AnyArg()).WillReturnRows(empty())
+	if _, err := db.Deposits.QueryLatestDepositByToAddress(address); err != nil {
+		t.Errorf("QueryLatestDepositByToAddress: %v", err)
+	}
+
+	mock.ExpectQuery(`FROM "balances" WHERE address = \$1 AND token_address = \$2`).WithArgs(key, tokenKey, sqlmock.AnyArg()).WillReturnRows(empty())
+	if _, err := db.Balances.QueryBalance(address, token); err != nil {
+		t.Errorf("QueryBalance: %v", err)
+	}
+
+	mock.ExpectQuery(`FROM "tokens" WHERE token_address = \$1`).WithArgs(tokenKey, sqlmock.AnyArg()).WillReturnRows(empty())
+	if _, err := db.Tokens.QueryToken(token); err != nil {
+		t.Errorf("QueryToken: %v", err)
+	}
+
+	mock.ExpectQuery(`FROM "balance_history" WHERE \(address = \$1 AND token_address = \$2\)`).WithArgs(key, tokenKey, "0", "10").WillReturnRows(empty())
+	if _, err := db.BalanceHistory.QueryBalanceHistory(address, token, big.NewInt(0), big.NewInt(10)); err != nil {
+		t.Errorf("QueryBalanceHistory: %v", err)
+	}
+
+	// The cache parses the stored form back with the normalizer.
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "addresses"`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT "address","address_type" FROM "addresses"`).
+		WillReturnRows(sqlmock.NewRows([]string{"address", "address_type"}).AddRow(key, AddressTypeUser))
+	if err := db.EnableAddressCache(10); err != nil {
+		t.Fatalf("EnableAddressCache: %v", err)
+	}
+	if ok, _ := db.Addresses.AddressExist(&address); !ok {
+		t.Errorf("cached AddressExist(%s) = false, want true", address)
+	}
+}
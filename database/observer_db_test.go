@@ -0,0 +1,36 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+func TestObserverDBRejectsWrites(t *testing.T) {
+	db, cfg := dbtest.NewDB(t)
+	stored := newAddress(t, database.AddressTypeUser)
+	if err := db.Addresses.StoreAddresses([]database.Addresses{stored}); err != nil {
+		t.Fatalf("StoreAddresses: %v", err)
+	}
+
+	observer, err := database.NewObserverDB(context.Background(), cfg, 0)
+	if err != nil {
+		t.Fatalf("NewObserverDB: %v", err)
+	}
+	defer observer.Close()
+	if ok, _ := observer.Addresses.AddressExist(&stored.Address); !ok {
+		t.Errorf("observer does not see stored address %s", stored.Address)
+	}
+
+	// Even a caller that gets at the write methods is stopped by the
+	// read-only connection.
+	writer, ok := observer.Addresses.(database.AddressesDB)
+	if !ok {
+		t.Skip("observer addresses table has no write methods to try")
+	}
+	if err := writer.StoreAddresses([]database.Addresses{newAddress(t, database.AddressTypeUser)}); err == nil {
+		t.Error("observer connection accepted a write")
+	}
+}
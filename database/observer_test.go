@@ -0,0 +1,44 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestObserverDBExposesOnlyViews(t *testing.T) {
+	// The write interface of each table, by the view the observer exposes.
+	writers := map[reflect.Type]reflect.Type{
+		reflect.TypeFor[AddressesView]():      reflect.TypeFor[AddressesDB](),
+		reflect.TypeFor[BlocksView]():         reflect.TypeFor[BlocksDB](),
+		reflect.TypeFor[DepositsView]():       reflect.TypeFor[DepositsDB](),
+		reflect.TypeFor[SweepsView]():         reflect.TypeFor[SweepsDB](),
+		reflect.TypeFor[ReorgsView]():         reflect.TypeFor[ReorgsDB](),
+		reflect.TypeFor[BalancesView]():       reflect.TypeFor[BalancesDB](),
+		reflect.TypeFor[TokensView]():         reflect.TypeFor[TokensDB](),
+		reflect.TypeFor[BalanceHistoryView](): reflect.TypeFor[BalanceHistoryDB](),
+		reflect.TypeFor[WithdrawalsView]():    reflect.TypeFor[WithdrawalsDB](),
+	}
+
+	observer := reflect.TypeFor[ObserverDB]()
+	for i := range observer.NumField() {
+		field := observer.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		writer, ok := writers[field.Type]
+		if !ok {
+			t.Errorf("ObserverDB.%s has type %s, want a table view", field.Name, field.Type)
+			continue
+		}
+		if field.Type.Implements(writer) {
+			t.Errorf("ObserverDB.%s exposes the write methods of %s", field.Name, writer)
+		}
+	}
+
+	// Close is the only method of the handle itself.
+	for i := range reflect.PointerTo(observer).NumMethod() {
+		if name := reflect.PointerTo(observer).Method(i).Name; name != "Close" {
+			t.Errorf("ObserverDB has method %s", name)
+		}
+	}
+}
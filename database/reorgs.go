@@ -0,0 +1,79 @@
+package database
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reorgs 结构体记录扫描器处理过的一次链重组，用于事后审计。
+type Reorgs struct {
+	// GUID 是重组记录的唯一标识符，并且是主键。
+	GUID uuid.UUID `gorm:"primaryKey" json:"guid"`
+
+	// OldHash 是被回滚的旧链在 FromBlock 高度上的区块哈希，
+	// NewHash 是新链在同一高度上的区块哈希。
+	OldHash common.Hash `json:"oldHash" gorm:"serializer:bytes"`
+	NewHash common.Hash `json:"newHash" gorm:"serializer:bytes"`
+
+	// Depth 是被回滚的区块数量。
+	Depth uint64 `json:"depth"`
+
+	// FromBlock 和 ToBlock 是受影响（被删除）的区块高度范围，包含两端。
+	FromBlock *big.Int `json:"fromBlock" gorm:"serializer:u256"`
+	ToBlock   *big.Int `json:"toBlock" gorm:"serializer:u256"`
+
+	// Timestamp 是检测到重组的时间（秒）。
+	Timestamp int64 `json:"timestamp"`
+}
+
+// BeforeCreate assigns a GUID from the configured IDGenerator when the
+// caller left it unset.
+func (r *Reorgs) BeforeCreate(_ *gorm.DB) error {
+	if r.GUID == uuid.Nil {
+		r.GUID = NewGUID()
+	}
+	return nil
+}
+
+// ReorgsView defines read access to the reorg history.
+type ReorgsView interface {
+	// QueryReorgs returns reorgs whose fork point (FromBlock) lies within
+	// [from, to], ordered by FromBlock.
+	QueryReorgs(from, to *big.Int) ([]*Reorgs, error)
+}
+
+// ReorgsDB 在 ReorgsView 的基础上增加了记录重组的能力。
+type ReorgsDB interface {
+	ReorgsView
+
+	// StoreReorg 方法用于记录一次重组。
+	StoreReorg(reorg Reorgs) error
+}
+
+type reorgsDB struct {
+	gorm *gorm.DB
+}
+
+// NewReorgsDB returns a ReorgsDB backed by the given Gorm DB.
+func NewReorgsDB(db *gorm.DB) ReorgsDB {
+	return &reorgsDB{gorm: db}
+}
+
+func (db *reorgsDB) StoreReorg(reorg Reorgs) error {
+	return db.gorm.Table("reorgs").Create(&reorg).Error
+}
+
+func (db *reorgsDB) QueryReorgs(from, to *big.Int) ([]*Reorgs, error) {
+	var reorgs []*Reorgs
+	err := db.gorm.Table("reorgs").
+		Where("from_block >= ? AND from_block <= ?", from.String(), to.String()).
+		Order("from_block asc").
+		Find(&reorgs).Error
+	if err != nil {
+		return nil, err
+	}
+	return reorgs, nil
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Sweeps 结构体表示一笔两端都是受管地址的转账，类型见 SweepType* 常量。
+// 归集与外部充值分开记录，用于跟踪 充值 → 归集 → 冷钱包 的完整资金流转。
+type Sweeps struct {
+	// GUID 是归集记录的唯一标识符，并且是主键。
+	GUID uuid.UUID `gorm:"primaryKey" json:"guid"`
+
+	// BlockHash 和 BlockNumber 标识归集交易所在的区块。
+	BlockHash   common.Hash `json:"blockHash" gorm:"serializer:bytes"`
+	BlockNumber *big.Int    `json:"blockNumber" gorm:"serializer:u256"`
+
+	// TxHash 是归集交易的哈希。
+	TxHash common.Hash `json:"txHash" gorm:"serializer:bytes"`
+
+	// TxIndex 是交易在区块中的序号。LogIndex 是 ERC20 Transfer 日志在区块中的序号，
+	// 原生币归集没有日志，为空。两者用于同一区块、同一交易内多笔转账的排序和去重。
+	TxIndex  uint  `json:"txIndex"`
+	LogIndex *uint `json:"logIndex"`
+
+	// FromAddress 是转出的受管地址，ToAddress 是接收的受管地址。
+	FromAddress common.Address `json:"fromAddress" gorm:"serializer:bytes"`
+	ToAddress   common.Address `json:"toAddress" gorm:"serializer:bytes"`
+
+	// Type 是转账的类型，取值见 SweepType* 常量。
+	Type uint8 `json:"type"`
+
+	// TokenAddress 是代币合约地址，原生币归集时为零地址。
+	TokenAddress common.Address `json:"tokenAddress" gorm:"serializer:bytes"`
+
+	// Amount 是归集金额（最小单位）。
+	Amount *big.Int `json:"amount" gorm:"serializer:u256"`
+
+	// DepositGUID 关联该用户地址在归集之前最近的一笔充值，没有找到或不是 SweepTypeSweep 时为空。
+	DepositGUID *uuid.UUID `json:"depositGuid"`
+
+	// Timestamp 是所在区块的时间戳（秒）。
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// Sweep types stored in Sweeps.Type, by the types of the two tracked
+// addresses.
+const (
+	// SweepTypeSweep moves funds from a user address to a hot wallet.
+	SweepTypeSweep uint8 = 0
+	// SweepTypeColdCollection moves funds from a hot wallet to a cold
+	// wallet.
+	SweepTypeColdCollection uint8 = 1
+	// SweepTypeInternal moves funds between two user addresses.
+	SweepTypeInternal uint8 = 2
+)
+
+// BeforeCreate assigns a GUID from the configured IDGenerator when the
+// caller left it unset.
+func (s *Sweeps) BeforeCreate(_ *gorm.DB) error {
+	if s.GUID == uuid.Nil {
+		s.GUID = NewGUID()
+	}
+	return nil
+}
+
+// SweepsView defines read access to recorded sweeps.
+type SweepsView interface {
+	// QuerySweepsByBlockRange returns sweeps with from <= block number <= to,
+	// ordered by block number, transaction index and log index.
+	QuerySweepsByBlockRange(from, to *big.Int) ([]*Sweeps, error)
+	// QuerySweepsByDeposit returns the sweeps linked to the given deposit.
+	QuerySweepsByDeposit(depositGUID uuid.UUID) ([]*Sweeps, error)
+}
+
+// SweepsDB 在 SweepsView 的基础上增加了存储归集记录的能力。
+type SweepsDB interface {
+	SweepsView
+
+	// StoreSweeps 方法用于批量存储归集记录。
+	StoreSweeps([]Sweeps) error
+	// DeleteSweepsFrom 方法删除区块高度大于等于 number 的归集记录，用于链重组回滚。
+	DeleteSweepsFrom(number *big.Int) error
+}
+
+type sweepsDB struct {
+	gorm *gorm.DB
+}
+
+// NewSweepsDB returns a SweepsDB backed by the given Gorm DB.
+func NewSweepsDB(db *gorm.DB) SweepsDB {
+	return &sweepsDB{gorm: db}
+}
+
+func (db *sweepsDB) StoreSweeps(sweepList []Sweeps) error {
+	if len(sweepList) == 0 {
+		return nil
+	}
+	result := db.gorm.Table("sweeps").CreateInBatches(&sweepList, len(sweepList))
+	return result.Error
+}
+
+func (db *sweepsDB) QuerySweepsByBlockRange(from, to *big.Int) ([]*Sweeps, error) {
+	var sweeps []*Sweeps
+	err := db.gorm.Table("sweeps").
+		Where("block_number >= ? AND block_number <= ?", from.String(), to.String()).
+		Order(transferOrder).
+		Find(&sweeps).Error
+	if err != nil {
+		return nil, err
+	}
+	return sweeps, nil
+}
+
+func (db *sweepsDB) QuerySweepsByDeposit(depositGUID uuid.UUID) ([]*Sweeps, error) {
+	var sweeps []*Sweeps
+	err := db.gorm.Table("sweeps").
+		Where("deposit_guid = ?", depositGUID).
+		Order(transferOrder).
+		Find(&sweeps).Error
+	if err != nil {
+		return nil, err
+	}
+	return sweeps, nil
+}
+
+func (db *sweepsDB) DeleteSweepsFrom(number *big.Int) error {
+	return db.gorm.Table("sweeps").Where("block_number >= ?", number.String()).Delete(&Sweeps{}).Error
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tokens 结构体保存 ERC20 代币的元数据，用于格式化代币充值的金额。
+// 每个代币合约地址只有一行。
+type Tokens struct {
+	// GUID 是代币记录的唯一标识符，并且是主键。
+	GUID uuid.UUID `gorm:"primaryKey" json:"guid"`
+
+	// TokenAddress 是代币合约地址，唯一。充值按 Transfer 日志的发出地址匹配，
+	// 因此可升级的代理合约代币（如 USDC）必须填写代理合约地址，而不是实现合约地址。
+	TokenAddress common.Address `json:"tokenAddress" gorm:"serializer:bytes"`
+
+	// Symbol 是代币符号，Name 是代币全称。
+	Symbol string `json:"symbol"`
+	Name   string `json:"name"`
+
+	// Decimals 是代币的精度。
+	Decimals uint8 `json:"decimals"`
+
+	// CollectAmount 是触发归集的最小余额（最小单位）。
+	CollectAmount *big.Int `json:"collectAmount" gorm:"serializer:u256"`
+
+	// HotCeiling 是热钱包余额的上限（最小单位），超过时把多余部分转入冷钱包，为 0 时不转。
+	// HotRetain 是转入冷钱包后热钱包保留的余额，应不大于 HotCeiling。
+	HotCeiling *big.Int `json:"hotCeiling" gorm:"serializer:u256"`
+	HotRetain  *big.Int `json:"hotRetain" gorm:"serializer:u256"`
+
+	// Timestamp 是记录创建的时间戳（秒）。
+	Timestamp int64 `json:"timestamp"`
+}
+
+// BeforeCreate assigns a GUID from the configured IDGenerator when the
+// caller left it unset.
+func (t *Tokens) BeforeCreate(_ *gorm.DB) error {
+	if t.GUID == uuid.Nil {
+		t.GUID = NewGUID()
+	}
+	return nil
+}
+
+// TokensView defines read access to token metadata.
+type TokensView interface {
+	// QueryToken returns the metadata of the token at address, or nil and a
+	// nil error if the token is unknown.
+	QueryToken(address common.Address) (*Tokens, error)
+	// ListTokens returns all known tokens ordered by symbol.
+	ListTokens() ([]*Tokens, error)
+}
+
+// TokensDB 在 TokensView 的基础上增加了存储代币元数据的能力。
+type TokensDB interface {
+	TokensView
+
+	// StoreTokens 方法用于批量存储代币元数据。合约地址已存在时返回唯一约束错误。
+	StoreTokens([]Tokens) error
+}
+
+type tokensDB struct {
+	gorm       *gorm.DB
+	normalizer AddressNormalizer
+}
+
+// NewTokensDB returns a TokensDB backed by the given Gorm DB that builds
+// address lookup keys with normalizer.
+func NewTokensDB(db *gorm.DB, normalizer AddressNormalizer) TokensDB {
+	return &tokensDB{gorm: db, normalizer: normalizer}
+}
+
+func (db *tokensDB) StoreTokens(tokenList []Tokens) error {
+	if len(tokenList) == 0 {
+		return nil
+	}
+	for i := range tokenList {
+		if tokenList[i].CollectAmount == nil {
+			tokenList[i].CollectAmount = new(big.Int)
+		}
+		if tokenList[i].HotCeiling == nil {
+			tokenList[i].HotCeiling = new(big.Int)
+		}
+		if tokenList[i].HotRetain == nil {
+			tokenList[i].HotRetain = new(big.Int)
+		}
+	}
+	result := db.gorm.Table("tokens").CreateInBatches(&tokenList, len(tokenList))
+	return result.Error
+}
+
+func (db *tokensDB) QueryToken(address common.Address) (*Tokens, error) {
+	var token Tokens
+	err := db.gorm.Table("tokens").Where("token_address = ?", db.normalizer.Normalize(address)).Take(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (db *tokensDB) ListTokens() ([]*Tokens, error) {
+	var tokens []*Tokens
+	err := db.gorm.Table("tokens").Order("symbol asc").Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
@@ -3,10 +3,12 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
 	"github.com/ethereum/go-ethereum/log"
@@ -15,11 +17,21 @@ import (
 var (
 	_ logger.Interface = Logger{}
 
+	// SlowThresholdMilliseconds is the slow query threshold used by NewLogger.
 	SlowThresholdMilliseconds = 200
 )
 
 type Logger struct {
 	log log.Logger
+	// slowThresholdMs is the duration from which queries are logged at warn
+	// instead of debug level.
+	slowThresholdMs int64
+
+	// IgnoreRecordNotFound stops failed queries from being logged as errors
+	// when they only failed with gorm.ErrRecordNotFound, which is an expected
+	// outcome of lookups such as AddressExist. Such queries are logged like
+	// successful ones. It is enabled by the constructors.
+	IgnoreRecordNotFound bool
 }
 
 // NewLogger creates a new Logger instance with a specific module name.
@@ -37,7 +49,13 @@ type Logger struct {
 //
 //	A Logger instance implementing the gorm logger.Interface
 func NewLogger(log log.Logger) Logger {
-	return Logger{log.New("module", "db")}
+	return NewLoggerWithThreshold(log, SlowThresholdMilliseconds)
+}
+
+// NewLoggerWithThreshold is like NewLogger but logs queries taking at least
+// slowThresholdMs milliseconds as slow, instead of SlowThresholdMilliseconds.
+func NewLoggerWithThreshold(log log.Logger, slowThresholdMs int) Logger {
+	return Logger{log: log.New("module", "db"), slowThresholdMs: int64(slowThresholdMs), IgnoreRecordNotFound: true}
 }
 
 func (l Logger) LogMode(lvl logger.LogLevel) logger.Interface {
@@ -96,9 +114,12 @@ func (l Logger) Trace(_ context.Context, begin time.Time, fc func() (sql string,
 		sql = fmt.Sprintf("%sVALUES (...)", sql[:i])
 	}
 
-	if elapsedMs < 200 {
+	switch {
+	case err != nil && !(l.IgnoreRecordNotFound && errors.Is(err, gorm.ErrRecordNotFound)):
+		l.log.Error("database operation failed", "duration_ms", elapsedMs, "rows_affected", rows, "sql", sql, "err", err)
+	case elapsedMs < l.slowThresholdMs:
 		l.log.Debug("database operation", "duration_ms", elapsedMs, "rows_affected", rows, "sql", sql)
-	} else {
+	default:
 		l.log.Warn("database operation", "duration_ms", elapsedMs, "rows_affected", rows, "sql", sql)
 	}
 }
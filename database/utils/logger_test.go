@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/log"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newCapturedGorm returns a Gorm DB on a sqlmock connection whose queries are
+// logged through a Logger writing every level to the returned buffer.
+func newCapturedGorm(t *testing.T, ignoreRecordNotFound bool) (*gorm.DB, sqlmock.Sqlmock, *bytes.Buffer) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("open sqlmock: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	var out bytes.Buffer
+	l := NewLogger(log.NewLogger(log.LogfmtHandlerWithLevel(&out, slog.LevelDebug)))
+	l.IgnoreRecordNotFound = ignoreRecordNotFound
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: l})
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+	return db, mock, &out
+}
+
+func TestLoggerNotFoundQuery(t *testing.T) {
+	for _, tt := range []struct {
+		ignoreRecordNotFound bool
+		wantLevel            string
+	}{
+		{true, "lvl=debug"},
+		{false, "lvl=error"},
+	} {
+		db, mock, out := newCapturedGorm(t, tt.ignoreRecordNotFound)
+		mock.ExpectQuery(`SELECT \* FROM "addresses"`).WillReturnRows(sqlmock.NewRows([]string{"guid"}))
+		var row struct{ GUID string }
+		if err := db.Table("addresses").Take(&row).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("Take error = %v, want %v", err, gorm.ErrRecordNotFound)
+		}
+		logged := out.String()
+		if !strings.Contains(logged, tt.wantLevel) || !strings.Contains(logged, `FROM \"addresses\"`) {
+			t.Errorf("IgnoreRecordNotFound=%t logged %q, want the query at %s", tt.ignoreRecordNotFound, logged, tt.wantLevel)
+		}
+		if tt.ignoreRecordNotFound && strings.Contains(logged, "failed") {
+			t.Errorf("not-found query logged as a failure: %q", logged)
+		}
+	}
+}
+
+func TestLoggerFailedQuery(t *testing.T) {
+	db, mock, out := newCapturedGorm(t, true)
+	mock.ExpectQuery(`SELECT \* FROM "addresses"`).WillReturnError(errors.New("relation does not exist"))
+	var row struct{ GUID string }
+	if err := db.Table("addresses").Take(&row).Error; err == nil {
+		t.Fatal("Take succeeded, want the driver error")
+	}
+	logged := out.String()
+	if !strings.Contains(logged, "lvl=error") || !strings.Contains(logged, "database operation failed") ||
+		!strings.Contains(logged, "relation does not exist") {
+		t.Errorf("failed query logged %q, want an error with the cause", logged)
+	}
+}
@@ -45,7 +45,9 @@ func init() {
 // an error.
 //
 // Finally, it will set the deserialized value into the dst value using `ReflectValueOf`.
-func (BytesSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+func (BytesSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) (err error) {
+	defer observeError("bytes", field, &err)
+
 	if dbValue == nil {
 		return nil
 	}
@@ -87,7 +89,9 @@ func (BytesSerializer) Scan(ctx context.Context, field *schema.Field, dst reflec
 	return nil
 }
 
-func (BytesSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+func (BytesSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (_ interface{}, err error) {
+	defer observeError("bytes", field, &err)
+
 	if fieldValue == nil || (field.FieldType.Kind() == reflect.Pointer && reflect.ValueOf(fieldValue).IsNil()) {
 		return nil, nil
 	}
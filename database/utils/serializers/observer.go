@@ -0,0 +1,41 @@
+package serializers
+
+import (
+	"reflect"
+	"sync/atomic"
+
+	"gorm.io/gorm/schema"
+)
+
+// ErrorObserver is notified whenever a serializer's Scan or Value fails.
+// serializer is the registered name ("bytes", "rlp", "u256") and fieldType
+// is the Go type of the model field. Implementations typically increment a
+// metrics counter labeled by both, so schema/serializer drift is visible.
+type ErrorObserver func(serializer string, fieldType reflect.Type, err error)
+
+var errorObserver atomic.Pointer[ErrorObserver]
+
+// SetErrorObserver installs the observer called on serializer errors. Passing
+// nil removes it.
+func SetErrorObserver(observer ErrorObserver) {
+	if observer == nil {
+		errorObserver.Store(nil)
+		return
+	}
+	errorObserver.Store(&observer)
+}
+
+// observeError reports *errp to the installed observer, if any. It is meant
+// to be deferred with the serializer method's named error result.
+func observeError(serializer string, field *schema.Field, errp *error) {
+	if *errp == nil {
+		return
+	}
+	if observer := errorObserver.Load(); observer != nil {
+		var fieldType reflect.Type
+		if field != nil {
+			fieldType = field.FieldType
+		}
+		(*observer)(serializer, fieldType, *errp)
+	}
+}
@@ -17,7 +17,9 @@ func init() {
 	schema.RegisterSerializer("rlp", RLPSerializer{})
 }
 
-func (RLPSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+func (RLPSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) (err error) {
+	defer observeError("rlp", field, &err)
+
 	if dbValue == nil {
 		return nil
 	}
@@ -41,7 +43,9 @@ func (RLPSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.
 	return nil
 }
 
-func (RLPSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+func (RLPSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (_ interface{}, err error) {
+	defer observeError("rlp", field, &err)
+
 	if fieldValue == nil || (field.FieldType.Kind() == reflect.Pointer && reflect.ValueOf(fieldValue).IsNil()) {
 		return nil, nil
 	}
@@ -6,6 +6,7 @@ import (
 	"math/big"
 	"reflect"
 
+	"github.com/holiman/uint256"
 	"github.com/jackc/pgtype"
 	"gorm.io/gorm/schema"
 )
@@ -13,23 +14,31 @@ import (
 var (
 	big10              = big.NewInt(10)
 	u256BigIntOverflow = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), nil)
+
+	bigIntType  = reflect.TypeOf((*big.Int)(nil))
+	uint256Type = reflect.TypeOf((*uint256.Int)(nil))
 )
 
+// U256Serializer stores *big.Int and *uint256.Int fields in a NUMERIC
+// (UINT256 domain) column. Nil values are stored as SQL NULL, and scanning a
+// value that doesn't fit in 256 bits is an error.
 type U256Serializer struct{}
 
 func init() {
 	schema.RegisterSerializer("u256", U256Serializer{})
 }
 
-func (U256Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+func (U256Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) (err error) {
+	defer observeError("u256", field, &err)
+
 	if dbValue == nil {
 		return nil
-	} else if field.FieldType != reflect.TypeOf((*big.Int)(nil)) {
-		return fmt.Errorf("can only deserialize into a *big.Int: %T", field.FieldType)
+	} else if field.FieldType != bigIntType && field.FieldType != uint256Type {
+		return fmt.Errorf("can only deserialize into a *big.Int or *uint256.Int: %s", field.FieldType)
 	}
 
 	numeric := new(pgtype.Numeric)
-	err := numeric.Scan(dbValue)
+	err = numeric.Scan(dbValue)
 	if err != nil {
 		return err
 	}
@@ -44,21 +53,31 @@ func (U256Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect
 		return fmt.Errorf("deserialized number larger than u256 can hold: %s", bigInt)
 	}
 
+	if field.FieldType == uint256Type {
+		field.ReflectValueOf(ctx, dst).Set(reflect.ValueOf(uint256.MustFromBig(bigInt)))
+		return nil
+	}
 	field.ReflectValueOf(ctx, dst).Set(reflect.ValueOf(bigInt))
 	return nil
 }
 
-func (U256Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+func (U256Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (_ interface{}, err error) {
+	defer observeError("u256", field, &err)
+
 	if fieldValue == nil || (field.FieldType.Kind() == reflect.Pointer && reflect.ValueOf(fieldValue).IsNil()) {
 		return nil, nil
-	} else if field.FieldType != reflect.TypeOf((*big.Int)(nil)) {
-		return nil, fmt.Errorf("can only serialize a *big.Int: %T", field.FieldType)
+	} else if field.FieldType != bigIntType && field.FieldType != uint256Type {
+		return nil, fmt.Errorf("can only serialize a *big.Int or *uint256.Int: %s", field.FieldType)
 	}
 
 	// 安全地进行类型断言
-	if bigInt, ok := fieldValue.(*big.Int); ok {
-		numeric := pgtype.Numeric{Int: bigInt, Status: pgtype.Present}
+	switch v := fieldValue.(type) {
+	case *big.Int:
+		numeric := pgtype.Numeric{Int: v, Status: pgtype.Present}
+		return numeric.Value()
+	case *uint256.Int:
+		numeric := pgtype.Numeric{Int: v.ToBig(), Status: pgtype.Present}
 		return numeric.Value()
 	}
-	return nil, fmt.Errorf("failed to assert type *big.Int")
+	return nil, fmt.Errorf("failed to assert type *big.Int or *uint256.Int: %T", fieldValue)
 }
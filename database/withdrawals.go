@@ -0,0 +1,262 @@
+package database
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Withdrawals 结构体表示一笔待发送的出账转账：从热钱包到外部地址的提现，
+// 或者由 Type 区分的内部资金调拨，例如从用户地址到热钱包的归集。
+// 记录先以未签名状态入队，签名、广播后逐步推进状态，直至链上确认。
+type Withdrawals struct {
+	// GUID 是提现记录的唯一标识符，并且是主键。
+	GUID uuid.UUID `gorm:"primaryKey" json:"guid"`
+
+	// FromAddress 是转出地址（提现时为热钱包，归集时为用户地址），ToAddress 是目标地址。
+	FromAddress common.Address `json:"fromAddress" gorm:"serializer:bytes"`
+	ToAddress   common.Address `json:"toAddress" gorm:"serializer:bytes"`
+
+	// Amount 是提现金额（最小单位）。
+	Amount *big.Int `json:"amount" gorm:"serializer:u256"`
+
+	// TokenAddress 是代币合约地址，原生币提现时为零地址。
+	TokenAddress common.Address `json:"tokenAddress" gorm:"serializer:bytes"`
+
+	// TxHash 是提现交易的哈希，广播之前为零值。
+	TxHash common.Hash `json:"txHash" gorm:"serializer:bytes"`
+
+	// Status 是提现的处理状态，取值见 WithdrawalStatus* 常量。
+	Status uint8 `json:"status"`
+
+	// Type 是出账的类型，取值见 WithdrawalType* 常量。
+	Type uint8 `json:"type"`
+
+	// Nonce 是签名提现交易时使用的热钱包 nonce，签名之前为空。
+	Nonce *uint64 `json:"nonce"`
+
+	// BlockNumber 是扫描到提现交易上链的区块高度，上链之前为空。
+	BlockNumber *big.Int `json:"blockNumber" gorm:"serializer:u256"`
+
+	// Timestamp 是提现入队的时间戳（秒）。
+	Timestamp int64 `json:"timestamp"`
+}
+
+// Withdrawal statuses, in the order a withdrawal moves through them.
+const (
+	WithdrawalStatusUnsigned  uint8 = 0
+	WithdrawalStatusSigned    uint8 = 1
+	WithdrawalStatusSent      uint8 = 2
+	WithdrawalStatusConfirmed uint8 = 3
+	// WithdrawalStatusFailed marks a withdrawal whose transaction was mined
+	// but reverted, so no funds moved.
+	WithdrawalStatusFailed uint8 = 4
+	// WithdrawalStatusMined marks a withdrawal whose transaction was mined
+	// and succeeded but isn't yet buried under the withdrawal confirmation
+	// depth. It moves between sent and confirmed.
+	WithdrawalStatusMined uint8 = 5
+)
+
+// Withdrawal types stored in Withdrawals.Type.
+const (
+	// WithdrawalTypeWithdrawal is a user withdrawal from a hot wallet to an
+	// external address.
+	WithdrawalTypeWithdrawal uint8 = 0
+	// WithdrawalTypeCollection sweeps a user address balance into a hot
+	// wallet.
+	WithdrawalTypeCollection uint8 = 1
+	// WithdrawalTypeColdTopUp moves hot wallet funds above the token's
+	// ceiling into the cold wallet.
+	WithdrawalTypeColdTopUp uint8 = 2
+)
+
+// statusList returns statuses as a slice gorm expands into an IN list. A
+// []uint8 is a []byte, which gorm binds as a single bytea value instead.
+func statusList(statuses ...uint8) []int {
+	list := make([]int, len(statuses))
+	for i, status := range statuses {
+		list[i] = int(status)
+	}
+	return list
+}
+
+// BeforeCreate assigns a GUID from the configured IDGenerator when the
+// caller left it unset.
+func (w *Withdrawals) BeforeCreate(_ *gorm.DB) error {
+	if w.GUID == uuid.Nil {
+		w.GUID = NewGUID()
+	}
+	return nil
+}
+
+// WithdrawalsView defines read access to queued withdrawals.
+type WithdrawalsView interface {
+	// UnsignedWithdrawals returns the withdrawals still waiting to be signed,
+	// oldest first.
+	UnsignedWithdrawals() ([]*Withdrawals, error)
+	// QueryPendingWithdrawals returns the withdrawals of the given type that
+	// are neither confirmed nor failed yet, oldest first.
+	QueryPendingWithdrawals(withdrawalType uint8) ([]*Withdrawals, error)
+}
+
+// WithdrawalsDB 在 WithdrawalsView 的基础上增加了提现入队和状态更新的能力。
+type WithdrawalsDB interface {
+	WithdrawalsView
+
+	// StoreWithdrawals 方法用于批量存储提现记录，Timestamp 为空时使用当前时间。
+	StoreWithdrawals([]Withdrawals) error
+	// MarkWithdrawalSent 方法记录提现交易已广播及其交易哈希。
+	// 只有未签名或已签名的提现可以标记，否则返回 gorm.ErrRecordNotFound。
+	MarkWithdrawalSent(guid uuid.UUID, txHash common.Hash) error
+	// MarkWithdrawalsMined 方法把交易哈希在 succeeded 或 failed 中的已广播提现
+	// 分别标记为已上链或失败，并记录上链的区块高度，返回被更新的提现。
+	MarkWithdrawalsMined(blockNumber *big.Int, succeeded, failed []common.Hash) ([]*Withdrawals, error)
+	// MarkWithdrawalsConfirmed 方法把区块高度小于等于 blockNumber 的已上链提现标记为已确认，
+	// 返回被确认的提现数。
+	MarkWithdrawalsConfirmed(blockNumber *big.Int) (int64, error)
+	// RevertWithdrawalsMinedFrom 方法把在区块高度大于等于 number 处上链的提现
+	// 恢复为已广播状态，用于链重组回滚，返回被恢复的提现及其恢复前的状态。
+	RevertWithdrawalsMinedFrom(number *big.Int) ([]*Withdrawals, error)
+}
+
+type withdrawalsDB struct {
+	gorm *gorm.DB
+}
+
+// NewWithdrawalsDB returns a WithdrawalsDB backed by the given Gorm DB.
+func NewWithdrawalsDB(db *gorm.DB) WithdrawalsDB {
+	return &withdrawalsDB{gorm: db}
+}
+
+func (db *withdrawalsDB) StoreWithdrawals(withdrawalList []Withdrawals) error {
+	if len(withdrawalList) == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	for i := range withdrawalList {
+		if withdrawalList[i].Timestamp == 0 {
+			withdrawalList[i].Timestamp = now
+		}
+	}
+	result := db.gorm.Table("withdrawals").CreateInBatches(&withdrawalList, len(withdrawalList))
+	return result.Error
+}
+
+func (db *withdrawalsDB) UnsignedWithdrawals() ([]*Withdrawals, error) {
+	var withdrawals []*Withdrawals
+	err := db.gorm.Table("withdrawals").
+		Where("status = ?", WithdrawalStatusUnsigned).
+		Order("timestamp asc, guid asc").
+		Find(&withdrawals).Error
+	if err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
+
+func (db *withdrawalsDB) QueryPendingWithdrawals(withdrawalType uint8) ([]*Withdrawals, error) {
+	var withdrawals []*Withdrawals
+	err := db.gorm.Table("withdrawals").
+		Where("type = ? AND status NOT IN ?", withdrawalType, statusList(WithdrawalStatusConfirmed, WithdrawalStatusFailed)).
+		Order("timestamp asc, guid asc").
+		Find(&withdrawals).Error
+	if err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
+
+func (db *withdrawalsDB) MarkWithdrawalSent(guid uuid.UUID, txHash common.Hash) error {
+	result := db.gorm.Table("withdrawals").
+		Where("guid = ? AND status IN ?", guid, statusList(WithdrawalStatusUnsigned, WithdrawalStatusSigned)).
+		Updates(map[string]any{
+			"status":  WithdrawalStatusSent,
+			"tx_hash": txHash.Hex(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no pending withdrawal %s: %w", guid, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (db *withdrawalsDB) MarkWithdrawalsMined(blockNumber *big.Int, succeeded, failed []common.Hash) ([]*Withdrawals, error) {
+	var mined []*Withdrawals
+	for _, outcome := range []struct {
+		hashes []common.Hash
+		status uint8
+	}{{succeeded, WithdrawalStatusMined}, {failed, WithdrawalStatusFailed}} {
+		if len(outcome.hashes) == 0 {
+			continue
+		}
+		hexes := make([]string, len(outcome.hashes))
+		for i, hash := range outcome.hashes {
+			hexes[i] = hash.Hex()
+		}
+		var withdrawals []*Withdrawals
+		err := db.gorm.Table("withdrawals").
+			Where("status = ? AND tx_hash IN ?", WithdrawalStatusSent, hexes).
+			Order("timestamp asc, guid asc").
+			Find(&withdrawals).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(withdrawals) == 0 {
+			continue
+		}
+		guids := make([]uuid.UUID, len(withdrawals))
+		for i, w := range withdrawals {
+			guids[i] = w.GUID
+			w.Status = outcome.status
+			w.BlockNumber = blockNumber
+		}
+		err = db.gorm.Table("withdrawals").
+			Where("guid IN ?", guids).
+			Updates(map[string]any{
+				"status":       outcome.status,
+				"block_number": blockNumber.String(),
+			}).Error
+		if err != nil {
+			return nil, err
+		}
+		mined = append(mined, withdrawals...)
+	}
+	return mined, nil
+}
+
+func (db *withdrawalsDB) MarkWithdrawalsConfirmed(blockNumber *big.Int) (int64, error) {
+	result := db.gorm.Table("withdrawals").
+		Where("status = ? AND block_number <= ?", WithdrawalStatusMined, blockNumber.String()).
+		Update("status", WithdrawalStatusConfirmed)
+	return result.RowsAffected, result.Error
+}
+
+func (db *withdrawalsDB) RevertWithdrawalsMinedFrom(number *big.Int) ([]*Withdrawals, error) {
+	var withdrawals []*Withdrawals
+	err := db.gorm.Table("withdrawals").
+		Where("block_number >= ?", number.String()).
+		Order("block_number asc, guid asc").
+		Find(&withdrawals).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(withdrawals) == 0 {
+		return nil, nil
+	}
+	err = db.gorm.Table("withdrawals").
+		Where("block_number >= ?", number.String()).
+		Updates(map[string]any{
+			"status":       WithdrawalStatusSent,
+			"block_number": nil,
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
@@ -0,0 +1,21 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+)
+
+func TestMarkWithdrawalSentExpandsStatuses(t *testing.T) {
+	db, mock := newMockDB(t)
+	guid := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "withdrawals" SET .* WHERE guid = \$\d+ AND status IN \(\$\d+,\$\d+\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := db.Withdrawals.MarkWithdrawalSent(guid, common.HexToHash("0x01")); err != nil {
+		t.Fatalf("MarkWithdrawalSent: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package web3scanner
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qiaopengjun5162/web3scanner/common/retry"
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+var errDBDown = errors.New("connection refused")
+
+// outageBlocks is a fakeBlocks whose queries fail while the database is
+// down.
+type outageBlocks struct {
+	*fakeBlocks
+	down atomic.Bool
+	// served counts the queries answered while the database was up.
+	served atomic.Int32
+}
+
+func (b *outageBlocks) LatestBlock() (*database.Blocks, error) {
+	if b.down.Load() {
+		return nil, errDBDown
+	}
+	b.served.Add(1)
+	return b.fakeBlocks.LatestBlock()
+}
+
+func (b *outageBlocks) ping(context.Context) error {
+	if b.down.Load() {
+		return errDBDown
+	}
+	return nil
+}
+
+// eventually fails the test if cond doesn't hold within a few seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScannerPausesWhileDBIsDown(t *testing.T) {
+	client := newFakeClient()
+	client.addBlock()
+	// The stored chain is at the head, so rounds only read the cursor.
+	stored := &fakeBlocks{}
+	for _, block := range client.blocks {
+		stored.rows = append(stored.rows, database.BlockFromHeader(block.Header()))
+	}
+	blocks := &outageBlocks{fakeBlocks: stored}
+
+	var shutdowns atomic.Int32
+	ws := newTestScanner(&database.DB{Blocks: blocks}, client)
+	ws.pollInterval = 5 * time.Millisecond
+	ws.dbPing = blocks.ping
+	ws.dbRetry = retry.Fixed(5 * time.Millisecond)
+	ws.shutdown = func(error) { shutdowns.Add(1) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ws.done = make(chan struct{})
+	go ws.loop(ctx)
+	defer func() {
+		cancel()
+		<-ws.done
+	}()
+
+	eventually(t, "a scan round", func() bool { return blocks.served.Load() > 0 })
+
+	// The database drops mid-scan: the scanner pauses instead of exiting.
+	blocks.down.Store(true)
+	eventually(t, "the scanner to pause", func() bool { return !ws.DBAvailable() })
+	time.Sleep(20 * time.Millisecond)
+	if ws.Stopped() {
+		t.Fatal("scanner stopped on a database outage")
+	}
+
+	// Once the database is back the scanner reads the stored cursor again.
+	served := blocks.served.Load()
+	blocks.down.Store(false)
+	eventually(t, "the scanner to resume", ws.DBAvailable)
+	eventually(t, "a scan round after recovery", func() bool { return blocks.served.Load() > served })
+	if shutdowns.Load() != 0 {
+		t.Error("scanner shut down on a database outage")
+	}
+}
@@ -0,0 +1,111 @@
+package web3scanner
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+// DepositRateAlert describes an address that received more deposits within
+// one window than the configured threshold.
+type DepositRateAlert struct {
+	Address     common.Address
+	Count       int
+	WindowStart time.Time
+	Window      time.Duration
+}
+
+// DepositRateAlertFunc is called when an address exceeds the deposit rate
+// threshold. It is called at most once per address and window.
+type DepositRateAlertFunc func(alert DepositRateAlert)
+
+// depositRateWindow is the deposit count of one address in its current
+// fixed window.
+type depositRateWindow struct {
+	address common.Address
+	start   time.Time
+	count   int
+	fired   bool
+}
+
+// depositRateLimiter counts deposits per address in fixed windows and
+// reports addresses that exceed the threshold.
+//
+// At most maxAddresses windows are kept; when full, the least recently
+// seen address is evicted, so an address flooding deposits stays tracked
+// while long-quiet ones are dropped. Not safe for concurrent use.
+type depositRateLimiter struct {
+	threshold    int
+	window       time.Duration
+	maxAddresses int
+
+	windows map[common.Address]*list.Element
+	lru     *list.List
+}
+
+func newDepositRateLimiter(threshold int, window time.Duration, maxAddresses int) *depositRateLimiter {
+	return &depositRateLimiter{
+		threshold:    threshold,
+		window:       window,
+		maxAddresses: maxAddresses,
+		windows:      make(map[common.Address]*list.Element),
+		lru:          list.New(),
+	}
+}
+
+// observe records a deposit to address at the given time and returns an
+// alert the first time the address exceeds the threshold in a window.
+func (l *depositRateLimiter) observe(address common.Address, at time.Time) (DepositRateAlert, bool) {
+	elem, ok := l.windows[address]
+	if ok {
+		l.lru.MoveToFront(elem)
+	} else {
+		if l.lru.Len() >= l.maxAddresses {
+			oldest := l.lru.Back()
+			delete(l.windows, oldest.Value.(*depositRateWindow).address)
+			l.lru.Remove(oldest)
+		}
+		elem = l.lru.PushFront(&depositRateWindow{address: address, start: at})
+		l.windows[address] = elem
+	}
+
+	w := elem.Value.(*depositRateWindow)
+	if at.Sub(w.start) >= l.window {
+		*w = depositRateWindow{address: address, start: at}
+	}
+	w.count++
+	if w.count <= l.threshold || w.fired {
+		return DepositRateAlert{}, false
+	}
+	w.fired = true
+	return DepositRateAlert{Address: address, Count: w.count, WindowStart: w.start, Window: l.window}, true
+}
+
+// OnDepositRateAlert sets the callback invoked when an address exceeds the
+// configured deposit rate. Without one, alerts are only logged. It must be
+// called before Start.
+func (ws *Web3Scanner) OnDepositRateAlert(fn DepositRateAlertFunc) {
+	ws.onDepositRateAlert = fn
+}
+
+// checkDepositRates feeds stored deposits to the rate limiter, using block
+// timestamps so rescans of old ranges are judged by chain time.
+func (ws *Web3Scanner) checkDepositRates(deposits []database.Deposits) {
+	if ws.depositRates == nil {
+		return
+	}
+	for _, deposit := range deposits {
+		alert, ok := ws.depositRates.observe(deposit.ToAddress, time.Unix(int64(deposit.Timestamp), 0))
+		if !ok {
+			continue
+		}
+		log.Warn("deposit rate exceeded", "address", alert.Address, "count", alert.Count, "window", alert.Window, "windowStart", alert.WindowStart)
+		if ws.onDepositRateAlert != nil {
+			ws.onDepositRateAlert(alert)
+		}
+	}
+}
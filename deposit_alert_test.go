@@ -0,0 +1,68 @@
+package web3scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestDepositRateAlertFiresOncePerWindow(t *testing.T) {
+	flooded := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	quiet := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	ws := &Web3Scanner{depositRates: newDepositRateLimiter(3, time.Minute, 10)}
+	var alerts []DepositRateAlert
+	ws.OnDepositRateAlert(func(alert DepositRateAlert) { alerts = append(alerts, alert) })
+
+	deposits := func(to common.Address, n int, start uint64) []database.Deposits {
+		d := make([]database.Deposits, n)
+		for i := range d {
+			d[i] = database.Deposits{ToAddress: to, Timestamp: start + uint64(i)}
+		}
+		return d
+	}
+	// Ten deposits in the first window, then two more in the next one.
+	ws.checkDepositRates(deposits(flooded, 10, 1_000))
+	ws.checkDepositRates(deposits(quiet, 3, 1_000))
+	if len(alerts) != 1 {
+		t.Fatalf("%d alerts in the first window, want 1", len(alerts))
+	}
+	want := DepositRateAlert{Address: flooded, Count: 4, WindowStart: time.Unix(1_000, 0), Window: time.Minute}
+	if alerts[0] != want {
+		t.Errorf("alert = %+v, want %+v", alerts[0], want)
+	}
+
+	ws.checkDepositRates(deposits(flooded, 3, 1_060))
+	if len(alerts) != 1 {
+		t.Fatalf("alert fired for a window at the threshold")
+	}
+	ws.checkDepositRates(deposits(flooded, 1, 1_063))
+	if len(alerts) != 2 || alerts[1].WindowStart != time.Unix(1_060, 0) {
+		t.Fatalf("alerts = %+v, want a second one for the window starting at 1060", alerts)
+	}
+}
+
+func TestDepositRateLimiterEvictsLeastRecentlySeen(t *testing.T) {
+	l := newDepositRateLimiter(1, time.Minute, 2)
+	a := common.HexToAddress("0x1")
+	b := common.HexToAddress("0x2")
+	c := common.HexToAddress("0x3")
+	at := time.Unix(1_000, 0)
+	l.observe(a, at)
+	l.observe(b, at)
+	l.observe(a, at) // a exceeds the threshold and becomes most recent.
+	l.observe(c, at) // Evicts b.
+
+	if len(l.windows) != 2 || l.lru.Len() != 2 {
+		t.Fatalf("limiter tracks %d addresses, want 2", len(l.windows))
+	}
+	if _, ok := l.windows[b]; ok {
+		t.Error("least recently seen address was not evicted")
+	}
+	// b starts over: a single deposit doesn't exceed the threshold.
+	if _, fired := l.observe(b, at); fired {
+		t.Error("evicted address kept its count")
+	}
+}
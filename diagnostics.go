@@ -0,0 +1,80 @@
+package web3scanner
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// diagnosticsTimeout bounds each database and node check of Diagnostics.
+const diagnosticsTimeout = 5 * time.Second
+
+// DiagnosticReport is a snapshot of the scanner's state for support.
+type DiagnosticReport struct {
+	// Cursor and CursorHash are the number and hash of the latest stored
+	// block, nil before the first block is stored or while the database is
+	// unavailable.
+	Cursor     *big.Int
+	CursorHash *common.Hash
+	// Head is the node's latest block number and Lag the number of blocks
+	// between Cursor and Head, both nil when unknown.
+	Head *big.Int
+	Lag  *big.Int
+
+	// DBAvailable reports whether the database answered a ping; DBError
+	// holds the ping error otherwise.
+	DBAvailable bool
+	DBError     error
+	// RPCError is the error of the node's head query, nil when the node is
+	// healthy.
+	RPCError error
+
+	// TrackedAddresses is the number of tracked addresses, zero while the
+	// database is unavailable.
+	TrackedAddresses int64
+}
+
+// Diagnostics checks the database and the node and reports the scanner's
+// state. It reads the cursor from the database rather than from the scan
+// loop, so it also works on a scanner that was never started, as in the
+// status command.
+//
+// An unreachable database or node is recorded in the report, not returned;
+// the error is only set when the database answers the ping but one of its
+// queries fails.
+func (ws *Web3Scanner) Diagnostics() (*DiagnosticReport, error) {
+	report := &DiagnosticReport{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+	if head, err := ws.client.BlockNumber(ctx); err != nil {
+		report.RPCError = err
+	} else {
+		report.Head = new(big.Int).SetUint64(head)
+	}
+
+	if err := ws.pingDB(context.Background()); err != nil {
+		report.DBError = err
+		return report, nil
+	}
+	report.DBAvailable = true
+	latest, err := ws.db.Blocks.LatestBlock()
+	if err != nil {
+		return nil, fmt.Errorf("query latest block: %w", err)
+	}
+	if latest != nil {
+		report.Cursor = latest.Number
+		report.CursorHash = &latest.BlockHash
+		if report.Head != nil {
+			report.Lag = new(big.Int).Sub(report.Head, latest.Number)
+		}
+	}
+	report.TrackedAddresses, err = ws.db.Addresses.CountAddresses()
+	if err != nil {
+		return nil, fmt.Errorf("count addresses: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,63 @@
+package web3scanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+// downClient fails every head query, like an unreachable node.
+type downClient struct {
+	*fakeClient
+}
+
+func (downClient) BlockNumber(context.Context) (uint64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func TestDiagnostics(t *testing.T) {
+	client := newFakeClient()
+	for range 5 {
+		client.addBlock()
+	}
+	stored := database.BlockFromHeader(client.blocks[3].Header())
+	db := &database.DB{
+		Blocks:    &fakeBlocks{rows: []database.Blocks{stored}},
+		Addresses: &fakeAddresses{rows: []database.Addresses{newTestAccount(t).row(database.AddressTypeUser), newTestAccount(t).row(database.AddressTypeHot)}},
+	}
+	ws := newTestScanner(db, client)
+	ws.dbPing = func(context.Context) error { return nil }
+
+	report, err := ws.Diagnostics()
+	if err != nil {
+		t.Fatalf("Diagnostics: %v", err)
+	}
+	if report.Cursor.Int64() != 3 || *report.CursorHash != client.blocks[3].Hash() {
+		t.Errorf("cursor = %s %s, want 3 %s", report.Cursor, report.CursorHash, client.blocks[3].Hash())
+	}
+	if report.Head.Int64() != 5 || report.Lag.Int64() != 2 {
+		t.Errorf("head = %s, lag = %s, want 5 and 2", report.Head, report.Lag)
+	}
+	if !report.DBAvailable || report.DBError != nil || report.RPCError != nil {
+		t.Errorf("database available %t (%v), rpc %v, want healthy", report.DBAvailable, report.DBError, report.RPCError)
+	}
+	if report.TrackedAddresses != 2 {
+		t.Errorf("tracked addresses = %d, want 2", report.TrackedAddresses)
+	}
+
+	// Outages are reported, not returned.
+	ws = newTestScanner(db, downClient{client})
+	ws.dbPing = func(context.Context) error { return errors.New("database down") }
+	report, err = ws.Diagnostics()
+	if err != nil {
+		t.Fatalf("Diagnostics during outage: %v", err)
+	}
+	if report.DBAvailable || report.DBError == nil || report.RPCError == nil {
+		t.Errorf("database available %t (%v), rpc %v, want both down", report.DBAvailable, report.DBError, report.RPCError)
+	}
+	if report.Cursor != nil || report.Head != nil || report.Lag != nil {
+		t.Errorf("cursor %s, head %s, lag %s during outage, want unknown", report.Cursor, report.Head, report.Lag)
+	}
+}
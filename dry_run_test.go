@@ -0,0 +1,53 @@
+package web3scanner
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+	"github.com/qiaopengjun5162/web3scanner/oracle"
+)
+
+func TestDryRunStoresNothing(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	user, hot, payer := newTestAccount(t), newTestAccount(t), newTestAccount(t)
+	if err := db.Addresses.StoreAddresses([]database.Addresses{user.row(database.AddressTypeUser), hot.row(database.AddressTypeHot)}); err != nil {
+		t.Fatalf("store addresses: %v", err)
+	}
+	client := newFakeClient()
+	client.addBlock(payer.transfer(t, user.address, 10))
+	client.addBlock(user.transfer(t, hot.address, 4))
+	client.addBlock()
+
+	logs := captureLogs(t)
+	ws := newTestScanner(db, client)
+	ws.dryRun = true
+	ws.blocksStep = 2
+	// Each round continues after the previous one although nothing is
+	// stored, until the dry run reaches the head.
+	scanToHead(t, ws)
+
+	if latest, err := db.Blocks.LatestBlock(); err != nil || latest != nil {
+		t.Errorf("latest stored block after dry run = %v, %v, want none", latest, err)
+	}
+	all := big.NewInt(100)
+	if deposits, err := db.Deposits.QueryDepositsByBlockRange(big.NewInt(0), all); err != nil || len(deposits) != 0 {
+		t.Errorf("%d deposits stored by dry run, %v, want none", len(deposits), err)
+	}
+	if sweeps, err := db.Sweeps.QuerySweepsByBlockRange(big.NewInt(0), all); err != nil || len(sweeps) != 0 {
+		t.Errorf("%d sweeps stored by dry run, %v, want none", len(sweeps), err)
+	}
+	if balance, err := db.Balances.QueryBalance(user.address, oracle.NativeToken); err != nil || balance != nil {
+		t.Errorf("user balance after dry run = %v, %v, want none", balance, err)
+	}
+	for _, want := range []string{
+		`msg="dry run rolled back" from=0 to=1 deposits=1 sweeps=0`,
+		`msg="dry run rolled back" from=2 to=3 deposits=0 sweeps=1`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("dry run logs lack %q:\n%s", want, logs)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package ens looks up the primary ENS names of addresses over RPC.
+package ens
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// RegistryAddress is the address of the ENS registry on Ethereum mainnet and
+// its testnets.
+var RegistryAddress = common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e")
+
+// ensABI holds the registry's resolver method and the name and addr methods
+// of resolvers.
+const ensABI = `[
+	{"name":"resolver","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"name":"name","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"string"}]},
+	{"name":"addr","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]}
+]`
+
+var parsedABI = mustParseABI(ensABI)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// Caller is the contract call the lookups need, implemented by
+// rpc.EthClient.
+type Caller interface {
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// Resolver looks up primary ENS names through the registry at a given
+// address.
+type Resolver struct {
+	caller   Caller
+	registry common.Address
+}
+
+// NewResolver returns a Resolver calling the registry at registry, usually
+// RegistryAddress, through caller.
+func NewResolver(caller Caller, registry common.Address) *Resolver {
+	return &Resolver{caller: caller, registry: registry}
+}
+
+// NameHash returns the EIP-137 namehash of name, which must already be
+// normalized.
+func NameHash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node.Bytes(), crypto.Keccak256([]byte(labels[i])))
+	}
+	return node
+}
+
+// LookupName returns the primary name of address, or "" if it has none. The
+// name of the address's reverse record is only returned when it resolves
+// back to address, as ENS requires, since anyone can claim any name in the
+// reverse record of their own address.
+func (r *Resolver) LookupName(ctx context.Context, address common.Address) (string, error) {
+	reverse := NameHash(strings.ToLower(address.Hex()[2:]) + ".addr.reverse")
+	resolver, err := r.callAddress(ctx, r.registry, "resolver", reverse)
+	if err != nil || resolver == (common.Address{}) {
+		return "", err
+	}
+	name, err := r.callString(ctx, resolver, "name", reverse)
+	if err != nil || name == "" {
+		return "", err
+	}
+
+	forward := NameHash(name)
+	resolver, err = r.callAddress(ctx, r.registry, "resolver", forward)
+	if err != nil || resolver == (common.Address{}) {
+		return "", err
+	}
+	resolved, err := r.callAddress(ctx, resolver, "addr", forward)
+	if err != nil || resolved != address {
+		return "", err
+	}
+	return name, nil
+}
+
+// call calls method of the contract at to with node and returns its single
+// output, or nil when the contract returned nothing, e.g. because there is
+// no contract at to.
+func (r *Resolver) call(ctx context.Context, to common.Address, method string, node common.Hash) (any, error) {
+	input, err := parsedABI.Pack(method, node)
+	if err != nil {
+		return nil, err
+	}
+	output, err := r.caller.CallContract(ctx, ethereum.CallMsg{To: &to, Data: input}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("call %s on %s: %w", method, to, err)
+	}
+	if len(output) == 0 {
+		return nil, nil
+	}
+	values, err := parsedABI.Unpack(method, output)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s from %s: %w", method, to, err)
+	}
+	return values[0], nil
+}
+
+func (r *Resolver) callAddress(ctx context.Context, to common.Address, method string, node common.Hash) (common.Address, error) {
+	value, err := r.call(ctx, to, method, node)
+	if err != nil || value == nil {
+		return common.Address{}, err
+	}
+	return value.(common.Address), nil
+}
+
+func (r *Resolver) callString(ctx context.Context, to common.Address, method string, node common.Hash) (string, error) {
+	value, err := r.call(ctx, to, method, node)
+	if err != nil || value == nil {
+		return "", err
+	}
+	return value.(string), nil
+}
@@ -0,0 +1,98 @@
+package ens
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNameHash(t *testing.T) {
+	tests := map[string]string{
+		"":        "0x0000000000000000000000000000000000000000000000000000000000000000",
+		"eth":     "0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae",
+		"foo.eth": "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f",
+	}
+	for name, want := range tests {
+		if got := NameHash(name); got != common.HexToHash(want) {
+			t.Errorf("NameHash(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+// fakeENS serves registry and resolver calls from in-memory records.
+type fakeENS struct {
+	registry  common.Address
+	resolvers map[common.Hash]common.Address
+	names     map[common.Hash]string
+	addrs     map[common.Hash]common.Address
+	err       error
+}
+
+func (f *fakeENS) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	method, err := parsedABI.MethodById(msg.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+	node := common.BytesToHash(msg.Data[4:36])
+	switch {
+	case *msg.To == f.registry && method.Name == "resolver":
+		return method.Outputs.Pack(f.resolvers[node])
+	case *msg.To != f.registry && method.Name == "name":
+		return method.Outputs.Pack(f.names[node])
+	case *msg.To != f.registry && method.Name == "addr":
+		return method.Outputs.Pack(f.addrs[node])
+	}
+	// Like a call to an address without code.
+	return nil, nil
+}
+
+// register sets name as the primary name of address, with a forward record
+// pointing at forward.
+func (f *fakeENS) register(name string, address, forward common.Address) {
+	resolver := common.HexToAddress("0x4976fb03C32e5B8cfe2b6cCB31c09Ba78EBaBa41")
+	reverse := NameHash(strings.ToLower(address.Hex()[2:]) + ".addr.reverse")
+	f.resolvers[reverse] = resolver
+	f.names[reverse] = name
+	f.resolvers[NameHash(name)] = resolver
+	f.addrs[NameHash(name)] = forward
+}
+
+func TestLookupName(t *testing.T) {
+	alice := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	mallory := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	nobody := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	caller := &fakeENS{
+		registry:  RegistryAddress,
+		resolvers: make(map[common.Hash]common.Address),
+		names:     make(map[common.Hash]string),
+		addrs:     make(map[common.Hash]common.Address),
+	}
+	caller.register("alice.eth", alice, alice)
+	// Mallory claims alice.eth in her reverse record, but it doesn't
+	// resolve to her.
+	caller.register("alice.eth", mallory, alice)
+	r := NewResolver(caller, RegistryAddress)
+
+	for address, want := range map[common.Address]string{alice: "alice.eth", mallory: "", nobody: ""} {
+		got, err := r.LookupName(context.Background(), address)
+		if err != nil {
+			t.Fatalf("LookupName(%s): %v", address, err)
+		}
+		if got != want {
+			t.Errorf("LookupName(%s) = %q, want %q", address, got, want)
+		}
+	}
+
+	caller.err = errors.New("node down")
+	if _, err := r.LookupName(context.Background(), alice); err == nil {
+		t.Error("LookupName with a failing node succeeded")
+	}
+}
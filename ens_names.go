@@ -0,0 +1,85 @@
+package web3scanner
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ensBatchSize is the number of addresses queried per batch of an ENS run.
+const ensBatchSize = 100
+
+// ensLookupTimeout bounds the node calls looking up one address's name.
+const ensLookupTimeout = 10 * time.Second
+
+// ensLookup looks up the primary ENS name of an address; it is implemented
+// by ens.Resolver.
+type ensLookup interface {
+	LookupName(ctx context.Context, address common.Address) (string, error)
+}
+
+// maybeResolveEnsNames starts an ENS run in the background when ENS lookups
+// are enabled and no run is in progress, so the extra node calls never hold
+// up scanning. Dry runs write nothing, so they look nothing up either.
+func (ws *Web3Scanner) maybeResolveEnsNames(ctx context.Context) {
+	if ws.ens == nil || ws.dryRun {
+		return
+	}
+	if !ws.resolvingEns.CompareAndSwap(false, true) {
+		return
+	}
+	ws.backgroundRuns.Add(1)
+	go func() {
+		defer ws.backgroundRuns.Done()
+		defer ws.resolvingEns.Store(false)
+		if resolved, err := ws.resolveEnsNames(ctx); err != nil {
+			log.Error("resolve ens names fail", "err", err)
+		} else if resolved > 0 {
+			log.Info("resolved ens names", "addresses", resolved)
+		}
+	}()
+}
+
+// resolveEnsNames looks up the names of the addresses never looked up
+// before, such as newly stored ones, and of those last looked up
+// ensRefreshInterval or longer ago, in batches until none is left or ctx
+// is done. It returns the number of addresses looked up.
+//
+// Lookups are best effort: a failed lookup keeps the stored name and is
+// retried at the next refresh.
+func (ws *Web3Scanner) resolveEnsNames(ctx context.Context) (int, error) {
+	var resolved int
+	for ctx.Err() == nil {
+		now := time.Now()
+		var before int64
+		if ws.ensRefreshInterval > 0 {
+			before = now.Add(-ws.ensRefreshInterval).Unix()
+		}
+		addresses, err := ws.db.Addresses.QueryAddressesForEnsLookup(before, ensBatchSize)
+		if err != nil {
+			return resolved, err
+		}
+		for _, address := range addresses {
+			lookupCtx, cancel := context.WithTimeout(ctx, ensLookupTimeout)
+			name, err := ws.ens.LookupName(lookupCtx, address.Address)
+			cancel()
+			if err != nil {
+				if ctx.Err() != nil {
+					return resolved, nil
+				}
+				log.Debug("ens lookup fail", "address", address.Address, "err", err)
+				name = address.EnsName
+			}
+			if err := ws.db.Addresses.UpdateEnsName(address.Address, name, now.Unix()); err != nil {
+				return resolved, err
+			}
+			resolved++
+		}
+		if len(addresses) < ensBatchSize {
+			break
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,75 @@
+package web3scanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+// fakeEns serves names from a map and fails for the addresses in failing.
+type fakeEns struct {
+	names   map[common.Address]string
+	failing map[common.Address]bool
+	lookups int
+}
+
+func (f *fakeEns) LookupName(_ context.Context, address common.Address) (string, error) {
+	f.lookups++
+	if f.failing[address] {
+		return "", errors.New("node down")
+	}
+	return f.names[address], nil
+}
+
+func TestResolveEnsNames(t *testing.T) {
+	alice, bob, carol := newTestAccount(t), newTestAccount(t), newTestAccount(t)
+	stale := time.Now().Add(-48 * time.Hour).Unix()
+	fresh := time.Now().Unix()
+	rows := []database.Addresses{
+		alice.row(database.AddressTypeUser),
+		bob.row(database.AddressTypeUser),
+		carol.row(database.AddressTypeHot),
+	}
+	// Bob was looked up long ago and carol just now.
+	rows[1].EnsName, rows[1].EnsResolvedAt = "bob.eth", &stale
+	rows[2].EnsName, rows[2].EnsResolvedAt = "carol.eth", &fresh
+	addresses := &fakeAddresses{rows: rows}
+	lookup := &fakeEns{
+		names:   map[common.Address]string{alice.address: "alice.eth", carol.address: "other.eth"},
+		failing: map[common.Address]bool{bob.address: true},
+	}
+	ws := newTestScanner(&database.DB{Addresses: addresses}, newFakeClient())
+	ws.ens, ws.ensRefreshInterval = lookup, 24*time.Hour
+
+	resolved, err := ws.resolveEnsNames(context.Background())
+	if err != nil {
+		t.Fatalf("resolveEnsNames: %v", err)
+	}
+	if resolved != 2 {
+		t.Errorf("resolveEnsNames looked up %d addresses, want 2", resolved)
+	}
+	// Bob's lookup failed, so his old name stays; carol isn't due yet.
+	for i, want := range []string{"alice.eth", "bob.eth", "carol.eth"} {
+		if got := addresses.rows[i].EnsName; got != want {
+			t.Errorf("name of %s = %q, want %q", addresses.rows[i].Address, got, want)
+		}
+	}
+	for i := range 2 {
+		if at := addresses.rows[i].EnsResolvedAt; at == nil || *at < fresh {
+			t.Errorf("lookup time of %s = %v, want now", addresses.rows[i].Address, at)
+		}
+	}
+
+	// Without a refresh interval, only addresses never looked up are.
+	lookup.lookups = 0
+	addresses.rows = append(addresses.rows, newTestAccount(t).row(database.AddressTypeUser))
+	ws.ensRefreshInterval = 0
+	if resolved, err := ws.resolveEnsNames(context.Background()); err != nil || resolved != 1 || lookup.lookups != 1 {
+		t.Errorf("resolveEnsNames without refreshes = %d, %v after %d lookups, want 1 lookup", resolved, err, lookup.lookups)
+	}
+}
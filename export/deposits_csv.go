@@ -0,0 +1,165 @@
+// Package export writes scanned data in formats consumed outside the scanner.
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/oracle"
+)
+
+// DepositsCSVHeader is the column layout of the accounting deposits export.
+var DepositsCSVHeader = []string{
+	"date",
+	"address",
+	"token_symbol",
+	"raw_amount",
+	"decimal_amount",
+	"tx_hash",
+	"block",
+	"confirmations",
+	"usd_value",
+}
+
+// TokenInfo is the display metadata of a token.
+type TokenInfo struct {
+	Symbol   string
+	Decimals uint8
+}
+
+// TokenMetadata looks up the metadata of a token. ok is false when the
+// token is unknown.
+type TokenMetadata func(token common.Address) (info TokenInfo, ok bool)
+
+// NativeTokenMetadata only knows the chain's native currency, reported as
+// ETH with 18 decimals.
+func NativeTokenMetadata(token common.Address) (TokenInfo, bool) {
+	if token == oracle.NativeToken {
+		return TokenInfo{Symbol: "ETH", Decimals: database.NativeTokenDecimals}, true
+	}
+	return TokenInfo{}, false
+}
+
+// TokenMetadataFromDB resolves tokens from the tokens table, caching every
+// lookup, and the native currency via NativeTokenMetadata. Lookup errors
+// are logged and treated as unknown tokens.
+func TokenMetadataFromDB(tokens database.TokensView) TokenMetadata {
+	cache := make(map[common.Address]*TokenInfo)
+	return func(token common.Address) (TokenInfo, bool) {
+		if info, ok := NativeTokenMetadata(token); ok {
+			return info, true
+		}
+		if info, ok := cache[token]; ok {
+			if info == nil {
+				return TokenInfo{}, false
+			}
+			return *info, true
+		}
+		row, err := tokens.QueryToken(token)
+		if err != nil {
+			log.Warn("failed to query token metadata", "token", token, "err", err)
+			return TokenInfo{}, false
+		}
+		if row == nil {
+			cache[token] = nil
+			return TokenInfo{}, false
+		}
+		info := TokenInfo{Symbol: row.Symbol, Decimals: row.Decimals}
+		cache[token] = &info
+		return info, true
+	}
+}
+
+// DepositsCSVWriter writes deposits one row at a time in the accounting
+// CSV layout.
+//
+// The decimal_amount and token_symbol columns are left empty when the token
+// is unknown to Metadata, usd_value when Oracle is nil or has no price, and
+// confirmations when Head is nil.
+type DepositsCSVWriter struct {
+	w *csv.Writer
+
+	// Metadata resolves token symbols and decimals. Defaults to
+	// NativeTokenMetadata.
+	Metadata TokenMetadata
+	// Oracle prices deposits in USD at their block. Optional.
+	Oracle oracle.PriceOracle
+	// Head is the chain head used to compute confirmations. Optional.
+	Head *big.Int
+}
+
+// NewDepositsCSVWriter creates a DepositsCSVWriter writing to w.
+func NewDepositsCSVWriter(w io.Writer) *DepositsCSVWriter {
+	return &DepositsCSVWriter{w: csv.NewWriter(w), Metadata: NativeTokenMetadata}
+}
+
+// WriteHeader writes the DepositsCSVHeader row.
+func (cw *DepositsCSVWriter) WriteHeader() error {
+	return cw.w.Write(DepositsCSVHeader)
+}
+
+// Write writes one deposit row. Rows are buffered; call Flush when done.
+func (cw *DepositsCSVWriter) Write(d *database.Deposits) error {
+	var symbol, decimalAmount, confirmations, usdValue string
+	if info, ok := cw.Metadata(d.TokenAddress); ok {
+		symbol = info.Symbol
+		decimalAmount = FormatUnits(d.Amount, info.Decimals)
+		if value := oracle.ValueUSD(cw.Oracle, d.TokenAddress, d.BlockNumber, d.Amount, info.Decimals); value != nil {
+			usdValue = strconv.FormatFloat(*value, 'f', 2, 64)
+		}
+	}
+	if cw.Head != nil && cw.Head.Cmp(d.BlockNumber) >= 0 {
+		confirmations = new(big.Int).Add(new(big.Int).Sub(cw.Head, d.BlockNumber), big.NewInt(1)).String()
+	}
+
+	return cw.w.Write([]string{
+		time.Unix(int64(d.Timestamp), 0).UTC().Format(time.RFC3339),
+		d.ToAddress.Hex(),
+		symbol,
+		d.Amount.String(),
+		decimalAmount,
+		d.TxHash.Hex(),
+		d.BlockNumber.String(),
+		confirmations,
+		usdValue,
+	})
+}
+
+// Flush writes any buffered rows and reports any write error.
+func (cw *DepositsCSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// FormatUnits renders a raw token amount as an exact decimal string with
+// the given number of decimals, trimming trailing zeros, e.g. 1500000 with
+// 6 decimals is "1.5".
+func FormatUnits(amount *big.Int, decimals uint8) string {
+	if amount == nil {
+		return ""
+	}
+	digits := new(big.Int).Abs(amount).String()
+	sign := ""
+	if amount.Sign() < 0 {
+		sign = "-"
+	}
+	if decimals == 0 {
+		return sign + digits
+	}
+	if pad := int(decimals) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	whole, frac := digits[:len(digits)-int(decimals)], strings.TrimRight(digits[len(digits)-int(decimals):], "0")
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
@@ -0,0 +1,91 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/oracle"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestDepositsCSV(t *testing.T) {
+	token := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	deposits := []*database.Deposits{
+		{
+			BlockNumber:  big.NewInt(100),
+			TxHash:       common.HexToHash("0xaa"),
+			ToAddress:    common.HexToAddress("0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"),
+			TokenAddress: oracle.NativeToken,
+			Amount:       big.NewInt(1_500_000_000_000_000_000),
+			Timestamp:    1_700_000_000,
+		},
+		// An unknown token past the head: no symbol, decimal amount, USD
+		// value or confirmations.
+		{
+			BlockNumber:  big.NewInt(120),
+			TxHash:       common.HexToHash("0xbb"),
+			ToAddress:    common.HexToAddress("0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"),
+			TokenAddress: token,
+			Amount:       big.NewInt(42),
+			Timestamp:    1_700_000_012,
+		},
+	}
+
+	var buf bytes.Buffer
+	cw := NewDepositsCSVWriter(&buf)
+	cw.Oracle = oracle.NewStaticPriceOracle(map[common.Address]float64{oracle.NativeToken: 2000})
+	cw.Head = big.NewInt(110)
+	if err := cw.WriteHeader(); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	for _, d := range deposits {
+		if err := cw.Write(d); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := cw.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "deposits.csv")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("update golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("CSV output:\n%s\nwant:\n%s", buf.Bytes(), want)
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		decimals uint8
+		want     string
+	}{
+		{1_500_000, 6, "1.5"},
+		{1_000_000, 6, "1"},
+		{1, 6, "0.000001"},
+		{0, 18, "0"},
+		{-25, 1, "-2.5"},
+		{42, 0, "42"},
+	}
+	for _, tt := range tests {
+		if got := FormatUnits(big.NewInt(tt.amount), tt.decimals); got != tt.want {
+			t.Errorf("FormatUnits(%d, %d) = %q, want %q", tt.amount, tt.decimals, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/oracle"
+)
+
+// Holding is the combined balance of every managed address in one token.
+type Holding struct {
+	Token common.Address
+	// Symbol and DecimalAmount are empty when the token is unknown to the
+	// metadata lookup.
+	Symbol        string
+	Amount        *big.Int
+	DecimalAmount string
+	Holders       int64
+	// USDValue is nil when no oracle was given, it has no price for the
+	// token or the token's decimals are unknown.
+	USDValue *float64
+}
+
+// Holdings are the per-token totals returned by TotalHoldings.
+type Holdings struct {
+	Tokens []Holding
+	// TotalUSD sums the USD value of the priced tokens. It is nil when no
+	// token could be priced.
+	TotalUSD *float64
+}
+
+// TotalHoldings sums the balances of every managed address per token and,
+// when o is non-nil, values them in USD at block. A nil metadata defaults
+// to NativeTokenMetadata.
+func TotalHoldings(balances database.BalancesView, metadata TokenMetadata, o oracle.PriceOracle, block *big.Int) (*Holdings, error) {
+	totals, err := balances.SumBalancesByToken()
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		metadata = NativeTokenMetadata
+	}
+
+	holdings := &Holdings{Tokens: make([]Holding, 0, len(totals))}
+	for _, total := range totals {
+		holding := Holding{Token: total.TokenAddress, Amount: total.Total, Holders: total.Holders}
+		if info, ok := metadata(total.TokenAddress); ok {
+			holding.Symbol = info.Symbol
+			holding.DecimalAmount = FormatUnits(total.Total, info.Decimals)
+			holding.USDValue = oracle.ValueUSD(o, total.TokenAddress, block, total.Total, info.Decimals)
+		}
+		if holding.USDValue != nil {
+			if holdings.TotalUSD == nil {
+				holdings.TotalUSD = new(float64)
+			}
+			*holdings.TotalUSD += *holding.USDValue
+		}
+		holdings.Tokens = append(holdings.Tokens, holding)
+	}
+	return holdings, nil
+}
@@ -0,0 +1,77 @@
+package export
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/oracle"
+)
+
+// stubBalances is a database.BalancesView returning fixed per-token totals.
+type stubBalances struct {
+	database.BalancesView
+	totals []database.TokenBalance
+}
+
+func (s stubBalances) SumBalancesByToken() ([]database.TokenBalance, error) {
+	return s.totals, nil
+}
+
+func TestTotalHoldings(t *testing.T) {
+	usdc := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	unknown := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	balances := stubBalances{totals: []database.TokenBalance{
+		{TokenAddress: oracle.NativeToken, Total: big.NewInt(2_500_000_000_000_000_000), Holders: 3},
+		{TokenAddress: usdc, Total: big.NewInt(1_250_000), Holders: 1},
+		{TokenAddress: unknown, Total: big.NewInt(7), Holders: 1},
+	}}
+	metadata := func(token common.Address) (TokenInfo, bool) {
+		if token == usdc {
+			return TokenInfo{Symbol: "USDC", Decimals: 6}, true
+		}
+		return NativeTokenMetadata(token)
+	}
+
+	t.Run("without oracle", func(t *testing.T) {
+		holdings, err := TotalHoldings(balances, metadata, nil, nil)
+		if err != nil {
+			t.Fatalf("TotalHoldings: %v", err)
+		}
+		if holdings.TotalUSD != nil {
+			t.Errorf("TotalUSD = %v, want nil", *holdings.TotalUSD)
+		}
+		want := []struct{ symbol, amount string }{{"ETH", "2.5"}, {"USDC", "1.25"}, {"", ""}}
+		if len(holdings.Tokens) != len(want) {
+			t.Fatalf("got %d holdings, want %d", len(holdings.Tokens), len(want))
+		}
+		for i, h := range holdings.Tokens {
+			if h.Symbol != want[i].symbol || h.DecimalAmount != want[i].amount || h.USDValue != nil {
+				t.Errorf("holding %d = %q %q (usd %v), want %q %q", i, h.Symbol, h.DecimalAmount, h.USDValue, want[i].symbol, want[i].amount)
+			}
+		}
+	})
+
+	t.Run("with oracle", func(t *testing.T) {
+		// USDC has no price, so only ETH is valued.
+		o := oracle.NewStaticPriceOracle(map[common.Address]float64{oracle.NativeToken: 2000, unknown: 1})
+		holdings, err := TotalHoldings(balances, metadata, o, big.NewInt(100))
+		if err != nil {
+			t.Fatalf("TotalHoldings: %v", err)
+		}
+		if v := holdings.Tokens[0].USDValue; v == nil || *v != 5000 {
+			t.Errorf("ETH value = %v, want 5000", v)
+		}
+		if v := holdings.Tokens[1].USDValue; v != nil {
+			t.Errorf("unpriced USDC value = %v, want nil", *v)
+		}
+		if v := holdings.Tokens[2].USDValue; v != nil {
+			t.Errorf("unknown token value = %v, want nil", *v)
+		}
+		if holdings.TotalUSD == nil || *holdings.TotalUSD != 5000 {
+			t.Errorf("TotalUSD = %v, want 5000", holdings.TotalUSD)
+		}
+	})
+}
@@ -0,0 +1,527 @@
+package web3scanner
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"gorm.io/gorm"
+
+	"github.com/qiaopengjun5162/web3scanner/common/retry"
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/metrics"
+	"github.com/qiaopengjun5162/web3scanner/rpc"
+	"github.com/qiaopengjun5162/web3scanner/scanner"
+)
+
+// testChainID is the chain ID of the transactions signed by tests.
+var testChainID = big.NewInt(1)
+
+var testSigner = types.LatestSignerForChainID(testChainID)
+
+// testAccount is a key pair used to sign test transactions.
+type testAccount struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+	nonce   uint64
+}
+
+func newTestAccount(t testing.TB) *testAccount {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return &testAccount{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// row returns the addresses table row of the account.
+func (a *testAccount) row(addressType uint8) database.Addresses {
+	return database.Addresses{
+		Address:     a.address,
+		AddressType: addressType,
+		PublicKey:   hex.EncodeToString(crypto.FromECDSAPub(&a.key.PublicKey)),
+		Timestamp:   time.Now().Unix(),
+	}
+}
+
+// transfer signs a native transfer of value wei to to.
+func (a *testAccount) transfer(t testing.TB, to common.Address, value int64) *types.Transaction {
+	t.Helper()
+	tx, err := types.SignNewTx(a.key, testSigner, &types.LegacyTx{
+		Nonce:    a.nonce,
+		To:       &to,
+		Value:    big.NewInt(value),
+		Gas:      21_000,
+		GasPrice: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("sign transaction: %v", err)
+	}
+	a.nonce++
+	return tx
+}
+
+// fakeClient is an rpc.EthClient serving an in-memory chain whose block
+// numbers start at 0.
+type fakeClient struct {
+	rpc.EthClient
+	blocks   []*types.Block
+	receipts map[common.Hash][]*types.Receipt
+	// byHash holds every block ever added, including reorged out ones.
+	byHash map[common.Hash]*types.Block
+	// forks counts the reorgs, so blocks of a new branch hash differently
+	// from the ones they replace.
+	forks byte
+}
+
+func newFakeClient() *fakeClient {
+	c := &fakeClient{receipts: make(map[common.Hash][]*types.Receipt), byHash: make(map[common.Hash]*types.Block)}
+	c.addBlock()
+	return c
+}
+
+// addBlock appends a block with txs on top of the current head. Each
+// transaction gets a successful receipt without logs unless receipts are
+// given.
+func (c *fakeClient) addBlock(txs ...*types.Transaction) *types.Block {
+	receipts := make([]*types.Receipt, len(txs))
+	for i, tx := range txs {
+		receipts[i] = &types.Receipt{TxHash: tx.Hash(), Status: types.ReceiptStatusSuccessful}
+	}
+	return c.addBlockWithReceipts(txs, receipts)
+}
+
+func (c *fakeClient) addBlockWithReceipts(txs []*types.Transaction, receipts []*types.Receipt) *types.Block {
+	header := &types.Header{
+		Number:     big.NewInt(int64(len(c.blocks))),
+		Time:       uint64(1_000 + len(c.blocks)),
+		Difficulty: new(big.Int),
+		Extra:      []byte{c.forks},
+	}
+	if len(c.blocks) > 0 {
+		header.ParentHash = c.blocks[len(c.blocks)-1].Hash()
+	}
+	block := types.NewBlockWithHeader(header).WithBody(types.Body{Transactions: txs})
+	// Index the logs as a node would: by position in the block and the
+	// transaction emitting them.
+	var logIndex uint
+	for i, receipt := range receipts {
+		receipt.BlockHash, receipt.TransactionIndex = block.Hash(), uint(i)
+		for _, l := range receipt.Logs {
+			l.TxIndex, l.Index = uint(i), logIndex
+			logIndex++
+		}
+	}
+	c.blocks = append(c.blocks, block)
+	c.receipts[block.Hash()] = receipts
+	c.byHash[block.Hash()] = block
+	return block
+}
+
+// transferReceipt returns a successful receipt for tx holding an ERC20
+// Transfer log of amount from from to to, emitted by token.
+func transferReceipt(tx *types.Transaction, token, from, to common.Address, amount int64) *types.Receipt {
+	return &types.Receipt{
+		TxHash: tx.Hash(),
+		Status: types.ReceiptStatusSuccessful,
+		Logs: []*types.Log{{
+			Address: token,
+			Topics:  []common.Hash{scanner.TransferEventTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+			Data:    common.BigToHash(big.NewInt(amount)).Bytes(),
+			TxHash:  tx.Hash(),
+		}},
+	}
+}
+
+// reorg drops every block from number on, so the following addBlock calls
+// build a competing branch.
+func (c *fakeClient) reorg(number int) {
+	c.blocks = c.blocks[:number]
+	c.forks++
+}
+
+func (c *fakeClient) ChainID(context.Context) (*big.Int, error) {
+	return testChainID, nil
+}
+
+func (c *fakeClient) BlockNumber(context.Context) (uint64, error) {
+	return uint64(len(c.blocks) - 1), nil
+}
+
+func (c *fakeClient) block(number *big.Int) (*types.Block, error) {
+	if !number.IsInt64() || number.Int64() >= int64(len(c.blocks)) {
+		return nil, ethereum.NotFound
+	}
+	return c.blocks[number.Int64()], nil
+}
+
+func (c *fakeClient) BlockByNumber(_ context.Context, number *big.Int) (*types.Block, error) {
+	return c.block(number)
+}
+
+func (c *fakeClient) BlockByHash(_ context.Context, hash common.Hash) (*types.Block, error) {
+	block, ok := c.byHash[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return block, nil
+}
+
+func (c *fakeClient) BatchBlocksByRange(_ context.Context, from, to *big.Int) ([]*types.Block, error) {
+	var blocks []*types.Block
+	for number := new(big.Int).Set(from); number.Cmp(to) <= 0; number.Add(number, big.NewInt(1)) {
+		block, err := c.block(number)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+func (c *fakeClient) HeaderAndTxHashesByNumber(_ context.Context, number *big.Int) (*types.Header, []common.Hash, error) {
+	block, err := c.block(number)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]common.Hash, 0, len(block.Transactions()))
+	for _, tx := range block.Transactions() {
+		hashes = append(hashes, tx.Hash())
+	}
+	return block.Header(), hashes, nil
+}
+
+func (c *fakeClient) BatchTransactionsAndReceipts(_ context.Context, hashes []common.Hash) ([]*types.Transaction, []*types.Receipt, error) {
+	var txs []*types.Transaction
+	var receipts []*types.Receipt
+	for _, hash := range hashes {
+		tx, receipt := c.transaction(hash)
+		if tx == nil {
+			return nil, nil, fmt.Errorf("transaction %s: %w", hash, ethereum.NotFound)
+		}
+		txs, receipts = append(txs, tx), append(receipts, receipt)
+	}
+	return txs, receipts, nil
+}
+
+// transaction returns the canonical transaction with the given hash and its
+// receipt, or nils.
+func (c *fakeClient) transaction(hash common.Hash) (*types.Transaction, *types.Receipt) {
+	for _, block := range c.blocks {
+		for i, tx := range block.Transactions() {
+			if tx.Hash() == hash {
+				return tx, c.receipts[block.Hash()][i]
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (c *fakeClient) BlockReceiptsByHash(_ context.Context, hash common.Hash) ([]*types.Receipt, error) {
+	receipts, ok := c.receipts[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipts, nil
+}
+
+func (c *fakeClient) ReportedBlockHash(_ context.Context, number *big.Int) (common.Hash, error) {
+	block, err := c.block(number)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return block.Hash(), nil
+}
+
+func (c *fakeClient) Close() {}
+
+// newTestScanner returns a scanner over db and client that detects sweeps
+// and confirms deposits immediately.
+func newTestScanner(db *database.DB, client rpc.EthClient) *Web3Scanner {
+	ws := &Web3Scanner{
+		db:            db,
+		client:        client,
+		signer:        testSigner,
+		shutdown:      func(error) {},
+		blocksStep:    100,
+		detectSweeps:  true,
+		skipZeroValue: true,
+		metrics:       metrics.NewMetrics(),
+
+		collectionStrategy: database.CollectionStrategyPriority,
+
+		dbPing:  db.Ping,
+		dbRetry: retry.Exponential(),
+	}
+	ws.dbAvailable.Store(true)
+	return ws
+}
+
+// logBuffer collects log output; it is safe for concurrent writes.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLogs sends info and higher level records of the default logger to
+// the returned buffer, in logfmt, until the test ends.
+func captureLogs(t *testing.T) *logBuffer {
+	t.Helper()
+	out := &logBuffer{}
+	previous := log.Root()
+	log.SetDefault(log.NewLogger(log.LogfmtHandlerWithLevel(out, slog.LevelInfo)))
+	t.Cleanup(func() { log.SetDefault(previous) })
+	return out
+}
+
+// fakeAddresses is an in-memory database.AddressesDB.
+type fakeAddresses struct {
+	database.AddressesDB
+	rows []database.Addresses
+	// strategies records the strategy of every SelectCollectionWallet call.
+	strategies []database.CollectionStrategy
+}
+
+func (f *fakeAddresses) BatchAddressExist(addresses []common.Address) (map[common.Address]uint8, error) {
+	tracked := make(map[common.Address]uint8)
+	for _, address := range addresses {
+		for _, row := range f.rows {
+			if row.Address == address {
+				tracked[address] = row.AddressType
+			}
+		}
+	}
+	return tracked, nil
+}
+
+func (f *fakeAddresses) QueryHotWalletsInfo() ([]*database.Addresses, error) {
+	var hotWallets []*database.Addresses
+	for i := range f.rows {
+		if f.rows[i].AddressType == database.AddressTypeHot {
+			hotWallets = append(hotWallets, &f.rows[i])
+		}
+	}
+	return hotWallets, nil
+}
+
+func (f *fakeAddresses) QueryColdWalletInfo() (*database.Addresses, error) {
+	for i := range f.rows {
+		if f.rows[i].AddressType == database.AddressTypeCold {
+			return &f.rows[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeAddresses) GetAllAddresses() ([]*database.Addresses, error) {
+	rows := make([]*database.Addresses, len(f.rows))
+	for i := range f.rows {
+		rows[i] = &f.rows[i]
+	}
+	return rows, nil
+}
+
+func (f *fakeAddresses) CountAddresses() (int64, error) {
+	return int64(len(f.rows)), nil
+}
+
+func (f *fakeAddresses) SelectCollectionWallet(strategy database.CollectionStrategy, _ common.Address) (*database.Addresses, error) {
+	f.strategies = append(f.strategies, strategy)
+	for i := range f.rows {
+		if f.rows[i].AddressType == database.AddressTypeHot {
+			return &f.rows[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeAddresses) QueryAddressesForEnsLookup(resolvedBefore int64, limit int) ([]*database.Addresses, error) {
+	var rows []*database.Addresses
+	for i := range f.rows {
+		if resolvedAt := f.rows[i].EnsResolvedAt; resolvedAt == nil || *resolvedAt < resolvedBefore {
+			rows = append(rows, &f.rows[i])
+		}
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (f *fakeAddresses) UpdateEnsName(address common.Address, name string, resolvedAt int64) error {
+	for i := range f.rows {
+		if f.rows[i].Address == address {
+			f.rows[i].EnsName, f.rows[i].EnsResolvedAt = name, &resolvedAt
+		}
+	}
+	return nil
+}
+
+// fakeBalances is an in-memory database.BalancesDB. QueryCollectableBalances
+// joins against addresses and tokens, which must be set to use it.
+type fakeBalances struct {
+	database.BalancesDB
+	balances map[[2]common.Address]*big.Int
+
+	addresses *fakeAddresses
+	tokens    *fakeTokens
+}
+
+func newFakeBalances() *fakeBalances {
+	return &fakeBalances{balances: make(map[[2]common.Address]*big.Int)}
+}
+
+func (f *fakeBalances) set(address, token common.Address, balance int64) {
+	f.balances[[2]common.Address{address, token}] = big.NewInt(balance)
+}
+
+func (f *fakeBalances) get(address, token common.Address) *big.Int {
+	if balance, ok := f.balances[[2]common.Address{address, token}]; ok {
+		return balance
+	}
+	return new(big.Int)
+}
+
+func (f *fakeBalances) QueryBalance(address, token common.Address) (*database.Balances, error) {
+	balance, ok := f.balances[[2]common.Address{address, token}]
+	if !ok {
+		return nil, nil
+	}
+	return &database.Balances{Address: address, TokenAddress: token, Balance: new(big.Int).Set(balance), LockBalance: new(big.Int)}, nil
+}
+
+func (f *fakeBalances) QueryCollectableBalances() ([]database.CollectableBalance, error) {
+	var collectable []database.CollectableBalance
+	for _, address := range f.addresses.rows {
+		if address.AddressType != database.AddressTypeUser {
+			continue
+		}
+		for _, token := range f.tokens.rows {
+			balance, ok := f.balances[[2]common.Address{address.Address, token.TokenAddress}]
+			if !ok || token.CollectAmount == nil || token.CollectAmount.Sign() <= 0 || balance.Cmp(token.CollectAmount) < 0 {
+				continue
+			}
+			collectable = append(collectable, database.CollectableBalance{Address: address.Address, TokenAddress: token.TokenAddress, Available: new(big.Int).Set(balance)})
+		}
+	}
+	slices.SortFunc(collectable, func(a, b database.CollectableBalance) int {
+		if c := a.Address.Cmp(b.Address); c != 0 {
+			return c
+		}
+		return a.TokenAddress.Cmp(b.TokenAddress)
+	})
+	return collectable, nil
+}
+
+func (f *fakeBalances) UpdateBalance(address, token common.Address, delta *big.Int) error {
+	updated := new(big.Int).Add(f.get(address, token), delta)
+	if updated.Sign() < 0 {
+		return database.ErrInsufficientBalance
+	}
+	f.balances[[2]common.Address{address, token}] = updated
+	return nil
+}
+
+// fakeBalanceHistory is an in-memory database.BalanceHistoryDB that enforces
+// the UNIQUE (address, token_address, block_number) constraint of the
+// balance_history table.
+type fakeBalanceHistory struct {
+	database.BalanceHistoryDB
+	snapshots []database.BalanceHistory
+}
+
+func (f *fakeBalanceHistory) StoreBalanceSnapshots(snapshots []database.BalanceHistory) (int, error) {
+	for _, snapshot := range snapshots {
+		for _, stored := range f.snapshots {
+			if stored.Address == snapshot.Address && stored.TokenAddress == snapshot.TokenAddress && stored.BlockNumber.Cmp(snapshot.BlockNumber) == 0 {
+				return 0, fmt.Errorf("duplicate balance snapshot for %s at block %s", snapshot.Address, snapshot.BlockNumber)
+			}
+		}
+		f.snapshots = append(f.snapshots, snapshot)
+	}
+	return len(snapshots), nil
+}
+
+// fakeTokens is an in-memory database.TokensDB.
+type fakeTokens struct {
+	database.TokensDB
+	rows []database.Tokens
+}
+
+func (f *fakeTokens) QueryToken(address common.Address) (*database.Tokens, error) {
+	for i := range f.rows {
+		if f.rows[i].TokenAddress == address {
+			return &f.rows[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeTokens) ListTokens() ([]*database.Tokens, error) {
+	rows := make([]*database.Tokens, len(f.rows))
+	for i := range f.rows {
+		rows[i] = &f.rows[i]
+	}
+	return rows, nil
+}
+
+// fakeWithdrawals is an in-memory database.WithdrawalsDB.
+type fakeWithdrawals struct {
+	database.WithdrawalsDB
+	rows []database.Withdrawals
+}
+
+func (f *fakeWithdrawals) QueryPendingWithdrawals(withdrawalType uint8) ([]*database.Withdrawals, error) {
+	var pending []*database.Withdrawals
+	for i := range f.rows {
+		w := &f.rows[i]
+		if w.Type == withdrawalType && w.Status != database.WithdrawalStatusConfirmed && w.Status != database.WithdrawalStatusFailed {
+			pending = append(pending, w)
+		}
+	}
+	return pending, nil
+}
+
+func (f *fakeWithdrawals) StoreWithdrawals(withdrawals []database.Withdrawals) error {
+	f.rows = append(f.rows, withdrawals...)
+	return nil
+}
+
+// fakeBlocks is an in-memory database.BlocksDB.
+type fakeBlocks struct {
+	database.BlocksDB
+	rows []database.Blocks
+}
+
+func (f *fakeBlocks) LatestBlock() (*database.Blocks, error) {
+	if len(f.rows) == 0 {
+		return nil, nil
+	}
+	return &f.rows[len(f.rows)-1], nil
+}
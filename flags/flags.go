@@ -1,7 +1,11 @@
 // Package flags provides the flags for the web3scanner
 package flags
 
-import "github.com/urfave/cli/v2"
+import (
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
 
 const evnVarPrefix = "WEB3SCANNER"
 
@@ -10,6 +14,11 @@ func prefixEnvVars(name string) []string {
 }
 
 var (
+	ConfigFlag = &cli.StringFlag{
+		Name:    "config",
+		Usage:   "Path to a YAML config file; flags that are set explicitly override its values",
+		EnvVars: prefixEnvVars("CONFIG"),
+	}
 	MigrationsFlag = &cli.StringFlag{
 		Name:    "migrations-dir",
 		Value:   "./migrations",
@@ -17,36 +26,68 @@ var (
 		EnvVars: prefixEnvVars("MIGRATIONS_DIR"),
 	}
 
-	// MasterDb Flags
+	// MasterDb Flags. They are required, but may be given in the --config
+	// file instead, so presence is checked when the config is loaded.
 	MasterDbHostFlag = &cli.StringFlag{
-		Name:     "master-db-host",
-		Usage:    "The host of the master database",
-		EnvVars:  prefixEnvVars("MASTER_DB_HOST"),
-		Required: true,
+		Name:    "master-db-host",
+		Usage:   "The host of the master database",
+		EnvVars: prefixEnvVars("MASTER_DB_HOST"),
 	}
 	MasterDbPortFlag = &cli.IntFlag{
-		Name:     "master-db-port",
-		Usage:    "The port of the master database",
-		EnvVars:  prefixEnvVars("MASTER_DB_PORT"),
-		Required: true,
+		Name:    "master-db-port",
+		Usage:   "The port of the master database",
+		EnvVars: prefixEnvVars("MASTER_DB_PORT"),
 	}
 	MasterDbUserFlag = &cli.StringFlag{
-		Name:     "master-db-user",
-		Usage:    "The user of the master database",
-		EnvVars:  prefixEnvVars("MASTER_DB_USER"),
-		Required: true,
+		Name:    "master-db-user",
+		Usage:   "The user of the master database",
+		EnvVars: prefixEnvVars("MASTER_DB_USER"),
 	}
 	MasterDbPasswordFlag = &cli.StringFlag{
-		Name:     "master-db-password",
-		Usage:    "The host of the master database",
-		EnvVars:  prefixEnvVars("MASTER_DB_PASSWORD"),
-		Required: true,
+		Name:    "master-db-password",
+		Usage:   "The host of the master database",
+		EnvVars: prefixEnvVars("MASTER_DB_PASSWORD"),
 	}
 	MasterDbNameFlag = &cli.StringFlag{
-		Name:     "master-db-name",
-		Usage:    "The db name of the master database",
-		EnvVars:  prefixEnvVars("MASTER_DB_NAME"),
-		Required: true,
+		Name:    "master-db-name",
+		Usage:   "The db name of the master database",
+		EnvVars: prefixEnvVars("MASTER_DB_NAME"),
+	}
+	MasterDbMaxOpenConnsFlag = &cli.IntFlag{
+		Name:    "master-db-max-open-conns",
+		Usage:   "The maximum number of open connections to the master database; 0 uses the default",
+		EnvVars: prefixEnvVars("MASTER_DB_MAX_OPEN_CONNS"),
+	}
+	MasterDbMaxIdleConnsFlag = &cli.IntFlag{
+		Name:    "master-db-max-idle-conns",
+		Usage:   "The maximum number of idle connections kept to the master database; 0 uses the default",
+		EnvVars: prefixEnvVars("MASTER_DB_MAX_IDLE_CONNS"),
+	}
+	MasterDbConnMaxLifetimeFlag = &cli.DurationFlag{
+		Name:    "master-db-conn-max-lifetime",
+		Usage:   "The maximum time a master database connection may be reused; 0 uses the default",
+		EnvVars: prefixEnvVars("MASTER_DB_CONN_MAX_LIFETIME"),
+	}
+	MasterDbSSLModeFlag = &cli.StringFlag{
+		Name:    "master-db-sslmode",
+		Value:   "disable",
+		Usage:   "The sslmode of the master database connection: disable, allow, prefer, require, verify-ca or verify-full",
+		EnvVars: prefixEnvVars("MASTER_DB_SSLMODE"),
+	}
+	MasterDbSSLRootCertFlag = &cli.StringFlag{
+		Name:    "master-db-sslrootcert",
+		Usage:   "Path to the CA certificate used to verify the master database server",
+		EnvVars: prefixEnvVars("MASTER_DB_SSLROOTCERT"),
+	}
+	MasterDbSSLCertFlag = &cli.StringFlag{
+		Name:    "master-db-sslcert",
+		Usage:   "Path to the client certificate presented to the master database",
+		EnvVars: prefixEnvVars("MASTER_DB_SSLCERT"),
+	}
+	MasterDbSSLKeyFlag = &cli.StringFlag{
+		Name:    "master-db-sslkey",
+		Usage:   "Path to the private key of the master database client certificate",
+		EnvVars: prefixEnvVars("MASTER_DB_SSLKEY"),
 	}
 
 	// Slave DB  flags
@@ -75,6 +116,279 @@ var (
 		Usage:   "The db name of the slave database",
 		EnvVars: prefixEnvVars("SLAVE_DB_NAME"),
 	}
+	SlaveDbMaxOpenConnsFlag = &cli.IntFlag{
+		Name:    "slave-db-max-open-conns",
+		Usage:   "The maximum number of open connections to the slave database; 0 uses the default",
+		EnvVars: prefixEnvVars("SLAVE_DB_MAX_OPEN_CONNS"),
+	}
+	SlaveDbMaxIdleConnsFlag = &cli.IntFlag{
+		Name:    "slave-db-max-idle-conns",
+		Usage:   "The maximum number of idle connections kept to the slave database; 0 uses the default",
+		EnvVars: prefixEnvVars("SLAVE_DB_MAX_IDLE_CONNS"),
+	}
+	SlaveDbConnMaxLifetimeFlag = &cli.DurationFlag{
+		Name:    "slave-db-conn-max-lifetime",
+		Usage:   "The maximum time a slave database connection may be reused; 0 uses the default",
+		EnvVars: prefixEnvVars("SLAVE_DB_CONN_MAX_LIFETIME"),
+	}
+	SlaveDbSSLModeFlag = &cli.StringFlag{
+		Name:    "slave-db-sslmode",
+		Value:   "disable",
+		Usage:   "The sslmode of the slave database connection: disable, allow, prefer, require, verify-ca or verify-full",
+		EnvVars: prefixEnvVars("SLAVE_DB_SSLMODE"),
+	}
+	SlaveDbSSLRootCertFlag = &cli.StringFlag{
+		Name:    "slave-db-sslrootcert",
+		Usage:   "Path to the CA certificate used to verify the slave database server",
+		EnvVars: prefixEnvVars("SLAVE_DB_SSLROOTCERT"),
+	}
+	SlaveDbSSLCertFlag = &cli.StringFlag{
+		Name:    "slave-db-sslcert",
+		Usage:   "Path to the client certificate presented to the slave database",
+		EnvVars: prefixEnvVars("SLAVE_DB_SSLCERT"),
+	}
+	SlaveDbSSLKeyFlag = &cli.StringFlag{
+		Name:    "slave-db-sslkey",
+		Usage:   "Path to the private key of the slave database client certificate",
+		EnvVars: prefixEnvVars("SLAVE_DB_SSLKEY"),
+	}
+
+	// Shared DB flags
+	DbApplicationNameFlag = &cli.StringFlag{
+		Name:    "db-application-name",
+		Value:   "web3scanner",
+		Usage:   "The application_name reported to Postgres and shown in pg_stat_activity; give each instance its own to tell them apart",
+		EnvVars: prefixEnvVars("DB_APPLICATION_NAME"),
+	}
+	DbKeepAliveIntervalFlag = &cli.DurationFlag{
+		Name:    "db-keepalive-interval",
+		Value:   5 * time.Minute,
+		Usage:   "How often to ping the database pool and retire connections idle longer than this; 0 disables",
+		EnvVars: prefixEnvVars("DB_KEEPALIVE_INTERVAL"),
+	}
+
+	// Scanner flags
+	RpcUrlFlag = &cli.StringFlag{
+		Name:    "rpc-url",
+		Usage:   "The HTTP or WebSocket URL of the Ethereum node to scan",
+		EnvVars: prefixEnvVars("RPC_URL"),
+	}
+	ChainIdFlag = &cli.Uint64Flag{
+		Name:    "chain-id",
+		Usage:   "The expected chain ID of the RPC node; the scanner refuses to start on mismatch. 0 skips the check",
+		EnvVars: prefixEnvVars("CHAIN_ID"),
+	}
+	StartingHeightFlag = &cli.Uint64Flag{
+		Name:    "starting-height",
+		Usage:   "The block to start scanning from when no block has been stored yet",
+		EnvVars: prefixEnvVars("STARTING_HEIGHT"),
+	}
+	BlocksStepFlag = &cli.Uint64Flag{
+		Name:    "blocks-step",
+		Value:   10,
+		Usage:   "The maximum number of blocks processed per poll",
+		EnvVars: prefixEnvVars("BLOCKS_STEP"),
+	}
+	PollIntervalFlag = &cli.DurationFlag{
+		Name:    "poll-interval",
+		Value:   5 * time.Second,
+		Usage:   "How often to poll the node for new blocks",
+		EnvVars: prefixEnvVars("POLL_INTERVAL"),
+	}
+	FailOnHookErrorFlag = &cli.BoolFlag{
+		Name:    "fail-on-hook-error",
+		Usage:   "Fail the whole block when a transaction hook returns an error",
+		EnvVars: prefixEnvVars("FAIL_ON_HOOK_ERROR"),
+	}
+	VerifyBlocksFlag = &cli.BoolFlag{
+		Name:    "verify-blocks",
+		Usage:   "Halt the scanner when a block's hash differs from the one reported by the node, instead of retrying the block range",
+		EnvVars: prefixEnvVars("VERIFY_BLOCKS"),
+	}
+	DetectSweepsFlag = &cli.BoolFlag{
+		Name:    "detect-sweeps",
+		Usage:   "Record transfers from user addresses to hot wallets as sweeps instead of ignoring them, and between user addresses as internal transfers instead of deposits",
+		EnvVars: prefixEnvVars("DETECT_SWEEPS"),
+		Value:   true,
+	}
+	DepositConfirmationsFlag = &cli.Uint64Flag{
+		Name:    "deposit-confirmations",
+		Value:   12,
+		Usage:   "The number of blocks on top of a deposit's block after which it is marked confirmed",
+		EnvVars: prefixEnvVars("DEPOSIT_CONFIRMATIONS"),
+	}
+	WithdrawalConfirmationsFlag = &cli.Uint64Flag{
+		Name:    "withdrawal-confirmations",
+		Value:   12,
+		Usage:   "The number of blocks on top of a mined withdrawal's block after which it is marked confirmed",
+		EnvVars: prefixEnvVars("WITHDRAWAL_CONFIRMATIONS"),
+	}
+	CatchUpBlocksStepFlag = &cli.Uint64Flag{
+		Name:    "catch-up-blocks-step",
+		Usage:   "The maximum number of blocks processed and committed per round while catching up; 0 always uses blocks-step",
+		EnvVars: prefixEnvVars("CATCH_UP_BLOCKS_STEP"),
+	}
+	CatchUpThresholdFlag = &cli.Uint64Flag{
+		Name:    "catch-up-threshold",
+		Value:   100,
+		Usage:   "The number of blocks behind the chain head above which the scanner uses catch-up-blocks-step",
+		EnvVars: prefixEnvVars("CATCH_UP_THRESHOLD"),
+	}
+	RpcBatchSizeFlag = &cli.Uint64Flag{
+		Name:    "rpc-batch-size",
+		Value:   50,
+		Usage:   "The maximum number of blocks fetched per JSON-RPC batch request while catching up; 0 disables batching",
+		EnvVars: prefixEnvVars("RPC_BATCH_SIZE"),
+	}
+	TxSubBatchSizeFlag = &cli.Uint64Flag{
+		Name:    "tx-sub-batch-size",
+		Usage:   "The maximum number of a block's transactions and receipts fetched and processed at once, bounding memory on huge blocks; 0 fetches whole blocks",
+		EnvVars: prefixEnvVars("TX_SUB_BATCH_SIZE"),
+	}
+	DepositPartitionSizeFlag = &cli.Uint64Flag{
+		Name:    "deposit-partition-size",
+		Usage:   "The number of blocks per deposits table partition, created ahead of the chain head; 0 keeps all deposits in the default partition",
+		EnvVars: prefixEnvVars("DEPOSIT_PARTITION_SIZE"),
+	}
+	DepositPartitionsAheadFlag = &cli.Uint64Flag{
+		Name:    "deposit-partitions-ahead",
+		Value:   2,
+		Usage:   "The number of deposits table partitions created past the one holding the chain head",
+		EnvVars: prefixEnvVars("DEPOSIT_PARTITIONS_AHEAD"),
+	}
+	MetricsListenAddrFlag = &cli.StringFlag{
+		Name:    "metrics-listen-addr",
+		Value:   "0.0.0.0:7300",
+		Usage:   "The address the /metrics and /healthz HTTP server listens on; empty disables it",
+		EnvVars: prefixEnvVars("METRICS_LISTEN_ADDR"),
+	}
+	DepositAlertThresholdFlag = &cli.IntFlag{
+		Name:    "deposit-alert-threshold",
+		Usage:   "Alert when an address receives more than this many deposits within the alert window; 0 disables",
+		EnvVars: prefixEnvVars("DEPOSIT_ALERT_THRESHOLD"),
+	}
+	DepositAlertWindowFlag = &cli.DurationFlag{
+		Name:    "deposit-alert-window",
+		Value:   time.Hour,
+		Usage:   "The time window, in block time, over which deposits per address are counted",
+		EnvVars: prefixEnvVars("DEPOSIT_ALERT_WINDOW"),
+	}
+	DepositAlertMaxAddressesFlag = &cli.IntFlag{
+		Name:    "deposit-alert-max-addresses",
+		Value:   10_000,
+		Usage:   "The maximum number of addresses whose deposit rate is tracked at once",
+		EnvVars: prefixEnvVars("DEPOSIT_ALERT_MAX_ADDRESSES"),
+	}
+	AddressCacheMaxSizeFlag = &cli.IntFlag{
+		Name:    "address-cache-max-size",
+		Value:   10_000,
+		Usage:   "Keep all tracked addresses in memory for existence checks while there are at most this many; 0 disables",
+		EnvVars: prefixEnvVars("ADDRESS_CACHE_MAX_SIZE"),
+	}
+	CollectionIntervalFlag = &cli.DurationFlag{
+		Name:    "collection-interval",
+		Usage:   "How often to queue collections into the hot wallet and transfers of hot wallet funds above the token ceiling to the cold wallet; 0 disables. Requires --detect-sweeps",
+		EnvVars: prefixEnvVars("COLLECTION_INTERVAL"),
+	}
+	CollectionStrategyFlag = &cli.StringFlag{
+		Name:    "collection-strategy",
+		Value:   "priority",
+		Usage:   "How the hot wallet receiving collections is chosen: priority, round-robin or lowest-balance",
+		EnvVars: prefixEnvVars("COLLECTION_STRATEGY"),
+	}
+	HeartbeatIntervalFlag = &cli.DurationFlag{
+		Name:    "heartbeat-interval",
+		Value:   time.Minute,
+		Usage:   "How often to log the scan cursor, chain head, lag and uptime, even when idle; 0 disables",
+		EnvVars: prefixEnvVars("HEARTBEAT_INTERVAL"),
+	}
+	EnsLookupFlag = &cli.BoolFlag{
+		Name:    "ens-lookup",
+		Usage:   "Look up the primary ENS name of every tracked address over RPC and store it with the address",
+		EnvVars: prefixEnvVars("ENS_LOOKUP"),
+	}
+	EnsRefreshIntervalFlag = &cli.DurationFlag{
+		Name:    "ens-refresh-interval",
+		Value:   24 * time.Hour,
+		Usage:   "How often stored ENS names are looked up again; 0 only looks up new addresses",
+		EnvVars: prefixEnvVars("ENS_REFRESH_INTERVAL"),
+	}
+	LogMatchesFlag = &cli.BoolFlag{
+		Name:    "log-matches",
+		Usage:   "Log every matched deposit and sweep at info level",
+		EnvVars: prefixEnvVars("LOG_MATCHES"),
+	}
+	StrictDecodeFlag = &cli.BoolFlag{
+		Name:    "strict-decode",
+		Usage:   "Halt the scanner on malformed logs and mismatched receipts instead of skipping them with a warning",
+		EnvVars: prefixEnvVars("STRICT_DECODE"),
+	}
+	SkipZeroValueTransfersFlag = &cli.BoolFlag{
+		Name:    "skip-zero-value-transfers",
+		Usage:   "Ignore zero-value native and ERC20 transfers to tracked addresses instead of recording them as deposits",
+		EnvVars: prefixEnvVars("SKIP_ZERO_VALUE_TRANSFERS"),
+		Value:   true,
+	}
+	DryRunFlag = &cli.BoolFlag{
+		Name:    "dry-run",
+		Usage:   "Process blocks and log what would be recorded, rolling back every write",
+		EnvVars: prefixEnvVars("DRY_RUN"),
+	}
+	RPCStatsFlag = &cli.BoolFlag{
+		Name:    "rpc-stats",
+		Usage:   "Count node calls, errors and latency per RPC method",
+		EnvVars: prefixEnvVars("RPC_STATS"),
+	}
+
+	// Command flags, only attached to the commands that use them.
+	FixFlag = &cli.BoolFlag{
+		Name:  "fix",
+		Usage: "Rewrite non-canonical addresses in place instead of only reporting them",
+	}
+
+	// Address import flags
+	ImportInputFlag = &cli.StringFlag{
+		Name:     "input",
+		Usage:    "CSV file of the addresses to import, with an address,address_type,public_key header",
+		Required: true,
+	}
+	SkipInvalidFlag = &cli.BoolFlag{
+		Name:  "skip-invalid",
+		Usage: "Skip and report addresses whose public key is missing or doesn't match instead of aborting the import",
+	}
+
+	// Rewind flags
+	RewindToFlag = &cli.Uint64Flag{
+		Name:     "to",
+		Usage:    "Block number to rewind the scan cursor to; stored blocks above it are removed",
+		Required: true,
+	}
+	YesFlag = &cli.BoolFlag{
+		Name:  "yes",
+		Usage: "Don't ask for confirmation",
+	}
+
+	// Deposit export flags
+	ExportFromFlag = &cli.Uint64Flag{
+		Name:     "from",
+		Usage:    "First block number of the export range",
+		Required: true,
+	}
+	ExportToFlag = &cli.Uint64Flag{
+		Name:     "to",
+		Usage:    "Last block number of the export range (inclusive)",
+		Required: true,
+	}
+	ExportFormatFlag = &cli.StringFlag{
+		Name:  "format",
+		Value: "csv",
+		Usage: "Export format; only csv is supported",
+	}
+	ExportOutputFlag = &cli.StringFlag{
+		Name:  "output",
+		Value: "-",
+		Usage: "File to write the export to, - for stdout",
+	}
 )
 
 var requireFlags = []cli.Flag{
@@ -87,11 +401,59 @@ var requireFlags = []cli.Flag{
 }
 
 var optionalFlags = []cli.Flag{
+	ConfigFlag,
+	MasterDbMaxOpenConnsFlag,
+	MasterDbMaxIdleConnsFlag,
+	MasterDbConnMaxLifetimeFlag,
+	MasterDbSSLModeFlag,
+	MasterDbSSLRootCertFlag,
+	MasterDbSSLCertFlag,
+	MasterDbSSLKeyFlag,
 	SlaveDbHostFlag,
 	SlaveDbPortFlag,
 	SlaveDbUserFlag,
 	SlaveDbPasswordFlag,
 	SlaveDbNameFlag,
+	SlaveDbMaxOpenConnsFlag,
+	SlaveDbMaxIdleConnsFlag,
+	SlaveDbConnMaxLifetimeFlag,
+	SlaveDbSSLModeFlag,
+	SlaveDbSSLRootCertFlag,
+	SlaveDbSSLCertFlag,
+	SlaveDbSSLKeyFlag,
+	DbApplicationNameFlag,
+	DbKeepAliveIntervalFlag,
+	RpcUrlFlag,
+	ChainIdFlag,
+	StartingHeightFlag,
+	BlocksStepFlag,
+	PollIntervalFlag,
+	FailOnHookErrorFlag,
+	VerifyBlocksFlag,
+	DetectSweepsFlag,
+	DepositConfirmationsFlag,
+	WithdrawalConfirmationsFlag,
+	CatchUpBlocksStepFlag,
+	CatchUpThresholdFlag,
+	RpcBatchSizeFlag,
+	TxSubBatchSizeFlag,
+	DepositPartitionSizeFlag,
+	DepositPartitionsAheadFlag,
+	MetricsListenAddrFlag,
+	DepositAlertThresholdFlag,
+	DepositAlertWindowFlag,
+	DepositAlertMaxAddressesFlag,
+	AddressCacheMaxSizeFlag,
+	CollectionIntervalFlag,
+	CollectionStrategyFlag,
+	HeartbeatIntervalFlag,
+	EnsLookupFlag,
+	EnsRefreshIntervalFlag,
+	LogMatchesFlag,
+	StrictDecodeFlag,
+	SkipZeroValueTransfersFlag,
+	DryRunFlag,
+	RPCStatsFlag,
 }
 
 func init() {
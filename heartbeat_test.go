@@ -0,0 +1,83 @@
+package web3scanner
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestHeartbeatCadence(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	client := newFakeClient()
+	ws := newTestScanner(&database.DB{Blocks: storedGenesis(client)}, client)
+	ws.heartbeatInterval = interval
+	ws.startedAt = time.Now()
+	logs := captureLogs(t)
+
+	// Run idle, caught-up rounds far faster than the interval.
+	start := time.Now()
+	for time.Since(start) < 10*interval-interval/2 {
+		if _, err := ws.scanBlocks(context.Background()); err != nil {
+			t.Fatalf("scanBlocks: %v", err)
+		}
+		ws.maybeHeartbeat()
+		time.Sleep(time.Millisecond)
+	}
+
+	// The first round logs right away and every later heartbeat waits a
+	// full interval, so 10 is the most that fit; allow for slow runners.
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	var heartbeats []string
+	for _, line := range lines {
+		if strings.Contains(line, "msg=heartbeat") {
+			heartbeats = append(heartbeats, line)
+		}
+	}
+	if len(heartbeats) < 7 || len(heartbeats) > 10 {
+		t.Fatalf("%d heartbeats in %v with a %v interval, want about 10", len(heartbeats), 10*interval, interval)
+	}
+	for _, field := range []string{"cursor=0", "head=0", "lag=0", "uptime="} {
+		if !strings.Contains(heartbeats[0], field) {
+			t.Errorf("heartbeat %q does not log %s", heartbeats[0], field)
+		}
+	}
+}
+
+func TestHeartbeatLag(t *testing.T) {
+	ws := newTestScanner(nil, newFakeClient())
+	ws.heartbeatInterval = time.Hour
+	ws.startedAt = time.Now().Add(-90 * time.Second)
+	logs := captureLogs(t)
+
+	// Before the first round there is no cursor or head to report.
+	ws.maybeHeartbeat()
+	if line := logs.String(); !strings.Contains(line, "msg=heartbeat") || strings.Contains(line, "lag=") {
+		t.Errorf("heartbeat before the first round = %q, want one without lag", line)
+	}
+
+	ws.lastHeartbeat = time.Time{}
+	ws.cursor, ws.head = big.NewInt(95), big.NewInt(100)
+	ws.maybeHeartbeat()
+	ws.maybeHeartbeat()
+	if got := strings.Count(logs.String(), "msg=heartbeat"); got != 2 {
+		t.Fatalf("%d heartbeats, want 2", got)
+	}
+	for _, field := range []string{"cursor=95", "head=100", "lag=5", "uptime=1m30s"} {
+		if !strings.Contains(logs.String(), field) {
+			t.Errorf("heartbeat %q does not log %s", logs.String(), field)
+		}
+	}
+}
+
+func TestHeartbeatDisabled(t *testing.T) {
+	ws := newTestScanner(nil, newFakeClient())
+	logs := captureLogs(t)
+	ws.maybeHeartbeat()
+	if strings.Contains(logs.String(), "heartbeat") {
+		t.Errorf("heartbeat logged with a zero interval: %q", logs.String())
+	}
+}
@@ -0,0 +1,56 @@
+package web3scanner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// TransactionHook lets callers run custom logic on every scanned transaction
+// that touches a tracked address, without forking the scanner.
+//
+// OnTransaction receives the transaction and its receipt. A returned error is
+// logged; whether it also fails the block is controlled by
+// Config.FailOnHookError.
+//
+// Delivery is at-least-once. Hooks run while a block range is processed,
+// before it is stored; if fetching or storing any block of the range fails,
+// the whole range is processed again on the next round and its hooks run
+// again. Hooks with side effects should be idempotent, for example by
+// keying them on the transaction hash.
+type TransactionHook interface {
+	OnTransaction(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error
+}
+
+// TransactionHookFunc is an adapter to allow the use of ordinary functions as
+// a TransactionHook.
+type TransactionHookFunc func(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error
+
+// OnTransaction calls f(ctx, tx, receipt).
+func (f TransactionHookFunc) OnTransaction(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error {
+	return f(ctx, tx, receipt)
+}
+
+// RegisterTransactionHook appends a hook to the scanner. Hooks are invoked in
+// the order they were registered. It must be called before Start.
+func (ws *Web3Scanner) RegisterTransactionHook(hook TransactionHook) {
+	ws.hooks = append(ws.hooks, hook)
+}
+
+// runTransactionHooks invokes every registered hook for the given transaction.
+//
+// Hook errors are always logged. If failOnHookError is set, the first error
+// stops the remaining hooks and is returned so the caller can fail the block.
+func (ws *Web3Scanner) runTransactionHooks(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error {
+	for i, hook := range ws.hooks {
+		if err := hook.OnTransaction(ctx, tx, receipt); err != nil {
+			log.Error("transaction hook failed", "hook", i, "tx", tx.Hash(), "err", err)
+			if ws.failOnHookError {
+				return fmt.Errorf("transaction hook %d failed for tx %s: %w", i, tx.Hash(), err)
+			}
+		}
+	}
+	return nil
+}
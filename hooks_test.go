@@ -0,0 +1,145 @@
+package web3scanner
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+func TestTransactionHooks(t *testing.T) {
+	user := newTestAccount(t)
+	stranger := newTestAccount(t)
+	external := common.HexToAddress("0x3000000000000000000000000000000000000003")
+
+	client := newFakeClient()
+	deposit := stranger.transfer(t, user.address, 1)
+	untracked := stranger.transfer(t, external, 2)
+	block := client.addBlock(deposit, untracked)
+	db := &database.DB{Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}}}
+
+	var calls []string
+	ws := newTestScanner(db, client)
+	ws.detectSweeps = false
+	for _, name := range []string{"first", "second"} {
+		ws.RegisterTransactionHook(TransactionHookFunc(func(_ context.Context, tx *types.Transaction, receipt *types.Receipt) error {
+			if tx.Hash() != deposit.Hash() || receipt.TxHash != deposit.Hash() {
+				t.Errorf("%s hook called with tx %s, receipt %s, want %s", name, tx.Hash(), receipt.TxHash, deposit.Hash())
+			}
+			calls = append(calls, name)
+			return errors.New("hook failed")
+		}))
+	}
+
+	// Hook errors are logged but don't fail the block by default.
+	m, err := ws.processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	if len(m.deposits) != 1 {
+		t.Errorf("%d deposits recorded, want 1", len(m.deposits))
+	}
+	if want := []string{"first", "second"}; !slices.Equal(calls, want) {
+		t.Errorf("hooks called %v, want %v", calls, want)
+	}
+
+	calls = nil
+	ws.failOnHookError = true
+	if _, err := ws.processBlock(context.Background(), block, nil); err == nil {
+		t.Fatal("processBlock succeeded with a failing hook and failOnHookError set")
+	}
+	if len(calls) != 1 {
+		t.Errorf("hooks called %v after the first failure, want only first", calls)
+	}
+}
+
+// failingBlockClient fails every fetch of one block number.
+type failingBlockClient struct {
+	*fakeClient
+	number int64
+}
+
+func (c failingBlockClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	if number.Int64() == c.number {
+		return nil, errors.New("block unavailable")
+	}
+	return c.fakeClient.BlockByNumber(ctx, number)
+}
+
+// hookCounter counts hook deliveries by transaction.
+func hookCounter(ws *Web3Scanner) map[common.Hash]int {
+	delivered := make(map[common.Hash]int)
+	ws.RegisterTransactionHook(TransactionHookFunc(func(_ context.Context, tx *types.Transaction, _ *types.Receipt) error {
+		delivered[tx.Hash()]++
+		return nil
+	}))
+	return delivered
+}
+
+func TestTransactionHooksRedeliveredOnRetriedRange(t *testing.T) {
+	user := newTestAccount(t)
+	stranger := newTestAccount(t)
+
+	client := newFakeClient()
+	deposit := stranger.transfer(t, user.address, 1)
+	client.addBlock(deposit)
+	client.addBlock()
+	db := &database.DB{
+		Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}},
+		Blocks:    &fakeBlocks{},
+	}
+	ws := newTestScanner(db, failingBlockClient{fakeClient: client, number: 2})
+	delivered := hookCounter(ws)
+
+	// Block 2 fails to fetch after block 1 was processed, so every round
+	// fails and processes block 1 again.
+	for round := 1; round <= 2; round++ {
+		if _, err := ws.scanBlocks(context.Background()); err == nil {
+			t.Fatalf("round %d: scanBlocks succeeded with an unavailable block", round)
+		}
+		if delivered[deposit.Hash()] != round {
+			t.Fatalf("after %d failed rounds the hook saw the deposit %d times, want %d", round, delivered[deposit.Hash()], round)
+		}
+	}
+}
+
+func TestTransactionHooksRedeliveredAfterFailedPersist(t *testing.T) {
+	db, cfg := dbtest.NewDB(t)
+	user := newTestAccount(t)
+	stranger := newTestAccount(t)
+	if err := db.Addresses.StoreAddresses([]database.Addresses{user.row(database.AddressTypeUser)}); err != nil {
+		t.Fatalf("store addresses: %v", err)
+	}
+
+	client := newFakeClient()
+	deposit := stranger.transfer(t, user.address, 1)
+	client.addBlock(deposit)
+	ws := newTestScanner(db, client)
+	delivered := hookCounter(ws)
+
+	dbtest.Exec(t, cfg, "ALTER TABLE deposits RENAME TO deposits_hidden")
+	if _, err := ws.scanBlocks(context.Background()); err == nil {
+		t.Fatal("scanBlocks succeeded without a deposits table")
+	}
+	dbtest.Exec(t, cfg, "ALTER TABLE deposits_hidden RENAME TO deposits")
+	if _, err := ws.scanBlocks(context.Background()); err != nil {
+		t.Fatalf("scanBlocks after the deposits table is back: %v", err)
+	}
+	if got := delivered[deposit.Hash()]; got != 2 {
+		t.Errorf("hook saw the deposit %d times, want 2: once per attempt at the range", got)
+	}
+	stored, err := db.Deposits.QueryDepositsByBlockRange(big.NewInt(0), big.NewInt(1))
+	if err != nil {
+		t.Fatalf("query deposits: %v", err)
+	}
+	if len(stored) != 1 {
+		t.Errorf("%d deposits stored, want 1", len(stored))
+	}
+}
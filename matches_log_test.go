@@ -0,0 +1,61 @@
+package web3scanner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestProcessBlockLogsMatches(t *testing.T) {
+	user, hot, payer := newTestAccount(t), newTestAccount(t), newTestAccount(t)
+	client := newFakeClient()
+	deposit := payer.transfer(t, user.address, 5)
+	sweep := user.transfer(t, hot.address, 3)
+	block := client.addBlock(deposit, sweep)
+	db := &database.DB{
+		Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser), hot.row(database.AddressTypeHot)}},
+	}
+	ws := newTestScanner(db, client)
+	logs := captureLogs(t)
+
+	// Off by default.
+	if _, err := ws.processBlock(context.Background(), block, nil); err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	if strings.Contains(logs.String(), "matched") {
+		t.Fatalf("matches logged while disabled: %q", logs.String())
+	}
+
+	ws.logMatches = true
+	if _, err := ws.processBlock(context.Background(), block, nil); err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	lines := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		for _, msg := range []string{"matched deposit", "matched sweep"} {
+			if strings.Contains(line, `msg="`+msg+`"`) {
+				lines[msg] = line
+			}
+		}
+	}
+	for msg, fields := range map[string][]string{
+		"matched deposit": {"to=" + user.address.Hex(), "amount=5", "tx=" + deposit.Hash().Hex(), "block=1"},
+		"matched sweep":   {"from=" + user.address.Hex(), "to=" + hot.address.Hex(), "amount=3", "tx=" + sweep.Hash().Hex(), "block=1"},
+	} {
+		line, ok := lines[msg]
+		if !ok {
+			t.Errorf("no %q log in %q", msg, logs.String())
+			continue
+		}
+		for _, field := range fields {
+			if !strings.Contains(line, field) {
+				t.Errorf("%q log %q does not contain %s", msg, line, field)
+			}
+		}
+		if !strings.Contains(line, "token=") {
+			t.Errorf("%q log %q does not name the token", msg, line)
+		}
+	}
+}
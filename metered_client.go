@@ -0,0 +1,160 @@
+package web3scanner
+
+import (
+	"context"
+	"maps"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/metrics"
+	"github.com/qiaopengjun5162/web3scanner/rpc"
+)
+
+// RPCMethodStats are the call statistics of one client method.
+type RPCMethodStats struct {
+	Calls  uint64
+	Errors uint64
+	// Latency is the total time spent in the method's calls.
+	Latency time.Duration
+}
+
+// rpcStats collects RPCMethodStats by method; it is safe for concurrent use.
+type rpcStats struct {
+	mu      sync.Mutex
+	methods map[string]RPCMethodStats
+}
+
+func newRPCStats() *rpcStats {
+	return &rpcStats{methods: make(map[string]RPCMethodStats)}
+}
+
+func (s *rpcStats) record(method string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.methods[method]
+	stats.Calls++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.Latency += d
+	s.methods[method] = stats
+}
+
+func (s *rpcStats) snapshot() map[string]RPCMethodStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.methods)
+}
+
+// RPCStats returns the call statistics of every client method called so far,
+// keyed by method name, or nil when RPC stats are disabled.
+func (ws *Web3Scanner) RPCStats() map[string]RPCMethodStats {
+	if ws.rpcStats == nil {
+		return nil
+	}
+	return ws.rpcStats.snapshot()
+}
+
+// meteredClient counts failed calls of the wrapped client in the RPC error
+// metric. With stats set it also counts calls, errors and latency per
+// method, in stats and the per-method metrics.
+type meteredClient struct {
+	rpc.EthClient
+	metrics *metrics.Metrics
+	stats   *rpcStats
+}
+
+func (c *meteredClient) record(method string, start time.Time, err error) error {
+	if err != nil {
+		c.metrics.RecordRPCError()
+	}
+	if c.stats != nil {
+		d := time.Since(start)
+		c.stats.record(method, d, err)
+		c.metrics.RecordRPCCall(method, d, err)
+	}
+	return err
+}
+
+func (c *meteredClient) ChainID(ctx context.Context) (*big.Int, error) {
+	start := time.Now()
+	id, err := c.EthClient.ChainID(ctx)
+	return id, c.record("ChainID", start, err)
+}
+
+func (c *meteredClient) BlockNumber(ctx context.Context) (uint64, error) {
+	start := time.Now()
+	number, err := c.EthClient.BlockNumber(ctx)
+	return number, c.record("BlockNumber", start, err)
+}
+
+func (c *meteredClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	start := time.Now()
+	block, err := c.EthClient.BlockByNumber(ctx, number)
+	return block, c.record("BlockByNumber", start, err)
+}
+
+func (c *meteredClient) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	start := time.Now()
+	block, err := c.EthClient.BlockByHash(ctx, hash)
+	return block, c.record("BlockByHash", start, err)
+}
+
+func (c *meteredClient) BatchBlocksByRange(ctx context.Context, from, to *big.Int) ([]*types.Block, error) {
+	start := time.Now()
+	blocks, err := c.EthClient.BatchBlocksByRange(ctx, from, to)
+	return blocks, c.record("BatchBlocksByRange", start, err)
+}
+
+func (c *meteredClient) HeaderAndTxHashesByNumber(ctx context.Context, number *big.Int) (*types.Header, []common.Hash, error) {
+	start := time.Now()
+	header, hashes, err := c.EthClient.HeaderAndTxHashesByNumber(ctx, number)
+	return header, hashes, c.record("HeaderAndTxHashesByNumber", start, err)
+}
+
+func (c *meteredClient) BatchTransactionsAndReceipts(ctx context.Context, hashes []common.Hash) ([]*types.Transaction, []*types.Receipt, error) {
+	start := time.Now()
+	txs, receipts, err := c.EthClient.BatchTransactionsAndReceipts(ctx, hashes)
+	return txs, receipts, c.record("BatchTransactionsAndReceipts", start, err)
+}
+
+func (c *meteredClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	start := time.Now()
+	header, err := c.EthClient.HeaderByNumber(ctx, number)
+	return header, c.record("HeaderByNumber", start, err)
+}
+
+func (c *meteredClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	start := time.Now()
+	receipt, err := c.EthClient.TransactionReceipt(ctx, txHash)
+	return receipt, c.record("TransactionReceipt", start, err)
+}
+
+func (c *meteredClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	start := time.Now()
+	nonce, err := c.EthClient.PendingNonceAt(ctx, account)
+	return nonce, c.record("PendingNonceAt", start, err)
+}
+
+func (c *meteredClient) BlockReceiptsByHash(ctx context.Context, blockHash common.Hash) ([]*types.Receipt, error) {
+	start := time.Now()
+	receipts, err := c.EthClient.BlockReceiptsByHash(ctx, blockHash)
+	return receipts, c.record("BlockReceiptsByHash", start, err)
+}
+
+func (c *meteredClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	start := time.Now()
+	output, err := c.EthClient.CallContract(ctx, msg, blockNumber)
+	return output, c.record("CallContract", start, err)
+}
+
+func (c *meteredClient) ReportedBlockHash(ctx context.Context, number *big.Int) (common.Hash, error) {
+	start := time.Now()
+	hash, err := c.EthClient.ReportedBlockHash(ctx, number)
+	return hash, c.record("ReportedBlockHash", start, err)
+}
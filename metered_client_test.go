@@ -0,0 +1,86 @@
+package web3scanner
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/metrics"
+)
+
+func TestMeteredClientCountsCallsPerMethod(t *testing.T) {
+	fake := newFakeClient()
+	fake.addBlock()
+	m := metrics.NewMetrics()
+	stats := newRPCStats()
+	client := &meteredClient{EthClient: fake, metrics: m, stats: stats}
+	ws := &Web3Scanner{client: client, metrics: m, rpcStats: stats}
+
+	ctx := context.Background()
+	for range 2 {
+		if _, err := client.BlockNumber(ctx); err != nil {
+			t.Fatalf("BlockNumber: %v", err)
+		}
+	}
+	if _, err := client.BlockByNumber(ctx, big.NewInt(1)); err != nil {
+		t.Fatalf("BlockByNumber: %v", err)
+	}
+	if _, err := client.BlockByNumber(ctx, big.NewInt(99)); err == nil {
+		t.Fatal("BlockByNumber of a missing block succeeded")
+	}
+
+	got := ws.RPCStats()
+	want := map[string]struct{ calls, errors uint64 }{
+		"BlockNumber":   {2, 0},
+		"BlockByNumber": {2, 1},
+	}
+	if len(got) != len(want) {
+		t.Errorf("RPCStats has %d methods, want %d: %+v", len(got), len(want), got)
+	}
+	for method, w := range want {
+		if got[method].Calls != w.calls || got[method].Errors != w.errors {
+			t.Errorf("%s stats = %+v, want %d calls and %d errors", method, got[method], w.calls, w.errors)
+		}
+	}
+
+	// The returned map is a copy.
+	got["BlockNumber"] = RPCMethodStats{}
+	if ws.RPCStats()["BlockNumber"].Calls != 2 {
+		t.Error("changing the RPCStats result changed the recorded stats")
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`web3scanner_rpc_calls_total{method="BlockByNumber"} 2`,
+		`web3scanner_rpc_calls_total{method="BlockNumber"} 2`,
+		`web3scanner_rpc_method_errors_total{method="BlockByNumber"} 1`,
+		`web3scanner_rpc_call_duration_seconds_count{method="BlockByNumber"} 2`,
+		`web3scanner_rpc_errors_total 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Errorf("metrics missing %q", line)
+		}
+	}
+}
+
+func TestMeteredClientStatsDisabled(t *testing.T) {
+	fake := newFakeClient()
+	m := metrics.NewMetrics()
+	client := &meteredClient{EthClient: fake, metrics: m}
+	ws := &Web3Scanner{client: client, metrics: m}
+
+	if _, err := client.BlockNumber(context.Background()); err != nil {
+		t.Fatalf("BlockNumber: %v", err)
+	}
+	if stats := ws.RPCStats(); stats != nil {
+		t.Errorf("RPCStats = %+v with stats disabled, want nil", stats)
+	}
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(rec.Body.String(), "web3scanner_rpc_calls_total{") {
+		t.Error("per-method RPC metrics recorded with stats disabled")
+	}
+}
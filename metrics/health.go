@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// HealthCheck reports whether a dependency is usable.
+type HealthCheck func(ctx context.Context) error
+
+// HealthHandler serves a readiness probe: it answers 200 when check
+// succeeds within timeout and 503 otherwise, so a hung dependency fails
+// the probe instead of blocking it.
+func HealthHandler(check HealthCheck, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		if err := check(ctx); err != nil {
+			log.Warn("health check failed", "err", err)
+			http.Error(w, "unhealthy: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
@@ -0,0 +1,231 @@
+// Package metrics exposes Prometheus metrics for the scanner.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "web3scanner"
+
+// Scan phases observed by ObserveScanPhase.
+const (
+	// PhaseFetch is fetching blocks and receipts from the node.
+	PhaseFetch = "fetch"
+	// PhaseDecode is decoding and classifying the transactions of a block.
+	PhaseDecode = "decode"
+	// PhasePersist is storing a scanned range in one transaction.
+	PhasePersist = "persist"
+)
+
+// Metrics holds the scanner's collectors on a dedicated registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	blocksScanned       prometheus.Counter
+	transactionsMatched prometheus.Counter
+	rpcErrors           prometheus.Counter
+	latestBlock         prometheus.Gauge
+	scanLag             prometheus.Gauge
+	lastHeartbeat       prometheus.Gauge
+	serializerErrors    *prometheus.CounterVec
+	scanPhaseDuration   *prometheus.HistogramVec
+	rpcCalls            *prometheus.CounterVec
+	rpcMethodErrors     *prometheus.CounterVec
+	rpcDuration         *prometheus.HistogramVec
+}
+
+// NewMetrics creates the scanner metrics, registered together with the Go
+// runtime and process collectors.
+func NewMetrics() *Metrics {
+	registry := prometheus.NewRegistry()
+	m := &Metrics{
+		registry: registry,
+		blocksScanned: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "blocks_scanned_total",
+			Help:      "Number of blocks scanned and stored.",
+		}),
+		transactionsMatched: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transactions_matched_total",
+			Help:      "Number of scanned transactions that touched a tracked address.",
+		}),
+		rpcErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rpc_errors_total",
+			Help:      "Number of failed RPC calls to the node.",
+		}),
+		latestBlock: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "latest_block",
+			Help:      "Number of the latest stored block.",
+		}),
+		scanLag: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "scan_lag_blocks",
+			Help:      "Number of blocks between the chain head and the latest stored block.",
+		}),
+		lastHeartbeat: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_heartbeat_timestamp_seconds",
+			Help:      "Unix time of the latest scanner heartbeat.",
+		}),
+		serializerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "serializer_errors_total",
+			Help:      "Number of failed database column encodes and decodes, by serializer and Go field type.",
+		}, []string{"serializer", "field_type"}),
+		scanPhaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "scan_phase_duration_seconds",
+			Help:      "Time spent in each scan phase: fetch, decode and persist.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"phase"}),
+		rpcCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rpc_calls_total",
+			Help:      "Number of RPC calls to the node, by client method. Only recorded with RPC stats enabled.",
+		}, []string{"method"}),
+		rpcMethodErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rpc_method_errors_total",
+			Help:      "Number of failed RPC calls to the node, by client method. Only recorded with RPC stats enabled.",
+		}, []string{"method"}),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rpc_call_duration_seconds",
+			Help:      "Latency of RPC calls to the node, by client method. Only recorded with RPC stats enabled.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.blocksScanned,
+		m.transactionsMatched,
+		m.rpcErrors,
+		m.latestBlock,
+		m.scanLag,
+		m.lastHeartbeat,
+		m.serializerErrors,
+		m.scanPhaseDuration,
+		m.rpcCalls,
+		m.rpcMethodErrors,
+		m.rpcDuration,
+	)
+	return m
+}
+
+// RecordBlocksScanned adds n to the scanned blocks counter.
+func (m *Metrics) RecordBlocksScanned(n int) {
+	m.blocksScanned.Add(float64(n))
+}
+
+// RecordTransactionsMatched adds n to the matched transactions counter.
+func (m *Metrics) RecordTransactionsMatched(n int) {
+	m.transactionsMatched.Add(float64(n))
+}
+
+// RecordRPCError increments the RPC error counter.
+func (m *Metrics) RecordRPCError() {
+	m.rpcErrors.Inc()
+}
+
+// RecordRPCCall records one call of the client method that took d and
+// failed if err is non-nil. It doesn't touch the RPC error counter, which
+// RecordRPCError maintains for every call.
+func (m *Metrics) RecordRPCCall(method string, d time.Duration, err error) {
+	m.rpcCalls.WithLabelValues(method).Inc()
+	if err != nil {
+		m.rpcMethodErrors.WithLabelValues(method).Inc()
+	}
+	m.rpcDuration.WithLabelValues(method).Observe(d.Seconds())
+}
+
+// RecordLatestBlock sets the latest stored block and the lag behind head.
+func (m *Metrics) RecordLatestBlock(latest, head *big.Int) {
+	latestF, _ := new(big.Float).SetInt(latest).Float64()
+	m.latestBlock.Set(latestF)
+	lagF, _ := new(big.Float).SetInt(new(big.Int).Sub(head, latest)).Float64()
+	m.scanLag.Set(lagF)
+}
+
+// RecordHeartbeat sets the time of the latest heartbeat.
+func (m *Metrics) RecordHeartbeat(t time.Time) {
+	m.lastHeartbeat.Set(float64(t.Unix()))
+}
+
+// RecordSerializerError increments the serializer error counter of the
+// serializer and field type. Its signature matches
+// serializers.ErrorObserver, so it can be installed directly.
+func (m *Metrics) RecordSerializerError(serializer string, fieldType reflect.Type, _ error) {
+	typeName := "unknown"
+	if fieldType != nil {
+		typeName = fieldType.String()
+	}
+	m.serializerErrors.WithLabelValues(serializer, typeName).Inc()
+}
+
+// ObserveScanPhase records d as the duration of one run of phase, one of
+// PhaseFetch, PhaseDecode or PhasePersist.
+func (m *Metrics) ObserveScanPhase(phase string, d time.Duration) {
+	m.scanPhaseDuration.WithLabelValues(phase).Observe(d.Seconds())
+}
+
+// Handler returns the HTTP handler serving the metrics in the Prometheus
+// text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Server is the HTTP server exposing /metrics.
+type Server struct {
+	srv      *http.Server
+	listener net.Listener
+}
+
+// StartServer listens on addr and serves the metrics on /metrics in the
+// background. Extra handlers can be mounted on mux before calling it; a nil
+// mux creates a new one.
+func StartServer(addr string, m *Metrics, mux *http.ServeMux) (*Server, error) {
+	if mux == nil {
+		mux = http.NewServeMux()
+	}
+	mux.Handle("/metrics", m.Handler())
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		srv:      &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		listener: listener,
+	}
+	go func() {
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("metrics server failed", "err", err)
+		}
+	}()
+	log.Info("metrics server started", "addr", listener.Addr())
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Stop gracefully shuts the server down.
+func (s *Server) Stop(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
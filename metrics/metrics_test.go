@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm/schema"
+
+	"github.com/qiaopengjun5162/web3scanner/database/utils/serializers"
+)
+
+func TestSerializerErrorsCounted(t *testing.T) {
+	m := NewMetrics()
+	serializers.SetErrorObserver(m.RecordSerializerError)
+	defer serializers.SetErrorObserver(nil)
+
+	field := &schema.Field{FieldType: reflect.TypeOf((*big.Int)(nil))}
+	var dst big.Int
+	err := serializers.U256Serializer{}.Scan(context.Background(), field, reflect.ValueOf(&dst), "not a number")
+	if err == nil {
+		t.Fatal("Scan of a non-numeric value succeeded")
+	}
+
+	counter := m.serializerErrors.WithLabelValues("u256", "*big.Int")
+	if got := testutil.ToFloat64(counter); got != 1 {
+		t.Errorf("serializer error counter = %v, want 1", got)
+	}
+}
+
+func TestRecordHeartbeat(t *testing.T) {
+	m := NewMetrics()
+	m.RecordHeartbeat(time.Unix(1700000000, 0))
+	if got := testutil.ToFloat64(m.lastHeartbeat); got != 1700000000 {
+		t.Errorf("last heartbeat = %v, want 1700000000", got)
+	}
+}
+
+func TestObserveScanPhase(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveScanPhase(PhaseFetch, 300*time.Millisecond)
+	m.ObserveScanPhase(PhaseFetch, 2*time.Second)
+	m.ObserveScanPhase(PhasePersist, 20*time.Millisecond)
+
+	if got := testutil.CollectAndCount(m.scanPhaseDuration); got != 2 {
+		t.Errorf("scan phase series = %d, want 2 (fetch and persist)", got)
+	}
+	want := `
+# HELP web3scanner_scan_phase_duration_seconds Time spent in each scan phase: fetch, decode and persist.
+# TYPE web3scanner_scan_phase_duration_seconds histogram
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="0.005"} 0
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="0.01"} 0
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="0.025"} 0
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="0.05"} 0
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="0.1"} 0
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="0.25"} 0
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="0.5"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="1"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="2.5"} 2
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="5"} 2
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="10"} 2
+web3scanner_scan_phase_duration_seconds_bucket{phase="fetch",le="+Inf"} 2
+web3scanner_scan_phase_duration_seconds_sum{phase="fetch"} 2.3
+web3scanner_scan_phase_duration_seconds_count{phase="fetch"} 2
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="0.005"} 0
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="0.01"} 0
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="0.025"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="0.05"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="0.1"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="0.25"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="0.5"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="1"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="2.5"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="5"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="10"} 1
+web3scanner_scan_phase_duration_seconds_bucket{phase="persist",le="+Inf"} 1
+web3scanner_scan_phase_duration_seconds_sum{phase="persist"} 0.02
+web3scanner_scan_phase_duration_seconds_count{phase="persist"} 1
+`
+	if err := testutil.CollectAndCompare(m.scanPhaseDuration, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,3 @@
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS updated_at INTEGER NOT NULL DEFAULT 0;
+UPDATE addresses SET updated_at = timestamp WHERE updated_at = 0;
+CREATE INDEX IF NOT EXISTS addresses_updated_at ON addresses (updated_at);
@@ -0,0 +1 @@
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS first_seen_block UINT256;
+CREATE INDEX IF NOT EXISTS addresses_first_seen_block_null ON addresses (timestamp) WHERE first_seen_block IS NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS blocks
+(
+    guid        VARCHAR PRIMARY KEY,
+    block_hash  VARCHAR UNIQUE NOT NULL,
+    parent_hash VARCHAR UNIQUE NOT NULL,
+    number      UINT256 UNIQUE NOT NULL,
+    timestamp   INTEGER        NOT NULL,
+    rlp_header  VARCHAR        NOT NULL,
+    base_fee    UINT256
+    );
+CREATE INDEX IF NOT EXISTS blocks_timestamp ON blocks (timestamp);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS deposits
+(
+    guid          VARCHAR PRIMARY KEY,
+    block_hash    VARCHAR NOT NULL,
+    block_number  UINT256 NOT NULL,
+    tx_hash       VARCHAR NOT NULL,
+    from_address  VARCHAR NOT NULL,
+    to_address    VARCHAR NOT NULL,
+    token_address VARCHAR NOT NULL,
+    amount        UINT256 NOT NULL,
+    timestamp     INTEGER NOT NULL
+    );
+CREATE INDEX IF NOT EXISTS deposits_block_number ON deposits (block_number);
+CREATE INDEX IF NOT EXISTS deposits_tx_hash ON deposits (tx_hash);
+CREATE INDEX IF NOT EXISTS deposits_to_address ON deposits (to_address);
@@ -0,0 +1,18 @@
+-- Normalize legacy rows with an out-of-range address_type to the user type
+-- before enforcing the enum, so existing data doesn't block the constraint.
+DO
+$$
+DECLARE
+    fixed INTEGER;
+BEGIN
+    UPDATE addresses SET address_type = 0 WHERE address_type NOT IN (0, 1, 2);
+    GET DIAGNOSTICS fixed = ROW_COUNT;
+    IF fixed > 0 THEN
+        RAISE NOTICE 'normalized % addresses with out-of-range address_type to 0', fixed;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'addresses_address_type_check') THEN
+        ALTER TABLE addresses ADD CONSTRAINT addresses_address_type_check CHECK (address_type IN (0, 1, 2));
+    END IF;
+END
+$$;
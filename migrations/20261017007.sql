@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS reorgs
+(
+    guid       VARCHAR PRIMARY KEY,
+    old_hash   VARCHAR NOT NULL,
+    new_hash   VARCHAR NOT NULL,
+    depth      BIGINT  NOT NULL CHECK (depth > 0),
+    from_block UINT256 NOT NULL,
+    to_block   UINT256 NOT NULL,
+    timestamp  INTEGER NOT NULL
+    );
+CREATE INDEX IF NOT EXISTS reorgs_from_block ON reorgs (from_block);
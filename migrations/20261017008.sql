@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS sweeps
+(
+    guid          VARCHAR PRIMARY KEY,
+    block_hash    VARCHAR NOT NULL,
+    block_number  UINT256 NOT NULL,
+    tx_hash       VARCHAR NOT NULL,
+    from_address  VARCHAR NOT NULL,
+    to_address    VARCHAR NOT NULL,
+    token_address VARCHAR NOT NULL,
+    amount        UINT256 NOT NULL,
+    deposit_guid  VARCHAR,
+    timestamp     INTEGER NOT NULL
+    );
+CREATE INDEX IF NOT EXISTS sweeps_block_number ON sweeps (block_number);
+CREATE INDEX IF NOT EXISTS sweeps_from_address ON sweeps (from_address);
+CREATE INDEX IF NOT EXISTS sweeps_deposit_guid ON sweeps (deposit_guid);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS balance_history
+(
+    guid          VARCHAR PRIMARY KEY,
+    address       VARCHAR NOT NULL,
+    token_address VARCHAR NOT NULL,
+    balance       UINT256 NOT NULL,
+    block_number  UINT256 NOT NULL,
+    timestamp     INTEGER NOT NULL,
+    UNIQUE (address, token_address, block_number)
+    );
+CREATE INDEX IF NOT EXISTS balance_history_address_token_block ON balance_history (address, token_address, block_number);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS balances
+(
+    guid          VARCHAR PRIMARY KEY,
+    address       VARCHAR NOT NULL,
+    token_address VARCHAR NOT NULL,
+    balance       UINT256 NOT NULL,
+    lock_balance  UINT256 NOT NULL,
+    timestamp     INTEGER NOT NULL,
+    UNIQUE (address, token_address)
+    );
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS tokens
+(
+    guid           VARCHAR PRIMARY KEY,
+    token_address  VARCHAR NOT NULL UNIQUE,
+    symbol         VARCHAR NOT NULL,
+    name           VARCHAR NOT NULL,
+    decimals       SMALLINT NOT NULL CHECK (decimals >= 0 AND decimals <= 255),
+    collect_amount UINT256 NOT NULL,
+    timestamp      INTEGER NOT NULL
+    );
@@ -0,0 +1,2 @@
+ALTER TABLE deposits ADD COLUMN IF NOT EXISTS status SMALLINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS deposits_pending_block_number ON deposits (block_number) WHERE status = 0;
@@ -0,0 +1,2 @@
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS addresses_deleted_at ON addresses (deleted_at);
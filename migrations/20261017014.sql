@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS withdrawals
+(
+    guid          VARCHAR PRIMARY KEY,
+    from_address  VARCHAR  NOT NULL,
+    to_address    VARCHAR  NOT NULL,
+    amount        UINT256  NOT NULL,
+    token_address VARCHAR  NOT NULL,
+    tx_hash       VARCHAR  NOT NULL,
+    status        SMALLINT NOT NULL DEFAULT 0,
+    nonce         BIGINT,
+    timestamp     INTEGER  NOT NULL
+    );
+CREATE INDEX IF NOT EXISTS withdrawals_status_timestamp ON withdrawals (status, timestamp);
+CREATE INDEX IF NOT EXISTS withdrawals_tx_hash ON withdrawals (tx_hash);
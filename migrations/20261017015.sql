@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS nonces
+(
+    guid      VARCHAR PRIMARY KEY,
+    address   VARCHAR UNIQUE NOT NULL,
+    nonce     BIGINT         NOT NULL,
+    timestamp INTEGER        NOT NULL
+    );
@@ -0,0 +1,2 @@
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS type SMALLINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS withdrawals_type_status ON withdrawals (type, status);
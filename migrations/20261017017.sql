@@ -0,0 +1,2 @@
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS hot_ceiling UINT256 NOT NULL DEFAULT 0;
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS hot_retain UINT256 NOT NULL DEFAULT 0;
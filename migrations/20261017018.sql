@@ -0,0 +1,2 @@
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS block_number UINT256;
+CREATE INDEX IF NOT EXISTS withdrawals_block_number ON withdrawals (block_number);
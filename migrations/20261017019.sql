@@ -0,0 +1,6 @@
+ALTER TABLE deposits ADD COLUMN IF NOT EXISTS tx_index INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE deposits ADD COLUMN IF NOT EXISTS log_index INTEGER;
+CREATE UNIQUE INDEX IF NOT EXISTS deposits_tx_hash_log_index ON deposits (tx_hash, log_index);
+ALTER TABLE sweeps ADD COLUMN IF NOT EXISTS tx_index INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sweeps ADD COLUMN IF NOT EXISTS log_index INTEGER;
+CREATE UNIQUE INDEX IF NOT EXISTS sweeps_tx_hash_log_index ON sweeps (tx_hash, log_index);
@@ -0,0 +1 @@
+ALTER TABLE sweeps ADD COLUMN IF NOT EXISTS type SMALLINT NOT NULL DEFAULT 0;
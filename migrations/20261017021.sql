@@ -0,0 +1,18 @@
+-- Recreate deposits as a table range-partitioned by block number. Existing
+-- and new rows land in deposits_default until CreateDepositPartition adds a
+-- partition for their range. Primary keys and unique indexes of a
+-- partitioned table must include the partition key.
+CREATE TABLE deposits_partitioned (LIKE deposits INCLUDING DEFAULTS INCLUDING CONSTRAINTS)
+    PARTITION BY RANGE (block_number);
+ALTER TABLE deposits_partitioned ADD CONSTRAINT deposits_partitioned_pkey PRIMARY KEY (guid, block_number);
+CREATE TABLE deposits_default PARTITION OF deposits_partitioned DEFAULT;
+INSERT INTO deposits_partitioned SELECT * FROM deposits;
+DROP TABLE deposits;
+ALTER TABLE deposits_partitioned RENAME TO deposits;
+ALTER TABLE deposits RENAME CONSTRAINT deposits_partitioned_pkey TO deposits_pkey;
+
+CREATE INDEX deposits_block_number ON deposits (block_number);
+CREATE INDEX deposits_tx_hash ON deposits (tx_hash);
+CREATE INDEX deposits_to_address ON deposits (to_address);
+CREATE INDEX deposits_pending_block_number ON deposits (block_number) WHERE status = 0;
+CREATE UNIQUE INDEX deposits_tx_hash_log_index ON deposits (tx_hash, log_index, block_number);
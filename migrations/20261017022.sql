@@ -0,0 +1,3 @@
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS ens_name VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS ens_resolved_at INTEGER;
+CREATE INDEX IF NOT EXISTS addresses_ens_resolved_at ON addresses (ens_resolved_at NULLS FIRST);
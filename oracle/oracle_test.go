@@ -0,0 +1,55 @@
+package oracle
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestValueUSD(t *testing.T) {
+	token := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	block := big.NewInt(7)
+	var calls int
+	mock := PriceOracleFunc(func(gotToken common.Address, gotBlock *big.Int) (float64, error) {
+		calls++
+		if gotToken != token || gotBlock.Cmp(block) != 0 {
+			t.Errorf("PriceUSD(%s, %s), want (%s, %s)", gotToken, gotBlock, token, block)
+		}
+		return 2.5, nil
+	})
+
+	// 1.5 tokens with 6 decimals at $2.5.
+	value := ValueUSD(mock, token, block, big.NewInt(1_500_000), 6)
+	if value == nil || *value != 3.75 {
+		t.Fatalf("ValueUSD = %v, want 3.75", value)
+	}
+	if calls != 1 {
+		t.Errorf("oracle called %d times, want 1", calls)
+	}
+
+	failing := PriceOracleFunc(func(common.Address, *big.Int) (float64, error) {
+		return 0, errors.New("feed down")
+	})
+	if value := ValueUSD(failing, token, block, big.NewInt(1), 0); value != nil {
+		t.Errorf("ValueUSD with a failing oracle = %v, want nil", *value)
+	}
+	if value := ValueUSD(nil, token, block, big.NewInt(1), 0); value != nil {
+		t.Errorf("ValueUSD without an oracle = %v, want nil", *value)
+	}
+}
+
+func TestStaticPriceOracle(t *testing.T) {
+	prices := map[common.Address]float64{NativeToken: 3000}
+	o := NewStaticPriceOracle(prices)
+	prices[NativeToken] = 1 // The oracle keeps its own copy.
+
+	if price, err := o.PriceUSD(NativeToken, nil); err != nil || price != 3000 {
+		t.Errorf("PriceUSD(native) = %v, %v, want 3000", price, err)
+	}
+	unknown := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	if _, err := o.PriceUSD(unknown, nil); !errors.Is(err, ErrPriceUnavailable) {
+		t.Errorf("PriceUSD(unknown) error = %v, want ErrPriceUnavailable", err)
+	}
+}
@@ -0,0 +1,49 @@
+package web3scanner
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// createDepositPartitions makes sure the deposits partitions of
+// depositPartitionSize blocks exist from the first partition boundary at or
+// above next through depositPartitionsAhead partitions past the one holding
+// head. Deposits below that first boundary stay in the default partition,
+// so enabling partitioning on a populated table moves no rows.
+//
+// The covered range is remembered, so after the first round the database is
+// only asked again once head enters the last remembered partitions. Dry runs
+// write nothing, so they create no partitions either.
+func (ws *Web3Scanner) createDepositPartitions(next, head *big.Int) error {
+	if ws.depositPartitionSize == 0 || ws.dryRun {
+		return nil
+	}
+	size := new(big.Int).SetUint64(ws.depositPartitionSize)
+	end := new(big.Int).Div(head, size)
+	end.Add(end, new(big.Int).SetUint64(ws.depositPartitionsAhead+1))
+	end.Mul(end, size)
+	if ws.depositPartitionsEnd != nil && ws.depositPartitionsEnd.Cmp(end) >= 0 {
+		return nil
+	}
+
+	from := ws.depositPartitionsEnd
+	if from == nil {
+		// Round next up to a partition boundary.
+		from = new(big.Int).Add(next, size)
+		from.Sub(from, big.NewInt(1))
+		from.Div(from, size)
+		from.Mul(from, size)
+	}
+	for from.Cmp(end) < 0 {
+		to := new(big.Int).Add(from, size)
+		if err := ws.db.Deposits.CreateDepositPartition(from, to); err != nil {
+			return fmt.Errorf("partition %s-%s: %w", from, to, err)
+		}
+		from = to
+	}
+	log.Debug("deposit partitions ready", "to", end)
+	ws.depositPartitionsEnd = end
+	return nil
+}
@@ -0,0 +1,55 @@
+package web3scanner
+
+import (
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+// partitionDeposits records the deposit partitions created.
+type partitionDeposits struct {
+	database.DepositsDB
+	created [][2]int64
+}
+
+func (f *partitionDeposits) CreateDepositPartition(fromBlock, toBlock *big.Int) error {
+	f.created = append(f.created, [2]int64{fromBlock.Int64(), toBlock.Int64()})
+	return nil
+}
+
+func TestCreateDepositPartitionsAheadOfHead(t *testing.T) {
+	deposits := &partitionDeposits{}
+	ws := newTestScanner(&database.DB{Deposits: deposits}, newFakeClient())
+	ws.depositPartitionSize = 100
+	ws.depositPartitionsAhead = 1
+
+	steps := []struct {
+		next, head int64
+		want       [][2]int64
+	}{
+		// Blocks 250-299 stay in the default partition; partitions start at
+		// the next boundary and reach one partition past the head's.
+		{250, 420, [][2]int64{{300, 400}, {400, 500}, {500, 600}}},
+		// Still covered.
+		{421, 499, nil},
+		{500, 520, [][2]int64{{600, 700}}},
+	}
+	for _, step := range steps {
+		deposits.created = nil
+		if err := ws.createDepositPartitions(big.NewInt(step.next), big.NewInt(step.head)); err != nil {
+			t.Fatalf("createDepositPartitions(%d, %d): %v", step.next, step.head, err)
+		}
+		if !slices.Equal(deposits.created, step.want) {
+			t.Errorf("createDepositPartitions(%d, %d) created %v, want %v", step.next, step.head, deposits.created, step.want)
+		}
+	}
+
+	ws.depositPartitionSize = 0
+	ws.depositPartitionsEnd = nil
+	deposits.created = nil
+	if err := ws.createDepositPartitions(big.NewInt(0), big.NewInt(1_000)); err != nil || deposits.created != nil {
+		t.Errorf("createDepositPartitions without a partition size created %v, %v, want nothing", deposits.created, err)
+	}
+}
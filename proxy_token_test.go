@@ -0,0 +1,54 @@
+package web3scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestProxyTokenTransferMatchesProxyEntry(t *testing.T) {
+	user, payer := newTestAccount(t), newTestAccount(t)
+	proxy := common.HexToAddress("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48")
+	implementation := common.HexToAddress("0x43506849d7c04f9138d1a2050bbf3a0c054402dd")
+
+	// The call goes to the proxy, which delegates to the implementation; the
+	// Transfer log is emitted from the proxy address.
+	client := newFakeClient()
+	call := payer.transfer(t, proxy, 0)
+	block := client.addBlockWithReceipts([]*types.Transaction{call},
+		[]*types.Receipt{transferReceipt(call, proxy, payer.address, user.address, 7)})
+	addresses := &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}}
+
+	for _, tt := range []struct {
+		name   string
+		listed common.Address
+		want   int
+	}{
+		{"proxy listed", proxy, 1},
+		{"implementation listed", implementation, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &database.DB{
+				Addresses: addresses,
+				Tokens:    &fakeTokens{rows: []database.Tokens{{TokenAddress: tt.listed, Symbol: "USDC", Decimals: 6}}},
+			}
+			m, err := newTestScanner(db, client).processBlock(context.Background(), block, nil)
+			if err != nil {
+				t.Fatalf("processBlock: %v", err)
+			}
+			if len(m.deposits) != tt.want {
+				t.Fatalf("%d deposits, want %d", len(m.deposits), tt.want)
+			}
+			if tt.want == 1 {
+				d := m.deposits[0]
+				if d.TokenAddress != proxy || d.ToAddress != user.address || d.FromAddress != payer.address || d.Amount.Int64() != 7 {
+					t.Errorf("deposit = %+v, want 7 of %s from %s to %s", d, proxy, payer.address, user.address)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package web3scanner
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+	"github.com/qiaopengjun5162/web3scanner/oracle"
+)
+
+// scanToHead runs scan rounds until the scanner reports it caught up.
+func scanToHead(t *testing.T, ws *Web3Scanner) {
+	t.Helper()
+	for range 100 {
+		caughtUp, err := ws.scanBlocks(context.Background())
+		if err != nil {
+			t.Fatalf("scanBlocks: %v", err)
+		}
+		if caughtUp {
+			return
+		}
+	}
+	t.Fatal("scanner did not catch up after 100 rounds")
+}
+
+// assertStoredChain checks that the stored blocks are exactly the blocks of
+// the client's chain.
+func assertStoredChain(t *testing.T, db *database.DB, client *fakeClient) {
+	t.Helper()
+	for _, block := range client.blocks {
+		stored, err := db.Blocks.QueryBlockByNumber(block.Number())
+		if err != nil {
+			t.Fatalf("query stored block %s: %v", block.Number(), err)
+		}
+		if stored.BlockHash != block.Hash() {
+			t.Errorf("stored block %s has hash %s, want %s", block.Number(), stored.BlockHash, block.Hash())
+		}
+	}
+	latest, err := db.Blocks.LatestBlock()
+	if err != nil {
+		t.Fatalf("query latest block: %v", err)
+	}
+	if head := client.blocks[len(client.blocks)-1]; latest.BlockHash != head.Hash() {
+		t.Errorf("latest stored block %s, want head %s", latest.Number, head.Number())
+	}
+}
+
+func TestReorgsRecorded(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	client := newFakeClient()
+	for range 6 {
+		client.addBlock()
+	}
+	ws := newTestScanner(db, client)
+	scanToHead(t, ws)
+
+	// Two reorgs: one replacing the last block, then one replacing the
+	// last four. The new branch is one block longer than the old one, so the
+	// scanner has a new block to detect the reorg with.
+	var want []database.Reorgs
+	for _, fork := range []int{6, 4} {
+		height := len(client.blocks)
+		old := client.blocks[fork].Hash()
+		client.reorg(fork)
+		for len(client.blocks) <= height {
+			client.addBlock()
+		}
+		scanToHead(t, ws)
+		assertStoredChain(t, db, client)
+		want = append(want, database.Reorgs{
+			OldHash:   old,
+			NewHash:   client.blocks[fork].Hash(),
+			Depth:     uint64(height - fork),
+			FromBlock: big.NewInt(int64(fork)),
+			ToBlock:   big.NewInt(int64(height - 1)),
+		})
+	}
+
+	// Reorgs are returned by fork point.
+	slices.Reverse(want)
+	reorgs, err := db.Reorgs.QueryReorgs(big.NewInt(0), big.NewInt(100))
+	if err != nil {
+		t.Fatalf("QueryReorgs: %v", err)
+	}
+	if len(reorgs) != len(want) {
+		t.Fatalf("QueryReorgs returned %d reorgs, want %d", len(reorgs), len(want))
+	}
+	for i, r := range reorgs {
+		w := want[i]
+		if r.OldHash != w.OldHash || r.NewHash != w.NewHash || r.Depth != w.Depth || r.FromBlock.Cmp(w.FromBlock) != 0 || r.ToBlock.Cmp(w.ToBlock) != 0 {
+			t.Errorf("reorg %d = {%s -> %s, depth %d, blocks %s-%s}, want {%s -> %s, depth %d, blocks %s-%s}",
+				i, r.OldHash, r.NewHash, r.Depth, r.FromBlock, r.ToBlock, w.OldHash, w.NewHash, w.Depth, w.FromBlock, w.ToBlock)
+		}
+	}
+
+	// The range filters on the fork point.
+	if reorgs, err := db.Reorgs.QueryReorgs(big.NewInt(5), big.NewInt(7)); err != nil || len(reorgs) != 1 || reorgs[0].Depth != 1 {
+		t.Errorf("QueryReorgs(5, 7) = %d reorgs, %v, want only the depth 1 reorg", len(reorgs), err)
+	}
+}
+
+func TestScanBlocksRollsBackReorg(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fork int
+		// Balances and the number of deposits and sweeps left after the
+		// reorg.
+		user, hot        int64
+		deposits, sweeps int
+	}{
+		{name: "single block", fork: 4, user: 6, hot: 4, deposits: 1, sweeps: 1},
+		{name: "multiple blocks", fork: 2, user: 0, hot: 0, deposits: 0, sweeps: 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := dbtest.NewDB(t)
+			user, hot, payer := newTestAccount(t), newTestAccount(t), newTestAccount(t)
+			if err := db.Addresses.StoreAddresses([]database.Addresses{user.row(database.AddressTypeUser), hot.row(database.AddressTypeHot)}); err != nil {
+				t.Fatalf("store addresses: %v", err)
+			}
+			balance := func(account *testAccount) int64 {
+				t.Helper()
+				b, err := db.Balances.QueryBalance(account.address, oracle.NativeToken)
+				if err != nil {
+					t.Fatalf("query balance: %v", err)
+				}
+				if b == nil {
+					return 0
+				}
+				return b.Balance.Int64()
+			}
+
+			client := newFakeClient()
+			client.addBlock()
+			client.addBlock(payer.transfer(t, user.address, 10))
+			client.addBlock(user.transfer(t, hot.address, 4))
+			client.addBlock(payer.transfer(t, user.address, 1))
+			ws := newTestScanner(db, client)
+			scanToHead(t, ws)
+			if user, hot := balance(user), balance(hot); user != 7 || hot != 4 {
+				t.Fatalf("balances before reorg: user %d, hot %d, want 7 and 4", user, hot)
+			}
+
+			height := len(client.blocks)
+			client.reorg(tt.fork)
+			for len(client.blocks) <= height {
+				client.addBlock()
+			}
+			scanToHead(t, ws)
+
+			assertStoredChain(t, db, client)
+			if user, hot := balance(user), balance(hot); user != tt.user || hot != tt.hot {
+				t.Errorf("balances after reorg: user %d, hot %d, want %d and %d", user, hot, tt.user, tt.hot)
+			}
+			all := big.NewInt(100)
+			deposits, err := db.Deposits.QueryDepositsByBlockRange(big.NewInt(0), all)
+			if err != nil {
+				t.Fatalf("query deposits: %v", err)
+			}
+			if len(deposits) != tt.deposits {
+				t.Errorf("%d deposits after reorg, want %d", len(deposits), tt.deposits)
+			}
+			sweeps, err := db.Sweeps.QuerySweepsByBlockRange(big.NewInt(0), all)
+			if err != nil {
+				t.Fatalf("query sweeps: %v", err)
+			}
+			if len(sweeps) != tt.sweeps {
+				t.Errorf("%d sweeps after reorg, want %d", len(sweeps), tt.sweeps)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package web3scanner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/log"
+	"gorm.io/gorm"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+// RemovedBlocks reports what was removed from the database together with a
+// range of stored blocks.
+type RemovedBlocks struct {
+	// From and To are the first and last removed block; both are nil when
+	// nothing was removed.
+	From, To *big.Int
+
+	Blocks      int
+	Deposits    int
+	Sweeps      int
+	Withdrawals int
+}
+
+// Rewind moves the scan cursor back to block number to, so the scanner
+// processes the blocks after it again on its next round. Every stored block
+// above to is removed together with its deposits, sweeps and balance
+// history, their balance changes are reverted, and withdrawals mined in
+// those blocks go back to sent, all in a single transaction.
+//
+// Block to must be stored, which keeps the cursor from moving below the
+// first scanned block. Rewinding to the latest stored block removes
+// nothing, so repeating a rewind is safe. The scanner must not be running.
+func Rewind(db *database.DB, to *big.Int) (*RemovedBlocks, error) {
+	var removed *RemovedBlocks
+	err := db.Transaction(func(tx *database.DB) error {
+		if _, err := tx.Blocks.QueryBlockByNumber(to); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("block %s is not stored, can't rewind to it", to)
+			}
+			return fmt.Errorf("query stored block %s: %w", to, err)
+		}
+		latest, err := tx.Blocks.LatestBlock()
+		if err != nil {
+			return fmt.Errorf("query latest block: %w", err)
+		}
+		if latest.Number.Cmp(to) <= 0 {
+			removed = &RemovedBlocks{}
+			return nil
+		}
+		removed, err = removeBlocks(tx, new(big.Int).Add(to, big.NewInt(1)), latest.Number)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if removed.Blocks > 0 {
+		log.Info("rewound scan cursor", "to", to, "removedFrom", removed.From, "removedTo", removed.To,
+			"blocks", removed.Blocks, "deposits", removed.Deposits, "sweeps", removed.Sweeps, "withdrawals", removed.Withdrawals)
+	}
+	return removed, nil
+}
+
+// removeBlocks deletes the stored blocks from..to, where to is the latest
+// stored block, together with their deposits, sweeps and balance history.
+// Their balance changes are reverted and the withdrawals mined in them go
+// back to sent. It must run inside a transaction.
+func removeBlocks(tx *database.DB, from, to *big.Int) (*RemovedBlocks, error) {
+	deposits, err := tx.Deposits.QueryDepositsByBlockRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	sweeps, err := tx.Sweeps.QuerySweepsByBlockRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	withdrawals, err := tx.Withdrawals.RevertWithdrawalsMinedFrom(from)
+	if err != nil {
+		return nil, err
+	}
+	changes := balanceChanges(derefAll(deposits), derefAll(sweeps))
+	changes = append(changes, withdrawalChanges(withdrawals, 0)...)
+	sortBalanceChanges(changes)
+	if err := revertBalanceChanges(tx, changes); err != nil {
+		return nil, err
+	}
+	if err := tx.BalanceHistory.DeleteBalanceHistoryFrom(from); err != nil {
+		return nil, err
+	}
+	if err := tx.Sweeps.DeleteSweepsFrom(from); err != nil {
+		return nil, err
+	}
+	if err := tx.Deposits.DeleteDepositsFrom(from); err != nil {
+		return nil, err
+	}
+	if err := tx.Blocks.DeleteBlocksFrom(from); err != nil {
+		return nil, err
+	}
+	return &RemovedBlocks{
+		From:        from,
+		To:          to,
+		Blocks:      int(new(big.Int).Sub(to, from).Int64()) + 1,
+		Deposits:    len(deposits),
+		Sweeps:      len(sweeps),
+		Withdrawals: len(withdrawals),
+	}, nil
+}
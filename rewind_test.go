@@ -0,0 +1,66 @@
+package web3scanner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+	"github.com/qiaopengjun5162/web3scanner/oracle"
+)
+
+func TestRewind(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	user, hot, payer := newTestAccount(t), newTestAccount(t), newTestAccount(t)
+	if err := db.Addresses.StoreAddresses([]database.Addresses{user.row(database.AddressTypeUser), hot.row(database.AddressTypeHot)}); err != nil {
+		t.Fatalf("store addresses: %v", err)
+	}
+
+	client := newFakeClient()
+	client.addBlock(payer.transfer(t, user.address, 10))
+	client.addBlock(user.transfer(t, hot.address, 4))
+	client.addBlock(payer.transfer(t, user.address, 1))
+	client.addBlock()
+	ws := newTestScanner(db, client)
+	scanToHead(t, ws)
+
+	removed, err := Rewind(db, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("Rewind: %v", err)
+	}
+	if removed.From.Int64() != 2 || removed.To.Int64() != 4 || removed.Blocks != 3 || removed.Deposits != 1 || removed.Sweeps != 1 {
+		t.Errorf("Rewind removed %+v, want blocks 2-4 with 1 deposit and 1 sweep", removed)
+	}
+	latest, err := db.Blocks.LatestBlock()
+	if err != nil || latest.Number.Int64() != 1 {
+		t.Fatalf("latest block after rewind = %v, %v, want 1", latest, err)
+	}
+	all := big.NewInt(100)
+	if deposits, err := db.Deposits.QueryDepositsByBlockRange(big.NewInt(0), all); err != nil || len(deposits) != 1 {
+		t.Errorf("%d deposits after rewind, %v, want only the one in block 1", len(deposits), err)
+	}
+	if sweeps, err := db.Sweeps.QuerySweepsByBlockRange(big.NewInt(0), all); err != nil || len(sweeps) != 0 {
+		t.Errorf("%d sweeps after rewind, %v, want none", len(sweeps), err)
+	}
+	balance, err := db.Balances.QueryBalance(user.address, oracle.NativeToken)
+	if err != nil || balance.Balance.Int64() != 10 {
+		t.Errorf("user balance after rewind = %v, %v, want 10", balance, err)
+	}
+
+	// Repeating the rewind removes nothing; a block that isn't stored is
+	// refused.
+	if removed, err := Rewind(db, big.NewInt(1)); err != nil || removed.Blocks != 0 {
+		t.Errorf("repeated Rewind = %+v, %v, want nothing removed", removed, err)
+	}
+	if _, err := Rewind(db, big.NewInt(50)); err == nil {
+		t.Error("Rewind to a block that isn't stored succeeded")
+	}
+
+	// The scanner processes the rewound blocks again.
+	scanToHead(t, ws)
+	assertStoredChain(t, db, client)
+	balance, err = db.Balances.QueryBalance(user.address, oracle.NativeToken)
+	if err != nil || balance.Balance.Int64() != 7 {
+		t.Errorf("user balance after rescanning = %v, %v, want 7", balance, err)
+	}
+}
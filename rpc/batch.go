@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// batchBlockBody is the part of an eth_getBlockByNumber response that is
+// not covered by the header.
+type batchBlockBody struct {
+	Transactions []*types.Transaction `json:"transactions"`
+	Withdrawals  []*types.Withdrawal  `json:"withdrawals,omitempty"`
+}
+
+// BatchBlocksByRange fetches the blocks from..to (inclusive) with full
+// transactions in a single JSON-RPC batch request.
+//
+// Unlike BlockByNumber, uncle headers are not loaded; the returned blocks
+// carry the header's uncle hash but an empty uncle list. An error is
+// returned if the node rejects the batch or any block in it.
+func (c *ethClient) BatchBlocksByRange(ctx context.Context, from, to *big.Int) ([]*types.Block, error) {
+	if from.Cmp(to) > 0 {
+		return nil, fmt.Errorf("invalid block range %s-%s", from, to)
+	}
+	count := int(new(big.Int).Sub(to, from).Int64()) + 1
+	raws := make([]json.RawMessage, count)
+	reqs := make([]gethrpc.BatchElem, count)
+	for i := range reqs {
+		number := new(big.Int).Add(from, big.NewInt(int64(i)))
+		reqs[i] = gethrpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []any{hexutil.EncodeBig(number), true},
+			Result: &raws[i],
+		}
+	}
+	if err := c.Client.Client().BatchCallContext(ctx, reqs); err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*types.Block, count)
+	for i, req := range reqs {
+		number := new(big.Int).Add(from, big.NewInt(int64(i)))
+		if req.Error != nil {
+			return nil, fmt.Errorf("block %s: %w", number, req.Error)
+		}
+		block, err := decodeBlock(raws[i])
+		if err != nil {
+			return nil, fmt.Errorf("block %s: %w", number, err)
+		}
+		blocks[i] = block
+	}
+	return blocks, nil
+}
+
+// decodeBlock decodes an eth_getBlockByNumber response with full
+// transactions.
+func decodeBlock(raw json.RawMessage) (*types.Block, error) {
+	var head *types.Header
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return nil, err
+	}
+	// When the block is not found, the API returns JSON null.
+	if head == nil {
+		return nil, ethereum.NotFound
+	}
+	var body batchBlockBody
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, err
+	}
+	if head.TxHash == types.EmptyTxsHash && len(body.Transactions) > 0 {
+		return nil, errors.New("server returned non-empty transaction list but block header indicates no transactions")
+	}
+	if head.TxHash != types.EmptyTxsHash && len(body.Transactions) == 0 {
+		return nil, errors.New("server returned empty transaction list but block header indicates transactions")
+	}
+	return types.NewBlockWithHeader(head).WithBody(types.Body{
+		Transactions: body.Transactions,
+		Withdrawals:  body.Withdrawals,
+	}), nil
+}
@@ -0,0 +1,83 @@
+// Package rpc provides the Ethereum node client used by the scanner.
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// EthClient is the subset of node RPC calls the scanner needs. It is an
+// interface so the scanner can be driven by a mock in place of a live node.
+type EthClient interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	// BlockByHash returns the block with the given hash, also when it is no
+	// longer part of the canonical chain, or ethereum.NotFound.
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	// BatchBlocksByRange fetches a contiguous range of blocks in a single
+	// batch request.
+	BatchBlocksByRange(ctx context.Context, from, to *big.Int) ([]*types.Block, error)
+	// HeaderAndTxHashesByNumber returns the header of a block and the hashes
+	// of its transactions, without decoding the transactions.
+	HeaderAndTxHashesByNumber(ctx context.Context, number *big.Int) (*types.Header, []common.Hash, error)
+	// BatchTransactionsAndReceipts fetches transactions and their receipts by
+	// hash in a single batch request.
+	BatchTransactionsAndReceipts(ctx context.Context, hashes []common.Hash) ([]*types.Transaction, []*types.Receipt, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	// PendingNonceAt returns the nonce of account including transactions
+	// still in the node's pool.
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	// BlockReceiptsByHash returns the receipts of all transactions in the
+	// block with a single eth_getBlockReceipts call.
+	BlockReceiptsByHash(ctx context.Context, blockHash common.Hash) ([]*types.Receipt, error)
+	// CallContract executes a read-only contract call at the given block,
+	// nil meaning the latest.
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	// ReportedBlockHash returns the block hash exactly as the node reports it,
+	// without recomputing it from the header.
+	ReportedBlockHash(ctx context.Context, number *big.Int) (common.Hash, error)
+	Close()
+}
+
+type ethClient struct {
+	*ethclient.Client
+}
+
+// DialEthClient connects to the node at the given HTTP or WebSocket URL,
+// typically Config.RpcUrl. The returned client retries the read calls
+// covered by NewRetryingClient.
+func DialEthClient(ctx context.Context, url string) (EthClient, error) {
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return NewRetryingClient(&ethClient{Client: client}, defaultRetryAttempts), nil
+}
+
+func (c *ethClient) ReportedBlockHash(ctx context.Context, number *big.Int) (common.Hash, error) {
+	var head *struct {
+		Hash common.Hash `json:"hash"`
+	}
+	err := c.Client.Client().CallContext(ctx, &head, "eth_getBlockByNumber", hexutil.EncodeBig(number), false)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if head == nil {
+		return common.Hash{}, fmt.Errorf("block %s: %w", number, ethereum.NotFound)
+	}
+	return head.Hash, nil
+}
+
+func (c *ethClient) BlockReceiptsByHash(ctx context.Context, blockHash common.Hash) ([]*types.Receipt, error) {
+	return c.Client.BlockReceipts(ctx, gethrpc.BlockNumberOrHashWithHash(blockHash, false))
+}
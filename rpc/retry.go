@@ -0,0 +1,184 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/common/retry"
+)
+
+// defaultRetryAttempts is the number of attempts DialEthClient makes for
+// each call before giving up.
+const defaultRetryAttempts = 5
+
+// Defaults for retrying blocks the node doesn't have yet, see
+// retryingClient.retryNearHead.
+const (
+	defaultNotFoundWindow   = 8
+	defaultNotFoundAttempts = 5
+	defaultNotFoundDelay    = 250 * time.Millisecond
+)
+
+// retryingClient retries the scanner's read calls with exponential backoff,
+// so a transient node or network hiccup doesn't fail a whole block range.
+type retryingClient struct {
+	EthClient
+	maxAttempts int
+	strategy    retry.Strategy
+
+	// notFoundWindow, notFoundAttempts and notFoundDelay bound the retries
+	// of a block reported as not found, see retryNearHead.
+	notFoundWindow   uint64
+	notFoundAttempts int
+	notFoundDelay    time.Duration
+
+	// head is the latest head number returned by BlockNumber, 0 until then.
+	head atomic.Uint64
+}
+
+// NewRetryingClient wraps client so that the block, header, receipt and
+// nonce reads, the contract calls and the transaction sub-batches are
+// retried up to maxAttempts times using retry.Exponential.
+// ChainID, BatchBlocksByRange and Close are passed through unchanged; a
+// failed batch is left to the caller to fall back to single calls.
+//
+// A block or header by number the node reports as not found is not treated
+// as a node failure, see retryNearHead.
+func NewRetryingClient(client EthClient, maxAttempts int) EthClient {
+	return &retryingClient{
+		EthClient:        client,
+		maxAttempts:      maxAttempts,
+		strategy:         retry.Exponential(),
+		notFoundWindow:   defaultNotFoundWindow,
+		notFoundAttempts: defaultNotFoundAttempts,
+		notFoundDelay:    defaultNotFoundDelay,
+	}
+}
+
+func (c *retryingClient) BlockNumber(ctx context.Context) (uint64, error) {
+	number, err := retry.Do(ctx, c.maxAttempts, c.strategy, func() (uint64, error) {
+		return c.EthClient.BlockNumber(ctx)
+	})
+	if err == nil {
+		c.head.Store(number)
+	}
+	return number, err
+}
+
+func (c *retryingClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return retryNearHead(ctx, c, number, func() (*types.Block, error) {
+		return c.EthClient.BlockByNumber(ctx, number)
+	})
+}
+
+// retryNearHead runs op, retrying node failures like the other reads. Right
+// after the head advances, the node can report a block within
+// notFoundWindow of the head as not found until it has propagated, so that
+// is retried up to notFoundAttempts times every notFoundDelay. Further below
+// the head the block is genuinely missing and ethereum.NotFound is returned
+// at once, as it is once the attempts run out.
+func retryNearHead[T any](ctx context.Context, c *retryingClient, number *big.Int, op func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		notFound := false
+		result, err := retry.Do(ctx, c.maxAttempts, c.strategy, func() (T, error) {
+			result, err := op()
+			if errors.Is(err, ethereum.NotFound) {
+				// Stop retry.Do without backing off; handled below.
+				notFound = true
+				return result, nil
+			}
+			return result, err
+		})
+		if !notFound {
+			return result, err
+		}
+		if attempt >= c.notFoundAttempts || !c.nearHead(number) {
+			return result, ethereum.NotFound
+		}
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(c.notFoundDelay):
+		}
+	}
+}
+
+// nearHead reports whether number is at most notFoundWindow blocks below
+// the last head returned by BlockNumber, or above it. A nil number, i.e.
+// the latest block, and an unknown head count as near.
+func (c *retryingClient) nearHead(number *big.Int) bool {
+	head := c.head.Load()
+	if number == nil || head == 0 || !number.IsUint64() {
+		return true
+	}
+	return number.Uint64()+c.notFoundWindow >= head
+}
+
+func (c *retryingClient) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return retry.Do(ctx, c.maxAttempts, c.strategy, func() (*types.Block, error) {
+		return c.EthClient.BlockByHash(ctx, hash)
+	})
+}
+
+func (c *retryingClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return retryNearHead(ctx, c, number, func() (*types.Header, error) {
+		return c.EthClient.HeaderByNumber(ctx, number)
+	})
+}
+
+func (c *retryingClient) HeaderAndTxHashesByNumber(ctx context.Context, number *big.Int) (*types.Header, []common.Hash, error) {
+	var hashes []common.Hash
+	header, err := retryNearHead(ctx, c, number, func() (*types.Header, error) {
+		header, txHashes, err := c.EthClient.HeaderAndTxHashesByNumber(ctx, number)
+		hashes = txHashes
+		return header, err
+	})
+	return header, hashes, err
+}
+
+func (c *retryingClient) BatchTransactionsAndReceipts(ctx context.Context, hashes []common.Hash) ([]*types.Transaction, []*types.Receipt, error) {
+	var receipts []*types.Receipt
+	txs, err := retry.Do(ctx, c.maxAttempts, c.strategy, func() ([]*types.Transaction, error) {
+		txs, batchReceipts, err := c.EthClient.BatchTransactionsAndReceipts(ctx, hashes)
+		receipts = batchReceipts
+		return txs, err
+	})
+	return txs, receipts, err
+}
+
+func (c *retryingClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return retry.Do(ctx, c.maxAttempts, c.strategy, func() (*types.Receipt, error) {
+		return c.EthClient.TransactionReceipt(ctx, txHash)
+	})
+}
+
+func (c *retryingClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return retry.Do(ctx, c.maxAttempts, c.strategy, func() (uint64, error) {
+		return c.EthClient.PendingNonceAt(ctx, account)
+	})
+}
+
+func (c *retryingClient) BlockReceiptsByHash(ctx context.Context, blockHash common.Hash) ([]*types.Receipt, error) {
+	return retry.Do(ctx, c.maxAttempts, c.strategy, func() ([]*types.Receipt, error) {
+		return c.EthClient.BlockReceiptsByHash(ctx, blockHash)
+	})
+}
+
+func (c *retryingClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return retry.Do(ctx, c.maxAttempts, c.strategy, func() ([]byte, error) {
+		return c.EthClient.CallContract(ctx, msg, blockNumber)
+	})
+}
+
+func (c *retryingClient) ReportedBlockHash(ctx context.Context, number *big.Int) (common.Hash, error) {
+	return retry.Do(ctx, c.maxAttempts, c.strategy, func() (common.Hash, error) {
+		return c.EthClient.ReportedBlockHash(ctx, number)
+	})
+}
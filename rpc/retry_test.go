@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/common/retry"
+)
+
+// flakyClient fails the first failures calls of each method it implements.
+// Calls to the other EthClient methods panic on the nil embedded client.
+type flakyClient struct {
+	EthClient
+	failures int
+	calls    int
+}
+
+var errNodeDown = errors.New("node down")
+
+func (c *flakyClient) fail() error {
+	c.calls++
+	if c.calls <= c.failures {
+		return errNodeDown
+	}
+	return nil
+}
+
+func (c *flakyClient) BlockNumber(context.Context) (uint64, error) {
+	if err := c.fail(); err != nil {
+		return 0, err
+	}
+	return 42, nil
+}
+
+func (c *flakyClient) ChainID(context.Context) (*big.Int, error) {
+	if err := c.fail(); err != nil {
+		return nil, err
+	}
+	return big.NewInt(1), nil
+}
+
+func (c *flakyClient) BatchBlocksByRange(context.Context, *big.Int, *big.Int) ([]*types.Block, error) {
+	if err := c.fail(); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func newTestRetryingClient(client EthClient, maxAttempts int) *retryingClient {
+	return &retryingClient{
+		EthClient:        client,
+		maxAttempts:      maxAttempts,
+		strategy:         retry.Fixed(0),
+		notFoundWindow:   defaultNotFoundWindow,
+		notFoundAttempts: 3,
+	}
+}
+
+// lateBlockClient reports every block as not found for its first notFound
+// BlockByNumber calls, like a node the block hasn't propagated to yet.
+type lateBlockClient struct {
+	EthClient
+	head     uint64
+	notFound int
+	calls    int
+}
+
+func (c *lateBlockClient) BlockNumber(context.Context) (uint64, error) {
+	return c.head, nil
+}
+
+func (c *lateBlockClient) BlockByNumber(_ context.Context, number *big.Int) (*types.Block, error) {
+	c.calls++
+	if c.calls <= c.notFound {
+		return nil, ethereum.NotFound
+	}
+	return types.NewBlockWithHeader(&types.Header{Number: number}), nil
+}
+
+func TestRetryingClientRetriesReads(t *testing.T) {
+	mock := &flakyClient{failures: 2}
+	number, err := newTestRetryingClient(mock, 3).BlockNumber(context.Background())
+	if err != nil || number != 42 {
+		t.Fatalf("BlockNumber = %d, %v, want 42", number, err)
+	}
+	if mock.calls != 3 {
+		t.Errorf("node was called %d times, want 3", mock.calls)
+	}
+}
+
+func TestRetryingClientGivesUp(t *testing.T) {
+	mock := &flakyClient{failures: 10}
+	_, err := newTestRetryingClient(mock, 3).BlockNumber(context.Background())
+	var permanent *retry.ErrFailedPermanently
+	if !errors.As(err, &permanent) || !errors.Is(err, errNodeDown) {
+		t.Fatalf("BlockNumber error = %v, want permanent failure wrapping %v", err, errNodeDown)
+	}
+	if mock.calls != 3 {
+		t.Errorf("node was called %d times, want 3", mock.calls)
+	}
+}
+
+func TestRetryingClientStopsOnCancel(t *testing.T) {
+	mock := &flakyClient{failures: 10}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := newTestRetryingClient(mock, 3).BlockNumber(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("BlockNumber error = %v, want %v", err, context.Canceled)
+	}
+	if mock.calls != 0 {
+		t.Errorf("node was called %d times after cancellation, want 0", mock.calls)
+	}
+}
+
+func TestRetryingClientPassesThrough(t *testing.T) {
+	client := newTestRetryingClient(&flakyClient{failures: 1}, 3)
+	if _, err := client.ChainID(context.Background()); !errors.Is(err, errNodeDown) {
+		t.Errorf("ChainID error = %v, want the first failure unretried", err)
+	}
+	client = newTestRetryingClient(&flakyClient{failures: 1}, 3)
+	if _, err := client.BatchBlocksByRange(context.Background(), big.NewInt(1), big.NewInt(2)); !errors.Is(err, errNodeDown) {
+		t.Errorf("BatchBlocksByRange error = %v, want the first failure unretried", err)
+	}
+}
+
+func TestRetryingClientRetriesBlockNotFoundNearHead(t *testing.T) {
+	tests := []struct {
+		name      string
+		number    int64
+		notFound  int
+		wantErr   error
+		wantCalls int
+	}{
+		{"propagated on the third call", 100, 2, nil, 3},
+		{"at the edge of the window", 92, 2, nil, 3},
+		{"still missing after the attempts", 100, 10, ethereum.NotFound, 3},
+		{"below the window", 91, 2, ethereum.NotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &lateBlockClient{head: 100, notFound: tt.notFound}
+			client := newTestRetryingClient(mock, 3)
+			if _, err := client.BlockNumber(context.Background()); err != nil {
+				t.Fatalf("BlockNumber: %v", err)
+			}
+			block, err := client.BlockByNumber(context.Background(), big.NewInt(tt.number))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("BlockByNumber error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && block.NumberU64() != uint64(tt.number) {
+				t.Errorf("BlockByNumber returned block %d, want %d", block.NumberU64(), tt.number)
+			}
+			if mock.calls != tt.wantCalls {
+				t.Errorf("node was called %d times, want %d", mock.calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// HeaderAndTxHashesByNumber fetches the header of the block with the given
+// number together with the hashes of its transactions, without the
+// transactions themselves, so that a huge block can be processed in
+// sub-batches with BatchTransactionsAndReceipts.
+func (c *ethClient) HeaderAndTxHashesByNumber(ctx context.Context, number *big.Int) (*types.Header, []common.Hash, error) {
+	var raw json.RawMessage
+	err := c.Client.Client().CallContext(ctx, &raw, "eth_getBlockByNumber", hexutil.EncodeBig(number), false)
+	if err != nil {
+		return nil, nil, err
+	}
+	var head *types.Header
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return nil, nil, err
+	}
+	// When the block is not found, the API returns JSON null.
+	if head == nil {
+		return nil, nil, fmt.Errorf("block %s: %w", number, ethereum.NotFound)
+	}
+	var body struct {
+		Transactions []common.Hash `json:"transactions"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, nil, err
+	}
+	if (head.TxHash == types.EmptyTxsHash) != (len(body.Transactions) == 0) {
+		return nil, nil, fmt.Errorf("block %s: server returned %d transaction hashes for transaction root %s", number, len(body.Transactions), head.TxHash)
+	}
+	return head, body.Transactions, nil
+}
+
+// BatchTransactionsAndReceipts fetches the transactions with the given
+// hashes and their receipts in a single JSON-RPC batch request. The results
+// are in the order of hashes. An error is returned if the node rejects the
+// batch or any call in it, or doesn't know one of the transactions.
+func (c *ethClient) BatchTransactionsAndReceipts(ctx context.Context, hashes []common.Hash) ([]*types.Transaction, []*types.Receipt, error) {
+	txs := make([]*types.Transaction, len(hashes))
+	receipts := make([]*types.Receipt, len(hashes))
+	reqs := make([]gethrpc.BatchElem, 0, 2*len(hashes))
+	for i, hash := range hashes {
+		reqs = append(reqs,
+			gethrpc.BatchElem{Method: "eth_getTransactionByHash", Args: []any{hash}, Result: &txs[i]},
+			gethrpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []any{hash}, Result: &receipts[i]},
+		)
+	}
+	if err := c.Client.Client().BatchCallContext(ctx, reqs); err != nil {
+		return nil, nil, err
+	}
+	for i, req := range reqs {
+		hash := hashes[i/2]
+		if req.Error != nil {
+			return nil, nil, fmt.Errorf("%s of transaction %s: %w", req.Method, hash, req.Error)
+		}
+	}
+	for i, hash := range hashes {
+		if txs[i] == nil || receipts[i] == nil {
+			return nil, nil, fmt.Errorf("transaction %s: %w", hash, ethereum.NotFound)
+		}
+	}
+	return txs, receipts, nil
+}
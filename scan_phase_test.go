@@ -0,0 +1,37 @@
+package web3scanner
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestProcessBlockObservesScanPhases(t *testing.T) {
+	user, payer := newTestAccount(t), newTestAccount(t)
+	client := newFakeClient()
+	block := client.addBlock(payer.transfer(t, user.address, 5))
+	db := &database.DB{Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}}}
+	ws := newTestScanner(db, client)
+
+	if _, err := ws.processBlock(context.Background(), block, nil); err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	ws.metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`web3scanner_scan_phase_duration_seconds_count{phase="fetch"} 1`,
+		`web3scanner_scan_phase_duration_seconds_count{phase="decode"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+	if strings.Contains(body, `phase="persist"`) {
+		t.Error("processBlock observed the persist phase, which only scanBlocks runs")
+	}
+}
@@ -0,0 +1,71 @@
+// Package scanner holds the chain data decoding used by the web3scanner.
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TransferEventTopic is the topic of the ERC20 Transfer(address,address,uint256)
+// event.
+var TransferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// ErrMalformedLog is wrapped by the errors DecodeTransfers reports for logs
+// that carry the Transfer topic but can't be decoded.
+var ErrMalformedLog = errors.New("malformed Transfer log")
+
+// Transfer is a decoded ERC20 Transfer event.
+type Transfer struct {
+	// Token is the address of the contract that emitted the event. For
+	// upgradeable tokens behind a proxy that is the proxy, not the
+	// implementation.
+	Token  common.Address
+	From   common.Address
+	To     common.Address
+	Amount *big.Int
+	TxHash common.Hash
+	// TxIndex is the position of the transaction in its block and LogIndex
+	// the position of the log in the block.
+	TxIndex  uint
+	LogIndex uint
+}
+
+// DecodeTransfers returns the ERC20 transfers emitted in the receipt, in
+// log order.
+//
+// ERC721 transfers, which index the token ID as a fourth topic, are
+// skipped. Other logs that carry the Transfer topic but do not have exactly
+// three topics and a 32-byte data field are skipped too, and reported in
+// the returned error, which wraps ErrMalformedLog. The transfers decoded
+// from the well-formed logs are returned either way.
+func DecodeTransfers(receipt *types.Receipt) ([]Transfer, error) {
+	var transfers []Transfer
+	var errs []error
+	for _, l := range receipt.Logs {
+		if l.Removed || len(l.Topics) == 0 || l.Topics[0] != TransferEventTopic {
+			continue
+		}
+		if len(l.Topics) == 4 && len(l.Data) == 0 {
+			continue
+		}
+		if len(l.Topics) != 3 || len(l.Data) != 32 {
+			errs = append(errs, fmt.Errorf("%w %d of tx %s from %s: %d topics, %d data bytes", ErrMalformedLog, l.Index, l.TxHash, l.Address, len(l.Topics), len(l.Data)))
+			continue
+		}
+		transfers = append(transfers, Transfer{
+			Token:    l.Address,
+			From:     common.BytesToAddress(l.Topics[1].Bytes()),
+			To:       common.BytesToAddress(l.Topics[2].Bytes()),
+			Amount:   new(big.Int).SetBytes(l.Data),
+			TxHash:   l.TxHash,
+			TxIndex:  l.TxIndex,
+			LogIndex: l.Index,
+		})
+	}
+	return transfers, errors.Join(errs...)
+}
@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDecodeTransfers(t *testing.T) {
+	token := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	from := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	to := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	txHash := common.HexToHash("0x01")
+	amount := common.BigToHash(big.NewInt(1000)).Bytes()
+	transferLog := func(index uint, topics []common.Hash, data []byte) *types.Log {
+		return &types.Log{Address: token, Topics: topics, Data: data, TxHash: txHash, TxIndex: 3, Index: index}
+	}
+	fromTopic, toTopic := common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())
+
+	receipt := &types.Receipt{Logs: []*types.Log{
+		transferLog(0, []common.Hash{TransferEventTopic, fromTopic, toTopic}, amount),
+		// Malformed: fewer than three topics.
+		transferLog(1, []common.Hash{TransferEventTopic}, amount),
+		transferLog(2, []common.Hash{TransferEventTopic, fromTopic}, amount),
+		// ERC721 Transfer, with the token ID as a fourth topic.
+		transferLog(3, []common.Hash{TransferEventTopic, fromTopic, toTopic, common.BigToHash(big.NewInt(7))}, nil),
+		// Wrong data length.
+		transferLog(4, []common.Hash{TransferEventTopic, fromTopic, toTopic}, amount[1:]),
+		// Other events and anonymous logs.
+		transferLog(5, []common.Hash{common.HexToHash("0x02"), fromTopic, toTopic}, amount),
+		transferLog(6, nil, amount),
+		{Address: token, Topics: []common.Hash{TransferEventTopic, fromTopic, toTopic}, Data: amount, Index: 7, Removed: true},
+		transferLog(8, []common.Hash{TransferEventTopic, toTopic, fromTopic}, amount),
+	}}
+
+	want := []Transfer{
+		{Token: token, From: from, To: to, Amount: big.NewInt(1000), TxHash: txHash, TxIndex: 3, LogIndex: 0},
+		{Token: token, From: to, To: from, Amount: big.NewInt(1000), TxHash: txHash, TxIndex: 3, LogIndex: 8},
+	}
+	got, err := DecodeTransfers(receipt)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeTransfers = %+v, want %+v", got, want)
+	}
+	// The three malformed logs are reported; the ERC721 transfer is not.
+	if !errors.Is(err, ErrMalformedLog) {
+		t.Fatalf("DecodeTransfers error = %v, want ErrMalformedLog", err)
+	}
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 3 {
+		t.Errorf("DecodeTransfers reported %d malformed logs, want 3: %v", n, err)
+	}
+
+	if _, err := DecodeTransfers(&types.Receipt{Logs: receipt.Logs[:1]}); err != nil {
+		t.Errorf("DecodeTransfers of a well-formed log: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package web3scanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/scanner"
+)
+
+// malformedBlock adds a block to client with a token transfer to user whose
+// receipt also holds a Transfer log missing its data, and returns the
+// database tracking user and the token.
+func malformedBlock(t *testing.T, client *fakeClient, user *testAccount) (*types.Block, *database.DB) {
+	t.Helper()
+	payer := newTestAccount(t)
+	token := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	tx := payer.transfer(t, token, 0)
+	receipt := transferReceipt(tx, token, payer.address, user.address, 7)
+	receipt.Logs = append(receipt.Logs, &types.Log{
+		Address: token,
+		Topics:  []common.Hash{scanner.TransferEventTopic, common.BytesToHash(payer.address.Bytes()), common.BytesToHash(user.address.Bytes())},
+		TxHash:  tx.Hash(),
+	})
+	block := client.addBlockWithReceipts([]*types.Transaction{tx}, []*types.Receipt{receipt})
+	return block, &database.DB{
+		Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}},
+		Tokens:    &fakeTokens{rows: []database.Tokens{{TokenAddress: token}}},
+	}
+}
+
+func TestMalformedLogSkippedInLenientMode(t *testing.T) {
+	user := newTestAccount(t)
+	client := newFakeClient()
+	block, db := malformedBlock(t, client, user)
+
+	m, err := newTestScanner(db, client).processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	// The well-formed transfer is still recorded.
+	if len(m.deposits) != 1 || m.deposits[0].Amount.Int64() != 7 {
+		t.Errorf("deposits = %+v, want the transfer of 7", m.deposits)
+	}
+}
+
+func TestMalformedLogHaltsInStrictMode(t *testing.T) {
+	user := newTestAccount(t)
+	client := newFakeClient()
+	_, db := malformedBlock(t, client, user)
+	db.Blocks = storedGenesis(client)
+
+	var shutdownCause error
+	ws := newTestScanner(db, client)
+	ws.strictDecode = true
+	ws.shutdown = func(cause error) { shutdownCause = cause }
+
+	// Nothing of the range may be stored: the fake database has no
+	// transactions.
+	_, err := ws.scanBlocks(context.Background())
+	if !errors.Is(err, errDecodeAnomaly) || !errors.Is(err, scanner.ErrMalformedLog) {
+		t.Fatalf("scanBlocks error = %v, want a malformed log decode anomaly", err)
+	}
+	if shutdownCause != err {
+		t.Errorf("scanner shut down with %v, want %v", shutdownCause, err)
+	}
+}
+
+func TestMismatchedReceipt(t *testing.T) {
+	user, payer := newTestAccount(t), newTestAccount(t)
+	client := newFakeClient()
+	tx := payer.transfer(t, user.address, 5)
+	other := payer.transfer(t, user.address, 6)
+	block := client.addBlockWithReceipts([]*types.Transaction{tx}, []*types.Receipt{
+		{TxHash: other.Hash(), Status: types.ReceiptStatusSuccessful},
+	})
+	db := &database.DB{Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}}}
+	ws := newTestScanner(db, client)
+
+	m, err := ws.processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	if len(m.deposits) != 0 {
+		t.Errorf("deposits = %+v, want the transaction skipped", m.deposits)
+	}
+
+	ws.strictDecode = true
+	if _, err := ws.processBlock(context.Background(), block, nil); !errors.Is(err, errDecodeAnomaly) {
+		t.Errorf("processBlock in strict mode error = %v, want a decode anomaly", err)
+	}
+}
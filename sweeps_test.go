@@ -0,0 +1,82 @@
+package web3scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/oracle"
+)
+
+// fakeDeposits is an in-memory database.DepositsDB.
+type fakeDeposits struct {
+	database.DepositsDB
+	rows []database.Deposits
+}
+
+func (f *fakeDeposits) QueryLatestDepositByToAddress(address common.Address) (*database.Deposits, error) {
+	for i := len(f.rows) - 1; i >= 0; i-- {
+		if f.rows[i].ToAddress == address {
+			return &f.rows[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func TestProcessBlockClassifiesSweeps(t *testing.T) {
+	user, hot, payer := newTestAccount(t), newTestAccount(t), newTestAccount(t)
+	client := newFakeClient()
+	deposit := payer.transfer(t, user.address, 5)
+	sweep := user.transfer(t, hot.address, 3)
+	block := client.addBlock(deposit, sweep)
+	stored := database.Deposits{GUID: uuid.New(), ToAddress: user.address}
+	db := &database.DB{
+		Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser), hot.row(database.AddressTypeHot)}},
+		Deposits:  &fakeDeposits{rows: []database.Deposits{stored}},
+	}
+	ws := newTestScanner(db, client)
+
+	m, err := ws.processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	if len(m.deposits) != 1 || m.deposits[0].TxHash != deposit.Hash() {
+		t.Fatalf("deposits = %+v, want only %s", m.deposits, deposit.Hash())
+	}
+	if len(m.sweeps) != 1 {
+		t.Fatalf("%d sweeps found, want 1", len(m.sweeps))
+	}
+	s := m.sweeps[0]
+	if s.TxHash != sweep.Hash() || s.FromAddress != user.address || s.ToAddress != hot.address || s.TokenAddress != oracle.NativeToken || s.Amount.Int64() != 3 {
+		t.Errorf("sweep = %+v, want 3 wei from %s to %s in %s", s, user.address, hot.address, sweep.Hash())
+	}
+
+	// The sweep links to the deposit of the same batch, or else to the
+	// latest stored one.
+	if err := ws.linkSweep(&s, m.deposits); err != nil {
+		t.Fatalf("linkSweep: %v", err)
+	}
+	if s.DepositGUID == nil || *s.DepositGUID != m.deposits[0].GUID {
+		t.Errorf("sweep linked to %v, want the pending deposit %s", s.DepositGUID, m.deposits[0].GUID)
+	}
+	s.DepositGUID = nil
+	if err := ws.linkSweep(&s, nil); err != nil {
+		t.Fatalf("linkSweep: %v", err)
+	}
+	if s.DepositGUID == nil || *s.DepositGUID != stored.GUID {
+		t.Errorf("sweep linked to %v, want the stored deposit %s", s.DepositGUID, stored.GUID)
+	}
+
+	// Without sweep detection the transfer to the hot wallet is ignored.
+	ws.detectSweeps = false
+	m, err = ws.processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	if len(m.sweeps) != 0 || len(m.deposits) != 1 {
+		t.Errorf("without sweep detection got %d sweeps and %d deposits, want 0 and 1", len(m.sweeps), len(m.deposits))
+	}
+}
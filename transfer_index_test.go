@@ -0,0 +1,46 @@
+package web3scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+func TestProcessBlockIndexesTransfersOfOneTransaction(t *testing.T) {
+	user, payer := newTestAccount(t), newTestAccount(t)
+	token := common.HexToAddress("0x5000000000000000000000000000000000000005")
+
+	// A native deposit followed by a batch call emitting two identical
+	// Transfer logs to the same user.
+	client := newFakeClient()
+	native := payer.transfer(t, user.address, 5)
+	batch := payer.transfer(t, token, 0)
+	receipt := transferReceipt(batch, token, payer.address, user.address, 7)
+	receipt.Logs = append(receipt.Logs, transferReceipt(batch, token, payer.address, user.address, 7).Logs...)
+	block := client.addBlockWithReceipts([]*types.Transaction{native, batch},
+		[]*types.Receipt{{TxHash: native.Hash(), Status: types.ReceiptStatusSuccessful}, receipt})
+	db := &database.DB{
+		Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}},
+		Tokens:    &fakeTokens{rows: []database.Tokens{{TokenAddress: token}}},
+	}
+
+	m, err := newTestScanner(db, client).processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	if len(m.deposits) != 3 {
+		t.Fatalf("%d deposits, want 3", len(m.deposits))
+	}
+	if d := m.deposits[0]; d.TxHash != native.Hash() || d.TxIndex != 0 || d.LogIndex != nil {
+		t.Errorf("native deposit at tx %d log %v, want tx 0 without log", d.TxIndex, d.LogIndex)
+	}
+	for i, d := range m.deposits[1:] {
+		if d.TxHash != batch.Hash() || d.TxIndex != 1 || d.LogIndex == nil || *d.LogIndex != uint(i) {
+			t.Errorf("token deposit %d = tx %s index %d log %v, want tx %s index 1 log %d", i, d.TxHash, d.TxIndex, d.LogIndex, batch.Hash(), i)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package web3scanner
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+// subBatchClient records the size of every transactions sub-batch and
+// rejects whole-block fetches.
+type subBatchClient struct {
+	*fakeClient
+	batches []int
+}
+
+func (c *subBatchClient) BatchTransactionsAndReceipts(ctx context.Context, hashes []common.Hash) ([]*types.Transaction, []*types.Receipt, error) {
+	c.batches = append(c.batches, len(hashes))
+	return c.fakeClient.BatchTransactionsAndReceipts(ctx, hashes)
+}
+
+func (c *subBatchClient) BlockByNumber(context.Context, *big.Int) (*types.Block, error) {
+	return nil, errors.New("whole block fetched")
+}
+
+func (c *subBatchClient) BatchBlocksByRange(context.Context, *big.Int, *big.Int) ([]*types.Block, error) {
+	return nil, errors.New("whole blocks fetched")
+}
+
+func TestProcessBlockInSubBatches(t *testing.T) {
+	user, payer := newTestAccount(t), newTestAccount(t)
+	client := &subBatchClient{fakeClient: newFakeClient()}
+	// A synthetic large block: only sub-batches of at most 128
+	// transactions and receipts may be held at once.
+	var txs []*types.Transaction
+	for i := range 1_000 {
+		txs = append(txs, payer.transfer(t, user.address, int64(i+1)))
+	}
+	block := client.addBlock(txs...)
+	db := &database.DB{Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}}}
+	ws := newTestScanner(db, client)
+	whole, err := ws.processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock of the whole block: %v", err)
+	}
+
+	ws.txSubBatchSize = 128
+	header, hashes, err := client.HeaderAndTxHashesByNumber(context.Background(), block.Number())
+	if err != nil {
+		t.Fatalf("HeaderAndTxHashesByNumber: %v", err)
+	}
+	m, err := ws.processBlock(context.Background(), types.NewBlockWithHeader(header), hashes)
+	if err != nil {
+		t.Fatalf("processBlock in sub-batches: %v", err)
+	}
+	if want := []int{128, 128, 128, 128, 128, 128, 128, 104}; !slices.Equal(client.batches, want) {
+		t.Errorf("sub-batch sizes = %v, want %v", client.batches, want)
+	}
+	if len(m.deposits) != len(whole.deposits) {
+		t.Fatalf("%d deposits in sub-batches, want %d", len(m.deposits), len(whole.deposits))
+	}
+	for i, d := range m.deposits {
+		if w := whole.deposits[i]; d.TxHash != w.TxHash || d.TxIndex != w.TxIndex || d.Amount.Cmp(w.Amount) != 0 {
+			t.Errorf("deposit %d = tx %s index %d amount %s, want tx %s index %d amount %s", i, d.TxHash, d.TxIndex, d.Amount, w.TxHash, w.TxIndex, w.Amount)
+		}
+	}
+}
+
+func TestProcessBlockInSubBatchesRejectsReorgedTransaction(t *testing.T) {
+	user, payer := newTestAccount(t), newTestAccount(t)
+	client := newFakeClient()
+	tx := payer.transfer(t, user.address, 5)
+	client.addBlock(tx)
+	header, hashes, err := client.HeaderAndTxHashesByNumber(context.Background(), big.NewInt(1))
+	if err != nil {
+		t.Fatalf("HeaderAndTxHashesByNumber: %v", err)
+	}
+	// The transaction is mined again in a competing block 1 before its
+	// sub-batch is fetched.
+	client.reorg(1)
+	client.addBlock(tx)
+	db := &database.DB{Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}}}
+	ws := newTestScanner(db, client)
+	ws.txSubBatchSize = 10
+
+	_, err = ws.processBlock(context.Background(), types.NewBlockWithHeader(header), hashes)
+	if err == nil || !strings.Contains(err.Error(), "is from block") {
+		t.Fatalf("processBlock error = %v, want a receipt from another block", err)
+	}
+}
+
+func TestScanBlocksFetchesTransactionsInSubBatches(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	user, payer := newTestAccount(t), newTestAccount(t)
+	if err := db.Addresses.StoreAddresses([]database.Addresses{user.row(database.AddressTypeUser)}); err != nil {
+		t.Fatalf("store addresses: %v", err)
+	}
+	client := &subBatchClient{fakeClient: newFakeClient()}
+	client.addBlock(payer.transfer(t, user.address, 1), payer.transfer(t, user.address, 2), payer.transfer(t, user.address, 3))
+	client.addBlock()
+	client.addBlock(payer.transfer(t, user.address, 4))
+	ws := newTestScanner(db, client)
+	// Catching up would fetch whole blocks in batches without sub-batching.
+	ws.blocksStep = 2
+	ws.rpcBatchSize = 2
+	ws.txSubBatchSize = 2
+
+	for caughtUp := false; !caughtUp; {
+		var err error
+		if caughtUp, err = ws.scanBlocks(context.Background()); err != nil {
+			t.Fatalf("scanBlocks: %v", err)
+		}
+	}
+	if want := []int{2, 1, 1}; !slices.Equal(client.batches, want) {
+		t.Errorf("sub-batch sizes = %v, want %v", client.batches, want)
+	}
+	deposits, err := db.Deposits.QueryDepositsByBlockRange(big.NewInt(0), big.NewInt(3))
+	if err != nil {
+		t.Fatalf("query deposits: %v", err)
+	}
+	if len(deposits) != 4 {
+		t.Fatalf("%d deposits stored, want 4", len(deposits))
+	}
+	for i, d := range deposits {
+		if d.Amount.Cmp(big.NewInt(int64(i+1))) != 0 {
+			t.Errorf("deposit %d amount = %s, want %d", i, d.Amount, i+1)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package web3scanner
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+)
+
+// storedGenesis returns fake blocks holding the client's genesis block.
+func storedGenesis(client *fakeClient) *fakeBlocks {
+	return &fakeBlocks{rows: []database.Blocks{database.BlockFromHeader(client.blocks[0].Header())}}
+}
+
+func TestScanBlocksRejectsUnlinkedBlock(t *testing.T) {
+	client := newFakeClient()
+	client.addBlock()
+	// Block 2 claims a parent that is not block 1.
+	client.blocks = append(client.blocks, types.NewBlockWithHeader(&types.Header{
+		Number:     big.NewInt(2),
+		ParentHash: common.HexToHash("0xbad"),
+		Difficulty: new(big.Int),
+	}))
+	client.addBlock()
+
+	// Nothing of the range may be stored: the fake database has no
+	// transactions, so storing would panic.
+	ws := newTestScanner(&database.DB{Blocks: storedGenesis(client)}, client)
+	caughtUp, err := ws.scanBlocks(context.Background())
+	if err != nil || caughtUp {
+		t.Fatalf("scanBlocks = %t, %v, want the range dropped for a retry", caughtUp, err)
+	}
+}
+
+// forgingClient reports a different hash for one block than the block it
+// serves.
+type forgingClient struct {
+	*fakeClient
+	forged *big.Int
+}
+
+func (c forgingClient) ReportedBlockHash(ctx context.Context, number *big.Int) (common.Hash, error) {
+	if number.Cmp(c.forged) == 0 {
+		return common.HexToHash("0xf0f0"), nil
+	}
+	return c.fakeClient.ReportedBlockHash(ctx, number)
+}
+
+func TestScanBlocksHaltsOnHashMismatch(t *testing.T) {
+	client := newFakeClient()
+	client.addBlock()
+	client.addBlock()
+
+	var shutdownCause error
+	ws := newTestScanner(&database.DB{Blocks: storedGenesis(client)}, forgingClient{client, big.NewInt(2)})
+	ws.verifyBlocks = true
+	ws.shutdown = func(cause error) { shutdownCause = cause }
+
+	_, err := ws.scanBlocks(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "block 2 hash mismatch") {
+		t.Fatalf("scanBlocks error = %v, want a hash mismatch on block 2", err)
+	}
+	if shutdownCause != err {
+		t.Errorf("scanner shut down with %v, want %v", shutdownCause, err)
+	}
+}
+
+func TestScanBlocksRetriesRangeOnInconsistentHash(t *testing.T) {
+	client := newFakeClient()
+	client.addBlock()
+	client.addBlock()
+
+	shutdowns := 0
+	// The node serves block 2 with one hash and reports another for it, as
+	// two backends of a load-balanced endpoint might. Nothing of the range
+	// may be stored: the fake database has no transactions.
+	ws := newTestScanner(&database.DB{Blocks: storedGenesis(client)}, forgingClient{client, big.NewInt(2)})
+	ws.shutdown = func(error) { shutdowns++ }
+
+	_, err := ws.scanBlocks(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "block 2 hash mismatch") {
+		t.Fatalf("scanBlocks error = %v, want a hash mismatch on block 2", err)
+	}
+	if shutdowns != 0 {
+		t.Errorf("scanner shut down %d times, want the range failed for a retry", shutdowns)
+	}
+}
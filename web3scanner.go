@@ -3,16 +3,28 @@ package web3scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
-	"github.com/google/uuid"
+	"gorm.io/gorm"
 
+	"github.com/qiaopengjun5162/web3scanner/common/retry"
 	"github.com/qiaopengjun5162/web3scanner/config"
 	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/utils/serializers"
+	"github.com/qiaopengjun5162/web3scanner/ens"
+	"github.com/qiaopengjun5162/web3scanner/metrics"
+	"github.com/qiaopengjun5162/web3scanner/oracle"
+	"github.com/qiaopengjun5162/web3scanner/rpc"
+	"github.com/qiaopengjun5162/web3scanner/scanner"
 )
 
 // Web3Scanner 是一个结构体，用于扫描和监控Web3相关的活动或数据。
@@ -21,6 +33,12 @@ type Web3Scanner struct {
 	// db 是一个数据库连接实例，用于执行数据库操作。
 	db *database.DB
 
+	// client 是以太坊节点的 RPC 客户端，用于拉取区块和交易回执。
+	client rpc.EthClient
+
+	// signer 用于从交易签名中恢复发送方地址。
+	signer types.Signer
+
 	// shutdown 是一个context.CancelCauseFunc类型的函数，
 	// 用于在需要停止扫描器时调用，以优雅地关闭扫描器。
 	shutdown context.CancelCauseFunc
@@ -28,6 +46,139 @@ type Web3Scanner struct {
 	// stopped 是一个原子布尔值，用于表示扫描器是否已经停止。
 	// 这提供了一种线程安全的方式来检查扫描器的停止状态。
 	stopped atomic.Bool
+
+	// done 在 Start 启动的扫描循环退出时关闭，Stop 通过它等待循环结束。
+	done chan struct{}
+
+	// hooks 是按注册顺序执行的交易钩子列表。
+	hooks []TransactionHook
+
+	// failOnHookError 为 true 时，钩子返回错误会导致整个区块处理失败。
+	failOnHookError bool
+
+	// startingHeight 是数据库中没有任何区块时的起始扫描高度。
+	startingHeight uint64
+
+	// blocksStep 是每轮最多处理的区块数。
+	blocksStep uint64
+
+	// catchUpBlocksStep 是距离链头超过 catchUpThreshold 个区块时每轮最多处理的区块数，
+	// 为 0 时始终使用 blocksStep。catchingUp 记录上一轮是否处于追块模式。
+	catchUpBlocksStep uint64
+	catchUpThreshold  uint64
+	catchingUp        bool
+
+	// pollInterval 是追上链头后轮询新区块的间隔。
+	pollInterval time.Duration
+
+	// verifyBlocks 为 true 时，区块哈希与节点返回的不一致会停止扫描器；
+	// 否则只放弃本轮区块范围，下一轮重新拉取。
+	verifyBlocks bool
+
+	// detectSweeps 为 true 时，用户地址转入热钱包的交易会被记录为归集，
+	// 用户地址之间的转账会被记录为内部转账，而不是充值。
+	detectSweeps bool
+
+	// logMatches 为 true 时，每笔匹配到的充值和归集都会输出一条 info 日志。
+	logMatches bool
+
+	// strictDecode 为 true 时，解码异常（格式错误的 Transfer 日志、与交易不匹配的收据）
+	// 会停止扫描器；否则跳过异常数据并输出警告。
+	strictDecode bool
+
+	// skipZeroValue 为 true 时，金额为 0 的原生转账和 ERC20 转账不会被记录为充值或归集。
+	skipZeroValue bool
+
+	// depositConfirmations 是充值被标记为已确认所需的后续区块数，
+	// withdrawalConfirmations 是已上链的提现被标记为已确认所需的后续区块数。
+	depositConfirmations    uint64
+	withdrawalConfirmations uint64
+
+	// rpcBatchSize 是追块时每个批量请求最多拉取的区块数，为 0 时不使用批量请求。
+	rpcBatchSize uint64
+
+	// txSubBatchSize 不为 0 时，区块只拉取交易哈希，交易和回执按每批最多
+	// txSubBatchSize 笔分批拉取和处理，以限制单个区块占用的内存。
+	txSubBatchSize uint64
+
+	// depositPartitionSize 是充值表每个分区包含的区块数，为 0 时不自动创建分区；
+	// depositPartitionsAhead 是链头所在分区之后预先创建的分区数。
+	// depositPartitionsEnd 是已确认存在的分区的区块上界（不含），启动后为 nil。
+	depositPartitionSize   uint64
+	depositPartitionsAhead uint64
+	depositPartitionsEnd   *big.Int
+
+	// dryRun 为 true 时，每轮的写事务都会回滚，只输出本轮将会记录的内容，
+	// 也不做归集。
+	dryRun bool
+
+	// dryRunLatest 是试运行中最近处理的区块，下一轮从它之后继续；
+	// 为 nil 时从数据库中最新的区块之后继续。
+	dryRunLatest *database.Blocks
+
+	// depositRates 统计每个地址在时间窗口内的充值次数，未配置阈值时为 nil。
+	depositRates *depositRateLimiter
+
+	// onDepositRateAlert 是充值频率超过阈值时调用的回调。
+	onDepositRateAlert DepositRateAlertFunc
+
+	// metrics 记录扫描器的 Prometheus 指标。
+	metrics *metrics.Metrics
+
+	// metricsListenAddr 是 /metrics HTTP 服务的监听地址，为空时不启动。
+	metricsListenAddr string
+
+	// rpcStats 按方法统计节点调用，未开启 RPC 统计时为 nil。
+	rpcStats *rpcStats
+
+	// collectionInterval 是检查用户地址余额生成归集、检查热钱包余额生成冷钱包转账的间隔，
+	// 为 0 时两者都不做。
+	collectionInterval time.Duration
+
+	// collectionStrategy 决定有多个热钱包时归集转入哪一个。
+	collectionStrategy database.CollectionStrategy
+
+	// lastCollection 是上一次开始检查归集的时间。
+	lastCollection time.Time
+
+	// collecting 在后台归集检查运行期间为 true。
+	collecting atomic.Bool
+
+	// ens 查询地址的 ENS 主名称，未开启 ENS 查询时为 nil。
+	// ensRefreshInterval 是重新查询已有名称的间隔，为 0 时只查询新地址。
+	// resolvingEns 在后台 ENS 查询运行期间为 true。
+	ens                ensLookup
+	ensRefreshInterval time.Duration
+	resolvingEns       atomic.Bool
+
+	// backgroundRuns 用于停止时等待后台的归集检查和 ENS 查询结束。
+	backgroundRuns sync.WaitGroup
+
+	// heartbeatInterval 是心跳日志的最小间隔，为 0 时不输出心跳。
+	heartbeatInterval time.Duration
+
+	// lastHeartbeat 是上一次输出心跳的时间。
+	lastHeartbeat time.Time
+
+	// startedAt 是扫描循环启动的时间，心跳中的运行时长由它计算。
+	startedAt time.Time
+
+	// cursor 是最近一轮扫描时数据库中最新的区块号，head 是当时节点的链头，
+	// 尚未扫描到时为 nil。
+	cursor, head *big.Int
+
+	// metricsServer 是 Start 时启动的指标 HTTP 服务。
+	metricsServer *metrics.Server
+
+	// dbAvailable 表示数据库当前是否可用；运行期数据库中断时扫描器会暂停，
+	// 直到数据库恢复后再从游标处继续。
+	dbAvailable atomic.Bool
+
+	// dbPing 检查数据库是否可达，默认为 db.Ping。
+	dbPing func(ctx context.Context) error
+
+	// dbRetry 是数据库中断期间重新检查的退避策略。
+	dbRetry retry.Strategy
 }
 
 // NewWeb3Scanner creates a new instance of Web3Scanner.
@@ -37,64 +188,998 @@ type Web3Scanner struct {
 // when the Web3Scanner is shut down.
 //
 // The function returns a pointer to the new Web3Scanner instance and an error.
-// The error is set if there was an error creating the database connection.
+// The error is set if the database or the RPC node can't be reached.
 func NewWeb3Scanner(ctx context.Context, cfg *config.Config, shutdown context.CancelCauseFunc) (*Web3Scanner, error) {
-	dba, err := database.NewDB(ctx, cfg.MasterDB)
+	if cfg.RpcUrl == "" {
+		return nil, errors.New("rpc url is required to run the scanner")
+	}
+	if cfg.BlocksStep == 0 {
+		return nil, errors.New("blocks step must be greater than zero")
+	}
+	if cfg.PollInterval <= 0 {
+		return nil, errors.New("poll interval must be greater than zero")
+	}
+	// Collected funds only leave the user balances through detected sweeps;
+	// without them every collection would be queued again once confirmed.
+	if cfg.CollectionInterval > 0 && !cfg.DetectSweeps {
+		return nil, errors.New("collection requires sweep detection to be enabled")
+	}
+	collectionStrategy := database.CollectionStrategy(cfg.CollectionStrategy)
+	if collectionStrategy == "" {
+		collectionStrategy = database.CollectionStrategyPriority
+	}
+
+	dba, err := database.NewDBWithReplica(ctx, cfg.MasterDB, cfg.SlaveDB, cfg.ChainID)
+	if err != nil {
+		log.Error("init database fail", "err", err)
+		return nil, err
+	}
+	// Everything opened from here on is closed again on any error return.
+	success := false
+	defer func() {
+		if !success {
+			_ = dba.Close()
+		}
+	}()
+	if err := dba.EnableAddressCache(cfg.AddressCacheMaxSize); err != nil {
+		log.Error("init address cache fail", "err", err)
+		return nil, err
+	}
+
+	m := metrics.NewMetrics()
+	serializers.SetErrorObserver(m.RecordSerializerError)
+	rawClient, err := rpc.DialEthClient(ctx, cfg.RpcUrl)
+	if err != nil {
+		log.Error("dial rpc fail", "err", err)
+		return nil, err
+	}
+	defer func() {
+		if !success {
+			rawClient.Close()
+		}
+	}()
+	var stats *rpcStats
+	if cfg.RPCStats {
+		stats = newRPCStats()
+	}
+	client := &meteredClient{EthClient: rawClient, metrics: m, stats: stats}
+	chainID, err := client.ChainID(ctx)
 	if err != nil {
-		log.Error("init database fail", err)
+		log.Error("query chain id fail", "err", err)
 		return nil, err
 	}
+	if err := checkChainID(cfg.ChainID, chainID); err != nil {
+		log.Error("rpc chain id check fail", "err", err)
+		return nil, err
+	}
+
+	dba.SetNonceSeeder(func(address common.Address) (uint64, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), nonceSeedTimeout)
+		defer cancel()
+		return client.PendingNonceAt(ctx, address)
+	})
+
 	out := &Web3Scanner{
-		db:       dba,
-		shutdown: shutdown,
+		db:                      dba,
+		client:                  client,
+		signer:                  types.LatestSignerForChainID(chainID),
+		shutdown:                shutdown,
+		failOnHookError:         cfg.FailOnHookError,
+		startingHeight:          cfg.StartingHeight,
+		blocksStep:              cfg.BlocksStep,
+		catchUpBlocksStep:       cfg.CatchUpBlocksStep,
+		catchUpThreshold:        cfg.CatchUpThreshold,
+		pollInterval:            cfg.PollInterval,
+		verifyBlocks:            cfg.VerifyBlocks,
+		detectSweeps:            cfg.DetectSweeps,
+		logMatches:              cfg.LogMatches,
+		strictDecode:            cfg.StrictDecode,
+		skipZeroValue:           cfg.SkipZeroValueTransfers,
+		depositConfirmations:    cfg.DepositConfirmations,
+		withdrawalConfirmations: cfg.WithdrawalConfirmations,
+		rpcBatchSize:            cfg.RpcBatchSize,
+		txSubBatchSize:          cfg.TxSubBatchSize,
+		depositPartitionSize:    cfg.DepositPartitionSize,
+		depositPartitionsAhead:  cfg.DepositPartitionsAhead,
+		dryRun:                  cfg.DryRun,
+		metrics:                 m,
+		rpcStats:                stats,
+		metricsListenAddr:       cfg.MetricsListenAddr,
+
+		collectionInterval: cfg.CollectionInterval,
+		collectionStrategy: collectionStrategy,
+		heartbeatInterval:  cfg.HeartbeatInterval,
+		ensRefreshInterval: cfg.EnsRefreshInterval,
+
+		dbPing:  dba.Ping,
+		dbRetry: retry.Exponential(),
+	}
+	// config.Validate ensures the window and max addresses are set.
+	if cfg.DepositAlertThreshold > 0 {
+		out.depositRates = newDepositRateLimiter(cfg.DepositAlertThreshold, cfg.DepositAlertWindow, cfg.DepositAlertMaxAddresses)
 	}
+	if cfg.EnsLookup {
+		out.ens = ens.NewResolver(client, ens.RegistryAddress)
+	}
+	if out.dryRun {
+		log.Warn("dry run: every scanned range is rolled back and nothing is stored")
+	}
+	out.dbAvailable.Store(true)
+	success = true
 	return out, nil
 }
 
+// nonceSeedTimeout bounds the node query that seeds a hot wallet's stored
+// nonce on first use.
+const nonceSeedTimeout = 10 * time.Second
+
+// checkChainID verifies that the node serves the configured chain, so a
+// misconfigured RPC URL can't make the scanner record another network's
+// data. An expected chain ID of 0 skips the check.
+func checkChainID(expected uint64, actual *big.Int) error {
+	if expected == 0 {
+		log.Warn("no chain id configured, skipping rpc chain id check", "rpcChainId", actual)
+		return nil
+	}
+	if !actual.IsUint64() || actual.Uint64() != expected {
+		return fmt.Errorf("rpc node serves chain id %s, but chain id %d is configured", actual, expected)
+	}
+	return nil
+}
+
 // Start starts the Web3Scanner.
 //
-// It takes a context and stores an address in the database. It then retrieves all
-// addresses from the database and prints them out.
+// It launches the scanning loop in the background and returns immediately.
+// If a metrics listen address is configured it also serves /metrics and a
+// /healthz readiness probe that checks the database.
+// The loop resumes from the block after the latest stored one (or from the
+// configured starting height), processes up to blocksStep blocks per round,
+// or catchUpBlocksStep while far behind the head, and persists blocks and
+// matched deposits atomically. It runs until ctx is done, which also
+// happens when shutdown is called.
+func (ws *Web3Scanner) Start(ctx context.Context) error {
+	log.Info("web3scanner start", "pollInterval", ws.pollInterval, "blocksStep", ws.blocksStep, "catchUpBlocksStep", ws.catchUpBlocksStep)
+	if ws.metricsListenAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", metrics.HealthHandler(ws.dbPing, dbPingTimeout))
+		server, err := metrics.StartServer(ws.metricsListenAddr, ws.metrics, mux)
+		if err != nil {
+			return fmt.Errorf("start metrics server: %w", err)
+		}
+		ws.metricsServer = server
+	}
+	ws.done = make(chan struct{})
+	go ws.loop(ctx)
+	return nil
+}
+
+// loop runs scan rounds until ctx is done. While behind the chain head it
+// scans back to back; once caught up it waits pollInterval between rounds
+// and queues collections and looks up ENS names when they are due.
+func (ws *Web3Scanner) loop(ctx context.Context) {
+	defer close(ws.done)
+	defer ws.stopped.Store(true)
+
+	ws.startedAt = time.Now()
+	ticker := time.NewTicker(ws.pollInterval)
+	defer ticker.Stop()
+	for {
+		caughtUp, err := ws.scanBlocks(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error("scan blocks fail", "err", err)
+			ws.waitForDB(ctx)
+		}
+		if err == nil && !caughtUp {
+			if ctx.Err() != nil {
+				return
+			}
+			ws.maybeHeartbeat()
+			continue
+		}
+		if err == nil {
+			ws.maybeCollect()
+			ws.maybeResolveEnsNames(ctx)
+		}
+		ws.maybeHeartbeat()
+		select {
+		case <-ctx.Done():
+			log.Info("web3scanner loop exit", "cause", context.Cause(ctx))
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// maybeHeartbeat logs the scan cursor, the chain head, the lag and the
+// uptime, at most once per heartbeatInterval however fast the loop runs, so
+// an idle but healthy scanner can be told apart from a stuck one.
+func (ws *Web3Scanner) maybeHeartbeat() {
+	if ws.heartbeatInterval <= 0 || time.Since(ws.lastHeartbeat) < ws.heartbeatInterval {
+		return
+	}
+	ws.lastHeartbeat = time.Now()
+	ws.metrics.RecordHeartbeat(ws.lastHeartbeat)
+	uptime := time.Since(ws.startedAt).Round(time.Second)
+	if ws.cursor == nil || ws.head == nil {
+		log.Info("heartbeat", "cursor", ws.cursor, "head", ws.head, "uptime", uptime)
+		return
+	}
+	log.Info("heartbeat", "cursor", ws.cursor, "head", ws.head, "lag", new(big.Int).Sub(ws.head, ws.cursor), "uptime", uptime)
+}
+
+// dbPingTimeout bounds each database health check.
+const dbPingTimeout = 5 * time.Second
+
+// waitForDB pauses the scanner while the database is unreachable.
 //
-// The function returns an error if there was an error storing or retrieving the
-// addresses.
-func (ws *Web3Scanner) Start(_ context.Context) error {
-	fmt.Println("Web3Scanner start .........")
-	var batchAddress []database.Addresses
-	addressItem := database.Addresses{
-		GUID:        uuid.New(),
-		Address:     common.HexToAddress("0x0fa09C3A328792253f8dee7116848723b72a6d2e"),
-		AddressType: 1,
-		PublicKey:   "0x0fa09C3A328792253f8dee7116848723b72a6d2e",
-		Timestamp:   time.Now().Unix(),
-	}
-	batchAddress = append(batchAddress, addressItem)
-	err := ws.db.Addresses.StoreAddresses(batchAddress)
+// Startup connection failures are handled by database.NewDB; this covers
+// outages at runtime. It pings with exponential backoff until the database
+// answers or ctx is done. Scanning then resumes from the stored cursor, so
+// no blocks are skipped or processed twice.
+func (ws *Web3Scanner) waitForDB(ctx context.Context) {
+	if ws.pingDB(ctx) == nil {
+		return
+	}
+	ws.dbAvailable.Store(false)
+	log.Warn("database unavailable, pausing scanner")
+
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ws.dbRetry.Duration(attempt)):
+		}
+		if err := ws.pingDB(ctx); err != nil {
+			log.Warn("database still unavailable", "attempt", attempt+1, "err", err)
+			continue
+		}
+		ws.dbAvailable.Store(true)
+		log.Info("database available again, resuming scanner")
+		return
+	}
+}
+
+func (ws *Web3Scanner) pingDB(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, dbPingTimeout)
+	defer cancel()
+	return ws.dbPing(ctx)
+}
+
+// DBAvailable reports whether the scanner currently considers the database
+// reachable. It is false while the scanner is paused on a runtime outage.
+func (ws *Web3Scanner) DBAvailable() bool {
+	return ws.dbAvailable.Load()
+}
+
+// errDryRun rolls back the write transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// errDecodeAnomaly is wrapped by the errors of data that couldn't be
+// decoded as expected in strict decode mode, which halt the scanner.
+var errDecodeAnomaly = errors.New("decode anomaly")
+
+// scanBlocks processes the next range of at most blocksStepFor blocks and
+// reports whether the scanner has reached the chain head. In a dry run the
+// range is processed and written as usual, but the transaction is rolled
+// back and a summary of what it would have recorded is logged.
+func (ws *Web3Scanner) scanBlocks(ctx context.Context) (bool, error) {
+	latest, err := ws.latestBlock()
 	if err != nil {
-		fmt.Println("store address fail")
-		return err
+		return false, fmt.Errorf("query latest block: %w", err)
+	}
+	next := new(big.Int).SetUint64(ws.startingHeight)
+	var prevHash *common.Hash
+	if latest != nil {
+		next = new(big.Int).Add(latest.Number, big.NewInt(1))
+		prevHash = &latest.BlockHash
+	}
+
+	headNumber, err := ws.client.BlockNumber(ctx)
+	if err != nil {
+		return false, fmt.Errorf("query head block number: %w", err)
+	}
+	head := new(big.Int).SetUint64(headNumber)
+	ws.head = head
+	if latest != nil {
+		ws.cursor = latest.Number
+	}
+	if err := ws.createDepositPartitions(next, head); err != nil {
+		return false, fmt.Errorf("create deposit partitions: %w", err)
+	}
+	if next.Cmp(head) > 0 {
+		return true, nil
+	}
+	end := new(big.Int).Add(next, new(big.Int).SetUint64(ws.blocksStepFor(next, head)-1))
+	if end.Cmp(head) > 0 {
+		end.Set(head)
+	}
+
+	fetchStart := time.Now()
+	prefetched := ws.prefetchBlocks(ctx, next, end, head)
+	if prefetched != nil {
+		ws.metrics.ObserveScanPhase(metrics.PhaseFetch, time.Since(fetchStart))
+	}
+	if want := new(big.Int).Sub(end, next).Int64() + 1; prefetched != nil && int64(len(prefetched)) != want {
+		return false, fmt.Errorf("batch fetch of blocks %s-%s returned %d blocks, want %d", next, end, len(prefetched), want)
+	}
+	var blocks []database.Blocks
+	var deposits []database.Deposits
+	var sweeps []database.Sweeps
+	var sent []*blockMatches
+	for number := new(big.Int).Set(next); number.Cmp(end) <= 0; number.Add(number, big.NewInt(1)) {
+		// On shutdown, stop fetching but still store the blocks that were
+		// fully processed, so the work done so far isn't lost.
+		if ctx.Err() != nil {
+			break
+		}
+		var block *types.Block
+		var txHashes []common.Hash
+		var err error
+		if prefetched != nil {
+			block = prefetched[new(big.Int).Sub(number, next).Int64()]
+		} else if ws.txSubBatchSize > 0 {
+			fetchStart := time.Now()
+			var header *types.Header
+			header, txHashes, err = ws.client.HeaderAndTxHashesByNumber(ctx, number)
+			ws.metrics.ObserveScanPhase(metrics.PhaseFetch, time.Since(fetchStart))
+			if err == nil {
+				block = types.NewBlockWithHeader(header)
+			}
+		} else {
+			fetchStart := time.Now()
+			block, err = ws.client.BlockByNumber(ctx, number)
+			ws.metrics.ObserveScanPhase(metrics.PhaseFetch, time.Since(fetchStart))
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return false, fmt.Errorf("fetch block %s: %w", number, err)
+		}
+		if prevHash != nil && block.ParentHash() != *prevHash {
+			if number.Cmp(next) == 0 {
+				if ws.dryRun {
+					return false, ws.dryRunReorg(latest)
+				}
+				// The first block of the range no longer builds on the latest
+				// stored block, so the stored chain tip was reorged out.
+				if err := ws.rollbackReorg(ctx, latest, block); err != nil {
+					return false, fmt.Errorf("roll back reorg at block %s: %w", number, err)
+				}
+				return false, nil
+			}
+			// The chain reorged while this range was being fetched. Nothing
+			// of it is stored yet, so drop it and fetch the range again.
+			log.Warn("chain reorged during scan, retrying range", "from", next, "number", number)
+			return false, nil
+		}
+		if err := ws.verifyBlock(ctx, block); err != nil {
+			if ws.verifyBlocks {
+				log.Error("block verification fail, halting scanner", "number", number, "err", err)
+				ws.shutdown(err)
+				return false, err
+			}
+			// A load-balanced endpoint can serve different blocks for the
+			// same number; drop the range and fetch it again.
+			log.Warn("inconsistent block from node, retrying range", "from", next, "number", number, "err", err)
+			return false, err
+		}
+		hash := block.Hash()
+		prevHash = &hash
+		matches, err := ws.processBlock(ctx, block, txHashes)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			err = fmt.Errorf("process block %s: %w", number, err)
+			if errors.Is(err, errDecodeAnomaly) {
+				log.Error("decode anomaly in strict mode, halting scanner", "number", number, "err", err)
+				ws.shutdown(err)
+			}
+			return false, err
+		}
+		blocks = append(blocks, database.BlockFromHeader(block.Header()))
+		deposits = append(deposits, matches.deposits...)
+		if len(matches.succeeded) > 0 || len(matches.failed) > 0 {
+			sent = append(sent, matches)
+		}
+		for _, sweep := range matches.sweeps {
+			if err := ws.linkSweep(&sweep, deposits); err != nil {
+				return false, fmt.Errorf("link sweep %s: %w", sweep.TxHash, err)
+			}
+			sweeps = append(sweeps, sweep)
+		}
+	}
+
+	if len(blocks) == 0 {
+		return false, ctx.Err()
+	}
+	end = blocks[len(blocks)-1].Number
+
+	var mined int
+	persistStart := time.Now()
+	err = ws.db.Transaction(func(tx *database.DB) error {
+		if err := tx.Blocks.StoreBlocks(blocks); err != nil {
+			return err
+		}
+		if err := tx.Deposits.StoreDeposits(deposits); err != nil {
+			return err
+		}
+		if err := tx.Sweeps.StoreSweeps(sweeps); err != nil {
+			return err
+		}
+		changes := balanceChanges(deposits, sweeps)
+		for _, m := range sent {
+			withdrawals, err := tx.Withdrawals.MarkWithdrawalsMined(m.block.Number(), m.succeeded, m.failed)
+			if err != nil {
+				return err
+			}
+			mined += len(withdrawals)
+			changes = append(changes, withdrawalChanges(withdrawals, m.block.Time())...)
+		}
+		sortBalanceChanges(changes)
+		if err := applyBalanceChanges(tx, changes); err != nil {
+			return err
+		}
+		if confirmed := new(big.Int).Sub(head, new(big.Int).SetUint64(ws.depositConfirmations)); confirmed.Sign() >= 0 {
+			if err := tx.Deposits.MarkConfirmed(confirmed); err != nil {
+				return err
+			}
+		}
+		if confirmed := new(big.Int).Sub(head, new(big.Int).SetUint64(ws.withdrawalConfirmations)); confirmed.Sign() >= 0 {
+			if _, err := tx.Withdrawals.MarkWithdrawalsConfirmed(confirmed); err != nil {
+				return err
+			}
+		}
+		if ws.dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	ws.metrics.ObserveScanPhase(metrics.PhasePersist, time.Since(persistStart))
+	if err != nil && !errors.Is(err, errDryRun) {
+		return false, fmt.Errorf("store blocks %s-%s: %w", next, end, err)
+	}
+	if ws.dryRun {
+		ws.dryRunLatest = &blocks[len(blocks)-1]
+		log.Info("dry run rolled back", "from", next, "to", end, "deposits", len(deposits), "sweeps", len(sweeps), "withdrawalsMined", mined)
+	}
+	ws.cursor = end
+	ws.checkDepositRates(deposits)
+	ws.metrics.RecordBlocksScanned(len(blocks))
+	ws.metrics.RecordLatestBlock(end, head)
+	log.Info("scanned blocks", "from", next, "to", end, "deposits", len(deposits), "sweeps", len(sweeps))
+	return end.Cmp(head) == 0, nil
+}
+
+// latestBlock returns the block the next round builds on: the latest stored
+// block or, in a dry run that already processed blocks, the last of them.
+func (ws *Web3Scanner) latestBlock() (*database.Blocks, error) {
+	if ws.dryRunLatest != nil {
+		return ws.dryRunLatest, nil
+	}
+	return ws.db.Blocks.LatestBlock()
+}
+
+// dryRunReorg handles a reorg detected in a dry run, where latest no longer
+// is part of the chain. If latest was only processed by the dry run, the
+// dry run starts over from the stored cursor. A reorg of stored blocks
+// can't be rolled back without writing, so it is returned as an error.
+func (ws *Web3Scanner) dryRunReorg(latest *database.Blocks) error {
+	if ws.dryRunLatest == nil {
+		return fmt.Errorf("stored block %s was reorged out, which a dry run can't roll back", latest.Number)
+	}
+	log.Warn("chain reorged during dry run, restarting from the stored cursor", "number", latest.Number)
+	ws.dryRunLatest = nil
+	return nil
+}
+
+// blocksStepFor returns the number of blocks to process in the round
+// starting at next: catchUpBlocksStep while more than catchUpThreshold
+// blocks up to head are left, blocksStep otherwise. Each round commits
+// once, so this is also the commit frequency. The mode is chosen afresh
+// every round from the stored cursor, so switching never skips or repeats
+// a block.
+func (ws *Web3Scanner) blocksStepFor(next, head *big.Int) uint64 {
+	left := new(big.Int).Sub(head, next)
+	left.Add(left, big.NewInt(1))
+	catchingUp := ws.catchUpBlocksStep > 0 && left.Cmp(new(big.Int).SetUint64(ws.catchUpThreshold)) > 0
+	if catchingUp != ws.catchingUp {
+		ws.catchingUp = catchingUp
+		if catchingUp {
+			log.Info("entering catch-up mode", "next", next, "head", head, "blocksStep", ws.catchUpBlocksStep)
+		} else {
+			log.Info("leaving catch-up mode", "next", next, "head", head, "blocksStep", ws.blocksStep)
+		}
+	}
+	if catchingUp {
+		return ws.catchUpBlocksStep
+	}
+	return ws.blocksStep
+}
+
+// prefetchBlocks fetches the blocks next..end in batch requests of at most
+// rpcBatchSize blocks when the scanner is catching up, i.e. the range ends
+// before head. It returns nil when batching is disabled, the scanner is
+// near the head, or the node rejects a batch; the caller then fetches the
+// blocks one by one. Batching fetches whole blocks, so it is disabled by
+// txSubBatchSize too.
+func (ws *Web3Scanner) prefetchBlocks(ctx context.Context, next, end, head *big.Int) []*types.Block {
+	if ws.rpcBatchSize == 0 || ws.txSubBatchSize > 0 || end.Cmp(head) >= 0 {
+		return nil
+	}
+	step := new(big.Int).SetUint64(ws.rpcBatchSize)
+	var blocks []*types.Block
+	for from := new(big.Int).Set(next); from.Cmp(end) <= 0; from.Add(from, step) {
+		to := new(big.Int).Add(from, step)
+		to.Sub(to, big.NewInt(1))
+		if to.Cmp(end) > 0 {
+			to.Set(end)
+		}
+		batch, err := ws.client.BatchBlocksByRange(ctx, from, to)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn("batch block fetch fail, falling back to single calls", "from", from, "to", to, "err", err)
+			}
+			return nil
+		}
+		blocks = append(blocks, batch...)
+	}
+	return blocks
+}
+
+// verifyBlock checks that the hash computed from the header of a fetched
+// block matches the hash the node reports for the same number. A mismatch
+// means the endpoint is serving inconsistent or fabricated data, or the
+// block was reorged out between the two calls. The parent link is checked
+// for every block by scanBlocks, where a break is handled as a reorg.
+func (ws *Web3Scanner) verifyBlock(ctx context.Context, block *types.Block) error {
+	reported, err := ws.client.ReportedBlockHash(ctx, block.Number())
+	if err != nil {
+		return fmt.Errorf("fetch reported hash of block %s: %w", block.Number(), err)
+	}
+	if computed := block.Hash(); computed != reported {
+		return fmt.Errorf("block %s hash mismatch: computed %s, node reported %s", block.Number(), computed, reported)
+	}
+	return nil
+}
+
+// rollbackReorg handles a reorg detected because block does not build on
+// latest, the highest stored block.
+//
+// It walks back from latest until the stored block hash matches the hash
+// the node reports at the same height; that block is the fork point. All
+// stored blocks above it are orphaned and are deleted together with their
+// deposits and sweeps, withdrawals mined in them go back to sent, the
+// balance changes of all of them are reverted, and the reorg is recorded,
+// all in a single transaction.
+// The next scan round resumes from the block after the fork point.
+func (ws *Web3Scanner) rollbackReorg(ctx context.Context, latest *database.Blocks, block *types.Block) error {
+	orphaned := latest
+	for {
+		parentNumber := new(big.Int).Sub(orphaned.Number, big.NewInt(1))
+		parent, err := ws.db.Blocks.QueryBlockByNumber(parentNumber)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Every stored block was orphaned.
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("query stored block %s: %w", parentNumber, err)
+		}
+		canonical, err := ws.client.ReportedBlockHash(ctx, parentNumber)
+		if err != nil {
+			return fmt.Errorf("fetch canonical hash of block %s: %w", parentNumber, err)
+		}
+		if canonical == parent.BlockHash {
+			break
+		}
+		orphaned = parent
+	}
+
+	newHash, err := ws.client.ReportedBlockHash(ctx, orphaned.Number)
+	if err != nil {
+		return fmt.Errorf("fetch canonical hash of block %s: %w", orphaned.Number, err)
+	}
+	reorg := database.Reorgs{
+		OldHash:   orphaned.BlockHash,
+		NewHash:   newHash,
+		Depth:     new(big.Int).Sub(latest.Number, orphaned.Number).Uint64() + 1,
+		FromBlock: orphaned.Number,
+		ToBlock:   latest.Number,
+		Timestamp: time.Now().Unix(),
+	}
+	log.Warn("chain reorg detected, rolling back", "from", reorg.FromBlock, "to", reorg.ToBlock, "depth", reorg.Depth, "newBlock", block.Number())
+	if reorg.Depth > ws.depositConfirmations {
+		log.Error("reorg deeper than deposit confirmations, confirmed deposits may be rolled back", "depth", reorg.Depth, "depositConfirmations", ws.depositConfirmations)
+	}
+	if reorg.Depth > ws.withdrawalConfirmations {
+		log.Error("reorg deeper than withdrawal confirmations, confirmed withdrawals may be rolled back", "depth", reorg.Depth, "withdrawalConfirmations", ws.withdrawalConfirmations)
+	}
+
+	return ws.db.Transaction(func(tx *database.DB) error {
+		if _, err := removeBlocks(tx, orphaned.Number, latest.Number); err != nil {
+			return err
+		}
+		return tx.Reorgs.StoreReorg(reorg)
+	})
+}
+
+// processBlock matches the block's transactions and the ERC20 transfers in
+// their receipts against tracked addresses, see matchTransactions.
+//
+// With txHashes nil the transactions are those of block, and all their
+// receipts are fetched with a single call. Otherwise block carries only the
+// header, and the transactions with the given hashes are fetched together
+// with their receipts in batches of at most txSubBatchSize, each matched
+// before the next is fetched, so a huge block is never held in memory at
+// once.
+//
+// The receipts and transactions calls are observed as the fetch phase and
+// the rest as the decode phase of the scan phase metrics. Returned sweeps
+// are not yet linked to their deposit.
+func (ws *Web3Scanner) processBlock(ctx context.Context, block *types.Block, txHashes []common.Hash) (*blockMatches, error) {
+	m := &blockMatches{block: block, knownTokens: make(map[common.Address]bool)}
+	if txHashes != nil {
+		for start := 0; start < len(txHashes); start += int(ws.txSubBatchSize) {
+			end := min(start+int(ws.txSubBatchSize), len(txHashes))
+			fetchStart := time.Now()
+			txs, receipts, err := ws.client.BatchTransactionsAndReceipts(ctx, txHashes[start:end])
+			ws.metrics.ObserveScanPhase(metrics.PhaseFetch, time.Since(fetchStart))
+			if err != nil {
+				return nil, fmt.Errorf("fetch transactions %d-%d: %w", start, end-1, err)
+			}
+			for _, receipt := range receipts {
+				// The transaction may have been reorged into another block
+				// since the header was fetched.
+				if receipt.BlockHash != block.Hash() {
+					return nil, fmt.Errorf("receipt of transaction %s is from block %s, not %s", receipt.TxHash, receipt.BlockHash, block.Hash())
+				}
+			}
+			if err := ws.matchTransactions(ctx, m, start, txs, receipts); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+
+	txs := block.Transactions()
+	if len(txs) == 0 {
+		return m, nil
+	}
+	fetchStart := time.Now()
+	receipts, err := ws.client.BlockReceiptsByHash(ctx, block.Hash())
+	ws.metrics.ObserveScanPhase(metrics.PhaseFetch, time.Since(fetchStart))
+	if err != nil {
+		return nil, fmt.Errorf("fetch receipts: %w", err)
+	}
+	if err := ws.matchTransactions(ctx, m, 0, txs, receipts); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// matchTransactions matches txs, the transactions of m.block from index
+// offset on, and the ERC20 transfers in their receipts against tracked
+// addresses. All senders, recipients and transfer parties are looked up
+// with a single batch query.
+//
+// Every transaction that touches a tracked address is passed to the
+// registered hooks. Successful native transfers and ERC20 transfers of
+// tokens in the tokens table to a tracked address are classified by
+// classifyTransfer. Zero-value transfers, such as plain contract calls or
+// spam Transfer events, carry no funds and are skipped unless skipZeroValue
+// is unset. The hashes of transactions sent from tracked addresses are
+// collected by outcome, so queued withdrawals can be confirmed.
+//
+// Malformed Transfer logs and receipts of another transaction than the one
+// at their position are handled by decodeAnomaly: logged and skipped, or
+// returned in strict decode mode.
+func (ws *Web3Scanner) matchTransactions(ctx context.Context, m *blockMatches, offset int, txs []*types.Transaction, receipts []*types.Receipt) error {
+	decodeStart := time.Now()
+	defer func() { ws.metrics.ObserveScanPhase(metrics.PhaseDecode, time.Since(decodeStart)) }()
+	if len(receipts) != len(txs) {
+		return fmt.Errorf("got %d receipts for %d transactions", len(receipts), len(txs))
+	}
+
+	senders := make([]*common.Address, len(txs))
+	transfers := make([][]scanner.Transfer, len(txs))
+	mismatched := make([]bool, len(txs))
+	candidates := make([]common.Address, 0, 2*len(txs))
+	for i, tx := range txs {
+		if receipts[i].TxHash != tx.Hash() {
+			if err := ws.decodeAnomaly(fmt.Errorf("receipt at position %d is for tx %s, not %s", offset+i, receipts[i].TxHash, tx.Hash())); err != nil {
+				return err
+			}
+			mismatched[i] = true
+			continue
+		}
+		if from, err := types.Sender(ws.signer, tx); err == nil {
+			senders[i] = &from
+			candidates = append(candidates, from)
+		} else {
+			log.Debug("recover tx sender fail", "tx", tx.Hash(), "err", err)
+		}
+		if tx.To() != nil {
+			candidates = append(candidates, *tx.To())
+		}
+		var err error
+		transfers[i], err = scanner.DecodeTransfers(receipts[i])
+		if err != nil {
+			if err := ws.decodeAnomaly(err); err != nil {
+				return err
+			}
+		}
+		for _, transfer := range transfers[i] {
+			candidates = append(candidates, transfer.From, transfer.To)
+		}
+	}
+	tracked, err := ws.db.Addresses.BatchAddressExist(candidates)
+	if err != nil {
+		return fmt.Errorf("query tracked addresses: %w", err)
+	}
+	isTracked := func(address *common.Address) bool {
+		if address == nil {
+			return false
+		}
+		_, ok := tracked[*address]
+		return ok
+	}
+
+	m.tracked = tracked
+	for i, tx := range txs {
+		if mismatched[i] {
+			continue
+		}
+		touched := isTracked(senders[i]) || isTracked(tx.To())
+		for _, transfer := range transfers[i] {
+			touched = touched || isTracked(&transfer.From) || isTracked(&transfer.To)
+		}
+		if !touched {
+			continue
+		}
+		ws.metrics.RecordTransactionsMatched(1)
+
+		receipt := receipts[i]
+		if err := ws.runTransactionHooks(ctx, tx, receipt); err != nil {
+			return err
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			if isTracked(senders[i]) {
+				m.failed = append(m.failed, tx.Hash())
+			}
+			continue
+		}
+		if isTracked(senders[i]) {
+			m.succeeded = append(m.succeeded, tx.Hash())
+		}
+
+		if (tx.Value().Sign() > 0 || !ws.skipZeroValue) && isTracked(tx.To()) {
+			ws.classifyTransfer(m, tx.Hash(), uint(offset+i), nil, senders[i], *tx.To(), oracle.NativeToken, tx.Value())
+		}
+		for _, transfer := range transfers[i] {
+			if (transfer.Amount.Sign() == 0 && ws.skipZeroValue) || !isTracked(&transfer.To) {
+				continue
+			}
+			known, err := ws.isKnownToken(m, transfer.Token)
+			if err != nil {
+				return err
+			}
+			if !known {
+				log.Debug("ignoring transfer of unknown token", "token", transfer.Token, "tx", tx.Hash(), "to", transfer.To)
+				continue
+			}
+			ws.classifyTransfer(m, tx.Hash(), transfer.TxIndex, &transfer.LogIndex, &transfer.From, transfer.To, transfer.Token, transfer.Amount)
+		}
+	}
+	return nil
+}
+
+// decodeAnomaly handles err, describing data that couldn't be decoded as
+// expected. In strict decode mode it is returned wrapped in
+// errDecodeAnomaly; otherwise it is logged and nil is returned, so the data
+// is skipped.
+func (ws *Web3Scanner) decodeAnomaly(err error) error {
+	if ws.strictDecode {
+		return fmt.Errorf("%w: %w", errDecodeAnomaly, err)
+	}
+	log.Warn("skipping undecodable data", "err", err)
+	return nil
+}
+
+// blockMatches collects the deposits and sweeps found in one block.
+type blockMatches struct {
+	block   *types.Block
+	tracked map[common.Address]uint8
+	// knownTokens caches tokens table lookups for the block.
+	knownTokens map[common.Address]bool
+
+	deposits []database.Deposits
+	sweeps   []database.Sweeps
+
+	// succeeded and failed are the hashes of the transactions sent from
+	// tracked addresses, by receipt status.
+	succeeded []common.Hash
+	failed    []common.Hash
+}
+
+// classifyTransfer records a transfer of amount of token to the tracked
+// address to. When the sender is tracked too, the pair of address types
+// decides how it is recorded:
+//
+//   - user to hot wallet: a sweep, when sweep detection is enabled.
+//   - hot wallet to cold wallet: a cold collection, always, since it is the
+//     only record debiting the hot wallet for the cold wallet transfers
+//     queued by topUpCold.
+//   - user to user: an internal transfer, when sweep detection is enabled,
+//     and a deposit otherwise.
+//
+// Otherwise a transfer to a user address is a deposit, and transfers to
+// other tracked addresses, such as hot wallet to hot wallet or cold wallet
+// to hot wallet, are not recorded. Neither are self-transfers, which move
+// no funds. With logMatches set every recorded transfer is also logged.
+func (ws *Web3Scanner) classifyTransfer(m *blockMatches, txHash common.Hash, txIndex uint, logIndex *uint, from *common.Address, to, token common.Address, amount *big.Int) {
+	if from != nil && *from == to {
+		// Recording it as a deposit would credit the address with funds it
+		// already held.
+		log.Debug("ignoring self-transfer", "address", to, "token", token, "tx", txHash)
+		return
+	}
+	toType := m.tracked[to]
+	fromType, fromTracked := uint8(0), false
+	if from != nil {
+		fromType, fromTracked = m.tracked[*from]
+	}
+
+	if sweepType, ok := ws.sweepType(fromTracked, fromType, toType); ok {
+		m.sweeps = append(m.sweeps, database.Sweeps{
+			BlockHash:    m.block.Hash(),
+			BlockNumber:  m.block.Number(),
+			TxHash:       txHash,
+			TxIndex:      txIndex,
+			LogIndex:     logIndex,
+			FromAddress:  *from,
+			ToAddress:    to,
+			Type:         sweepType,
+			TokenAddress: token,
+			Amount:       amount,
+			Timestamp:    m.block.Time(),
+		})
+		if ws.logMatches {
+			log.Info("matched sweep", "type", sweepType, "from", *from, "to", to, "token", token, "amount", amount, "tx", txHash, "block", m.block.Number())
+		}
+		return
+	}
+	if toType != database.AddressTypeUser {
+		return
+	}
+	// The GUID is assigned up front so sweeps later in the same batch can
+	// link to the deposit before it is stored.
+	deposit := database.Deposits{
+		GUID:         database.NewGUID(),
+		BlockHash:    m.block.Hash(),
+		BlockNumber:  m.block.Number(),
+		TxHash:       txHash,
+		TxIndex:      txIndex,
+		LogIndex:     logIndex,
+		ToAddress:    to,
+		TokenAddress: token,
+		Amount:       amount,
+		Timestamp:    m.block.Time(),
+	}
+	if from != nil {
+		deposit.FromAddress = *from
+	}
+	m.deposits = append(m.deposits, deposit)
+	if ws.logMatches {
+		log.Info("matched deposit", "to", to, "from", deposit.FromAddress, "token", token, "amount", amount, "tx", txHash, "block", m.block.Number())
+	}
+}
+
+// sweepType returns the type of sweep a transfer from a tracked address of
+// fromType to one of toType is recorded as, and false if it is not a sweep.
+// See classifyTransfer for the rules.
+func (ws *Web3Scanner) sweepType(fromTracked bool, fromType, toType uint8) (uint8, bool) {
+	if !fromTracked {
+		return 0, false
+	}
+	switch {
+	case fromType == database.AddressTypeUser && toType == database.AddressTypeHot:
+		return database.SweepTypeSweep, ws.detectSweeps
+	case fromType == database.AddressTypeHot && toType == database.AddressTypeCold:
+		return database.SweepTypeColdCollection, true
+	case fromType == database.AddressTypeUser && toType == database.AddressTypeUser:
+		return database.SweepTypeInternal, ws.detectSweeps
+	}
+	return 0, false
+}
+
+// isKnownToken reports whether token is in the tokens table. Only known
+// tokens are recorded, so spam tokens sent to user addresses are ignored.
+// token is the address that emitted the Transfer log, so proxy tokens must
+// be listed under their proxy address.
+func (ws *Web3Scanner) isKnownToken(m *blockMatches, token common.Address) (bool, error) {
+	if known, ok := m.knownTokens[token]; ok {
+		return known, nil
+	}
+	row, err := ws.db.Tokens.QueryToken(token)
+	if err != nil {
+		return false, fmt.Errorf("query token %s: %w", token, err)
 	}
+	m.knownTokens[token] = row != nil
+	return row != nil, nil
+}
 
-	addrList, err := ws.db.Addresses.GetAllAddresses()
+// linkSweep sets sweep.DepositGUID to the most recent deposit into the swept
+// address, looking first at the pending, not yet stored deposits of the
+// current batch and then at the database. Only sweeps of SweepTypeSweep are
+// linked; the other types don't move deposited funds out of a user address
+// into a hot wallet.
+func (ws *Web3Scanner) linkSweep(sweep *database.Sweeps, pending []database.Deposits) error {
+	if sweep.Type != database.SweepTypeSweep {
+		return nil
+	}
+	for i := len(pending) - 1; i >= 0; i-- {
+		if pending[i].ToAddress == sweep.FromAddress {
+			sweep.DepositGUID = &pending[i].GUID
+			return nil
+		}
+	}
+	deposit, err := ws.db.Deposits.QueryLatestDepositByToAddress(sweep.FromAddress)
 	if err != nil {
 		return err
 	}
-	for _, item := range addrList {
-		fmt.Println("=======print address list==========")
-		fmt.Println(item.Address)
-		fmt.Println(item.Timestamp)
-		fmt.Println(item.AddressType)
-		fmt.Println("=======print address list==========")
+	if deposit != nil {
+		sweep.DepositGUID = &deposit.GUID
 	}
 	return nil
 }
 
-// Stop stops the Web3Scanner.
+// Stop stops the Web3Scanner gracefully.
 //
-// It prints a message to the console. It's currently a no-op, but it's a
-// placeholder for future code that will do something more interesting.
-func (ws *Web3Scanner) Stop(_ context.Context) error {
-	fmt.Println("DbOp stop .........")
+// It cancels the scanning loop through shutdown and waits, bounded by ctx,
+// for the loop and a collection run in progress to exit. A loop interrupted
+// mid-range stores the blocks it already processed before exiting. Stop
+// then shuts down the metrics server and closes the RPC client and the
+// database.
+func (ws *Web3Scanner) Stop(ctx context.Context) error {
+	ws.shutdown(nil)
+	var result error
+	if ws.done != nil {
+		select {
+		case <-ws.done:
+		case <-ctx.Done():
+			result = fmt.Errorf("waiting for scan loop to exit: %w", context.Cause(ctx))
+		}
+	}
+	if result == nil {
+		// The loop has exited, so no new background run can start.
+		finished := make(chan struct{})
+		go func() {
+			ws.backgroundRuns.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			result = fmt.Errorf("waiting for background runs to finish: %w", context.Cause(ctx))
+		}
+	}
+	ws.stopped.Store(true)
+
+	if ws.metricsServer != nil {
+		if err := ws.metricsServer.Stop(ctx); err != nil {
+			result = errors.Join(result, fmt.Errorf("stop metrics server: %w", err))
+		}
+	}
+	ws.client.Close()
+	if result != nil {
+		// The loop may still be writing; leave the database open.
+		return result
+	}
+	if err := ws.db.Close(); err != nil {
+		return fmt.Errorf("close database: %w", err)
+	}
+	log.Info("web3scanner stopped")
 	return nil
 }
 
@@ -0,0 +1,253 @@
+package web3scanner
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/config"
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/database/dbtest"
+)
+
+func TestNewWeb3ScannerRequiresSweepsForCollection(t *testing.T) {
+	cfg := &config.Config{
+		RpcUrl:             "http://127.0.0.1:8545",
+		BlocksStep:         10,
+		PollInterval:       time.Second,
+		CollectionInterval: time.Minute,
+	}
+	_, err := NewWeb3Scanner(context.Background(), cfg, func(error) {})
+	if err == nil || !strings.Contains(err.Error(), "sweep detection") {
+		t.Fatalf("NewWeb3Scanner error = %v, want sweep detection error", err)
+	}
+}
+
+func TestProcessBlockRecordsSentTransactions(t *testing.T) {
+	hot := newTestAccount(t)
+	stranger := newTestAccount(t)
+	external := common.HexToAddress("0x3000000000000000000000000000000000000003")
+
+	client := newFakeClient()
+	succeeded := hot.transfer(t, external, 1)
+	failed := hot.transfer(t, external, 2)
+	untracked := stranger.transfer(t, external, 3)
+	block := client.addBlockWithReceipts(
+		[]*types.Transaction{succeeded, failed, untracked},
+		[]*types.Receipt{
+			{TxHash: succeeded.Hash(), Status: types.ReceiptStatusSuccessful},
+			{TxHash: failed.Hash(), Status: types.ReceiptStatusFailed},
+			{TxHash: untracked.Hash(), Status: types.ReceiptStatusSuccessful},
+		})
+	db := &database.DB{Addresses: &fakeAddresses{rows: []database.Addresses{hot.row(database.AddressTypeHot)}}}
+
+	m, err := newTestScanner(db, client).processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	if !slices.Equal(m.succeeded, []common.Hash{succeeded.Hash()}) {
+		t.Errorf("succeeded = %v, want [%s]", m.succeeded, succeeded.Hash())
+	}
+	if !slices.Equal(m.failed, []common.Hash{failed.Hash()}) {
+		t.Errorf("failed = %v, want [%s]", m.failed, failed.Hash())
+	}
+}
+
+func TestScanBlocksConfirmsWithdrawalsAndDebitsHotWallet(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	hot := newTestAccount(t)
+	external := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	if err := db.Addresses.StoreAddresses([]database.Addresses{hot.row(database.AddressTypeHot)}); err != nil {
+		t.Fatalf("store addresses: %v", err)
+	}
+	if err := db.Balances.UpdateBalance(hot.address, common.Address{}, big.NewInt(10)); err != nil {
+		t.Fatalf("seed hot wallet balance: %v", err)
+	}
+	hotBalance := func() *big.Int {
+		t.Helper()
+		balance, err := db.Balances.QueryBalance(hot.address, common.Address{})
+		if err != nil || balance == nil {
+			t.Fatalf("query hot wallet balance: %v, %v", balance, err)
+		}
+		return balance.Balance
+	}
+
+	client := newFakeClient()
+	succeeded := hot.transfer(t, external, 1)
+	failed := hot.transfer(t, external, 2)
+	client.addBlockWithReceipts(
+		[]*types.Transaction{succeeded, failed},
+		[]*types.Receipt{
+			{TxHash: succeeded.Hash(), Status: types.ReceiptStatusSuccessful},
+			{TxHash: failed.Hash(), Status: types.ReceiptStatusFailed},
+		})
+	withdrawals := []database.Withdrawals{
+		{FromAddress: hot.address, ToAddress: external, Amount: big.NewInt(1), TxHash: succeeded.Hash(), Status: database.WithdrawalStatusSent},
+		{FromAddress: hot.address, ToAddress: external, Amount: big.NewInt(2), TxHash: failed.Hash(), Status: database.WithdrawalStatusSent},
+	}
+	if err := db.Withdrawals.StoreWithdrawals(withdrawals); err != nil {
+		t.Fatalf("store withdrawals: %v", err)
+	}
+
+	ws := newTestScanner(db, client)
+	if _, err := ws.scanBlocks(context.Background()); err != nil {
+		t.Fatalf("scanBlocks: %v", err)
+	}
+	pending, err := db.Withdrawals.QueryPendingWithdrawals(database.WithdrawalTypeWithdrawal)
+	if err != nil {
+		t.Fatalf("query pending withdrawals: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("%d withdrawals still pending after their transactions were mined", len(pending))
+	}
+	// Only the successful withdrawal moved funds.
+	if got := hotBalance(); got.Cmp(big.NewInt(9)) != 0 {
+		t.Errorf("hot wallet balance after withdrawals = %s, want 9", got)
+	}
+
+	// Reorg block 1 out: both withdrawals go back to sent.
+	client.reorg(1)
+	client.addBlock()
+	client.addBlock()
+	for range 2 {
+		if _, err := ws.scanBlocks(context.Background()); err != nil {
+			t.Fatalf("scanBlocks after reorg: %v", err)
+		}
+	}
+	pending, err = db.Withdrawals.QueryPendingWithdrawals(database.WithdrawalTypeWithdrawal)
+	if err != nil {
+		t.Fatalf("query pending withdrawals: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("%d withdrawals pending after reorg, want 2", len(pending))
+	}
+	for _, w := range pending {
+		if w.Status != database.WithdrawalStatusSent || w.BlockNumber != nil {
+			t.Errorf("withdrawal %s status %d block %v after reorg, want sent and no block", w.TxHash, w.Status, w.BlockNumber)
+		}
+	}
+	if got := hotBalance(); got.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("hot wallet balance after reorg = %s, want 10", got)
+	}
+}
+
+func TestScanBlocksAppliesSeparateConfirmationThresholds(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	hot, user, payer := newTestAccount(t), newTestAccount(t), newTestAccount(t)
+	external := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	err := db.Addresses.StoreAddresses([]database.Addresses{hot.row(database.AddressTypeHot), user.row(database.AddressTypeUser)})
+	if err != nil {
+		t.Fatalf("store addresses: %v", err)
+	}
+	if err := db.Balances.UpdateBalance(hot.address, common.Address{}, big.NewInt(10)); err != nil {
+		t.Fatalf("seed hot wallet balance: %v", err)
+	}
+
+	// A deposit and a withdrawal mined in the same block.
+	client := newFakeClient()
+	withdrawal := hot.transfer(t, external, 1)
+	client.addBlock(withdrawal, payer.transfer(t, user.address, 5))
+	client.addBlock()
+	err = db.Withdrawals.StoreWithdrawals([]database.Withdrawals{
+		{FromAddress: hot.address, ToAddress: external, Amount: big.NewInt(1), TxHash: withdrawal.Hash(), Status: database.WithdrawalStatusSent},
+	})
+	if err != nil {
+		t.Fatalf("store withdrawals: %v", err)
+	}
+	ws := newTestScanner(db, client)
+	ws.depositConfirmations = 2
+	ws.withdrawalConfirmations = 1
+
+	depositStatus := func() uint8 {
+		t.Helper()
+		deposits, err := db.Deposits.QueryDepositsByBlockRange(big.NewInt(1), big.NewInt(1))
+		if err != nil || len(deposits) != 1 {
+			t.Fatalf("query deposits: %v, %v", deposits, err)
+		}
+		return deposits[0].Status
+	}
+
+	// Head 2: block 1 has 1 block on top, enough for the withdrawal only.
+	scanToHead(t, ws)
+	if status := depositStatus(); status != database.DepositStatusPending {
+		t.Errorf("at head 2: deposit status %d, want pending", status)
+	}
+	pending, err := db.Withdrawals.QueryPendingWithdrawals(database.WithdrawalTypeWithdrawal)
+	if err != nil {
+		t.Fatalf("query pending withdrawals: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("at head 2: withdrawal %s still pending with status %d, want confirmed", pending[0].TxHash, pending[0].Status)
+	}
+
+	// Head 3: 2 blocks on top of block 1 confirm the deposit too.
+	client.addBlock()
+	scanToHead(t, ws)
+	if status := depositStatus(); status != database.DepositStatusConfirmed {
+		t.Errorf("at head 3: deposit status %d, want confirmed", status)
+	}
+}
+
+func TestScanBlocksKeepsWithdrawalMinedBelowThreshold(t *testing.T) {
+	db, _ := dbtest.NewDB(t)
+	hot := newTestAccount(t)
+	external := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	if err := db.Addresses.StoreAddresses([]database.Addresses{hot.row(database.AddressTypeHot)}); err != nil {
+		t.Fatalf("store addresses: %v", err)
+	}
+	client := newFakeClient()
+	withdrawal := hot.transfer(t, external, 1)
+	client.addBlock(withdrawal)
+	err := db.Withdrawals.StoreWithdrawals([]database.Withdrawals{
+		{FromAddress: hot.address, ToAddress: external, Amount: big.NewInt(1), TxHash: withdrawal.Hash(), Status: database.WithdrawalStatusSent},
+	})
+	if err != nil {
+		t.Fatalf("store withdrawals: %v", err)
+	}
+	ws := newTestScanner(db, client)
+	ws.withdrawalConfirmations = 1
+
+	scanToHead(t, ws)
+	pending, err := db.Withdrawals.QueryPendingWithdrawals(database.WithdrawalTypeWithdrawal)
+	if err != nil {
+		t.Fatalf("query pending withdrawals: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Status != database.WithdrawalStatusMined || pending[0].BlockNumber.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("pending withdrawals = %+v, want the withdrawal mined in block 1", pending)
+	}
+}
+
+// shortBatchClient drops the last block of every batch.
+type shortBatchClient struct {
+	*fakeClient
+}
+
+func (c shortBatchClient) BatchBlocksByRange(ctx context.Context, from, to *big.Int) ([]*types.Block, error) {
+	blocks, err := c.fakeClient.BatchBlocksByRange(ctx, from, to)
+	if err != nil || len(blocks) == 0 {
+		return blocks, err
+	}
+	return blocks[:len(blocks)-1], nil
+}
+
+func TestScanBlocksRejectsShortBatch(t *testing.T) {
+	client := newFakeClient()
+	for range 5 {
+		client.addBlock()
+	}
+	db := &database.DB{Blocks: &fakeBlocks{}}
+	ws := newTestScanner(db, shortBatchClient{client})
+	ws.blocksStep = 3
+	ws.rpcBatchSize = 3
+
+	_, err := ws.scanBlocks(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "returned 2 blocks, want 3") {
+		t.Fatalf("scanBlocks error = %v, want short batch error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package web3scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/web3scanner/database"
+	"github.com/qiaopengjun5162/web3scanner/oracle"
+)
+
+func TestProcessBlockZeroValueTransfers(t *testing.T) {
+	user, payer := newTestAccount(t), newTestAccount(t)
+	token := common.HexToAddress("0x1000000000000000000000000000000000000001")
+
+	client := newFakeClient()
+	// A zero-value contract call to the user address, and a zero-value
+	// Transfer event of a listed token.
+	call := payer.transfer(t, user.address, 0)
+	spam := payer.transfer(t, token, 0)
+	block := client.addBlockWithReceipts([]*types.Transaction{call, spam}, []*types.Receipt{
+		{TxHash: call.Hash(), Status: types.ReceiptStatusSuccessful},
+		transferReceipt(spam, token, payer.address, user.address, 0),
+	})
+	db := &database.DB{
+		Addresses: &fakeAddresses{rows: []database.Addresses{user.row(database.AddressTypeUser)}},
+		Tokens:    &fakeTokens{rows: []database.Tokens{{TokenAddress: token, Symbol: "TKN", Decimals: 18}}},
+	}
+	ws := newTestScanner(db, client)
+
+	m, err := ws.processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	if len(m.deposits) != 0 {
+		t.Errorf("zero-value transfers produced deposits %+v, want none", m.deposits)
+	}
+
+	ws.skipZeroValue = false
+	m, err = ws.processBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("processBlock: %v", err)
+	}
+	tokens := make(map[common.Address]common.Hash)
+	for _, d := range m.deposits {
+		if d.Amount.Sign() != 0 {
+			t.Errorf("deposit %+v has a non-zero amount", d)
+		}
+		tokens[d.TokenAddress] = d.TxHash
+	}
+	if len(m.deposits) != 2 || tokens[oracle.NativeToken] != call.Hash() || tokens[token] != spam.Hash() {
+		t.Errorf("with zero-value transfers kept got deposits %+v, want the native call and the token transfer", m.deposits)
+	}
+}